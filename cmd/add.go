@@ -39,6 +39,8 @@ var cmdDisk int
 var cmdOvr int
 var cmdPri int
 var cmdRet int
+var cmdRetryPolicy string
+var cmdEscalation string
 var cmdFile string
 var cmdCwdMatters bool
 var cmdChangeHome bool
@@ -50,6 +52,10 @@ var cmdOnFailure string
 var cmdOnSuccess string
 var cmdOnExit string
 var cmdMounts string
+var cmdContainer string
+var cmdInterpreter string
+var cmdChildren bool
+var cmdWait string
 var cmdEnv string
 var cmdReRun bool
 var cmdOsPrefix string
@@ -69,9 +75,10 @@ specific options using a JSON object in (tab separated) column 2, or
 alternatively have only a JSON object in column 1 that also specifies the
 command as one of the name:value pairs. The possible options are:
 
-cmd cwd cwd_matters change_home on_failure on_success on_exit mounts req_grp
-memory time override cpus disk priority retries rep_grp dep_grps deps cmd_deps
-cloud_os cloud_username cloud_ram cloud_script env
+cmd script interpreter cwd cwd_matters change_home on_failure on_success on_exit
+mounts container req_grp memory time override cpus disk priority retries
+retry_policy escalation rep_grp dep_grps deps cmd_deps cloud_os cloud_username
+cloud_ram cloud_script env
 
 If any of these will be the same for all your commands, you can instead specify
 them as flags (which are treated as defaults in the case that they are
@@ -87,6 +94,21 @@ enclosed in square brackets. For example (on one line): {"cmd":"myexe -f input >
 output","cwd":"/path/to/cwd","priority":1,"dep_grps":["dg2","dg3"],"deps":
 ["dg1"]}
 
+"script" lets you supply a multi-line script to run instead of a single command
+line, so that long scripts don't have to be escaped into one line. In JSON you
+write new lines as \n, eg. {"script":"#!/bin/bash\nset -e\nstep1\nstep2"}. The
+script is stored compressed by the manager, and different scripts make for
+different commands even when "cmd" is the same. If you don't also specify "cmd",
+it defaults to the first line of the script that isn't blank or a comment, and
+is used to describe the script when reporting on it.
+
+"interpreter" (or the --interpreter option) is the program that runs your cmd
+or script, eg. "bash", "sh", "python" or "Rscript". If set, your cmd or script
+is written to a file in $TMPDIR (or the system temp directory if cwd_matters is
+true), and the interpreter is run with the path to that file as its only
+argument. If not set, scripts are run by the same shell that normally runs your
+cmd.
+
 "cwd" determines the directory to cd to before running the command (the 'command
 working directory'). If none is specified, the default will be your current
 directory right now. (If adding to a remote cloud-deployed manager, then cwd
@@ -142,6 +164,19 @@ your mounted directories and any mount cache directories, so that nothing on
 your remote file systems gets deleted. Unmounting will get rid of them though,
 so you would still end up with a "cleaned" workspace.
 
+"container" (or the --container option) lets you run your command inside a
+Docker or Singularity container. The value is a JSON object with an "Image"
+name:value pair, and optionally "Runtime" ("singularity", the default, or
+"docker", or the path to one of those executables) and "Binds" (an array of
+extra host paths to make available inside the container, like
+"/host/path[:/container/path[:opts]]"). For example (on one line):
+{"Image":"docker://ubuntu:16.04","Binds":["/refs:/refs:ro"]}
+Your command is run inside the container using the same shell as usual, with
+the actual working directory, $TMPDIR and any mount points bound in to the
+container at the same paths. The container runtime must be installed on the
+machines your command will run on. Memory usage is measured for all the
+processes running in the container.
+
 "req_grp" is an arbitrary string that identifies the kind of commands you are
 adding, such that future commands you add with this same requirements group are
 likely to have similar memory and time requirements. It defaults to the basename
@@ -154,9 +189,11 @@ specify a unit, eg "100M" for 100 megabytes, or "1G" for 1 gigabyte. "time"
 values should do the same, eg. "30m" for 30 minutes, or "1h" for 1 hour.
 
 The manager learns how much memory and time commands in the same req_grp
-actually used in the past, and will use its own values unless you set an
-override. For this learning to work well, you should have reason to believe that
-all the commands you add with the same req_grp will have similar memory and time
+actually used in the past (and also how much disk, for commands that don't have
+cwd_matters, and how many cpus), and will use its own values unless you set an
+override. You can see and adjust what it has learned with "wr reqgroup". For
+this learning to work well, you should have reason to believe that all the
+commands you add with the same req_grp will have similar memory and time
 requirements, and you should pick the name in a consistent way such that you'll
 use it again in the future.
 
@@ -171,13 +208,13 @@ sizes in a certain range, and then provide a req_grp that describes this, eg.
 only learning about how good your estimates are! The name of your executable
 should almost always be part of the req_grp name.)
 
-"override" defines if your memory and time should be used instead of the
-manager's estimate. Possible values are:
-0 = do not override wr's learned values for memory and time (if any)
+"override" defines if your memory, time, cpus and disk should be used instead
+of the manager's estimate. Possible values are:
+0 = do not override wr's learned values for these (if any)
 1 = override if yours are higher
 2 = always override
 
-"cpus" tells wr manager exactly how many CPU cores your command needs.
+"cpus" tells wr manager how many CPU cores your command needs.
 
 "disk" tells wr manager how much free disk space (in GB) your command needs. If
 you know that where your command will store its outputs to will not run out of
@@ -199,6 +236,34 @@ more memory/time reserved). Once this number of retries is reached, the command
 will be 'buried' until you take manual action to fix the problem and press the
 retry button in the web interface.
 
+"retry_policy" (or the --retry_policy option) lets you control which failures
+get retried and how quickly. It is a JSON object with these optional names:
+"delay" (eg. "30s") is how long to wait before the first retry; "backoff" (eg.
+2) multiplies that delay for each subsequent retry; "max_delay" (eg. "1h") caps
+the delay; "exit_codes" (eg. [1,75]) are the only non-0 exit codes that will be
+retried; "fail_reasons" (eg. ["lost contact with runner"]) are the only other
+kinds of failure that will be retried, using the exact reasons that 'wr status'
+reports, and can include "mounting of remote file system(s) failed" to retry
+failed mounts, which would otherwise be buried straight away;
+"success_exit_codes" (eg. [3]) are exit codes besides 0 that mean your command
+worked. Failures not allowed by exit_codes or fail_reasons (when either is set)
+are buried immediately, regardless of "retries".
+
+"escalation" (or the --escalation option) controls how much more memory, time
+or disk a command is given when it is retried after using too much of them, and
+the most it can be given. It is a JSON object with these optional names:
+"ram_mult" (eg. 1.5) multiplies the peak memory used [default 2, or 1.3 over
+8G]; "ram_min" (eg. "2G") is the minimum increase [default 1G]; "ram_max" (eg.
+"64G") is the ceiling; "time_mult", "time_min" (eg. "30m") and "time_max" (eg.
+"72h") are the same for time [default increase 1h]; "disk_mult", "disk_min"
+and "disk_max" are the same for disk, in GB, multiplying the greater of the
+requested and peak used disk [default increase 100%, min 1]. A command is
+considered to have run out of disk if it fails with "No space left on device"
+in its STDERR. If a command runs out of something while it already has the
+ceiling amount, it is buried (with a reason saying so) instead of being
+retried. ("wr reqgroup pin --escalation" can set a default escalation for
+commands subsequently added to a req_grp.)
+
 "rep_grp" is an arbitrary group you can give your commands so you can query
 their status later. This is only used for reporting and presentation purposes
 when viewing status.
@@ -233,12 +298,46 @@ base variables as they were at the moment in time you run 'wr add', so to set a
 certain environment variable for all commands, you could instead just set it
 prior to calling 'wr add'. In the remote case the command will use base
 variables as they were on the machine where the command is executed when that
-machine was started.`,
+machine was started.
+
+Regardless of "env", your commands will also see these environment variables
+that tell them about themselves: WR_JOB_KEY (the command's unique key),
+WR_REPGROUP (its rep_grp), WR_ATTEMPT (1 for the first attempt at running it,
+2 for the first retry and so on), WR_CORES and WR_RAM_MB (the cpus and memory
+in MB that it was given) and WR_MANAGER (the address of the manager). Your
+commands could, for example, use WR_CORES to size their thread pools.
+
+A command that is being run by wr can itself run 'wr add --children' to add new
+commands as its children; this lets your workflow decide what to do next based
+on what it finds at run time. Children use the rep_grp, cwd, mounts and
+environment variables of their parent unless you specify them, and 'wr status'
+will show them as the parent's children. By default the parent carries on and
+can complete before its children do, but you can use --wait to change that:
+"running" makes 'wr add' itself wait until the children have completed (so the
+parent command stays running), exiting non-0 if any of them get buried;
+"waiting" has 'wr add' return immediately, but makes anything that depends on
+the parent's dep_grps also wait for the children to complete.`,
 	Run: func(combraCmd *cobra.Command, args []string) {
 		// check the command line options
 		if cmdFile == "" {
 			die("--file is required")
 		}
+		var parentKey, parentToken string
+		if cmdChildren {
+			parentKey = os.Getenv("WR_JOB_KEY")
+			parentToken = os.Getenv("WR_JOB_TOKEN")
+			if parentKey == "" || parentToken == "" {
+				die("--children can only be used by commands that are being run by wr")
+			}
+			if !combraCmd.Flags().Changed("report_grp") {
+				cmdRepGroup = ""
+			}
+		} else if cmdWait != "" {
+			die("--wait can only be used with --children")
+		}
+		if cmdWait != "" && cmdWait != "running" && cmdWait != "waiting" {
+			die("--wait must be running or waiting")
+		}
 
 		jd := &jobqueue.JobDefaults{
 			RepGrp:      cmdRepGroup,
@@ -257,7 +356,7 @@ machine was started.`,
 			CloudOSRam:  cmdOsRAM,
 		}
 
-		if jd.RepGrp == "" {
+		if jd.RepGrp == "" && !cmdChildren {
 			jd.RepGrp = "manually_added"
 		}
 		var err error
@@ -323,6 +422,44 @@ machine was started.`,
 			jd.MountConfigs = mountParse(mountJSON, mountSimple)
 		}
 
+		jd.Interpreter = cmdInterpreter
+
+		if cmdRetryPolicy != "" {
+			rpvj := &jobqueue.RetryPolicyViaJSON{}
+			err = json.Unmarshal([]byte(cmdRetryPolicy), rpvj)
+			if err != nil {
+				die("bad --retry_policy: %s", err)
+			}
+			jd.RetryPolicy, err = rpvj.RetryPolicy()
+			if err != nil {
+				die("bad --retry_policy: %s", err)
+			}
+		}
+
+		if cmdEscalation != "" {
+			evj := &jobqueue.EscalationViaJSON{}
+			err = json.Unmarshal([]byte(cmdEscalation), evj)
+			if err != nil {
+				die("bad --escalation: %s", err)
+			}
+			jd.Escalation, err = evj.Escalation()
+			if err != nil {
+				die("bad --escalation: %s", err)
+			}
+		}
+
+		if cmdContainer != "" {
+			cc := &jobqueue.ContainerConfig{}
+			err = json.Unmarshal([]byte(cmdContainer), cc)
+			if err != nil {
+				die("bad --container: %s", err)
+			}
+			if cc.Image == "" {
+				die("bad --container: no Image specified")
+			}
+			jd.Container = cc
+		}
+
 		// open file or set up to read from STDIN
 		var reader io.Reader
 		if cmdFile == "-" {
@@ -354,7 +491,9 @@ machine was started.`,
 			if err != nil {
 				die("%s", err)
 			}
-			envVars = os.Environ()
+			if !cmdChildren {
+				envVars = os.Environ()
+			}
 		} else {
 			pwd = "/tmp"
 			remoteWarning = true
@@ -398,7 +537,8 @@ machine was started.`,
 				die("line %d had a problem with the JSON: %s", lineNum, jsonErr)
 			}
 
-			if jvj.Cwd == "" && jd.Cwd == "" {
+			inheritCwd := cmdChildren && jvj.Cwd == "" && jd.Cwd == ""
+			if jvj.Cwd == "" && jd.Cwd == "" && !cmdChildren {
 				if remoteWarning {
 					warn("command working directories defaulting to /tmp since the manager is running remotely")
 				}
@@ -413,6 +553,10 @@ machine was started.`,
 			if err != nil {
 				die("line %d had a problem: %s\n", lineNum, err)
 			}
+			if inheritCwd {
+				// the manager will use the parent's cwd
+				job.Cwd = ""
+			}
 
 			jobs = append(jobs, job)
 		}
@@ -425,12 +569,22 @@ machine was started.`,
 		defer jq.Disconnect()
 
 		// add the jobs to the queue
-		inserts, dups, err := jq.Add(jobs, envVars, !cmdReRun)
+		var inserts, dups int
+		if cmdChildren {
+			inserts, dups, err = jq.AddChildren(parentKey, parentToken, jobs, envVars, cmdWait == "waiting", !cmdReRun)
+		} else {
+			inserts, dups, err = jq.Add(jobs, envVars, !cmdReRun)
+		}
 		if err != nil {
 			die("%s", err)
 		}
 
-		if defaultedRepG {
+		if cmdChildren {
+			info("Added %d new child commands (%d were duplicates) to the queue", inserts, dups)
+			if cmdWait == "running" {
+				waitForChildren(jq, parentKey)
+			}
+		} else if defaultedRepG {
 			info("Added %d new commands (%d were duplicates) to the queue using default identifier '%s'", inserts, dups, cmdRepGroup)
 		} else {
 			info("Added %d new commands (%d were duplicates) to the queue", inserts, dups)
@@ -438,6 +592,32 @@ machine was started.`,
 	},
 }
 
+// waitForChildren waits until all the children of the given parent job are
+// complete, dying if any of them get buried.
+func waitForChildren(jq *jobqueue.Client, parentKey string) {
+	ticker := time.NewTicker(1 * time.Second)
+	defer ticker.Stop()
+	for range ticker.C {
+		children, err := jq.GetChildren(parentKey, false, false)
+		if err != nil {
+			die("failed to get the status of the child commands: %s", err)
+		}
+		complete := 0
+		for _, child := range children {
+			switch child.State {
+			case jobqueue.JobStateComplete:
+				complete++
+			case jobqueue.JobStateBuried:
+				die("child command [%s] was buried", child.Cmd)
+			}
+		}
+		if complete == len(children) {
+			info("All %d child commands completed", complete)
+			return
+		}
+	}
+}
+
 func init() {
 	RootCmd.AddCommand(addCmd)
 
@@ -456,6 +636,8 @@ func init() {
 	addCmd.Flags().IntVarP(&cmdOvr, "override", "o", 0, "[0|1|2] should your mem/time estimates override? (default 0)")
 	addCmd.Flags().IntVarP(&cmdPri, "priority", "p", 0, "[0-255] command priority (default 0)")
 	addCmd.Flags().IntVarP(&cmdRet, "retries", "r", 3, "[0-255] number of automatic retries for failed commands")
+	addCmd.Flags().StringVar(&cmdRetryPolicy, "retry_policy", "", "which failures to retry and how long to wait between retries, in JSON format")
+	addCmd.Flags().StringVar(&cmdEscalation, "escalation", "", "how much to increase memory, time or disk after running out, and the ceiling, in JSON format")
 	addCmd.Flags().StringVar(&cmdCmdDeps, "cmd_deps", "", "dependencies of your commands, in the form \"command1,cwd1,command2,cwd2...\"")
 	addCmd.Flags().StringVarP(&cmdGroupDeps, "deps", "d", "", "dependencies of your commands, in the form \"dep_grp1,dep_grp2...\"")
 	addCmd.Flags().StringVar(&cmdOnFailure, "on_failure", "", "behaviours to carry out when cmds fails, in JSON format")
@@ -463,11 +645,15 @@ func init() {
 	addCmd.Flags().StringVar(&cmdOnExit, "on_exit", `[{"cleanup":true}]`, "behaviours to carry out when cmds finish running, in JSON format")
 	addCmd.Flags().StringVarP(&mountJSON, "mount_json", "j", "", "remote file systems to mount, in JSON format")
 	addCmd.Flags().StringVar(&mountSimple, "mounts", "", "remote file systems to mount, as a ,-separated list of [c|u][r|w]:bucket[/path]")
+	addCmd.Flags().StringVar(&cmdContainer, "container", "", "container to run the commands in, in JSON format")
+	addCmd.Flags().StringVar(&cmdInterpreter, "interpreter", "", "program to run the commands (or scripts) with, eg. python [default is the runner's shell]")
 	addCmd.Flags().StringVar(&cmdOsPrefix, "cloud_os", "", "in the cloud, prefix name of the OS image servers that run the commands must use")
 	addCmd.Flags().StringVar(&cmdOsUsername, "cloud_username", "", "in the cloud, username needed to log in to the OS image specified by --cloud_os")
 	addCmd.Flags().IntVar(&cmdOsRAM, "cloud_ram", 0, "in the cloud, ram (MB) needed by the OS image specified by --cloud_os")
 	addCmd.Flags().StringVar(&cmdPostCreationScript, "cloud_script", "", "in the cloud, path to a start-up script that will be run on the servers created to run these commands")
 	addCmd.Flags().StringVar(&cmdEnv, "env", "", "comma-separated list of key=value environment variables to set before running the commands")
+	addCmd.Flags().BoolVar(&cmdChildren, "children", false, "add the commands as children of the wr command that is running this")
+	addCmd.Flags().StringVar(&cmdWait, "wait", "", "with --children, [running|waiting] make the parent wait for its children to complete")
 	addCmd.Flags().BoolVar(&cmdReRun, "rerun", false, "re-run any commands that you add that had been previously added and have since completed")
 
 	addCmd.Flags().IntVar(&timeoutint, "timeout", 30, "how long (seconds) to wait to get a reply from 'wr manager'")
@@ -0,0 +1,109 @@
+// Copyright © 2017 Genome Research Limited
+// Author: Sendu Bala <sb10@sanger.ac.uk>.
+//
+//  This file is part of wr.
+//
+//  wr is free software: you can redistribute it and/or modify
+//  it under the terms of the GNU Lesser General Public License as published by
+//  the Free Software Foundation, either version 3 of the License, or
+//  (at your option) any later version.
+//
+//  wr is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of
+//  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU Lesser General Public License for more details.
+//
+//  You should have received a copy of the GNU Lesser General Public License
+//  along with wr. If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"encoding/json"
+	"github.com/VertebrateResequencing/wr/jobqueue"
+	"github.com/spf13/cobra"
+	"io"
+	"os"
+	"time"
+)
+
+// options for this cmd
+var exportFile string
+var exportComplete bool
+
+// exportCmd represents the export command
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export commands for importing in to another manager",
+	Long: `You can export the commands you've previously added using "wr add"
+by running this command, so that they can be imported in to a different
+manager (eg. one running in a different deployment, or on a different machine)
+using "wr import".
+
+By default only incomplete commands are exported. Use --complete to also export
+the commands that have completed, so that their history is retained and "live"
+dependencies on them continue to work in the other manager.
+
+Commands are output as JSON objects, one per line. Everything about each command
+is exported, including its current state, number of attempts, dependencies,
+environment variables and (where stored) STDOUT and STDERR.
+
+Commands that are running at the time of export will be exported, but if they
+are still running when later imported, the importing manager will run them
+again. Ideally you should "wr manager drain" first, so that nothing is running,
+and stop the manager once you're happy with the export.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		timeout := time.Duration(timeoutint) * time.Second
+		jq, err := jobqueue.Connect(addr, "cmds", timeout)
+		if err != nil {
+			die("%s", err)
+		}
+		defer jq.Disconnect()
+
+		jobs, err := jq.Export(exportComplete)
+		if err != nil {
+			die("%s", err)
+		}
+
+		var writer io.Writer
+		if exportFile == "-" {
+			writer = os.Stdout
+		} else {
+			f, err := os.Create(exportFile)
+			if err != nil {
+				die("could not create file '%s': %s", exportFile, err)
+			}
+			defer f.Close()
+			writer = f
+		}
+
+		enc := json.NewEncoder(writer)
+		running := 0
+		for _, job := range jobs {
+			if job.State == jobqueue.JobStateRunning || job.State == jobqueue.JobStateReserved || job.State == jobqueue.JobStateLost {
+				running++
+			}
+			err = enc.Encode(job)
+			if err != nil {
+				die("failed to export a command: %s", err)
+			}
+		}
+
+		if running > 0 {
+			warn("%d commands were running; they will be run again when imported", running)
+		}
+		if exportFile != "-" {
+			info("Exported %d commands to %s", len(jobs), exportFile)
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(exportCmd)
+
+	// flags specific to this sub-command
+	exportCmd.Flags().StringVarP(&exportFile, "file", "f", "-", "file to export your commands to; - means write to STDOUT")
+	exportCmd.Flags().BoolVarP(&exportComplete, "complete", "c", false, "also export commands that have completed")
+
+	exportCmd.Flags().IntVar(&timeoutint, "timeout", 120, "how long (seconds) to wait to get a reply from 'wr manager'")
+}
@@ -0,0 +1,115 @@
+// Copyright © 2017 Genome Research Limited
+// Author: Sendu Bala <sb10@sanger.ac.uk>.
+//
+//  This file is part of wr.
+//
+//  wr is free software: you can redistribute it and/or modify
+//  it under the terms of the GNU Lesser General Public License as published by
+//  the Free Software Foundation, either version 3 of the License, or
+//  (at your option) any later version.
+//
+//  wr is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of
+//  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU Lesser General Public License for more details.
+//
+//  You should have received a copy of the GNU Lesser General Public License
+//  along with wr. If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"encoding/json"
+	"github.com/VertebrateResequencing/wr/jobqueue"
+	"github.com/spf13/cobra"
+	"io"
+	"os"
+	"time"
+)
+
+// options for this cmd
+var importFile string
+
+// importCmd represents the import command
+var importCmd = &cobra.Command{
+	Use:   "import",
+	Short: "Import commands exported from another manager",
+	Long: `You can import commands that were exported from a different manager
+using "wr export" by running this command.
+
+The commands keep everything they had in the other manager, including their
+identifiers, dependency groups, dependencies, environment variables and history
+(number of attempts, previous failure reasons and so on).
+
+Commands that had completed are imported as complete; they will not be run
+again. Commands that were buried are imported as if they had been retried.
+Commands that were running at the time of export are imported as if they had
+been lost and confirmed dead, and so will be run again. Commands that the
+manager already knows about are skipped.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		var reader io.Reader
+		if importFile == "-" {
+			reader = os.Stdin
+		} else {
+			f, err := os.Open(importFile)
+			if err != nil {
+				die("could not open file '%s': %s", importFile, err)
+			}
+			defer f.Close()
+			reader = f
+		}
+
+		var jobs []*jobqueue.Job
+		dec := json.NewDecoder(reader)
+		for {
+			job := &jobqueue.Job{}
+			err := dec.Decode(job)
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				die("command %d could not be read: %s", len(jobs)+1, err)
+			}
+
+			// JSON has turned any []string behaviour args in to []interface{}
+			for _, b := range job.Behaviours {
+				if args, isSlice := b.Arg.([]interface{}); isSlice {
+					var strs []string
+					for _, arg := range args {
+						if str, isStr := arg.(string); isStr {
+							strs = append(strs, str)
+						}
+					}
+					b.Arg = strs
+				}
+			}
+
+			jobs = append(jobs, job)
+		}
+		if len(jobs) == 0 {
+			die("no commands were found to import")
+		}
+
+		timeout := time.Duration(timeoutint) * time.Second
+		jq, err := jobqueue.Connect(addr, "cmds", timeout)
+		if err != nil {
+			die("%s", err)
+		}
+		defer jq.Disconnect()
+
+		added, existed, err := jq.Import(jobs)
+		if err != nil {
+			die("%s", err)
+		}
+
+		info("Imported %d commands (%d were already known about)", added, existed)
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(importCmd)
+
+	// flags specific to this sub-command
+	importCmd.Flags().StringVarP(&importFile, "file", "f", "-", "file containing your exported commands; - means read from STDIN")
+
+	importCmd.Flags().IntVar(&timeoutint, "timeout", 120, "how long (seconds) to wait to get a reply from 'wr manager'")
+}
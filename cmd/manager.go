@@ -42,6 +42,12 @@ var foreground bool
 var scheduler string
 var localUsername string
 var backupPath string
+var keepRunners bool
+var standby bool
+var restoreFrom string
+var excludeHost string
+var includeHost string
+var excludeReason string
 
 // managerCmd represents the manager command
 var managerCmd = &cobra.Command{
@@ -78,11 +84,26 @@ var managerStartCmd = &cobra.Command{
 	Use:   "start",
 	Short: "Start workflow management",
 	Long: `Start the workflow manager, daemonizing it in to the background
-(unless --foreground option is supplied).`,
+(unless --foreground option is supplied).
+
+With --standby, instead of starting a new manager, start a hot-standby for the
+manager already running on this host with the same port. The standby continually
+replicates the manager's database, and if the manager stops responding (and is
+confirmed to no longer be listening on its port or holding its database open)
+it takes over in its place (on the same port), recovering the manager's queues;
+any runners that were running commands will re-attach to it.
+
+If the manager is deliberately stopped (eg. with 'wr manager stop'), it tells
+the standby, which then exits instead of taking over.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		// first we need our working directory to exist
 		createWorkingDir()
 
+		if standby {
+			startStandby()
+			return
+		}
+
 		// check to see if the manager is already running (regardless of the
 		// state of the pid file), giving us a meaningful error message in the
 		// most obvious case of failure to start
@@ -153,8 +174,46 @@ var managerStopCmd = &cobra.Command{
 	Long: `Immediately stop the workflow manager, saving its state.
 
 Note that any runners that are currently running will die, along with any
-commands they were running. It is more graceful to use 'drain' instead.`,
+commands they were running. It is more graceful to use 'drain' instead.
+
+Alternatively, use --keep_runners to leave the runners (and their commands)
+running. When you next start the manager (eg. after upgrading wr), the runners
+will re-attach to it and report on the commands they were running, which will
+not need to be run again. Runners can only wait for the manager to come back for
+so long, so you should start it again promptly.
+
+Use --standby to stop just the hot-standby (started with 'wr manager start
+--standby'), leaving the manager itself running.`,
 	Run: func(cmd *cobra.Command, args []string) {
+		if standby {
+			pidFile := config.ManagerPidFile + ".standby"
+			pid, err := daemon.ReadPidFile(pidFile)
+			if err != nil {
+				die("wr manager standby does not seem to be running (could not read pid file %s: %s)", pidFile, err)
+			}
+			if !stopdaemon(pid, "pid file "+pidFile, "manager standby") {
+				die("wr manager standby with pid %d could not be stopped", pid)
+			}
+			info("wr manager standby was stopped")
+			return
+		}
+
+		if keepRunners {
+			jq := connect(5 * time.Second)
+			if jq == nil {
+				die("wr manager does not seem to be running on port %s", config.ManagerPort)
+			}
+			if !jq.ShutdownServerKeepRunners() {
+				die("failed to stop the manager on port %s", config.ManagerPort)
+			}
+			jq = connect(1 * time.Second)
+			if jq != nil {
+				die("I requested shut down of the manager on port %s, but it is still up!", config.ManagerPort)
+			}
+			info("wr manager running on port %s was gracefully shut down, leaving its runners running; start it again for them to re-attach", config.ManagerPort)
+			return
+		}
+
 		// the daemon could be running but be non-responsive, or it could have
 		// exited but left the pid file in place; to best cover all
 		// eventualities we check the pid file first, try and terminate its pid,
@@ -277,7 +336,12 @@ down will be lost.`,
 var managerStatusCmd = &cobra.Command{
 	Use:   "status",
 	Short: "Get status of the workflow manager",
-	Long:  `Find out if the workflow manager is currently running or not.`,
+	Long: `Find out if the workflow manager is currently running or not.
+
+If it is running, the number of runners that have started and exited is also
+shown, along with the errors reported by its runners (counted per host), most
+recent first, followed by any hosts that runners are not being scheduled on
+(see 'wr manager hosts').`,
 	Run: func(cmd *cobra.Command, args []string) {
 		// see if pid file suggests it is supposed to be running
 		pid, err := daemon.ReadPidFile(config.ManagerPidFile)
@@ -308,8 +372,11 @@ var managerBackupCmd = &cobra.Command{
 	Short: "Backup wr's database",
 	Long: `Manually backup wr's job database.
 
-The manager automatically backs up its database to the configured location every
-time there is a change.
+The manager automatically backs up its database to the configured location
+(managerdbbkfile) every time there is a change, or every managerdbbkinterval
+minutes if that is configured. It also keeps the last managerdbbkkeep backups as
+timestamped copies alongside it, making a new copy at most once an hour; see
+'wr manager restore'.
 
 You can use this command to create an additional backup to a different location.
 Note that the manager must be running.
@@ -335,9 +402,132 @@ somewhere.)`,
 	},
 }
 
+// restore sub-command restores the database from a backup
+var managerRestoreCmd = &cobra.Command{
+	Use:   "restore",
+	Short: "Restore wr's database from a backup",
+	Long: `Replace wr's job database with one of its backups.
+
+The manager keeps timestamped copies of its latest backups (see the
+managerdbbkkeep config option); run this command without --from to list them.
+Use --from to say which backup (or any other copy of the database) you want to
+restore from. The backup is checked for corruption before it is used, and your
+current database file is kept with a ".pre_restore" suffix.
+
+The manager must be stopped first. When you next start it, it will use the
+restored database.
+
+For backups stored in S3, first download the one you want, then use --from with
+the local path.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		jq := connect(1 * time.Second)
+		if jq != nil {
+			die("wr manager on port %s is running; stop it before restoring its database", config.ManagerPort)
+		}
+
+		if restoreFrom == "" {
+			if internal.IsRemote(config.ManagerDbBkFile) {
+				die("--from is required (your backups are at %s)", config.ManagerDbBkFile)
+			}
+			backups, err := jobqueue.DBBackups(config.ManagerDbBkFile)
+			if err != nil {
+				die("could not list backups: %s", err)
+			}
+			if _, err = os.Stat(config.ManagerDbBkFile); err == nil {
+				backups = append([]string{config.ManagerDbBkFile}, backups...)
+			}
+			if len(backups) == 0 {
+				die("there are no backups at %s", config.ManagerDbBkFile)
+			}
+			info("available backups, most recent first:")
+			for _, backup := range backups {
+				fmt.Println(backup)
+			}
+			die("--from is required")
+		}
+
+		err := jobqueue.RestoreDB(restoreFrom, config.ManagerDbFile)
+		if err != nil {
+			die("%s", err)
+		}
+		info("restored the database at %s from %s", config.ManagerDbFile, restoreFrom)
+	},
+}
+
+// hosts sub-command lets you see and change which hosts are excluded from
+// having runners scheduled on them
+var managerHostsCmd = &cobra.Command{
+	Use:   "hosts",
+	Short: "See and change the hosts that runners are not scheduled on",
+	Long: `See and change the hosts that the manager will not schedule runners on.
+
+Hosts can be excluded with the managerexcludedhosts config option, or
+automatically when a runner finds that its host fails the health checks
+configured with the runnerhealth* config options, or when jobs from several
+different req_grps keep failing on a host in ways that suggest a problem with
+the host itself (their commands couldn't be started, their mounts failed, or
+they were lost and you confirmed them dead). Without any options, this lists
+the currently excluded hosts, along with why and when they were excluded.
+Automatically excluded hosts are also shown on the status webpage until you
+confirm or clear them there; those not yet confirmed are marked "unconfirmed"
+here. (With the local scheduler, the automatic exclusion of the manager's own
+host only takes effect once you confirm it.)
+
+Use --exclude to stop scheduling runners on a host yourself, and --include to
+start scheduling runners on an excluded host again (eg. after fixing it).
+Runners that are already scheduled or running are not affected.
+
+Note that the manager must be running, and that the list of excluded hosts is
+not remembered when it is restarted (other than those in the config option).`,
+	Run: func(cmd *cobra.Command, args []string) {
+		timeout := time.Duration(timeoutint) * time.Second
+		jq, err := jobqueue.Connect(addr, "cmds", timeout)
+		if err != nil {
+			die("%s", err)
+		}
+		defer jq.Disconnect()
+
+		if excludeHost != "" {
+			err = jq.ExcludeHost(excludeHost, excludeReason)
+			if err != nil {
+				die("%s", err)
+			}
+			info("runners will no longer be scheduled on %s", excludeHost)
+		}
+		if includeHost != "" {
+			err = jq.IncludeHost(includeHost)
+			if err != nil {
+				die("%s", err)
+			}
+			info("runners can be scheduled on %s again", includeHost)
+		}
+		if excludeHost != "" || includeHost != "" {
+			return
+		}
+
+		hosts, err := jq.ExcludedHosts()
+		if err != nil {
+			die("%s", err)
+		}
+		printExcludedHosts(hosts)
+	},
+}
+
+// printExcludedHosts prints the given excluded hosts, one per line.
+func printExcludedHosts(hosts []*jobqueue.ExcludedHost) {
+	for _, eh := range hosts {
+		var unconfirmed string
+		if !eh.Confirmed {
+			unconfirmed = " (unconfirmed)"
+		}
+		fmt.Printf("%s%s: %s [since %s]\n", eh.Host, unconfirmed, eh.Reason, time.Unix(eh.Date, 0).Format(shortTimeFormat))
+	}
+}
+
 // reportLiveStatus is used by the status command on a working connection to
 // distinguish between the server being in a normal 'started' state or the
-// 'drain' state.
+// 'drain' state. It also shows what runners have reported, to help you find out
+// if/why they are failing.
 func reportLiveStatus(jq *jobqueue.Client) {
 	sstats, err := jq.ServerStats()
 	if err != nil {
@@ -345,6 +535,27 @@ func reportLiveStatus(jq *jobqueue.Client) {
 	}
 	mode := sstats.ServerInfo.Mode
 	fmt.Println(mode)
+	fmt.Printf("(runners started: %d; exited: %d)\n", sstats.RunnerStarts, sstats.RunnerExits)
+
+	issues, err := jq.GetRunnerIssues()
+	if err != nil {
+		warn("could not get the runner reports: %s", err)
+		return
+	}
+	if len(issues) > 0 {
+		fmt.Printf("\nRunner reports:\n")
+		printRunnerIssues(issues)
+	}
+
+	hosts, err := jq.ExcludedHosts()
+	if err != nil {
+		warn("could not get the excluded hosts: %s", err)
+		return
+	}
+	if len(hosts) > 0 {
+		fmt.Printf("\nHosts runners are not scheduled on:\n")
+		printExcludedHosts(hosts)
+	}
 }
 
 func init() {
@@ -354,6 +565,8 @@ func init() {
 	managerCmd.AddCommand(managerStopCmd)
 	managerCmd.AddCommand(managerStatusCmd)
 	managerCmd.AddCommand(managerBackupCmd)
+	managerCmd.AddCommand(managerRestoreCmd)
+	managerCmd.AddCommand(managerHostsCmd)
 
 	// flags specific to these sub-commands
 	defaultConfig := internal.DefaultConfig()
@@ -374,7 +587,43 @@ func init() {
 	managerStartCmd.Flags().StringVar(&cloudConfigFiles, "cloud_config_files", defaultConfig.CloudConfigFiles, "for cloud schedulers, comma separated paths of config files to copy to spawned servers")
 	managerStartCmd.Flags().BoolVar(&cloudDebug, "cloud_debug", false, "for cloud schedulers, include extra debugging information in the logs")
 
+	managerStartCmd.Flags().BoolVar(&standby, "standby", false, "be a hot-standby for the manager already running on this host")
+
+	managerStopCmd.Flags().BoolVar(&keepRunners, "keep_runners", false, "do not kill runners, so they can re-attach when the manager is next started")
+	managerStopCmd.Flags().BoolVar(&standby, "standby", false, "stop the hot-standby instead of the manager")
+
 	managerBackupCmd.Flags().StringVarP(&backupPath, "path", "p", "", "backup file path")
+
+	managerRestoreCmd.Flags().StringVar(&restoreFrom, "from", "", "path to the backup to restore from")
+
+	managerHostsCmd.Flags().StringVarP(&excludeHost, "exclude", "e", "", "host to stop scheduling runners on")
+	managerHostsCmd.Flags().StringVar(&excludeReason, "reason", "excluded manually", "why the --exclude host is being excluded")
+	managerHostsCmd.Flags().StringVarP(&includeHost, "include", "i", "", "excluded host to start scheduling runners on again")
+}
+
+// startStandby starts a hot-standby for the manager already running on our
+// port, daemonizing unless in foreground mode.
+func startStandby() {
+	jq := connect(5 * time.Second)
+	if jq == nil {
+		die("wr manager does not seem to be running on port %s; start it before starting a standby", config.ManagerPort)
+	}
+	jq.Disconnect()
+
+	if foreground {
+		syscall.Umask(config.ManagerUmask)
+		info("wr manager standby replicating the manager on port %s", config.ManagerPort)
+		startJQ(true, nil)
+		return
+	}
+
+	child, context := daemonize(config.ManagerPidFile+".standby", config.ManagerUmask)
+	if child != nil {
+		info("wr manager standby started with pid %d, replicating the manager on port %s", child.Pid, config.ManagerPort)
+	} else {
+		defer context.Release()
+		startJQ(false, nil)
+	}
 }
 
 func logStarted(s *jobqueue.ServerInfo) {
@@ -425,19 +674,41 @@ func startJQ(sayStarted bool, postCreation []byte) {
 		serverCIDR = cloudCIDR
 	}
 
-	// start the jobqueue server
-	server, msg, err := jobqueue.Serve(jobqueue.ServerConfig{
-		AllowedUsers:    []string{localUsername},
-		Port:            config.ManagerPort,
-		WebPort:         config.ManagerWeb,
-		SchedulerName:   scheduler,
-		SchedulerConfig: schedulerConfig,
-		RunnerCmd:       exe + " runner -q %s -s '%s' --deployment %s --server '%s' -r %d -m %d",
-		DBFile:          config.ManagerDbFile,
-		DBFileBackup:    config.ManagerDbBkFile,
-		Deployment:      config.Deployment,
-		CIDR:            serverCIDR,
-	})
+	var excludedHosts []string
+	for _, host := range strings.Split(config.ManagerExcludedHosts, ",") {
+		if host = strings.TrimSpace(host); host != "" {
+			excludedHosts = append(excludedHosts, host)
+		}
+	}
+
+	// start the jobqueue server, or stand by to take over from the one already
+	// running
+	serverConfig := jobqueue.ServerConfig{
+		AllowedUsers:         []string{localUsername},
+		Port:                 config.ManagerPort,
+		WebPort:              config.ManagerWeb,
+		SchedulerName:        scheduler,
+		SchedulerConfig:      schedulerConfig,
+		RunnerCmd:            exe + " runner -q %s -s '%s' --deployment %s --server '%s' -r %d -m %d",
+		RunnerSlots:          config.RunnerSlots,
+		DBFile:               config.ManagerDbFile,
+		DBFileBackup:         config.ManagerDbBkFile,
+		DBFileBackupKeep:     config.ManagerDbBkKeep,
+		DBFileBackupInterval: time.Duration(config.ManagerDbBkInterval) * time.Minute,
+		DBKeepDays:           config.ManagerDbKeepDays,
+		DBKeepPerRepGroup:    config.ManagerDbKeepPerRepGroup,
+		DBFilePruned:         config.ManagerDbPrunedFile,
+		ExcludedHosts:        excludedHosts,
+		Deployment:           config.Deployment,
+		CIDR:                 serverCIDR,
+	}
+	var server *jobqueue.Server
+	var msg string
+	if standby {
+		server, msg, err = jobqueue.Standby("localhost:"+config.ManagerPort, serverConfig)
+	} else {
+		server, msg, err = jobqueue.Serve(serverConfig)
+	}
 
 	if sayStarted && err == nil {
 		logStarted(server.ServerInfo)
@@ -454,6 +725,10 @@ func startJQ(sayStarted bool, postCreation []byte) {
 
 	// log to file failure to Serve
 	if err != nil {
+		if jqerr, ok := err.(jobqueue.Error); ok && jqerr.Err == jobqueue.ErrPrimaryStopped {
+			log.Printf("wr manager standby stopped, since the manager on port %s was deliberately stopped\n", config.ManagerPort)
+			os.Exit(0)
+		}
 		if msg != "" {
 			log.Printf("wr manager : %s\n", msg)
 		}
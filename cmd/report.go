@@ -0,0 +1,109 @@
+// Copyright © 2017 Genome Research Limited
+// Author: Sendu Bala <sb10@sanger.ac.uk>.
+//
+//  This file is part of wr.
+//
+//  wr is free software: you can redistribute it and/or modify
+//  it under the terms of the GNU Lesser General Public License as published by
+//  the Free Software Foundation, either version 3 of the License, or
+//  (at your option) any later version.
+//
+//  wr is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of
+//  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU Lesser General Public License for more details.
+//
+//  You should have received a copy of the GNU Lesser General Public License
+//  along with wr. If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"encoding/json"
+	"github.com/VertebrateResequencing/wr/jobqueue"
+	"github.com/spf13/cobra"
+	"os"
+	"time"
+)
+
+// options for this cmd
+var reportBy string
+var reportRepGroup string
+var reportOutput string
+
+// reportCmd represents the report command
+var reportCmd = &cobra.Command{
+	Use:   "report",
+	Short: "Report on the resource efficiency of completed commands",
+	Long: `Find out how efficiently your completed commands used the resources
+they requested, so that you can request less (or more) in the future.
+
+Completed commands are grouped by their rep_grp, or by their req_grp if you
+supply --by reqgroup, and for each group you're told:
+
+group             the rep_grp or req_grp
+jobs              the number of completed commands in the group
+ram_requested_mb  the mean memory requested (MB)
+ram_peak_mb       the mean peak memory used (MB)
+ram_efficiency    total peak memory / total memory requested
+time_requested_s  the mean time requested (seconds)
+wall_time_s       the mean time taken to run (seconds)
+time_efficiency   total wall time / total time requested
+cpu_efficiency    total CPU time / total (wall time * cores requested)
+core_hours        total (wall time * cores requested), in hours
+ram_retries       the number of retries caused by running out of memory
+time_retries      the number of retries caused by running out of time
+
+Use -i to only consider the commands in a particular rep_grp.
+
+The output is tab separated values with a header line, or JSON if you supply
+--output json.
+
+The same report can be seen in the web interface (the "Resource report" link at
+the top of the status page), or retrieved from the /rest/v1/report/ REST
+endpoint.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if reportBy != jobqueue.ReportByRepGroup && reportBy != jobqueue.ReportByReqGroup {
+			die("--by must be %s or %s", jobqueue.ReportByRepGroup, jobqueue.ReportByReqGroup)
+		}
+		if reportOutput != "tsv" && reportOutput != "json" {
+			die("--output must be tsv or json")
+		}
+
+		timeout := time.Duration(timeoutint) * time.Second
+		jq, err := jobqueue.Connect(addr, "cmds", timeout)
+		if err != nil {
+			die("%s", err)
+		}
+		defer jq.Disconnect()
+
+		reports, err := jq.GetEfficiencyReports(reportBy, reportRepGroup)
+		if err != nil {
+			die("%s", err)
+		}
+
+		if reportOutput == "json" {
+			if len(reports) == 0 {
+				reports = []*jobqueue.EfficiencyReport{}
+			}
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			err = enc.Encode(reports)
+		} else {
+			err = jobqueue.WriteEfficiencyReportsTSV(os.Stdout, reports)
+		}
+		if err != nil {
+			die("%s", err)
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(reportCmd)
+
+	// flags specific to this sub-command
+	reportCmd.Flags().StringVarP(&reportBy, "by", "b", jobqueue.ReportByRepGroup, "[repgroup|reqgroup] how to group commands together")
+	reportCmd.Flags().StringVarP(&reportRepGroup, "identifier", "i", "", "only report on commands with this rep_grp")
+	reportCmd.Flags().StringVarP(&reportOutput, "output", "o", "tsv", "[tsv|json] output format")
+	reportCmd.Flags().IntVar(&timeoutint, "timeout", 30, "how long (seconds) to wait to get a reply from 'wr manager'")
+}
@@ -0,0 +1,311 @@
+// Copyright © 2017 Genome Research Limited
+// Author: Sendu Bala <sb10@sanger.ac.uk>.
+//
+//  This file is part of wr.
+//
+//  wr is free software: you can redistribute it and/or modify
+//  it under the terms of the GNU Lesser General Public License as published by
+//  the Free Software Foundation, either version 3 of the License, or
+//  (at your option) any later version.
+//
+//  wr is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of
+//  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU Lesser General Public License for more details.
+//
+//  You should have received a copy of the GNU Lesser General Public License
+//  along with wr. If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"code.cloudfoundry.org/bytefmt"
+	"encoding/json"
+	"fmt"
+	"github.com/VertebrateResequencing/wr/jobqueue"
+	"github.com/spf13/cobra"
+	"os"
+	"strings"
+	"time"
+)
+
+// options for this cmd
+var rgName string
+var rgJSON bool
+var rgHistograms bool
+var rgStat string
+var rgMin int
+var rgMax int
+var rgPinMem string
+var rgPinTime string
+var rgPinCPUs int
+var rgPinDisk int
+var rgUnpin bool
+var rgEscalation string
+
+// reqgroupCmd represents the reqgroup command
+var reqgroupCmd = &cobra.Command{
+	Use:   "reqgroup",
+	Short: "Inspect and adjust what has been learned about req_grps",
+	Long: `Inspect and adjust what has been learned about the resource usage of
+the commands in each req_grp.
+
+When commands finish running, the manager records their peak memory usage, wall
+time, peak disk usage (for commands that did not have cwd_matters) and CPU
+usage against their req_grp. Commands subsequently added to the same req_grp
+then have their memory, time, disk and cpus requirements set to the 95th
+percentile of these samples (depending on their override setting).
+
+If a few bad runs have poisoned a req_grp, you can see that with
+"wr reqgroup list", and fix it with "wr reqgroup trim" or "wr reqgroup reset".
+Or you can override what has been learned with "wr reqgroup pin".`,
+}
+
+// list sub-command shows what has been learned
+var reqgroupListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List what has been learned about req_grps",
+	Long: `List what has been learned about all req_grps, or just the one given
+by -g.
+
+For each kind of resource usage you're shown the number of distinct samples
+recorded, their minimum, median, 95th percentile and maximum values, and what
+is currently being recommended based on them. Memory and disk samples are in
+MB, time samples in seconds, and cpu samples in percent of a core.
+Recommendations are in the units you use with "wr add", ie. MB, seconds, GB
+and cores. Any pinned recommendations and escalation are also shown.
+
+Use --histograms to also see how the samples are distributed, or --json to get
+all the details in JSON format.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		jq := connectForReqGroup()
+		defer jq.Disconnect()
+
+		stats, err := jq.GetReqGroupStats(rgName)
+		if err != nil {
+			die("%s", err)
+		}
+
+		if rgJSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			err = enc.Encode(stats)
+			if err != nil {
+				die("%s", err)
+			}
+			return
+		}
+
+		for _, rgs := range stats {
+			fmt.Printf("\n# %s\n", rgs.ReqGroup)
+			printReqGroupStat("Memory", rgs.Memory)
+			printReqGroupStat("Time", rgs.Time)
+			printReqGroupStat("Disk", rgs.Disk)
+			printReqGroupStat("Cpus", rgs.Cores)
+			if rgs.Pin != nil {
+				fmt.Printf("Pinned: { %s }\n", rgs.Pin)
+			}
+			if rgs.Escalation != nil {
+				fmt.Printf("Escalation: %s\n", rgs.Escalation)
+			}
+		}
+	},
+}
+
+// reset sub-command deletes everything learned
+var reqgroupResetCmd = &cobra.Command{
+	Use:   "reset",
+	Short: "Forget what has been learned about a req_grp",
+	Long: `Delete all the samples that have been recorded for the req_grp given
+by -g, so that its commands go back to using the requirements they were added
+with until new samples are recorded. Any pinned recommendations and escalation
+are unaffected.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if rgName == "" {
+			die("-g is required")
+		}
+		jq := connectForReqGroup()
+		defer jq.Disconnect()
+
+		removed, err := jq.ResetReqGroup(rgName)
+		if err != nil {
+			die("%s", err)
+		}
+		info("Deleted %d samples for req_grp %s", removed, rgName)
+	},
+}
+
+// trim sub-command deletes outlier samples
+var reqgroupTrimCmd = &cobra.Command{
+	Use:   "trim",
+	Short: "Delete outlying samples of a req_grp",
+	Long: `Delete the samples recorded for the req_grp given by -g that are
+less than --min or greater than --max, in the units shown by "wr reqgroup
+list" (MB for memory and disk, seconds for time, percent of a core for cpus).
+
+Use --stat to only trim one kind of sample, which you'll normally want to do
+since the units differ.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if rgName == "" {
+			die("-g is required")
+		}
+		if rgMin <= 0 && rgMax <= 0 {
+			die("at least one of --min and --max must be supplied")
+		}
+		jq := connectForReqGroup()
+		defer jq.Disconnect()
+
+		removed, err := jq.TrimReqGroup(rgName, rgStat, rgMin, rgMax)
+		if err != nil {
+			die("%s", err)
+		}
+		info("Deleted %d samples for req_grp %s", removed, rgName)
+	},
+}
+
+// pin sub-command sets fixed recommendations
+var reqgroupPinCmd = &cobra.Command{
+	Use:   "pin",
+	Short: "Fix the recommendations of a req_grp",
+	Long: `Set fixed recommendations for the commands in the req_grp given by
+-g, to be used instead of what has been learned from prior commands. Only the
+resources you supply are pinned. As with learned recommendations, they are
+only used for commands according to their override setting.
+
+Use --unpin to go back to using what has been learned.
+
+You can also set the escalation (see "wr add -h") that will apply to commands
+subsequently added to the req_grp without their own escalation, using
+--escalation. Supply --escalation '' to remove it.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if rgName == "" {
+			die("-g is required")
+		}
+		jq := connectForReqGroup()
+		defer jq.Disconnect()
+
+		if cmd.Flags().Changed("escalation") {
+			var esc *jobqueue.Escalation
+			if rgEscalation != "" {
+				evj := &jobqueue.EscalationViaJSON{}
+				err := json.Unmarshal([]byte(rgEscalation), evj)
+				if err != nil {
+					die("bad --escalation: %s", err)
+				}
+				esc, err = evj.Escalation()
+				if err != nil {
+					die("bad --escalation: %s", err)
+				}
+			}
+			err := jq.SetReqGroupEscalation(rgName, esc)
+			if err != nil {
+				die("%s", err)
+			}
+			if esc == nil {
+				info("Removed the escalation of req_grp %s", rgName)
+			} else {
+				info("Set the escalation of req_grp %s", rgName)
+			}
+		}
+
+		if rgUnpin {
+			err := jq.PinReqGroup(rgName, nil)
+			if err != nil {
+				die("%s", err)
+			}
+			info("Unpinned req_grp %s", rgName)
+			return
+		}
+
+		pin := &jobqueue.ReqGroupPin{Cores: rgPinCPUs, Disk: rgPinDisk}
+		if rgPinMem != "" {
+			mb, err := bytefmt.ToMegabytes(rgPinMem)
+			if err != nil {
+				die("--memory was not specified correctly: %s", err)
+			}
+			pin.RAM = int(mb)
+		}
+		if rgPinTime != "" {
+			var err error
+			pin.Time, err = time.ParseDuration(rgPinTime)
+			if err != nil {
+				die("--time was not specified correctly: %s", err)
+			}
+		}
+		if pin.RAM == 0 && pin.Time == 0 && pin.Cores == 0 && pin.Disk == 0 {
+			if !cmd.Flags().Changed("escalation") {
+				die("at least one of --memory, --time, --cpus, --disk, --unpin or --escalation must be supplied")
+			}
+			return
+		}
+
+		err := jq.PinReqGroup(rgName, pin)
+		if err != nil {
+			die("%s", err)
+		}
+		info("Pinned req_grp %s to { %s }", rgName, pin)
+	},
+}
+
+// connectForReqGroup connects to the manager, dying on failure.
+func connectForReqGroup() *jobqueue.Client {
+	timeout := time.Duration(timeoutint) * time.Second
+	jq, err := jobqueue.Connect(addr, "cmds", timeout)
+	if err != nil {
+		die("%s", err)
+	}
+	return jq
+}
+
+// printReqGroupStat prints a summary line (and optionally the histogram) of a
+// ReqGroupStat.
+func printReqGroupStat(name string, stat *jobqueue.ReqGroupStat) {
+	if stat == nil {
+		return
+	}
+	fmt.Printf("%s: { samples: %d; min: %d; median: %d; 95th percentile: %d; max: %d; recommended: %d }\n", name, stat.Samples, stat.Min, stat.Median, stat.Percentile95, stat.Max, stat.Recommendation)
+	if !rgHistograms {
+		return
+	}
+
+	most := 0
+	for _, bin := range stat.Histogram {
+		if bin.Count > most {
+			most = bin.Count
+		}
+	}
+	for i, bin := range stat.Histogram {
+		closer := ")"
+		if i == len(stat.Histogram)-1 {
+			closer = "]"
+		}
+		bar := strings.Repeat("#", (bin.Count*40+most-1)/most)
+		fmt.Printf("  [%d, %d%s\t%d\t%s\n", bin.Lower, bin.Upper, closer, bin.Count, bar)
+	}
+}
+
+func init() {
+	RootCmd.AddCommand(reqgroupCmd)
+	reqgroupCmd.AddCommand(reqgroupListCmd)
+	reqgroupCmd.AddCommand(reqgroupResetCmd)
+	reqgroupCmd.AddCommand(reqgroupTrimCmd)
+	reqgroupCmd.AddCommand(reqgroupPinCmd)
+
+	// flags specific to these sub-commands
+	reqgroupCmd.PersistentFlags().StringVarP(&rgName, "req_grp", "g", "", "name of the req_grp")
+	reqgroupCmd.PersistentFlags().IntVar(&timeoutint, "timeout", 30, "how long (seconds) to wait to get a reply from 'wr manager'")
+
+	reqgroupListCmd.Flags().BoolVar(&rgHistograms, "histograms", false, "show histograms of the samples")
+	reqgroupListCmd.Flags().BoolVar(&rgJSON, "json", false, "output in JSON format")
+
+	reqgroupTrimCmd.Flags().StringVar(&rgStat, "stat", "", "[memory|time|disk|cores] only trim this kind of sample")
+	reqgroupTrimCmd.Flags().IntVar(&rgMin, "min", 0, "delete samples less than this")
+	reqgroupTrimCmd.Flags().IntVar(&rgMax, "max", 0, "delete samples greater than this")
+
+	reqgroupPinCmd.Flags().StringVarP(&rgPinMem, "memory", "m", "", "pinned memory [specify units such as M for Megabytes or G for Gigabytes]")
+	reqgroupPinCmd.Flags().StringVarP(&rgPinTime, "time", "t", "", "pinned time [specify units such as m for minutes or h for hours]")
+	reqgroupPinCmd.Flags().IntVar(&rgPinCPUs, "cpus", 0, "pinned cpu cores")
+	reqgroupPinCmd.Flags().IntVar(&rgPinDisk, "disk", 0, "pinned disk space (GB)")
+	reqgroupPinCmd.Flags().BoolVar(&rgUnpin, "unpin", false, "remove the pinned recommendations")
+	reqgroupPinCmd.Flags().StringVar(&rgEscalation, "escalation", "", "escalation for commands subsequently added to the req_grp, in JSON format")
+}
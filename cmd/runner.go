@@ -22,10 +22,15 @@ import (
 	"fmt"
 	"github.com/VertebrateResequencing/wr/internal"
 	"github.com/VertebrateResequencing/wr/jobqueue"
+	jqs "github.com/VertebrateResequencing/wr/jobqueue/scheduler"
 	"github.com/kardianos/osext"
+	"github.com/shirou/gopsutil/disk"
+	"github.com/shirou/gopsutil/load"
 	"github.com/spf13/cobra"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"runtime"
 	"strings"
 	"time"
 )
@@ -36,6 +41,15 @@ var schedgrp string
 var reserveint int
 var rserver string
 var maxtime int
+var runnerRAM int
+var runnerCores int
+var runnerDisk int
+
+// a host must fail its health checks this many times in a row, this far apart,
+// before we consider it unhealthy, so that brief problems don't get it
+// excluded
+const runnerHealthChecks = 3
+const runnerHealthRecheck = 10 * time.Second
 
 // runnerCmd represents the runner command
 var runnerCmd = &cobra.Command{
@@ -47,11 +61,23 @@ You won't normally run this yourself directly - "wr manager" spawns these as
 needed.
 
 A runner will pick up a queued command and run it. Once that cmd completes, the
-runner will pick up another and so on. Once max_time has been used (or would be
-used based on the expected time to complete of the next queued command), the
-runner stops picking up new commands and exits instead; max_time does not cause
-the runner to kill itself if the cmd it is running takes longer than max_time to
-complete.`,
+runner will pick up another and so on. It only picks up commands that are
+expected to complete before max_time has been used, so once max_time has been
+used (or none of the queued commands would fit in the time left), the runner
+exits instead; max_time does not cause the runner to kill itself if the cmd it
+is running takes longer than max_time to complete.
+
+If given a resource envelope with any of --ram, --cores and --disk, the runner
+instead runs as many commands at once as fit within that envelope, picking up
+more as earlier ones complete. "wr manager" does this for groups of small
+commands when its runnerslots config option is greater than 1.
+
+Before reserving commands, the runner checks that its host is healthy according
+to the runnerhealth* config options (free disk space, fuse availability, load
+and a custom script). If it fails them 3 times in a row, 10s apart, it tells the
+manager, which stops scheduling runners on the host, and exits. (With the local
+scheduler, the load isn't checked, since all the runners share the one host.)
+See 'wr manager hosts'.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		if queuename == "" {
 			die("--queue is required")
@@ -72,6 +98,7 @@ complete.`,
 			die("%s", err)
 		}
 		defer jq.Disconnect()
+		runnerReport(jq, jobqueue.RunnerEventStart, "")
 
 		// in case any job we execute has a Cmd that calls `wr add`, we alter
 		// the environment to make that call work
@@ -103,46 +130,321 @@ complete.`,
 		// aren't any more commands in the queue
 		numrun := 0
 		exitReason := fmt.Sprintf("there are no more commands in queue '%s' in scheduler group '%s'", queuename, schedgrp)
-		for {
-			var job *jobqueue.Job
-			var err error
-			if schedgrp == "" {
-				job, err = jq.Reserve(rtimeout)
-			} else {
-				job, err = jq.ReserveScheduled(rtimeout, schedgrp)
+		if runnerRAM > 0 || runnerCores > 0 || runnerDisk > 0 {
+			envelope := runnerEnvelope{ram: runnerRAM, cores: runnerCores, disk: runnerDisk}
+			numrun, exitReason = runConcurrently(jq, rtimeout, endTime, envelope, exitReason)
+		} else {
+			for {
+				// don't take on commands if our host is no longer fit to run
+				// them
+				if reason := runnerUnhealthy(jq); reason != "" {
+					exitReason = reason
+					break
+				}
+
+				// we only get commands we have enough time left to run
+				job, err := runnerReserve(jq, rtimeout, &jobqueue.Capacity{Time: endTime.Sub(time.Now())})
+				if noneFit(err) {
+					exitReason = "we're about to hit our maximum time limit"
+					break
+				}
+				if err != nil {
+					runnerReport(jq, jobqueue.RunnerEventError, fmt.Sprintf("could not reserve commands: %s", err))
+					die("%s", err)
+				}
+				if job == nil {
+					break
+				}
+
+				// actually run the cmd
+				err = jq.Execute(job, config.RunnerExecShell)
+				if err != nil {
+					if runnerExecuteError(jq, job, err) {
+						exitReason = "we received a signal to stop"
+						break
+					}
+				} else {
+					info("command [%s] ran OK (exit code %d)", job.Cmd, job.Exitcode)
+				}
+
+				numrun++
 			}
+		}
 
-			if err != nil {
-				die("%s", err) //*** we want this in a central log so we can know if/why our runners are failing
+		info("wr runner exiting, having run %d commands, because %s", numrun, exitReason)
+		runnerReport(jq, jobqueue.RunnerEventExit, exitReason)
+	},
+}
+
+// runnerReserve reserves the next command for us to run that fits within the
+// given capacity, from our scheduler group if we have one.
+func runnerReserve(jq *jobqueue.Client, rtimeout time.Duration, capacity *jobqueue.Capacity) (*jobqueue.Job, error) {
+	return jq.ReserveFitting(rtimeout, schedgrp, capacity)
+}
+
+// noneFit tells you if the given error from runnerReserve() means that there
+// were commands in the queue, but none of them fit within our capacity.
+func noneFit(err error) bool {
+	jqerr, ok := err.(jobqueue.Error)
+	return ok && jqerr.Err == jobqueue.ErrNoneFit
+}
+
+// runnerReport tells the manager that we started, are exiting or had an error,
+// so that it can be seen centrally instead of being lost with our STDERR.
+func runnerReport(jq *jobqueue.Client, event string, msg string) {
+	err := jq.ReportRunner(event, schedgrp, msg)
+	if err != nil {
+		warn("could not report %s to the manager: %s", event, err)
+	}
+}
+
+// runnerUnhealthy runs the configured health checks on our host. If it fails
+// them runnerHealthChecks times in a row, the problem is reported to the
+// manager (which stops scheduling runners on our host) and our reason for
+// exiting is returned. Otherwise returns an empty string.
+func runnerUnhealthy(jq *jobqueue.Client) (exitReason string) {
+	var problem string
+	for i := 0; i < runnerHealthChecks; i++ {
+		if i > 0 {
+			warn("this host might be unhealthy: %s", problem)
+			<-time.After(runnerHealthRecheck)
+		}
+		problem = runnerHealthProblem()
+		if problem == "" {
+			return
+		}
+	}
+	warn("this host is unhealthy: %s", problem)
+	runnerReport(jq, jobqueue.RunnerEventUnhealthy, problem)
+	return fmt.Sprintf("our host failed its health checks (%s)", problem)
+}
+
+// runnerHealthProblem runs the health checks configured with the
+// runnerhealth* config options, returning a description of the first problem
+// found, or an empty string if there are none.
+func runnerHealthProblem() string {
+	if config.RunnerHealthMinDisk > 0 {
+		usage, err := disk.Usage(config.RunnerHealthDir)
+		if err != nil {
+			return fmt.Sprintf("could not check the free disk space in %s: %s", config.RunnerHealthDir, err)
+		}
+		freeGB := int(usage.Free / 1073741824)
+		if freeGB < config.RunnerHealthMinDisk {
+			return fmt.Sprintf("only %dGB of disk space is free in %s", freeGB, config.RunnerHealthDir)
+		}
+	}
+
+	if config.RunnerHealthFuse {
+		if _, err := os.Stat("/dev/fuse"); err != nil {
+			return fmt.Sprintf("fuse is not available: %s", err)
+		}
+		if _, err := exec.LookPath("fusermount"); err != nil {
+			return fmt.Sprintf("fuse is not available: %s", err)
+		}
+	}
+
+	// (with the local scheduler, our sibling runners' commands are the load,
+	// so it isn't a sign of a problem with the host)
+	if config.RunnerHealthMaxLoad > 0 && config.ManagerScheduler != "local" {
+		avg, err := load.Avg()
+		if err != nil {
+			return fmt.Sprintf("could not check the load average: %s", err)
+		}
+		percent := int(avg.Load1 * 100 / float64(runtime.NumCPU()))
+		if percent > config.RunnerHealthMaxLoad {
+			return fmt.Sprintf("the load average is %.2f, %d%% of the %d cores", avg.Load1, percent, runtime.NumCPU())
+		}
+	}
+
+	if config.RunnerHealthScript != "" {
+		out, err := exec.Command(config.RunnerExecShell, "-c", config.RunnerHealthScript).CombinedOutput()
+		if err != nil {
+			msg := strings.TrimSpace(string(out))
+			if len(msg) > 200 {
+				msg = msg[:197] + "..."
 			}
-			if job == nil {
+			return fmt.Sprintf("health check script [%s] failed (%s): %s", config.RunnerHealthScript, err, msg)
+		}
+	}
+
+	return ""
+}
+
+// runnerExecuteError warns about an error from Execute()ing the given job, and
+// reports it to the manager unless it was just the job's Cmd exiting non-zero,
+// or the job being killed or us being signalled (which are already recorded
+// against the job). Returns true if the error was due to us receiving a signal
+// to stop.
+func runnerExecuteError(jq *jobqueue.Client, job *jobqueue.Job, err error) (signalled bool) {
+	warn("%s", err)
+	if jqerr, ok := err.(jobqueue.Error); ok {
+		switch jqerr.Err {
+		case jobqueue.FailReasonSignal:
+			return true
+		case jobqueue.FailReasonKilled:
+			return false
+		}
+		// report just the reason, so that the same problem with different jobs
+		// is counted together
+		runnerReport(jq, jobqueue.RunnerEventError, jqerr.Err)
+		return false
+	}
+	if !job.Exited {
+		runnerReport(jq, jobqueue.RunnerEventError, err.Error())
+	}
+	return false
+}
+
+// runnerEnvelope holds the resources that a runner can use to run commands
+// concurrently, or the resources those commands are using. A 0 value for a
+// resource in an envelope means that resource is not limited.
+type runnerEnvelope struct {
+	ram   int // MB
+	cores int
+	disk  int // GB
+}
+
+// fits tells you if a command with the given requirements would fit in the
+// envelope, given the resources already used.
+func (e runnerEnvelope) fits(used runnerEnvelope, req *jqs.Requirements) bool {
+	return (e.ram == 0 || used.ram+req.RAM <= e.ram) &&
+		(e.cores == 0 || used.cores+req.Cores <= e.cores) &&
+		(e.disk == 0 || used.disk+req.Disk <= e.disk)
+}
+
+// free returns the capacity left in the envelope given the resources already
+// used and the given end time, for use when reserving a command. ok is false
+// if a limited resource has been used up entirely.
+func (e runnerEnvelope) free(used runnerEnvelope, endTime time.Time) (capacity *jobqueue.Capacity, ok bool) {
+	capacity = &jobqueue.Capacity{Time: endTime.Sub(time.Now())}
+	if e.ram > 0 {
+		capacity.RAM = e.ram - used.ram
+		if capacity.RAM <= 0 {
+			return
+		}
+	}
+	if e.cores > 0 {
+		capacity.Cores = e.cores - used.cores
+		if capacity.Cores <= 0 {
+			return
+		}
+	}
+	if e.disk > 0 {
+		capacity.Disk = e.disk - used.disk
+		if capacity.Disk <= 0 {
+			return
+		}
+	}
+	ok = true
+	return
+}
+
+// add adjusts the used resources by those of a command with the given
+// requirements; supply a negative sign to remove them instead.
+func (e *runnerEnvelope) add(req *jqs.Requirements, sign int) {
+	e.ram += sign * req.RAM
+	e.cores += sign * req.Cores
+	e.disk += sign * req.Disk
+}
+
+// runConcurrently is the multi-slot version of the runner loop, reserving and
+// executing as many commands at once as fit in the given envelope, touching
+// them all together, until there aren't any more commands in the queue. It
+// returns the number of commands run and why we stopped.
+func runConcurrently(jq *jobqueue.Client, rtimeout time.Duration, endTime time.Time, envelope runnerEnvelope, exitReason string) (numrun int, reason string) {
+	reason = exitReason
+	stopTouching := jq.CombineTouches()
+	defer stopTouching()
+
+	type executed struct {
+		job       *jobqueue.Job
+		signalled bool
+	}
+	finished := make(chan executed)
+	var used runnerEnvelope
+	running := 0
+	stopping := false
+	waitForOne := func() {
+		e := <-finished
+		running--
+		used.add(e.job.Requirements, -1)
+		numrun++
+		if e.signalled && !stopping {
+			stopping = true
+			reason = "we received a signal to stop"
+		}
+	}
+
+	var lastReq *jqs.Requirements
+	for !stopping {
+		// if the last command we picked up wouldn't fit now, the next one
+		// probably won't either, so wait for a running one to finish first
+		if running > 0 && lastReq != nil && !envelope.fits(used, lastReq) {
+			waitForOne()
+			continue
+		}
+
+		// don't take on commands if our host is no longer fit to run them;
+		// we only check when nothing of ours is running, so that our own load
+		// doesn't count against us
+		if running == 0 {
+			if unhealthy := runnerUnhealthy(jq); unhealthy != "" {
+				reason = unhealthy
 				break
 			}
+		}
 
-			// see if we have enough time left to run this
-			if time.Now().Add(job.Requirements.Time).After(endTime) {
-				jq.Release(job, job.FailReason)
-				exitReason = "we're about to hit our maximum time limit"
+		// we only get commands that fit in our remaining time and resources
+		capacity, ok := envelope.free(used, endTime)
+		if !ok {
+			waitForOne()
+			continue
+		}
+		job, err := runnerReserve(jq, rtimeout, capacity)
+		if noneFit(err) {
+			if running == 0 {
+				reason = "none of the remaining commands fit within our remaining time and resources"
+				break
+			}
+			waitForOne()
+			continue
+		}
+		if err != nil {
+			warn("%s", err)
+			runnerReport(jq, jobqueue.RunnerEventError, fmt.Sprintf("could not reserve commands: %s", err))
+			reason = fmt.Sprintf("we could not reserve commands: %s", err)
+			break
+		}
+		if job == nil {
+			if running == 0 {
 				break
 			}
+			// wait for something to finish before looking again
+			waitForOne()
+			continue
+		}
+		lastReq = job.Requirements
 
-			// actually run the cmd
-			err = jq.Execute(job, config.RunnerExecShell)
+		// actually run the cmd, alongside the others
+		used.add(job.Requirements, 1)
+		running++
+		go func(job *jobqueue.Job) {
+			signalled := false
+			err := jq.Execute(job, config.RunnerExecShell)
 			if err != nil {
-				warn("%s", err)
-				if jqerr, ok := err.(jobqueue.Error); ok && jqerr.Err == jobqueue.FailReasonSignal {
-					exitReason = "we received a signal to stop"
-					break
-				}
+				signalled = runnerExecuteError(jq, job, err)
 			} else {
 				info("command [%s] ran OK (exit code %d)", job.Cmd, job.Exitcode)
 			}
+			finished <- executed{job: job, signalled: signalled}
+		}(job)
+	}
 
-			numrun++
-		}
-
-		info("wr runner exiting, having run %d commands, because %s", numrun, exitReason)
-	},
+	// wait for everything we started to finish
+	for running > 0 {
+		waitForOne()
+	}
+	return
 }
 
 func init() {
@@ -155,4 +457,7 @@ func init() {
 	runnerCmd.Flags().IntVarP(&reserveint, "reserve_timeout", "r", 1, "how long (seconds) to wait for there to be a command in the queue, before exiting")
 	runnerCmd.Flags().IntVarP(&maxtime, "max_time", "m", 0, "maximum time (minutes) to run for before exiting; 0 means unlimited")
 	runnerCmd.Flags().StringVar(&rserver, "server", internal.DefaultServer(), "ip:port of wr manager")
+	runnerCmd.Flags().IntVar(&runnerRAM, "ram", 0, "run commands concurrently within this much memory (MB)")
+	runnerCmd.Flags().IntVar(&runnerCores, "cores", 0, "run commands concurrently within this many cores")
+	runnerCmd.Flags().IntVar(&runnerDisk, "disk", 0, "run commands concurrently within this much disk space (GB)")
 }
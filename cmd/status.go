@@ -20,17 +20,35 @@ package cmd
 
 import (
 	"bufio"
+	"encoding/json"
 	"fmt"
 	"github.com/VertebrateResequencing/wr/jobqueue"
 	"github.com/spf13/cobra"
 	"io"
 	"os"
+	"sort"
+	"strconv"
 	"strings"
+	"text/tabwriter"
 	"time"
 )
 
 const shortTimeFormat = "06/1/2-15:04:05"
 
+// statusWatchMaxRunning is the most running commands --watch will list.
+const statusWatchMaxRunning = 20
+
+// statusWatchStates are the states --watch shows counts of, in order.
+var statusWatchStates = []jobqueue.JobState{
+	jobqueue.JobStateDelayed,
+	jobqueue.JobStateDependent,
+	jobqueue.JobStateReady,
+	jobqueue.JobStateRunning,
+	jobqueue.JobStateLost,
+	jobqueue.JobStateBuried,
+	jobqueue.JobStateComplete,
+}
+
 // options for this cmd
 var cmdFileStatus string
 var cmdIDStatus string
@@ -38,8 +56,30 @@ var cmdLine string
 var showBuried bool
 var showStd bool
 var showEnv bool
+var showChildren bool
+var showHistory bool
 var quietMode bool
 var statusLimit int
+var statusState string
+var statusFailReason string
+var statusExitCode int
+var statusHost string
+var statusReqGroup string
+var statusSort string
+var statusOutput string
+var statusWatch bool
+var statusWatchInterval int
+
+// statusStates are the states that can be supplied to --state
+var statusStates = []jobqueue.JobState{
+	jobqueue.JobStateDelayed,
+	jobqueue.JobStateReady,
+	jobqueue.JobStateDependent,
+	jobqueue.JobStateRunning,
+	jobqueue.JobStateLost,
+	jobqueue.JobStateBuried,
+	jobqueue.JobStateComplete,
+}
 
 // statusCmd represents the status command
 var statusCmd = &cobra.Command{
@@ -66,7 +106,40 @@ grouped together and only a random 1 of them is displayed (and you are told how
 many were skipped). --limit changes how many commands in each of these groups
 are displayed. A limit of 0 turns off grouping and shows all your desired
 commands individually, but you could hit a timeout if retrieving the details of
-very many (tens of thousands+) commands.`,
+very many (tens of thousands+) commands.
+
+Commands that were added by other commands (using 'wr add --children') show the
+key of their parent. --children shows the commands that each displayed command
+added as a tree, with their states, so you can follow a dynamic workflow.
+
+--history shows everything that happened to each displayed command: when it
+was added, reserved (by which client), started (on which host, with which pid),
+exited (with which exit code), released or buried (and why), kicked, killed or
+deleted (by which user), and completed.
+
+In default or -i mode you can narrow down the commands you get the status of
+with --state (one of delayed, ready, dependent, running, lost, buried or
+complete; complete commands are only found in -i mode), --fail_reason,
+--exit_code, --host and --req_grp. --buried is the same as --state buried.
+
+--sort start or --sort end orders the commands by the time they started or
+ended (commands that haven't yet started or ended come first).
+
+For use by scripts, --output json gives you a JSON array of objects (with the
+same properties as used by the web interface), and --output tsv gives you tab
+separated values with a header line and these columns:
+key, rep_grp, req_grp, cmd, cwd, state, exit_code, fail_reason, host,
+expected_ram_mb, expected_time_s, cores, requested_disk_gb, peak_ram_mb,
+peak_disk_mb, walltime_s, cputime_s, started, ended, attempts, similar
+(started and ended are unix timestamps; exit_code, started and ended are blank
+when not yet known; tabs and newlines in values are shown as \t and \n).
+
+--watch gives you a live view in your terminal of the same things you see in
+the status web page: the number of commands in each state for each rep_grp with
+incomplete commands (or just the one you specify with -i), the currently
+running commands with their host and how long they've been running for, bad
+servers and scheduler issues. It updates as soon as anything changes, or every
+--watch_interval seconds otherwise. Press ctrl-c to stop watching.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		set := 0
 		if cmdFileStatus != "" {
@@ -81,9 +154,51 @@ very many (tens of thousands+) commands.`,
 		if set > 1 {
 			die("-f, -i and -l are mutually exclusive; only specify one of them")
 		}
-		var cmdState jobqueue.JobState
-		if showBuried {
-			cmdState = jobqueue.JobStateBuried
+		if statusWatch {
+			if cmdFileStatus != "" || cmdLine != "" || statusOutput != "text" || quietMode {
+				die("--watch can't be used with -f, -l, -o or -q")
+			}
+			if statusWatchInterval < 1 || statusWatchInterval >= timeoutint {
+				die("--watch_interval must be at least 1 and less than --timeout")
+			}
+		}
+		if statusOutput != "text" && statusOutput != "json" && statusOutput != "tsv" {
+			die("--output must be text, json or tsv")
+		}
+		if statusOutput != "text" && quietMode {
+			die("--quiet can only be used with --output text")
+		}
+		if statusSort != "" && statusSort != "start" && statusSort != "end" {
+			die("--sort must be start or end")
+		}
+
+		filter := &jobqueue.JobFilter{
+			RepGroup:   cmdIDStatus,
+			FailReason: statusFailReason,
+			Host:       statusHost,
+			ReqGroup:   statusReqGroup,
+		}
+		if cmd.Flags().Changed("exit_code") {
+			filter.Exitcode = &statusExitCode
+		}
+		if statusState != "" {
+			if showBuried {
+				die("--buried and --state are mutually exclusive")
+			}
+			for _, state := range statusStates {
+				if string(state) == statusState {
+					filter.State = state
+					break
+				}
+			}
+			if filter.State == "" {
+				die("--state must be one of %s", statusStates)
+			}
+		} else if showBuried {
+			filter.State = jobqueue.JobStateBuried
+		}
+		if (cmdFileStatus != "" || cmdLine != "") && (filter.State != "" || filter.FailReason != "" || filter.Exitcode != nil || filter.Host != "" || filter.ReqGroup != "") {
+			die("--buried, --state, --fail_reason, --exit_code, --host and --req_grp can only be used in default or -i mode")
 		}
 		timeout := time.Duration(timeoutint) * time.Second
 
@@ -91,6 +206,14 @@ very many (tens of thousands+) commands.`,
 		if cmdMounts != "" {
 			defaultMounts = mountParseJSON(cmdMounts)
 		}
+		var container *jobqueue.ContainerConfig
+		if cmdContainer != "" {
+			container = &jobqueue.ContainerConfig{}
+			err := json.Unmarshal([]byte(cmdContainer), container)
+			if err != nil {
+				die("bad --container: %s", err)
+			}
+		}
 
 		jq, err := jobqueue.Connect(addr, "cmds", timeout)
 		if err != nil {
@@ -98,15 +221,18 @@ very many (tens of thousands+) commands.`,
 		}
 		defer jq.Disconnect()
 
+		if statusWatch {
+			watchStatus(jq, cmdIDStatus, time.Duration(statusWatchInterval)*time.Second)
+			return
+		}
+
 		var jobs []*jobqueue.Job
 		showextra := true
 		switch {
-		case set == 0:
-			// get incomplete jobs
-			jobs, err = jq.GetIncomplete(statusLimit, cmdState, showStd, showEnv)
-		case cmdIDStatus != "":
-			// get all jobs with this identifier (repgroup)
-			jobs, err = jq.GetByRepGroup(cmdIDStatus, statusLimit, cmdState, showStd, showEnv)
+		case set == 0, cmdIDStatus != "":
+			// get incomplete jobs, or all jobs with this identifier (repgroup),
+			// that match our filter
+			jobs, err = jq.GetByFilter(filter, statusLimit, showStd, showEnv)
 		case cmdFileStatus != "":
 			// get jobs that have the supplied commands. We support a cmd\tcwd
 			// format file
@@ -143,7 +269,7 @@ very many (tens of thousands+) commands.`,
 					mounts = mountParseJSON(cols[2])
 				}
 
-				jes = append(jes, &jobqueue.JobEssence{Cmd: cols[0], Cwd: cwd, MountConfigs: mounts})
+				jes = append(jes, &jobqueue.JobEssence{Cmd: cols[0], Cwd: cwd, MountConfigs: mounts, Container: container, Interpreter: cmdInterpreter})
 				desired++
 			}
 			jobs, err = jq.GetByEssences(jes)
@@ -154,7 +280,7 @@ very many (tens of thousands+) commands.`,
 		default:
 			// get job that has the supplied command
 			var job *jobqueue.Job
-			job, err = jq.GetByEssence(&jobqueue.JobEssence{Cmd: cmdLine, Cwd: cmdCwd, MountConfigs: defaultMounts}, showStd, showEnv)
+			job, err = jq.GetByEssence(&jobqueue.JobEssence{Cmd: cmdLine, Cwd: cmdCwd, MountConfigs: defaultMounts, Container: container, Interpreter: cmdInterpreter}, showStd, showEnv)
 			if job != nil {
 				jobs = append(jobs, job)
 			}
@@ -164,6 +290,42 @@ very many (tens of thousands+) commands.`,
 			die("failed to get jobs corresponding to your settings: %s", err)
 		}
 
+		switch statusSort {
+		case "start":
+			sort.SliceStable(jobs, func(i, j int) bool {
+				return jobs[i].StartTime.Before(jobs[j].StartTime)
+			})
+		case "end":
+			sort.SliceStable(jobs, func(i, j int) bool {
+				return jobs[i].EndTime.Before(jobs[j].EndTime)
+			})
+		}
+
+		if statusOutput != "text" {
+			stati := make([]jobqueue.JStatus, len(jobs))
+			for i, job := range jobs {
+				stati[i] = jobqueue.JobToStatus(job)
+				if showHistory {
+					stati[i].History, err = jq.GetHistory(job.Key())
+					if err != nil {
+						warn("problem getting the history of %s: %s", job.Key(), err)
+					}
+				}
+			}
+			if statusOutput == "json" {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetEscapeHTML(false)
+				enc.SetIndent("", "  ")
+				err = enc.Encode(stati)
+			} else {
+				err = writeStatusTSV(os.Stdout, stati)
+			}
+			if err != nil {
+				die("%s", err)
+			}
+			return
+		}
+
 		if quietMode {
 			var d, re, b, ru, l, c int
 			for _, job := range jobs {
@@ -191,6 +353,15 @@ very many (tens of thousands+) commands.`,
 				if len(job.MountConfigs) > 0 {
 					mounts = fmt.Sprintf("Mounts: %s\n", job.MountConfigs)
 				}
+				if job.Container != nil {
+					mounts += fmt.Sprintf("Container: %s\n", job.Container)
+				}
+				if job.Interpreter != "" {
+					mounts += fmt.Sprintf("Interpreter: %s\n", job.Interpreter)
+				}
+				if job.Parent != "" {
+					mounts += fmt.Sprintf("Parent: %s\n", job.Parent)
+				}
 				var homeChanged string
 				if job.ActualCwd != "" {
 					cwd = job.ActualCwd
@@ -202,6 +373,12 @@ very many (tens of thousands+) commands.`,
 				if len(job.Behaviours) > 0 {
 					behaviours = fmt.Sprintf("Behaviours: %s\n", job.Behaviours)
 				}
+				if job.RetryPolicy != nil {
+					behaviours += fmt.Sprintf("Retry policy: %s\n", job.RetryPolicy)
+				}
+				if job.Escalation != nil {
+					behaviours += fmt.Sprintf("Escalation: %s\n", job.Escalation)
+				}
 				fmt.Printf("\n# %s\nCwd: %s\n%s%s%sId: %s; Requirements group: %s; Priority: %d; Attempts: %d\nExpected requirements: { memory: %dMB; time: %s; cpus: %d disk: %dGB }\n", job.Cmd, cwd, mounts, homeChanged, behaviours, job.RepGroup, job.ReqGroup, job.Priority, job.Attempts, job.Requirements.RAM, job.Requirements.Time, job.Requirements.Cores, job.Requirements.Disk)
 
 				switch job.State {
@@ -233,7 +410,11 @@ very many (tens of thousands+) commands.`,
 					if job.State != jobqueue.JobStateComplete {
 						prefix = "Stats of previous attempt"
 					}
-					fmt.Printf("%s: { Exit code: %d; Peak memory: %dMB; Wall time: %s; CPU time: %s }\nHost: %s (IP: %s%s); Pid: %d\n", prefix, job.Exitcode, job.PeakRAM, job.WallTime(), job.CPUtime, job.Host, job.HostIP, hostID, job.Pid)
+					var peakDisk string
+					if job.PeakDisk > 0 {
+						peakDisk = fmt.Sprintf("; Peak disk: %dMB", job.PeakDisk)
+					}
+					fmt.Printf("%s: { Exit code: %d; Peak memory: %dMB%s; Wall time: %s; CPU time: %s }\nHost: %s (IP: %s%s); Pid: %d\n", prefix, job.Exitcode, job.PeakRAM, peakDisk, job.WallTime(), job.CPUtime, job.Host, job.HostIP, hostID, job.Pid)
 					if showextra && showStd && job.Exitcode != 0 {
 						stdout, err := job.StdOut()
 						if err != nil {
@@ -274,6 +455,24 @@ very many (tens of thousands+) commands.`,
 					} else {
 						fmt.Printf("Env: %s\n", env)
 					}
+					script, err := job.Script()
+					if err != nil {
+						warn("problem reading the cmd's Script: %s", err)
+					} else if script != "" {
+						fmt.Printf("Script:\n%s\n", script)
+					}
+				}
+
+				if showextra && showStd && len(job.AttemptRecords) > 1 {
+					printAttempts(job.AttemptRecords)
+				}
+
+				if showextra && showChildren {
+					printChildren(jq, job.Key(), "")
+				}
+
+				if showextra && showHistory {
+					printHistory(jq, job.Key())
 				}
 
 				if job.Similar > 0 {
@@ -298,6 +497,232 @@ very many (tens of thousands+) commands.`,
 	},
 }
 
+// printChildren prints the commands that were added as children of the command
+// with the given key, and their children, as an indented tree.
+func printChildren(jq *jobqueue.Client, parentKey string, indent string) {
+	children, err := jq.GetChildren(parentKey, false, false)
+	if err != nil {
+		warn("problem getting the children of %s: %s", parentKey, err)
+		return
+	}
+	if len(children) == 0 {
+		return
+	}
+	if indent == "" {
+		fmt.Printf("Children:\n")
+	}
+	for i, child := range children {
+		branch, nextIndent := "├─ ", "│  "
+		if i == len(children)-1 {
+			branch, nextIndent = "└─ ", "   "
+		}
+		fmt.Printf("%s%s%s [%s]\n", indent, branch, child.Cmd, child.State)
+		printChildren(jq, child.Key(), indent+nextIndent)
+	}
+}
+
+// printAttempts prints the details of every attempt at running a command,
+// oldest first.
+func printAttempts(attempts []*jobqueue.JobAttempt) {
+	fmt.Printf("Attempts:\n")
+	for _, a := range attempts {
+		details := []string{"on " + a.Host}
+		if a.Exited {
+			details = append(details, fmt.Sprintf("exit code %d", a.Exitcode))
+		}
+		if a.FailReason != "" {
+			details = append(details, a.FailReason)
+		}
+		details = append(details, fmt.Sprintf("peak memory %dMB", a.PeakRAM))
+		if a.PeakDisk > 0 {
+			details = append(details, fmt.Sprintf("peak disk %dMB", a.PeakDisk))
+		}
+		details = append(details, fmt.Sprintf("wall time %s", a.EndTime.Sub(a.StartTime)))
+		fmt.Printf("  %d: %s (%s)\n", a.Attempt, a.StartTime.Format(shortTimeFormat), strings.Join(details, "; "))
+		stderr, err := a.StdErr()
+		if err != nil {
+			warn("problem reading the STDERR of attempt %d: %s", a.Attempt, err)
+		} else if stderr != "" {
+			fmt.Printf("     StdErr:\n%s\n", stderr)
+		}
+	}
+}
+
+// printHistory prints everything that happened to the command with the given
+// key, oldest first.
+func printHistory(jq *jobqueue.Client, key string) {
+	history, err := jq.GetHistory(key)
+	if err != nil {
+		warn("problem getting the history of %s: %s", key, err)
+		return
+	}
+	fmt.Printf("History:\n")
+	for _, e := range history {
+		var details []string
+		if e.User != "" {
+			details = append(details, "by "+e.User)
+		}
+		if e.ClientID != "" {
+			details = append(details, "client "+e.ClientID)
+		}
+		if e.Host != "" {
+			if e.Pid > 0 {
+				details = append(details, fmt.Sprintf("on %s as pid %d", e.Host, e.Pid))
+			} else {
+				details = append(details, "on "+e.Host)
+			}
+		}
+		if e.Exited {
+			details = append(details, fmt.Sprintf("exit code %d", e.Exitcode))
+		}
+		if e.FailReason != "" {
+			details = append(details, e.FailReason)
+		}
+		var detail string
+		if len(details) > 0 {
+			detail = " (" + strings.Join(details, "; ") + ")"
+		}
+		fmt.Printf("  %s %s -> %s%s\n", e.Time.Format(shortTimeFormat), e.Event, e.State, detail)
+	}
+}
+
+// watchStatus repeatedly clears the terminal and displays a summary of the
+// current state of the queue (optionally only for the given RepGroup), waiting
+// up to the given interval for something to change between each display.
+func watchStatus(jq *jobqueue.Client, repGroup string, interval time.Duration) {
+	var wait time.Duration
+	for {
+		ws, err := jq.WatchStatus(wait, repGroup)
+		if err != nil {
+			die("failed to get the status: %s", err)
+		}
+		wait = interval
+
+		// clear the screen and move the cursor to the top left
+		fmt.Print("\033[H\033[2J")
+		fmt.Printf("wr status at %s (updates on change, or every %s; ctrl-c to quit)\n\n", time.Now().Format(shortTimeFormat), interval)
+
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprint(tw, "rep_grp")
+		for _, state := range statusWatchStates {
+			fmt.Fprintf(tw, "\t%s", state)
+		}
+		fmt.Fprint(tw, "\n")
+		for _, rgc := range ws.RepGroups {
+			fmt.Fprint(tw, rgc.RepGroup)
+			for _, state := range statusWatchStates {
+				fmt.Fprintf(tw, "\t%d", rgc.Counts[state])
+			}
+			fmt.Fprint(tw, "\n")
+		}
+		tw.Flush()
+		if len(ws.RepGroups) == 0 {
+			fmt.Println("(no incomplete commands)")
+		}
+
+		if len(ws.Running) > 0 {
+			fmt.Printf("\nRunning:\n")
+			tw = tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			fmt.Fprint(tw, "host\telapsed\trep_grp\tcmd\n")
+			for i, job := range ws.Running {
+				if i == statusWatchMaxRunning {
+					break
+				}
+				elapsed := time.Since(job.StartTime) / time.Second * time.Second
+				host := job.Host
+				if job.State == jobqueue.JobStateLost {
+					host += " (lost contact)"
+				}
+				cmd := job.Cmd
+				if len(cmd) > 60 {
+					cmd = cmd[:57] + "..."
+				}
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", host, elapsed, job.RepGroup, strings.Replace(cmd, "\n", " ", -1))
+			}
+			tw.Flush()
+			if len(ws.Running) > statusWatchMaxRunning {
+				fmt.Printf("+ %d more running commands\n", len(ws.Running)-statusWatchMaxRunning)
+			}
+		}
+
+		var badServers []*jobqueue.BadServer
+		for _, bs := range ws.BadServers {
+			if bs.IsBad {
+				badServers = append(badServers, bs)
+			}
+		}
+		if len(badServers) > 0 {
+			fmt.Printf("\nBad servers:\n")
+			for _, bs := range badServers {
+				if bs.ExcludedHost {
+					fmt.Printf("%s (excluded host): %s\n", bs.Name, bs.Problem)
+					continue
+				}
+				fmt.Printf("%s (%s): %s\n", bs.Name, bs.IP, bs.Problem)
+			}
+		}
+
+		if len(ws.SchedulerIssues) > 0 {
+			fmt.Printf("\nScheduler issues:\n")
+			for _, si := range ws.SchedulerIssues {
+				fmt.Printf("%s [%dx, last at %s]\n", si.Msg, si.Count, time.Unix(si.LastDate, 0).Format(shortTimeFormat))
+			}
+		}
+
+		if len(ws.RunnerIssues) > 0 {
+			fmt.Printf("\nRunner reports:\n")
+			printRunnerIssues(ws.RunnerIssues)
+		}
+	}
+}
+
+// printRunnerIssues prints the given runner reports, one per line.
+func printRunnerIssues(issues []*jobqueue.RunnerIssue) {
+	for _, ri := range issues {
+		msg := ri.Event
+		if ri.Msg != "" {
+			msg += ": " + ri.Msg
+		}
+		fmt.Printf("%s %s [%dx, last at %s]\n", ri.Host, msg, ri.Count, time.Unix(ri.LastDate, 0).Format(shortTimeFormat))
+	}
+}
+
+// statusTSVEscaper makes values safe to output as a tab separated value.
+var statusTSVEscaper = strings.NewReplacer("\\", "\\\\", "\t", "\\t", "\n", "\\n", "\r", "\\r")
+
+// writeStatusTSV writes the given job statuses as tab separated values, with a
+// header line.
+func writeStatusTSV(w io.Writer, stati []jobqueue.JStatus) error {
+	_, err := fmt.Fprintln(w, "key\trep_grp\treq_grp\tcmd\tcwd\tstate\texit_code\tfail_reason\thost\texpected_ram_mb\texpected_time_s\tcores\trequested_disk_gb\tpeak_ram_mb\tpeak_disk_mb\twalltime_s\tcputime_s\tstarted\tended\tattempts\tsimilar")
+	if err != nil {
+		return err
+	}
+	for _, js := range stati {
+		var exitcode, started, ended string
+		if js.Exited {
+			exitcode = strconv.Itoa(js.Exitcode)
+		}
+		if js.Started > 0 {
+			started = strconv.FormatInt(js.Started, 10)
+		}
+		if js.Ended > 0 {
+			ended = strconv.FormatInt(js.Ended, 10)
+		}
+		cwd := js.CwdBase
+		if js.Cwd != "" {
+			cwd += js.Cwd
+		}
+		_, err = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%d\t%.0f\t%d\t%d\t%d\t%d\t%.0f\t%.0f\t%s\t%s\t%d\t%d\n",
+			js.Key, statusTSVEscaper.Replace(js.RepGroup), statusTSVEscaper.Replace(js.ReqGroup), statusTSVEscaper.Replace(js.Cmd), statusTSVEscaper.Replace(cwd),
+			js.State, exitcode, statusTSVEscaper.Replace(js.FailReason), js.Host, js.ExpectedRAM, js.ExpectedTime, js.Cores, js.RequestedDisk,
+			js.PeakRAM, js.PeakDisk, js.Walltime, js.CPUtime, started, ended, js.Attempts, js.Similar)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func init() {
 	RootCmd.AddCommand(statusCmd)
 
@@ -307,11 +732,24 @@ func init() {
 	statusCmd.Flags().StringVarP(&cmdLine, "cmdline", "l", "", "a command line you want the status of")
 	statusCmd.Flags().StringVarP(&cmdCwd, "cwd", "c", "", "working dir that the command(s) specified by -l or -f were set to run in")
 	statusCmd.Flags().StringVar(&cmdMounts, "mounts", "", "mounts that the command(s) specified by -l or -f were set to use")
+	statusCmd.Flags().StringVar(&cmdContainer, "container", "", "container that the command(s) specified by -l or -f were set to use")
+	statusCmd.Flags().StringVar(&cmdInterpreter, "interpreter", "", "interpreter that the command(s) specified by -l or -f were set to use")
 	statusCmd.Flags().BoolVarP(&showBuried, "buried", "b", false, "in default or -i mode only, only show the status of buried commands")
-	statusCmd.Flags().BoolVarP(&showStd, "std", "s", false, "except in -f mode, also show the most recent STDOUT and STDERR of incomplete commands")
-	statusCmd.Flags().BoolVarP(&showEnv, "env", "e", false, "except in -f mode, also show the environment variables the command(s) ran with")
+	statusCmd.Flags().BoolVarP(&showStd, "std", "s", false, "except in -f mode, also show the most recent STDOUT and STDERR of incomplete commands, and the details of each attempt at running commands that were tried more than once")
+	statusCmd.Flags().BoolVarP(&showEnv, "env", "e", false, "except in -f mode, also show the environment variables (and script) the command(s) ran with")
+	statusCmd.Flags().BoolVar(&showChildren, "children", false, "except in -f mode, also show the tree of commands that the command(s) added")
+	statusCmd.Flags().BoolVar(&showHistory, "history", false, "except in -f mode, also show everything that happened to the command(s)")
 	statusCmd.Flags().BoolVarP(&quietMode, "quiet", "q", false, "minimal verbosity: just display status counts")
 	statusCmd.Flags().IntVar(&statusLimit, "limit", 1, "number of commands that share the same properties to display; 0 displays all")
+	statusCmd.Flags().StringVar(&statusState, "state", "", "in default or -i mode only, only show the status of commands in this state")
+	statusCmd.Flags().StringVar(&statusFailReason, "fail_reason", "", "in default or -i mode only, only show the status of commands that last failed for this reason")
+	statusCmd.Flags().IntVar(&statusExitCode, "exit_code", 0, "in default or -i mode only, only show the status of commands that last exited with this code")
+	statusCmd.Flags().StringVar(&statusHost, "host", "", "in default or -i mode only, only show the status of commands that last ran on this host")
+	statusCmd.Flags().StringVar(&statusReqGroup, "req_grp", "", "in default or -i mode only, only show the status of commands in this req_grp")
+	statusCmd.Flags().StringVar(&statusSort, "sort", "", "[start|end] order commands by the time they started or ended")
+	statusCmd.Flags().StringVarP(&statusOutput, "output", "o", "text", "[text|json|tsv] output format")
+	statusCmd.Flags().BoolVarP(&statusWatch, "watch", "w", false, "live view of commands, servers and issues that updates as things change")
+	statusCmd.Flags().IntVar(&statusWatchInterval, "watch_interval", 5, "in --watch mode, the most seconds to wait between updates")
 
 	statusCmd.Flags().IntVar(&timeoutint, "timeout", 30, "how long (seconds) to wait to get a reply from 'wr manager'")
 }
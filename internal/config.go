@@ -44,30 +44,42 @@ const (
 
 // Config holds the configuration options for jobqueue server and client
 type Config struct {
-	ManagerPort      string `default:""`
-	ManagerWeb       string `default:""`
-	ManagerHost      string `default:"localhost"`
-	ManagerDir       string `default:"~/.wr"`
-	ManagerPidFile   string `default:"pid"`
-	ManagerLogFile   string `default:"log"`
-	ManagerDbFile    string `default:"db"`
-	ManagerDbBkFile  string `default:"db_bk"`
-	ManagerUmask     int    `default:"007"`
-	ManagerScheduler string `default:"local"`
-	RunnerExecShell  string `default:"bash"`
-	Deployment       string `default:"production"`
-	CloudFlavor      string `default:""`
-	CloudKeepAlive   int    `default:"120"`
-	CloudServers     int    `default:"-1"`
-	CloudCIDR        string `default:"192.168.0.0/18"`
-	CloudGateway     string `default:"192.168.0.1"`
-	CloudDNS         string `default:"8.8.4.4,8.8.8.8"`
-	CloudOS          string `default:"Ubuntu Xenial"`
-	CloudUser        string `default:"ubuntu"`
-	CloudRAM         int    `default:"2048"`
-	CloudDisk        int    `default:"1"`
-	CloudScript      string `default:""`
-	CloudConfigFiles string `default:"~/.s3cfg,~/.aws/credentials,~/.aws/config"`
+	ManagerPort              string `default:""`
+	ManagerWeb               string `default:""`
+	ManagerHost              string `default:"localhost"`
+	ManagerDir               string `default:"~/.wr"`
+	ManagerPidFile           string `default:"pid"`
+	ManagerLogFile           string `default:"log"`
+	ManagerDbFile            string `default:"db"`
+	ManagerDbBkFile          string `default:"db_bk"`
+	ManagerDbBkKeep          int    `default:"3"`
+	ManagerDbBkInterval      int    `default:"0"`
+	ManagerDbKeepDays        int    `default:"0"`
+	ManagerDbKeepPerRepGroup int    `default:"0"`
+	ManagerDbPrunedFile      string `default:"db_pruned"`
+	ManagerUmask             int    `default:"007"`
+	ManagerScheduler         string `default:"local"`
+	ManagerExcludedHosts     string `default:""`
+	RunnerExecShell          string `default:"bash"`
+	RunnerSlots              int    `default:"1"`
+	RunnerHealthScript       string `default:""`
+	RunnerHealthDir          string `default:"/tmp"`
+	RunnerHealthMinDisk      int    `default:"0"`
+	RunnerHealthFuse         bool   `default:"false"`
+	RunnerHealthMaxLoad      int    `default:"0"`
+	Deployment               string `default:"production"`
+	CloudFlavor              string `default:""`
+	CloudKeepAlive           int    `default:"120"`
+	CloudServers             int    `default:"-1"`
+	CloudCIDR                string `default:"192.168.0.0/18"`
+	CloudGateway             string `default:"192.168.0.1"`
+	CloudDNS                 string `default:"8.8.4.4,8.8.8.8"`
+	CloudOS                  string `default:"Ubuntu Xenial"`
+	CloudUser                string `default:"ubuntu"`
+	CloudRAM                 int    `default:"2048"`
+	CloudDisk                int    `default:"1"`
+	CloudScript              string `default:""`
+	CloudConfigFiles         string `default:"~/.s3cfg,~/.aws/credentials,~/.aws/config"`
 }
 
 /*
@@ -161,6 +173,9 @@ func ConfigLoad(deployment string, useparentdir bool) Config {
 	if !filepath.IsAbs(config.ManagerDbFile) {
 		config.ManagerDbFile = filepath.Join(config.ManagerDir, config.ManagerDbFile)
 	}
+	if !filepath.IsAbs(config.ManagerDbPrunedFile) {
+		config.ManagerDbPrunedFile = filepath.Join(config.ManagerDir, config.ManagerDbPrunedFile)
+	}
 	if !IsRemote(config.ManagerDbBkFile) && !filepath.IsAbs(config.ManagerDbBkFile) {
 		config.ManagerDbBkFile = filepath.Join(config.ManagerDir, config.ManagerDbBkFile)
 	}
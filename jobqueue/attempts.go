@@ -0,0 +1,88 @@
+// Copyright © 2017 Genome Research Limited
+// Author: Sendu Bala <sb10@sanger.ac.uk>.
+//
+//  This file is part of wr.
+//
+//  wr is free software: you can redistribute it and/or modify
+//  it under the terms of the GNU Lesser General Public License as published by
+//  the Free Software Foundation, either version 3 of the License, or
+//  (at your option) any later version.
+//
+//  wr is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of
+//  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU Lesser General Public License for more details.
+//
+//  You should have received a copy of the GNU Lesser General Public License
+//  along with wr. If not, see <http://www.gnu.org/licenses/>.
+
+package jobqueue
+
+// This file contains the code for remembering the details of every attempt at
+// running a Job's Cmd, since the Job itself only holds those of the latest.
+
+import (
+	"time"
+)
+
+// JobAttempt describes one attempt at running a Job's Cmd.
+type JobAttempt struct {
+	// Attempt is 1 for the first time the Cmd was run, 2 for the second, and
+	// so on, counting across every time the Job was added (unlike
+	// Job.Attempts, which starts again when a complete Job is re-added).
+	Attempt    uint32
+	Host       string
+	HostID     string
+	StartTime  time.Time
+	EndTime    time.Time
+	Exited     bool
+	Exitcode   int
+	FailReason string
+	PeakRAM    int
+	PeakDisk   int
+	CPUtime    time.Duration
+	// to read, call StdErr() instead; if the attempt failed, its (truncated)
+	// STDERR will be here.
+	StdErrC []byte
+}
+
+// StdErr returns the decompressed StdErrC, which is the head and tail of the
+// STDERR of the attempt's Cmd, if it failed.
+func (a *JobAttempt) StdErr() (stderr string, err error) {
+	if len(a.StdErrC) == 0 {
+		return
+	}
+	decomp, err := decompress(a.StdErrC)
+	if err != nil {
+		return
+	}
+	stderr = string(decomp)
+	return
+}
+
+// newJobAttempt creates a JobAttempt describing the Job's current attempt at
+// running its Cmd, now that that attempt is over and its FailReason (if any)
+// is known. Returns nil if the Cmd was never started. You must have (read)
+// locked the Job.
+func newJobAttempt(job *Job) *JobAttempt {
+	if job.StartTime.IsZero() {
+		return nil
+	}
+	attempt := &JobAttempt{
+		Attempt:    job.Attempts,
+		Host:       job.Host,
+		HostID:     job.HostID,
+		StartTime:  job.StartTime,
+		EndTime:    job.EndTime,
+		Exited:     job.Exited,
+		Exitcode:   job.Exitcode,
+		FailReason: job.FailReason,
+		PeakRAM:    job.PeakRAM,
+		PeakDisk:   job.PeakDisk,
+		CPUtime:    job.CPUtime,
+	}
+	if job.FailReason != "" {
+		attempt.StdErrC = job.attemptStdErrC
+	}
+	return attempt
+}
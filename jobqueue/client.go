@@ -24,6 +24,7 @@ import (
 	"bytes"
 	"fmt"
 	"github.com/VertebrateResequencing/wr/internal"
+	"github.com/VertebrateResequencing/wr/jobqueue/scheduler"
 	"github.com/go-mangos/mangos"
 	"github.com/go-mangos/mangos/protocol/req"
 	"github.com/go-mangos/mangos/transport/tcp"
@@ -33,7 +34,9 @@ import (
 	"os"
 	"os/exec"
 	"os/signal"
+	"path/filepath"
 	"runtime"
+	"strconv"
 	"strings"
 	"sync"
 	"syscall"
@@ -42,22 +45,29 @@ import (
 
 // FailReason* are the reasons for cmd line failure stored on Jobs
 const (
-	FailReasonEnv      = "failed to get environment variables"
-	FailReasonCwd      = "working directory does not exist"
-	FailReasonStart    = "command failed to start"
-	FailReasonCPerm    = "command permission problem"
-	FailReasonCFound   = "command not found"
-	FailReasonCExit    = "command invalid exit code"
-	FailReasonExit     = "command exited non-zero"
-	FailReasonRAM      = "command used too much RAM"
-	FailReasonTime     = "command used too much time"
-	FailReasonAbnormal = "command failed to complete normally"
-	FailReasonLost     = "lost contact with runner"
-	FailReasonSignal   = "runner received a signal to stop"
-	FailReasonResource = "resource requirements cannot be met"
-	FailReasonMount    = "mounting of remote file system(s) failed"
-	FailReasonUpload   = "failed to upload files to remote file system"
-	FailReasonKilled   = "killed by user request"
+	FailReasonEnv       = "failed to get environment variables"
+	FailReasonCwd       = "working directory does not exist"
+	FailReasonStart     = "command failed to start"
+	FailReasonCPerm     = "command permission problem"
+	FailReasonCFound    = "command not found"
+	FailReasonCExit     = "command invalid exit code"
+	FailReasonExit      = "command exited non-zero"
+	FailReasonRAM       = "command used too much RAM"
+	FailReasonTime      = "command used too much time"
+	FailReasonDisk      = "command ran out of disk space"
+	FailReasonAbnormal  = "command failed to complete normally"
+	FailReasonLost      = "lost contact with runner"
+	FailReasonSignal    = "runner received a signal to stop"
+	FailReasonResource  = "resource requirements cannot be met"
+	FailReasonMount     = "mounting of remote file system(s) failed"
+	FailReasonUpload    = "failed to upload files to remote file system"
+	FailReasonKilled    = "killed by user request"
+	FailReasonContainer = "container runtime could not be used"
+	FailReasonScript    = "script could not be prepared"
+
+	FailReasonRAMCeiling  = "command needs more RAM than its escalation ceiling"
+	FailReasonTimeCeiling = "command needs more time than its escalation ceiling"
+	FailReasonDiskCeiling = "command needs more disk than its escalation ceiling"
 )
 
 // these global variables are primarily exported for testing purposes; you
@@ -70,6 +80,18 @@ var (
 	RAMIncreaseMultLow                = 2.0
 	RAMIncreaseMultHigh               = 1.3
 	RAMIncreaseMultBreakpoint float64 = 8192
+	TimeIncreaseMin                   = 1 * time.Hour
+	DiskIncreaseMin                   = 1
+	DiskIncreaseMult                  = 2.0
+)
+
+// ClientDiskCheckFiles is how many files in a command's working directory we
+// allow for each touch interval between measurements of its disk usage, so
+// that commands which create many files are measured less often (but at least
+// every ClientDiskCheckMaxSkip intervals, and when they finish).
+var (
+	ClientDiskCheckFiles   = 10000
+	ClientDiskCheckMaxSkip = 20
 )
 
 // clientRequest is the struct that clients send to the server over the network
@@ -92,12 +114,33 @@ type clientRequest struct {
 	Limit          int
 	State          JobState
 	FirstReserve   bool
+	KeepRunners    bool
+	ReplID         string
+	ReplSeq        uint64
+	Parent         string
+	ParentToken    string
+	WaitChildren   bool
+	ReqGroup       string
+	Escalation     *Escalation
+	Stat           string
+	Min            int
+	Max            int
+	Pin            *ReqGroupPin
+	ReportBy       string
+	Filter         *JobFilter
+	Since          uint64
+	Capacity       *Capacity
+	RunnerIssue    *RunnerIssue
+	Host           string
+	Reason         string
+	RepGroup       string
 }
 
 // Client represents the client side of the socket that the jobqueue server is
 // Serve()ing, specific to a particular queue.
 type Client struct {
 	sock        mangos.Socket
+	addr        string
 	queue       string
 	ch          codec.Handle
 	clientid    uuid.UUID
@@ -105,7 +148,9 @@ type Client struct {
 	gotHostID   bool
 	user        string
 	hasReserved bool
+	timeout     time.Duration
 	teMutex     sync.Mutex // to protect Touch() from other methods during Execute()
+	touches     *touchGroup
 	sync.Mutex
 }
 
@@ -156,7 +201,7 @@ func Connect(addr string, queue string, timeout time.Duration) (c *Client, err e
 	// Connect() once; on the other hand, we avoid any possible problem with
 	// running on machines with low time resolution
 	u, _ := uuid.NewV4()
-	c = &Client{sock: sock, queue: queue, ch: new(codec.BincHandle), user: user, clientid: u}
+	c = &Client{sock: sock, addr: addr, queue: queue, ch: new(codec.BincHandle), user: user, clientid: u, timeout: timeout}
 
 	// Dial succeeds even when there's no server up, so we test the connection
 	// works with a Ping()
@@ -214,6 +259,28 @@ func (c *Client) ShutdownServer() bool {
 	return false
 }
 
+// ShutdownServerKeepRunners is like ShutdownServer(), except that existing
+// runners will not fail: the commands they are running will carry on, and when
+// the server is next started they will re-attach to it and report on how those
+// commands went. This lets you eg. upgrade the server without affecting any
+// running commands.
+func (c *Client) ShutdownServerKeepRunners() bool {
+	_, err := c.request(&clientRequest{Method: "shutdown", KeepRunners: true})
+	if err == nil || (err != nil && err.Error() == "receive time out") {
+		return true
+	}
+	return false
+}
+
+// replicate is used by Standby() to get the changes made to the server's
+// database since the transaction with the given sequence number, waiting up to
+// wait for there to be some. If id is not that of the server's replication log,
+// or the server no longer has the changes we need, we get a complete snapshot
+// of the database instead, in the DB property of the response.
+func (c *Client) replicate(id string, seq uint64, wait time.Duration) (sr *serverResponse, err error) {
+	return c.request(&clientRequest{Method: "replicate", ReplID: id, ReplSeq: seq, Timeout: wait})
+}
+
 // ServerStats returns stats of the jobqueue server itself.
 func (c *Client) ServerStats() (s *ServerStats, err error) {
 	resp, err := c.request(&clientRequest{Method: "sstats"})
@@ -267,6 +334,39 @@ func (c *Client) Add(jobs []*Job, envVars []string, ignoreComplete bool) (added
 	return
 }
 
+// AddChildren adds new jobs to the job queue as the children of the Job with
+// the given key, which must currently be running. It is intended to be used by
+// a running Job's Cmd, which authenticates itself as the parent by supplying
+// the values of the WR_JOB_KEY and WR_JOB_TOKEN environment variables that
+// Execute() gave it.
+//
+// Children get the parent's RepGroup, Cwd and MountConfigs unless they have
+// their own. If envVars is empty, they will run with the parent's environment
+// variables, otherwise it works as per Add().
+//
+// If waitChildren is true, the children also become members of the parent's
+// DepGroups, so that anything that depends on the parent will also wait for
+// the children to complete. (If you would rather the parent itself kept
+// running until its children complete, have its Cmd wait for them, using
+// GetChildren().)
+//
+// The parent and child links are recorded, so you can later GetChildren() of
+// the parent, and children have their Parent property set. Otherwise this is
+// just like Add().
+func (c *Client) AddChildren(parentKey string, token string, jobs []*Job, envVars []string, waitChildren bool, ignoreComplete bool) (added int, existed int, err error) {
+	var env []byte
+	if len(envVars) > 0 {
+		env = c.CompressEnv(envVars)
+	}
+	resp, err := c.request(&clientRequest{Method: "addc", Parent: parentKey, ParentToken: token, Jobs: jobs, Env: env, WaitChildren: waitChildren, IgnoreComplete: ignoreComplete})
+	if err != nil {
+		return
+	}
+	added = resp.Added
+	existed = resp.Existed
+	return
+}
+
 // Reserve takes a job off the jobqueue. If you process the job successfully you
 // should Archive() it. If you can't deal with it right now you should Release()
 // it. If you think it can never be dealt with you should Bury() it. If you die
@@ -319,6 +419,52 @@ func (c *Client) ReserveScheduled(timeout time.Duration, schedulerGroup string)
 	return
 }
 
+// Capacity describes the resources a client has available for running a Job,
+// for use with ReserveFitting(). A 0 value for a resource means that resource
+// is not limited.
+type Capacity struct {
+	Time  time.Duration // the time left before the client will stop running Jobs
+	RAM   int           // free RAM in MB
+	Cores int           // free processor cores
+	Disk  int           // free local disk space in GB
+}
+
+// fits tells you if a Job with the given Requirements could be run within this
+// Capacity.
+func (c *Capacity) fits(reqs *scheduler.Requirements) bool {
+	if reqs == nil {
+		return true
+	}
+	return (c.Time == 0 || reqs.Time <= c.Time) &&
+		(c.RAM == 0 || reqs.RAM <= c.RAM) &&
+		(c.Cores == 0 || reqs.Cores <= c.Cores) &&
+		(c.Disk == 0 || reqs.Disk <= c.Disk)
+}
+
+// ReserveFitting is like ReserveScheduled() (or like Reserve() if
+// schedulerGroup is blank), except that it will only return a Job whose
+// Requirements fit within the given capacity. Jobs that don't fit are left in
+// the queue for other clients, instead of you having to Release() them (which
+// would delay them). If there were ready Jobs, but none of them fit, you get a
+// nil Job and an Error with Err ErrNoneFit, so you can tell this apart from
+// there being nothing ready (which gets you a nil Job and nil error).
+func (c *Client) ReserveFitting(timeout time.Duration, schedulerGroup string, capacity *Capacity) (j *Job, err error) {
+	fr := false
+	if !c.hasReserved {
+		fr = true
+		c.hasReserved = true
+	}
+	resp, err := c.request(&clientRequest{Method: "reserve", Timeout: timeout, SchedulerGroup: schedulerGroup, FirstReserve: fr, Capacity: capacity})
+	if err != nil {
+		return
+	}
+	j = resp.Job
+	if j == nil && resp.NoneFit {
+		err = Error{c.queue, "ReserveFitting", "", ErrNoneFit}
+	}
+	return
+}
+
 // Execute runs the given Job's Cmd and blocks until it exits. Then any Job
 // Behaviours get triggered as appropriate for the exit status.
 //
@@ -340,12 +486,37 @@ func (c *Client) ReserveScheduled(timeout time.Duration, schedulerGroup string)
 // If any remote file system mounts have been configured for the Job, these are
 // mounted prior to running the Cmd, and unmounted afterwards.
 //
+// The Cmd is run with the environment variables returned by the Job's Env(),
+// which include the following that describe the Job to the Cmd:
+//
+//	WR_JOB_KEY  - the Job's unique key
+//	WR_REPGROUP - the Job's RepGroup
+//	WR_ATTEMPT  - the number of this attempt at running the Cmd, from 1
+//	WR_CORES    - the number of cores the Job was given (Requirements.Cores)
+//	WR_RAM_MB   - the MB of memory the Job was given (Requirements.RAM)
+//	WR_MANAGER  - the address of the server the Job was reserved from
+//
+// It also sets WR_JOB_TOKEN, which the Cmd can supply to AddChildren(), along
+// with WR_JOB_KEY, to add child Jobs as this Job.
+//
+// If the Job has an Interpreter or a script (see SetScript()), the script (or
+// Cmd, if there is no script) is written to a file in the TMPDIR (or the system
+// temp directory if CwdMatters), and the Interpreter (or the supplied shell) is
+// run with the path to that file as its only argument.
+//
+// If the Job has a Container, the Cmd is run inside it using the configured
+// container runtime, with the actual working directory, TMPDIR and any mount
+// points bound in to the container at the same paths. Peak RAM is then that of
+// all the processes running in the container.
+//
 // Internally, Execute() calls Mount(), Started() and Ended() and keeps track of
 // peak RAM used. It regularly calls Touch() on the Job so that the server knows
-// we are still alive and handling the Job successfully. It also intercepts
-// SIGTERM, SIGINT, SIGQUIT, SIGUSR1 and SIGUSR2, sending SIGKILL to the running
-// Cmd and returning Error.Err(FailReasonSignal); you should check for this and
-// exit your process. Finally it calls Unmount() and TriggerBehaviours().
+// we are still alive and handling the Job successfully (or leaves that to the
+// combined touch loop, if you called CombineTouches() to Execute() multiple Jobs
+// concurrently). It also intercepts SIGTERM, SIGINT, SIGQUIT, SIGUSR1 and
+// SIGUSR2, sending SIGKILL to the running Cmd and returning
+// Error.Err(FailReasonSignal); you should check for this and exit your process.
+// Finally it calls Unmount() and TriggerBehaviours().
 //
 // If Kill() is called while executing the Cmd, the next internal Touch() call
 // will result in the Cmd being killed and the job being Bury()ied.
@@ -399,7 +570,7 @@ func (c *Client) Execute(job *Job, shell string) error {
 		c.Bury(job, FailReasonCwd)
 		return fmt.Errorf("working directory [%s] does not exist", job.Cwd)
 	}
-	var actualCwd, tmpDir string
+	var actualCwd, tmpDir, bindScriptDir string
 	if job.CwdMatters {
 		cmd.Dir = job.Cwd
 	} else {
@@ -425,7 +596,12 @@ func (c *Client) Execute(job *Job, shell string) error {
 		}
 		if err != nil {
 			buryErr := fmt.Errorf("failed to mount remote file system(s): %s", err)
-			c.Bury(job, FailReasonMount, buryErr)
+			if job.RetryPolicy.listed(FailReasonMount) {
+				// (which buries after job.Retries fails in a row)
+				c.Release(job, FailReasonMount)
+			} else {
+				c.Bury(job, FailReasonMount, buryErr)
+			}
 			return buryErr
 		}
 	}
@@ -440,6 +616,7 @@ func (c *Client) Execute(job *Job, shell string) error {
 		job.Unmount(true)
 		return fmt.Errorf("failed to extract environment variables for job [%s]: %s", job.key(), err)
 	}
+	env = envOverride(env, []string{"WR_JOB_TOKEN=" + job.ChildToken})
 	if tmpDir != "" {
 		// (this works fine even if tmpDir has a space in one of the dir names)
 		env = envOverride(env, []string{"TMPDIR=" + tmpDir})
@@ -451,6 +628,59 @@ func (c *Client) Execute(job *Job, shell string) error {
 	}
 	cmd.Env = env
 
+	// if the Job has its own Interpreter or a script, we run that on a file
+	// containing the script (or Cmd) instead of passing Cmd to shell -c
+	if job.Interpreter != "" || job.ScriptKey != "" {
+		scriptPath, errs := job.writeScript(tmpDir)
+		if errs == nil && tmpDir == "" {
+			defer os.Remove(scriptPath)
+		}
+		interpreter := job.Interpreter
+		if interpreter == "" {
+			interpreter = shell
+		}
+		if errs == nil {
+			cmd.Path, errs = exec.LookPath(interpreter)
+		}
+		if errs != nil {
+			buryErr := fmt.Errorf("could not prepare to run the script for job [%s]: %s", job.key(), errs)
+			c.Bury(job, FailReasonScript, buryErr)
+			job.Unmount(true)
+			return buryErr
+		}
+		cmd.Args = []string{interpreter, scriptPath}
+		if tmpDir == "" && job.Container != nil {
+			bindScriptDir = filepath.Dir(scriptPath)
+		}
+	}
+
+	// if the Cmd should run in a container, we actually run the container
+	// runtime, in its own process group so that we can kill everything it
+	// starts
+	killCmd := func() {
+		cmd.Process.Kill()
+	}
+	var containerName string
+	if job.Container != nil {
+		paths := []string{cmd.Dir, tmpDir, bindScriptDir}
+		for _, mc := range job.MountConfigs {
+			paths = append(paths, job.mountPoint(mc))
+		}
+		containerName = "wr_" + job.key() + "_" + strconv.FormatInt(time.Now().UnixNano(), 10)
+		cmd.Path, cmd.Args, err = job.Container.command(cmd.Args, cmd.Dir, paths, env, containerName)
+		if err != nil {
+			buryErr := fmt.Errorf("could not run command [%s] in container: %s", jc, err)
+			c.Bury(job, FailReasonContainer, buryErr)
+			job.Unmount(true)
+			return buryErr
+		}
+		cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+		killCmd = func() {
+			syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+			job.Container.kill(containerName)
+		}
+	}
+
 	// intercept certain signals (under LSF and SGE, SIGUSR2 may mean out-of-
 	// time, but there's no reliable way of knowing out-of-memory, so we will
 	// just treat them all the same)
@@ -473,7 +703,7 @@ func (c *Client) Execute(job *Job, shell string) error {
 	if err != nil {
 		// if we can't access the server, may as well bail out now - kill the
 		// command (and don't bother trying to Release(); it will auto-Release)
-		cmd.Process.Kill()
+		killCmd()
 		job.TriggerBehaviours(false)
 		job.Unmount(true)
 		return fmt.Errorf("command [%s] started running, but I killed it due to a jobqueue server error: %s", job.Cmd, err)
@@ -490,11 +720,99 @@ func (c *Client) Execute(job *Job, shell string) error {
 	killCalled := false
 	var stateMutex sync.Mutex
 	stopChecking := make(chan bool, 1)
+
+	// if CombineTouches() was called, we leave touching the job to the
+	// combined touch loop, which will tell us if Kill() was called
+	tg := c.combinedTouches()
+	if tg != nil {
+		tg.add(job, func() {
+			stateMutex.Lock()
+			killCalled = true
+			stateMutex.Unlock()
+			killCmd()
+		})
+		defer tg.remove(job)
+	}
+
+	// for containers we measure the memory of all the processes in the
+	// container, not just the runtime process we started
+	cmdMemory := func() (int, error) {
+		return currentMemory(job.Pid)
+	}
+	if job.Container != nil {
+		containerPid := 0
+		cmdMemory = func() (int, error) {
+			if containerPid == 0 {
+				containerPid = job.Container.pid(containerName, job.Pid)
+				if containerPid == 0 {
+					return 0, fmt.Errorf("container not running yet")
+				}
+			}
+			return processTreeMemory(containerPid)
+		}
+	}
+
+	// if we made a unique working directory, we can track how much disk space
+	// the command uses within it (excluding any mounted remote file systems).
+	// Since that involves walking the whole directory tree, we back off from
+	// doing it every touch as the number of files grows, unless this is our
+	// final check
+	peakdisk := 0
+	checkDisk := func(final bool) {}
+	if actualCwd != "" {
+		uniqueDir := filepath.Dir(actualCwd)
+		mountPoints := make(map[string]bool)
+		for _, mc := range job.MountConfigs {
+			mountPoints[job.mountPoint(mc)] = true
+		}
+		skip := 0
+		checkDisk = func(final bool) {
+			if !final && skip > 0 {
+				skip--
+				return
+			}
+			disk, files := diskUsage(uniqueDir, mountPoints)
+			skip = files / ClientDiskCheckFiles
+			if skip > ClientDiskCheckMaxSkip {
+				skip = ClientDiskCheckMaxSkip
+			}
+			stateMutex.Lock()
+			if disk > peakdisk {
+				peakdisk = disk
+			}
+			stateMutex.Unlock()
+		}
+	}
+
+	// we also keep a time series of the resources the command uses, to help
+	// diagnose brief spikes in usage
+	series := newResourceSeries(time.Now())
+
 	go func() {
+		// if we lose contact with the manager (eg. because it is being
+		// restarted), we keep the touch pending and retry it more frequently
+		// than normal, so that we re-attach to our job as soon as possible
+		touchPending := false
+		touch := func() bool {
+			kc, err := c.Touch(job)
+			if err != nil {
+				touchPending = true
+				return false
+			}
+			touchPending = false
+			if kc {
+				killCmd()
+				stateMutex.Lock()
+				killCalled = true
+				stateMutex.Unlock()
+			}
+			return kc
+		}
+
 		for {
 			select {
 			case <-sigs:
-				cmd.Process.Kill()
+				killCmd()
 				stateMutex.Lock()
 				signalled = true
 				stateMutex.Unlock()
@@ -509,21 +827,23 @@ func (c *Client) Execute(job *Job, shell string) error {
 				}
 				stateMutex.Unlock()
 
-				kc, err := c.Touch(job)
-				if err != nil {
-					// we may have lost contact with the manager; this is OK. We
-					// will keep trying to touch until it works
-					continue
-				}
-				if kc {
-					cmd.Process.Kill()
-					stateMutex.Lock()
-					killCalled = true
-					stateMutex.Unlock()
+				checkDisk(false)
+
+				stateMutex.Lock()
+				job.ResourceSeriesC = series.compressed()
+				stateMutex.Unlock()
+
+				if tg != nil {
+					tg.setSeries(job, job.ResourceSeriesC)
+				} else if touch() {
 					return
 				}
 			case <-memTicker.C:
-				mem, err := currentMemory(job.Pid)
+				if touchPending && touch() {
+					return
+				}
+
+				mem, err := cmdMemory()
 				stateMutex.Lock()
 				if err == nil && mem > peakmem {
 					peakmem = mem
@@ -531,12 +851,15 @@ func (c *Client) Execute(job *Job, shell string) error {
 					if peakmem > job.Requirements.RAM {
 						// we don't allow things to use too much memory, or we
 						// could screw up the machine we're running on
-						cmd.Process.Kill()
+						killCmd()
 						ranoutMem = true
 						stateMutex.Unlock()
 						return
 					}
 				}
+				if now := time.Now(); series.due(now) {
+					series.sample(now, job.Pid, mem)
+				}
 				stateMutex.Unlock()
 			case <-stopChecking:
 				return
@@ -578,6 +901,11 @@ func (c *Client) Execute(job *Job, shell string) error {
 	}
 	peakmem += ourmem
 
+	// get a final disk usage now, before behaviours might clean up
+	checkDisk(true)
+	job.PeakDisk = peakdisk
+	job.ResourceSeriesC = series.compressed()
+
 	// get the exit code and figure out what to do with the Job
 	exitcode := 0
 	var myerr error
@@ -623,6 +951,11 @@ func (c *Client) Execute(job *Job, shell string) error {
 					dobury = true
 					failreason = FailReasonKilled
 					myerr = Error{c.queue, "Execute", job.key(), FailReasonKilled}
+				} else if bytes.Contains(bytes.ToLower(stderr.Bytes()), []byte(syscall.ENOSPC.Error())) {
+					// (tools print glibc's capitalised "No space left on
+					// device", while Go's message is all lower case)
+					failreason = FailReasonDisk
+					myerr = fmt.Errorf("command [%s] exited with code %d after running out of disk space%s", job.Cmd, exitcode, mayBeTemp)
 				} else {
 					failreason = FailReasonExit
 					myerr = fmt.Errorf("command [%s] exited with code %d%s", job.Cmd, exitcode, mayBeTemp)
@@ -642,6 +975,14 @@ func (c *Client) Execute(job *Job, shell string) error {
 		myerr = nil
 	}
 
+	// the job's RetryPolicy may say that some non-0 exit codes mean success
+	if exitcode != 0 && failreason == FailReasonExit && job.RetryPolicy.success(exitcode) {
+		dorelease = false
+		doarchive = true
+		failreason = ""
+		myerr = nil
+	}
+
 	finalStdErr := bytes.TrimSpace(stderr.Bytes())
 
 	// behaviours/ unmounting may take some time we need to make sure to keep
@@ -654,11 +995,10 @@ func (c *Client) Execute(job *Job, shell string) error {
 			case <-sigs:
 				return
 			case <-ticker2.C:
-				if !killCalled {
-					_, err := c.Touch(job)
-					if err != nil {
-						return
-					}
+				if !killCalled && tg == nil {
+					// we may have lost contact with the manager; this is OK.
+					// We will keep trying to touch until it works
+					c.Touch(job)
 				}
 			case <-stopChecking2:
 				return
@@ -699,8 +1039,6 @@ func (c *Client) Execute(job *Job, shell string) error {
 			myerr = unmountErr
 		}
 	}
-	ticker2.Stop()
-	stopChecking2 <- true
 
 	if addMountLogs && logs != "" {
 		finalStdErr = append(finalStdErr, "\n\nMount logs:\n"...)
@@ -712,17 +1050,39 @@ func (c *Client) Execute(job *Job, shell string) error {
 		finalStdErr = append(finalStdErr, berr.Error()...)
 	}
 
+	// the job's Escalation may say it can't be given any more of the resource
+	// it ran out of
+	if dorelease {
+		if ceiling := job.Escalation.exceeded(job.Requirements, failreason); ceiling != "" {
+			dorelease = false
+			dobury = true
+			failreason = ceiling
+			myerr = fmt.Errorf("command [%s] %s, so it has been buried", job.Cmd, ceiling)
+		}
+	}
+
+	// the job's RetryPolicy may say that this failure shouldn't be retried
+	if dorelease && !job.RetryPolicy.retryable(failreason, exitcode) {
+		dorelease = false
+		dobury = true
+		if failreason == FailReasonExit {
+			myerr = fmt.Errorf("command [%s] exited with code %d, which its retry policy does not retry, so it has been buried", job.Cmd, exitcode)
+		}
+	}
+
 	// though we may have had some problem, we always try and update our job end
 	// state, and we try many times to avoid having to repeat jobs unnecessarily
 	// (we keep retying for ~12+ hrs, giving plenty of time for issues to be
 	// fixed and potentially a new manager to be brought online for us to
-	// connect to and succeed)
+	// connect to and succeed). Meanwhile we keep touching, so that if the
+	// manager was restarted and our job was restored as running, it doesn't
+	// get treated as lost before we can tell the manager about it
 	maxRetries := 300
 	endedWorked := false
 	worked := false
 	for retryNum := 0; retryNum < maxRetries; retryNum++ {
 		if !endedWorked {
-			err = c.Ended(job, actualCwd, exitcode, peakmem, cmd.ProcessState.SystemTime(), bytes.TrimSpace(stdout.Bytes()), finalStdErr)
+			err = c.Ended(job, actualCwd, exitcode, peakmem, cmd.ProcessState.SystemTime()+cmd.ProcessState.UserTime(), bytes.TrimSpace(stdout.Bytes()), finalStdErr)
 
 			if err != nil {
 				<-time.After(time.Duration(retryNum*100) * time.Millisecond)
@@ -747,6 +1107,8 @@ func (c *Client) Execute(job *Job, shell string) error {
 		worked = true
 		break
 	}
+	ticker2.Stop()
+	stopChecking2 <- true
 
 	if !worked {
 		job.TriggerBehaviours(false)
@@ -794,7 +1156,8 @@ func (c *Client) Touch(job *Job) (killCalled bool, err error) {
 }
 
 // Ended updates a Job on the server with information that you've finished
-// running the Job's Cmd. Peakram should be in MB. The cwd you supply should be
+// running the Job's Cmd. Peakram should be in MB. The job's PeakDisk (in MB) and
+// ResourceSeriesC are also sent, if you set them. The cwd you supply should be
 // the actual working directory used, which may be different to the Job's Cwd
 // property; if not, supply empty string.
 func (c *Client) Ended(job *Job, cwd string, exitcode int, peakram int, cputime time.Duration, stdout []byte, stderr []byte) (err error) {
@@ -837,17 +1200,21 @@ func (c *Client) Archive(job *Job) (err error) {
 // You can only Release() the same job as many times as its Retries value if it
 // has been run and failed; a subsequent call to Release() will instead result
 // in a Bury(). (If the job's Cmd was not run, you can Release() an unlimited
-// number of times.)
+// number of times, unless failreason is one of its RetryPolicy's FailReasons.)
+// If the job has a RetryPolicy, it will not be retried until after the delay
+// that specifies.
 func (c *Client) Release(job *Job, failreason string) (err error) {
 	c.teMutex.Lock()
 	defer c.teMutex.Unlock()
 	job.FailReason = failreason
-	_, err = c.request(&clientRequest{Method: "jrelease", Job: job})
+	_, err = c.request(&clientRequest{Method: "jrelease", Job: job, Host: hostName()})
 	if err == nil {
 		// update our process with what the server would have done
 		if job.Exited && job.Exitcode != 0 {
 			job.UntilBuried--
 			job.updateRecsAfterFailure()
+		} else if job.RetryPolicy.listed(failreason) {
+			job.UntilBuried--
 		}
 		if job.UntilBuried <= 0 {
 			job.State = JobStateBuried
@@ -869,7 +1236,7 @@ func (c *Client) Bury(job *Job, failreason string, stderr ...error) (err error)
 	if len(stderr) == 1 && stderr[0] != nil {
 		job.StdErrC = compress([]byte(stderr[0].Error()))
 	}
-	_, err = c.request(&clientRequest{Method: "jbury", Job: job})
+	_, err = c.request(&clientRequest{Method: "jbury", Job: job, Host: hostName()})
 	if err == nil {
 		job.State = JobStateBuried
 	}
@@ -977,6 +1344,243 @@ func (c *Client) GetByRepGroup(repgroup string, limit int, state JobState, getSt
 	return
 }
 
+// GetChildren gets the Jobs that were added with AddChildren() as the children
+// of the Job with the given key (see Job.Key()), whether they are complete or
+// not. The other args are as in GetByRepGroup().
+func (c *Client) GetChildren(parentKey string, getStd bool, getEnv bool) (jobs []*Job, err error) {
+	resp, err := c.request(&clientRequest{Method: "getbp", Parent: parentKey, GetStd: getStd, GetEnv: getEnv})
+	if err != nil {
+		return
+	}
+	jobs = resp.Jobs
+	return
+}
+
+// GetByFilter gets the Jobs described by the given filter: incomplete ones,
+// and also complete ones if filter.RepGroup is set. The other args are as in
+// GetByRepGroup(), with 'limit' applying after the filter.
+func (c *Client) GetByFilter(filter *JobFilter, limit int, getStd bool, getEnv bool) (jobs []*Job, err error) {
+	resp, err := c.request(&clientRequest{Method: "getbf", Filter: filter, Limit: limit, GetStd: getStd, GetEnv: getEnv})
+	if err != nil {
+		return
+	}
+	jobs = resp.Jobs
+	return
+}
+
+// GetHistory gets the history of the Job with the given key (see Job.Key()):
+// everything that happened to it since it was added, oldest first, even if it
+// has since completed.
+func (c *Client) GetHistory(jobKey string) (history []*JobHistoryEvent, err error) {
+	resp, err := c.request(&clientRequest{Method: "history", Keys: []string{jobKey}})
+	if err != nil {
+		return
+	}
+	history = resp.History
+	return
+}
+
+// Subscribe lets you react to Jobs changing state as soon as it happens,
+// instead of having to poll GetIncomplete() or GetByRepGroup(). It returns a
+// channel on which you will receive a JobEvent every time a Job in this
+// Client's queue that matches the filter changes state (the filter's State is
+// compared to the state the Job changed to; supply an empty filter to receive
+// every change).
+//
+// Supply since as 0 to only receive changes that happen from now on, or as
+// the Seq of the last JobEvent you received (eg. from a previous run of your
+// program) to resume from that point.
+//
+// Subscribe makes its own connection to the server, so you can continue to use
+// this Client while subscribed. If that connection fails (eg. because the
+// server was restarted), it keeps trying to reconnect, and then resumes from
+// the last event it received. Call the returned function to end the
+// subscription; the channel will be closed shortly after.
+func (c *Client) Subscribe(filter *JobFilter, since uint64) (events <-chan *JobEvent, stop func(), err error) {
+	if filter == nil {
+		filter = &JobFilter{}
+	}
+	sub, err := Connect(c.addr, c.queue, c.timeout)
+	if err != nil {
+		return
+	}
+
+	// find out where "now" is, so we don't miss anything that happens after
+	// we return
+	if since == 0 {
+		var resp *serverResponse
+		resp, err = sub.request(&clientRequest{Method: "events", Filter: filter})
+		if err != nil {
+			sub.Disconnect()
+			return
+		}
+		since = resp.EventSeq
+	}
+
+	ch := make(chan *JobEvent)
+	events = ch
+	stopCh := make(chan struct{})
+	var once sync.Once
+	stop = func() {
+		once.Do(func() {
+			close(stopCh)
+		})
+	}
+
+	go func() {
+		defer close(ch)
+		defer func() {
+			if sub != nil {
+				sub.Disconnect()
+			}
+		}()
+
+		// we wait for events for less time than it takes the server to time
+		// out on us
+		wait := c.timeout / 2
+		missed := false
+		for {
+			select {
+			case <-stopCh:
+				return
+			default:
+			}
+
+			var rerr error
+			if sub == nil {
+				sub, rerr = Connect(c.addr, c.queue, c.timeout)
+			}
+			var resp *serverResponse
+			if rerr == nil {
+				resp, rerr = sub.request(&clientRequest{Method: "events", Filter: filter, Since: since, Timeout: wait})
+			}
+			if rerr != nil {
+				if sub != nil {
+					sub.Disconnect()
+					sub = nil
+				}
+				select {
+				case <-stopCh:
+					return
+				case <-time.After(ClientSubscribeRetryInterval):
+				}
+				continue
+			}
+
+			missed = missed || resp.EventsMissed
+			for _, e := range resp.Events {
+				if missed {
+					e.Missed = true
+					missed = false
+				}
+				select {
+				case ch <- e:
+				case <-stopCh:
+					return
+				}
+			}
+			since = resp.EventSeq
+		}
+	}()
+	return
+}
+
+// WatchStatus gets a summary of the current state of the queue: the number of
+// jobs in each state per RepGroup, the running jobs, bad servers and scheduler
+// issues (the same information shown by the status web page). If wait is
+// greater than 0, the server first waits up to that long for something to
+// change, so you can call this in a loop to follow changes as they happen.
+// wait should be less than the timeout you supplied to Connect(). If repGroup
+// is not blank, only the counts and running jobs of that RepGroup are
+// returned, and changes to other RepGroups are not waited for.
+func (c *Client) WatchStatus(wait time.Duration, repGroup string) (ws *WatchStatus, err error) {
+	resp, err := c.request(&clientRequest{Method: "wstatus", Timeout: wait, RepGroup: repGroup})
+	if err != nil {
+		return
+	}
+	ws = resp.WatchStatus
+	return
+}
+
+// SetReqGroupEscalation sets the Escalation that will apply to Jobs
+// subsequently added with the given ReqGroup, if they don't have their own
+// Escalation. Supply a nil esc to remove a previously set Escalation.
+func (c *Client) SetReqGroupEscalation(reqGroup string, esc *Escalation) (err error) {
+	_, err = c.request(&clientRequest{Method: "sesc", ReqGroup: reqGroup, Escalation: esc})
+	return
+}
+
+// GetReqGroupEscalation gets the Escalation set with SetReqGroupEscalation()
+// for the given ReqGroup, returning nil if there isn't one.
+func (c *Client) GetReqGroupEscalation(reqGroup string) (esc *Escalation, err error) {
+	resp, err := c.request(&clientRequest{Method: "gesc", ReqGroup: reqGroup})
+	if err != nil {
+		return
+	}
+	esc = resp.Escalation
+	return
+}
+
+// GetReqGroupStats gets a summary of what has been learned about the resource
+// usage of the Jobs in the given ReqGroup, or of all ReqGroups if reqGroup is
+// blank.
+func (c *Client) GetReqGroupStats(reqGroup string) (stats []*ReqGroupStats, err error) {
+	resp, err := c.request(&clientRequest{Method: "rgstats", ReqGroup: reqGroup})
+	if err != nil {
+		return
+	}
+	stats = resp.ReqGroupStats
+	return
+}
+
+// ResetReqGroup deletes everything that has been learned about the resource
+// usage of the Jobs in the given ReqGroup, returning the number of samples
+// deleted. (Any ReqGroupPin or Escalation is unaffected.)
+func (c *Client) ResetReqGroup(reqGroup string) (removed int, err error) {
+	resp, err := c.request(&clientRequest{Method: "rgtrim", ReqGroup: reqGroup, Min: -1, Max: -1})
+	if err != nil {
+		return
+	}
+	removed = resp.Removed
+	return
+}
+
+// TrimReqGroup deletes the samples learned for the given ReqGroup of the given
+// kind (one of the ReqGroupStat* constants, or blank for all kinds) that are
+// less than min or greater than max (0 meaning no maximum), returning the
+// number of samples deleted. Samples are in the units described in
+// ReqGroupStats.
+func (c *Client) TrimReqGroup(reqGroup string, kind string, min int, max int) (removed int, err error) {
+	resp, err := c.request(&clientRequest{Method: "rgtrim", ReqGroup: reqGroup, Stat: kind, Min: min, Max: max})
+	if err != nil {
+		return
+	}
+	removed = resp.Removed
+	return
+}
+
+// PinReqGroup sets fixed recommendations for the Jobs in the given ReqGroup,
+// used instead of what has been learned from prior Jobs (subject to each Job's
+// Override). Supply a nil pin to go back to using what has been learned.
+func (c *Client) PinReqGroup(reqGroup string, pin *ReqGroupPin) (err error) {
+	_, err = c.request(&clientRequest{Method: "rgpin", ReqGroup: reqGroup, Pin: pin})
+	return
+}
+
+// GetEfficiencyReports gets an EfficiencyReport for each RepGroup or ReqGroup
+// (according to the ReportBy* constant supplied) of the Jobs that are complete
+// and have been Archive()d, summarising how well they used the resources they
+// requested. If repGroup is supplied, only Jobs in that RepGroup are
+// considered.
+func (c *Client) GetEfficiencyReports(by string, repGroup string) (reports []*EfficiencyReport, err error) {
+	resp, err := c.request(&clientRequest{Method: "report", ReportBy: by, Job: &Job{RepGroup: repGroup}})
+	if err != nil {
+		return
+	}
+	reports = resp.Reports
+	return
+}
+
 // GetIncomplete gets all Jobs that are currently in the jobqueue, ie. excluding
 // those that are complete and have been Archive()d. The args are as in
 // GetByRepGroup().
@@ -989,6 +1593,38 @@ func (c *Client) GetIncomplete(limit int, state JobState, getStd bool, getEnv bo
 	return
 }
 
+// Export gets all Jobs that are currently in the jobqueue along with their
+// current state, StdOutC, StdErrC and EnvC, suitable for passing to Import()
+// on a different server. If includeComplete is true, Jobs that are complete
+// and have been Archive()d are also returned.
+func (c *Client) Export(includeComplete bool) (jobs []*Job, err error) {
+	resp, err := c.request(&clientRequest{Method: "export", IgnoreComplete: !includeComplete})
+	if err != nil {
+		return
+	}
+	jobs = resp.Jobs
+	return
+}
+
+// Import adds Jobs that were previously Export()ed from a (typically
+// different) server, preserving their history (Attempts, FailReason and so
+// on), their own EnvC and any stored StdOutC and StdErrC. Complete Jobs are
+// stored as complete, without being run again. Incomplete Jobs are added to
+// the queue; those that had been buried are treated as if they were Kick()ed,
+// and those that were running when exported are treated as if they had been
+// lost and confirmed dead (they will be run again). Jobs that already exist in
+// this server's queue or database are skipped, and counted in the existed
+// return value.
+func (c *Client) Import(jobs []*Job) (added int, existed int, err error) {
+	resp, err := c.request(&clientRequest{Method: "import", Jobs: jobs})
+	if err != nil {
+		return
+	}
+	added = resp.Added
+	existed = resp.Existed
+	return
+}
+
 // request the server do something and get back its response. We can only cope
 // with one request at a time per client, or we'll get replies back in the
 // wrong order, hence we lock.
@@ -1023,6 +1659,14 @@ func (c *Client) request(cr *clientRequest) (sr *serverResponse, err error) {
 		return
 	}
 
+	// let the jobs know where they came from, for the benefit of Env()
+	if sr.Job != nil {
+		sr.Job.managerAddr = c.addr
+	}
+	for _, job := range sr.Jobs {
+		job.managerAddr = c.addr
+	}
+
 	// pull the error out of sr
 	if sr.Err != "" {
 		key := ""
@@ -0,0 +1,187 @@
+// Copyright © 2017 Genome Research Limited
+// Author: Sendu Bala <sb10@sanger.ac.uk>.
+//
+//  This file is part of wr.
+//
+//  wr is free software: you can redistribute it and/or modify
+//  it under the terms of the GNU Lesser General Public License as published by
+//  the Free Software Foundation, either version 3 of the License, or
+//  (at your option) any later version.
+//
+//  wr is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of
+//  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU Lesser General Public License for more details.
+//
+//  You should have received a copy of the GNU Lesser General Public License
+//  along with wr. If not, see <http://www.gnu.org/licenses/>.
+
+package jobqueue
+
+// This file contains the container related code.
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	// ContainerRuntimeDocker is the ContainerConfig.Runtime for Docker.
+	ContainerRuntimeDocker = "docker"
+
+	// ContainerRuntimeSingularity is the ContainerConfig.Runtime for
+	// Singularity.
+	ContainerRuntimeSingularity = "singularity"
+)
+
+// ContainerConfig struct is used for setting in a Job to specify that its Cmd
+// should be run inside a container.
+type ContainerConfig struct {
+	// Image (required) is the container image to run Cmd in. For Docker this
+	// is an image name like "ubuntu:16.04"; for Singularity it can be a path to
+	// an image file or any URI that `singularity exec` understands, eg.
+	// "docker://ubuntu:16.04".
+	Image string
+
+	// Runtime is either "docker" or "singularity" (the default), or the path to
+	// one of those executables (the kind of runtime is determined by the
+	// basename of the path). The runtime must be installed on the machines
+	// your Cmd runs on.
+	Runtime string `json:",omitempty"`
+
+	// Binds are extra host paths you want to be accessible from inside the
+	// container. Each is specified like "/host/path[:/container/path[:opts]]",
+	// where opts are runtime-specific, eg. "ro". The actual working directory,
+	// $TMPDIR and the mount points of any MountConfigs are always bound (at the
+	// same path inside the container), so you don't need to specify those here.
+	Binds []string `json:",omitempty"`
+}
+
+// String provides a JSON representation of the ContainerConfig.
+func (cc *ContainerConfig) String() string {
+	if cc == nil {
+		return ""
+	}
+	b, _ := json.Marshal(cc)
+	return string(b)
+}
+
+// Key returns a string representation of the parts of the config that would
+// make it different from other ContainerConfigs in practical terms of what
+// software and files are accessible: the Image and the Binds.
+func (cc *ContainerConfig) Key() string {
+	if cc == nil {
+		return ""
+	}
+	return cc.Image + ":" + strings.Join(cc.Binds, ",")
+}
+
+// runtime returns the executable to use for the runtime, and the kind of
+// runtime it is (one of the ContainerRuntime* constants).
+func (cc *ContainerConfig) runtime() (exe string, kind string) {
+	exe = cc.Runtime
+	if exe == "" {
+		exe = ContainerRuntimeSingularity
+	}
+	kind = ContainerRuntimeSingularity
+	if filepath.Base(exe) == ContainerRuntimeDocker {
+		kind = ContainerRuntimeDocker
+	}
+	return
+}
+
+// command returns the Path and Args for an exec.Cmd that will run the given
+// argv (eg. shell, "-c", cmdline) in our container, with the working directory
+// dir. The given host paths will be bound in to the container at the same path,
+// along with our Binds. Environment variables in env are passed through to the
+// container. name is a unique name to give the container, which can later be
+// used with pid() and kill().
+func (cc *ContainerConfig) command(argv []string, dir string, paths []string, env []string, name string) (path string, args []string, err error) {
+	if cc.Image == "" {
+		err = fmt.Errorf("no container image was specified")
+		return
+	}
+	exe, kind := cc.runtime()
+	path, err = exec.LookPath(exe)
+	if err != nil {
+		return
+	}
+
+	binds := make([]string, 0, len(paths)+len(cc.Binds))
+	for _, p := range paths {
+		if p != "" {
+			binds = append(binds, p)
+		}
+	}
+	binds = append(binds, cc.Binds...)
+
+	switch kind {
+	case ContainerRuntimeDocker:
+		args = []string{exe, "run", "--rm", "--name", name, "-u", strconv.Itoa(os.Getuid()) + ":" + strconv.Itoa(os.Getgid()), "-w", dir}
+		for _, bind := range binds {
+			if !strings.Contains(bind, ":") {
+				bind = bind + ":" + bind
+			}
+			args = append(args, "-v", bind)
+		}
+
+		// docker doesn't pass through our environment, but will take the value
+		// of named variables from it; we skip PATH since that's specific to
+		// the host and would probably break the container
+		for _, e := range env {
+			parts := strings.SplitN(e, "=", 2)
+			if parts[0] == "" || parts[0] == "PATH" {
+				continue
+			}
+			args = append(args, "-e", parts[0])
+		}
+	default:
+		args = []string{exe, "exec", "--pwd", dir}
+		for _, bind := range binds {
+			args = append(args, "--bind", bind)
+		}
+	}
+
+	args = append(args, cc.Image)
+	args = append(args, argv...)
+	return
+}
+
+// pid returns the pid (in our pid namespace) of the process running inside
+// the container started by the command() with the given name, where runtimePid
+// is the pid of the runtime process we started. Returns 0 if this can't be
+// determined (yet).
+func (cc *ContainerConfig) pid(name string, runtimePid int) int {
+	exe, kind := cc.runtime()
+	if kind != ContainerRuntimeDocker {
+		// singularity runs the command as a descendant of itself
+		return runtimePid
+	}
+
+	// docker runs the command as a child of its daemon, not of the docker
+	// client we started
+	out, err := exec.Command(exe, "inspect", "--format", "{{.State.Pid}}", name).Output()
+	if err != nil {
+		return 0
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(out)))
+	if err != nil {
+		return 0
+	}
+	return pid
+}
+
+// kill kills the container started by the command() with the given name. This
+// is only needed for runtimes where killing the runtime process we started
+// does not kill the container.
+func (cc *ContainerConfig) kill(name string) {
+	exe, kind := cc.runtime()
+	if kind == ContainerRuntimeDocker {
+		exec.Command(exe, "kill", name).Run()
+	}
+}
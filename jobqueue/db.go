@@ -25,11 +25,15 @@ package jobqueue
 
 import (
 	"bytes"
+	"compress/gzip"
+	"encoding/json"
 	"fmt"
 	"github.com/VertebrateResequencing/muxfys"
 	"github.com/VertebrateResequencing/wr/internal"
+	"github.com/VertebrateResequencing/wr/jobqueue/scheduler"
 	"github.com/boltdb/bolt"
 	"github.com/hashicorp/golang-lru"
+	"github.com/satori/go.uuid"
 	"github.com/ugorji/go/codec"
 	"io"
 	"math"
@@ -46,19 +50,38 @@ const (
 	dbDelimiter          = "_::_"
 	jobStatWindowPercent = float32(5)
 	dbFilePermission     = 0600
+	dbBkVersionFormat    = "20060102-150405.000"
 )
 
+// DBBackupVersionMinAge is the minimum time between the timestamped copies of
+// the database backup that are kept when ServerConfig.DBFileBackupKeep is set.
+// Backups made more often than this (eg. after every change to the database)
+// only replace the latest backup, so that the kept copies span a useful amount
+// of time and every backup doesn't cost extra copies.
+var DBBackupVersionMinAge = 1 * time.Hour
+
 var (
 	bucketJobsLive     = []byte("jobslive")
 	bucketJobsComplete = []byte("jobscomplete")
+	bucketJobsRunning  = []byte("jobsrunning")
 	bucketRTK          = []byte("repgroupToKey")
 	bucketDTK          = []byte("depgroupToKey")
 	bucketRDTK         = []byte("reverseDepgroupToKey")
+	bucketPTK          = []byte("parentToKey")
 	bucketEnvs         = []byte("envs")
+	bucketScripts      = []byte("scripts")
 	bucketStdO         = []byte("stdo")
 	bucketStdE         = []byte("stde")
+	bucketSeries       = []byte("resourceSeries")
+	bucketHistory      = []byte("jobHistory")
+	bucketAttempts     = []byte("jobAttempts")
 	bucketJobMBs       = []byte("jobMBs")
 	bucketJobSecs      = []byte("jobSecs")
+	bucketJobDisks     = []byte("jobDisks")
+	bucketJobCores     = []byte("jobCores")
+	bucketEscalations  = []byte("reqGroupEscalations")
+	bucketPins         = []byte("reqGroupPins")
+	bucketExcluded     = []byte("excludedHosts")
 	wipeDevDBOnInit    = true
 	forceBackups       = false
 )
@@ -66,10 +89,63 @@ var (
 // Rec* variables are only exported for testing purposes (*** though they should
 // probably be user configurable somewhere...).
 var (
-	RecMBRound  = 100  // when we recommend amount of memory to reserve for a job, we round up to the nearest RecMBRound MBs
-	RecSecRound = 1800 // when we recommend time to reserve for a job, we round up to the nearest RecSecRound seconds
+	RecMBRound   = 100  // when we recommend amount of memory to reserve for a job, we round up to the nearest RecMBRound MBs
+	RecSecRound  = 1800 // when we recommend time to reserve for a job, we round up to the nearest RecSecRound seconds
+	RecDiskRound = 1024 // when we recommend disk to reserve for a job, we round up to the nearest RecDiskRound MBs (before converting to GBs)
 )
 
+// runningJob holds the properties of a Job that get set when it starts
+// running, for storing in the running bucket.
+type runningJob struct {
+	ReservedBy uuid.UUID
+	ChildToken string
+	Host       string
+	HostID     string
+	HostIP     string
+	Pid        int
+	StartTime  time.Time
+	Attempts   uint32
+}
+
+// replTx wraps a bolt.Tx so that the changes made to its buckets get recorded,
+// for replication to standby servers. Get one by using db.batch() or
+// db.update() instead of the bolt.DB equivalents.
+type replTx struct {
+	*bolt.Tx
+	ops []*dbOp
+}
+
+// Bucket is like bolt.Tx.Bucket(), but the returned bucket records the Put()s
+// and Delete()s made to it.
+func (tx *replTx) Bucket(name []byte) *replBucket {
+	return &replBucket{Bucket: tx.Tx.Bucket(name), name: name, tx: tx}
+}
+
+// replBucket wraps a bolt.Bucket to record changes made to it in its replTx.
+type replBucket struct {
+	*bolt.Bucket
+	name []byte
+	tx   *replTx
+}
+
+// Put is like bolt.Bucket.Put(), but records the change.
+func (b *replBucket) Put(key []byte, value []byte) error {
+	err := b.Bucket.Put(key, value)
+	if err == nil {
+		b.tx.ops = append(b.tx.ops, &dbOp{Bucket: b.name, Key: copyBytes(key), Value: copyBytes(value)})
+	}
+	return err
+}
+
+// Delete is like bolt.Bucket.Delete(), but records the change.
+func (b *replBucket) Delete(key []byte) error {
+	err := b.Bucket.Delete(key)
+	if err == nil {
+		b.tx.ops = append(b.tx.ops, &dbOp{Bucket: b.name, Key: copyBytes(key), Delete: true})
+	}
+	return err
+}
+
 // sobsd ('slice of byte slice doublets') implements sort interface so we can
 // sort a slice of []byte doublets, sorting on the first byte slice, needed for
 // efficient Puts in to the database.
@@ -98,6 +174,12 @@ type db struct {
 	envcache             *lru.ARCCache
 	ch                   codec.Handle
 	updatingAfterJobExit int
+	updatingSeries       int
+	lastHistoryNano      int64
+	historyBuffer        []*bufferedHistory
+	historyMutex         sync.Mutex
+	historyFlushMutex    sync.Mutex
+	stopHistory          chan bool
 	backupsEnabled       bool
 	backupPath           string
 	backupMount          *muxfys.MuxFys
@@ -105,8 +187,14 @@ type db struct {
 	backupQueued         bool
 	backupFinal          bool
 	backupNotification   chan bool
+	backupKeep           int
+	backupInterval       time.Duration
+	backupDue            bool
+	backupErrCB          func(err error)
+	stopBackups          chan bool
 	slowBackups          bool // just for testing purposes
 	closed               bool
+	repl                 *replicationLog
 	sync.RWMutex
 }
 
@@ -118,9 +206,10 @@ type db struct {
 // which will cause that s3 path to be mounted in the same directory as dbFile
 // and backups will be written there.
 //
-// In development we delete any existing db and force a fresh start. Backups
-// are also not carried out, so dbBkFile is ignored.
-func initDB(dbFile string, dbBkFile string, deployment string) (dbstruct *db, msg string, err error) {
+// In development we delete any existing db and force a fresh start (unless
+// keepExisting is true). Backups are also not carried out, so dbBkFile is
+// ignored.
+func initDB(dbFile string, dbBkFile string, deployment string, keepExisting bool) (dbstruct *db, msg string, err error) {
 	var backupsEnabled bool
 	bkPath := dbBkFile
 	var fs *muxfys.MuxFys
@@ -173,7 +262,7 @@ func initDB(dbFile string, dbBkFile string, deployment string) (dbstruct *db, ms
 		}
 	}
 
-	if wipeDevDBOnInit && deployment == internal.Development {
+	if wipeDevDBOnInit && deployment == internal.Development && !keepExisting {
 		os.Remove(dbFile)
 		os.Remove(bkPath)
 	}
@@ -240,10 +329,18 @@ func initDB(dbFile string, dbBkFile string, deployment string) (dbstruct *db, ms
 		if err != nil {
 			return fmt.Errorf("create bucket %s: %s", bucketRDTK, err)
 		}
+		_, err = tx.CreateBucketIfNotExists(bucketPTK)
+		if err != nil {
+			return fmt.Errorf("create bucket %s: %s", bucketPTK, err)
+		}
 		_, err = tx.CreateBucketIfNotExists(bucketEnvs)
 		if err != nil {
 			return fmt.Errorf("create bucket %s: %s", bucketEnvs, err)
 		}
+		_, err = tx.CreateBucketIfNotExists(bucketScripts)
+		if err != nil {
+			return fmt.Errorf("create bucket %s: %s", bucketScripts, err)
+		}
 		_, err = tx.CreateBucketIfNotExists(bucketStdO)
 		if err != nil {
 			return fmt.Errorf("create bucket %s: %s", bucketStdO, err)
@@ -252,6 +349,18 @@ func initDB(dbFile string, dbBkFile string, deployment string) (dbstruct *db, ms
 		if err != nil {
 			return fmt.Errorf("create bucket %s: %s", bucketStdE, err)
 		}
+		_, err = tx.CreateBucketIfNotExists(bucketSeries)
+		if err != nil {
+			return fmt.Errorf("create bucket %s: %s", bucketSeries, err)
+		}
+		_, err = tx.CreateBucketIfNotExists(bucketHistory)
+		if err != nil {
+			return fmt.Errorf("create bucket %s: %s", bucketHistory, err)
+		}
+		_, err = tx.CreateBucketIfNotExists(bucketAttempts)
+		if err != nil {
+			return fmt.Errorf("create bucket %s: %s", bucketAttempts, err)
+		}
 		_, err = tx.CreateBucketIfNotExists(bucketJobMBs)
 		if err != nil {
 			return fmt.Errorf("create bucket %s: %s", bucketJobMBs, err)
@@ -260,6 +369,30 @@ func initDB(dbFile string, dbBkFile string, deployment string) (dbstruct *db, ms
 		if err != nil {
 			return fmt.Errorf("create bucket %s: %s", bucketJobSecs, err)
 		}
+		_, err = tx.CreateBucketIfNotExists(bucketJobDisks)
+		if err != nil {
+			return fmt.Errorf("create bucket %s: %s", bucketJobDisks, err)
+		}
+		_, err = tx.CreateBucketIfNotExists(bucketJobCores)
+		if err != nil {
+			return fmt.Errorf("create bucket %s: %s", bucketJobCores, err)
+		}
+		_, err = tx.CreateBucketIfNotExists(bucketJobsRunning)
+		if err != nil {
+			return fmt.Errorf("create bucket %s: %s", bucketJobsRunning, err)
+		}
+		_, err = tx.CreateBucketIfNotExists(bucketEscalations)
+		if err != nil {
+			return fmt.Errorf("create bucket %s: %s", bucketEscalations, err)
+		}
+		_, err = tx.CreateBucketIfNotExists(bucketPins)
+		if err != nil {
+			return fmt.Errorf("create bucket %s: %s", bucketPins, err)
+		}
+		_, err = tx.CreateBucketIfNotExists(bucketExcluded)
+		if err != nil {
+			return fmt.Errorf("create bucket %s: %s", bucketExcluded, err)
+		}
 		return nil
 	})
 	if err != nil {
@@ -279,10 +412,13 @@ func initDB(dbFile string, dbBkFile string, deployment string) (dbstruct *db, ms
 		backupsEnabled:     backupsEnabled,
 		backupPath:         bkPath,
 		backupNotification: make(chan bool),
+		repl:               newReplicationLog(),
+		stopHistory:        make(chan bool),
 	}
 	if fs != nil {
 		dbstruct.backupMount = fs
 	}
+	go dbstruct.flushJobHistoryPeriodically(dbstruct.stopHistory)
 	return
 }
 
@@ -313,6 +449,7 @@ func (db *db) storeNewJobs(jobs []*Job, ignoreAdded bool) (jobsToQueue []*Job, j
 	var rgLookups sobsd
 	var dgLookups sobsd
 	var rdgLookups sobsd
+	var pLookups sobsd
 	depGroups := make(map[string]bool)
 	newJobKeys := make(map[string]bool)
 	var keptJobs []*Job
@@ -348,6 +485,10 @@ func (db *db) storeNewJobs(jobs []*Job, ignoreAdded bool) (jobsToQueue []*Job, j
 		for _, depGroup := range job.Dependencies.DepGroups() {
 			rdgLookups = append(rdgLookups, [2][]byte{db.generateLookupKey(depGroup, key), nil})
 		}
+
+		if job.Parent != "" {
+			pLookups = append(pLookups, [2][]byte{db.generateLookupKey(job.Parent, key), nil})
+		}
 		job.RUnlock()
 
 		var encoded []byte
@@ -399,6 +540,9 @@ func (db *db) storeNewJobs(jobs []*Job, ignoreAdded bool) (jobsToQueue []*Job, j
 		if len(rdgLookups) > 0 {
 			numStores++
 		}
+		if len(pLookups) > 0 {
+			numStores++
+		}
 		errors := make(chan error, numStores)
 
 		go func() {
@@ -420,6 +564,13 @@ func (db *db) storeNewJobs(jobs []*Job, ignoreAdded bool) (jobsToQueue []*Job, j
 			}()
 		}
 
+		if len(pLookups) > 0 {
+			go func() {
+				sort.Sort(pLookups)
+				errors <- db.storeBatched(bucketPTK, pLookups, db.storeLookups)
+			}()
+		}
+
 		go func() {
 			sort.Sort(encodedJobs)
 			errors <- db.storeBatched(bucketJobsLive, encodedJobs, db.storeEncodedJobs)
@@ -501,7 +652,7 @@ func (db *db) archiveJob(key string, job *Job) (err error) {
 		return
 	}
 
-	err = db.bolt.Batch(func(tx *bolt.Tx) error {
+	err = db.batch(func(tx *replTx) error {
 		b := tx.Bucket(bucketJobsLive)
 		b.Delete([]byte(key))
 
@@ -516,22 +667,103 @@ func (db *db) archiveJob(key string, job *Job) (err error) {
 }
 
 // deleteLiveJob remove a job from the live bucket, for use when jobs were
-// added in error.
+// added in error. The job's lookup entries in the bucket*TK buckets are also
+// removed, unless the job is also in the complete bucket (ie. it was being
+// re-run), in which case they are still needed. We don't care about errors
+// here.
 func (db *db) deleteLiveJob(key string) {
-	db.remove(bucketJobsLive, key)
+	go db.batch(func(tx *replTx) error {
+		b := tx.Bucket(bucketJobsLive)
+		jobKey := []byte(key)
+		encoded := b.Get(jobKey)
+		if encoded == nil {
+			return nil
+		}
+		dec := codec.NewDecoderBytes(encoded, db.ch)
+		job := &Job{}
+		err := dec.Decode(job)
+		b.Delete(jobKey)
+		if err != nil || tx.Bucket(bucketJobsComplete).Get(jobKey) != nil {
+			return nil
+		}
+
+		tx.Bucket(bucketRTK).Delete(db.generateLookupKey(job.RepGroup, jobKey))
+		dtk := tx.Bucket(bucketDTK)
+		for _, depGroup := range job.DepGroups {
+			if depGroup != "" {
+				dtk.Delete(db.generateLookupKey(depGroup, jobKey))
+			}
+		}
+		rdtk := tx.Bucket(bucketRDTK)
+		for _, depGroup := range job.Dependencies.DepGroups() {
+			rdtk.Delete(db.generateLookupKey(depGroup, jobKey))
+		}
+		if job.Parent != "" {
+			tx.Bucket(bucketPTK).Delete(db.generateLookupKey(job.Parent, jobKey))
+		}
+		return nil
+	})
+	db.backgroundBackup()
+}
+
+// storeRunningJob records the properties of the given job that were set when
+// it started running, so that if the server is restarted while its Cmd is still
+// running, recoverIncompleteJobs() can return it in its running state and its
+// runner can carry on with it.
+func (db *db) storeRunningJob(job *Job) (err error) {
+	job.RLock()
+	rj := &runningJob{
+		ReservedBy: job.ReservedBy,
+		ChildToken: job.ChildToken,
+		Host:       job.Host,
+		HostID:     job.HostID,
+		HostIP:     job.HostIP,
+		Pid:        job.Pid,
+		StartTime:  job.StartTime,
+		Attempts:   job.Attempts,
+	}
+	job.RUnlock()
+
+	var encoded []byte
+	enc := codec.NewEncoderBytes(&encoded, db.ch)
+	err = enc.Encode(rj)
+	if err != nil {
+		return
+	}
+
+	err = db.batch(func(tx *replTx) error {
+		return tx.Bucket(bucketJobsRunning).Put([]byte(job.key()), encoded)
+	})
 	db.backgroundBackup()
-	//*** we're not removing the lookup entries from the bucket*TK buckets...
+	return
+}
+
+// deleteRunningJobs removes the records made by storeRunningJob() for the jobs
+// with the given keys, for when those jobs are no longer running. We don't care
+// about errors here.
+func (db *db) deleteRunningJobs(keys []string) {
+	db.batch(func(tx *replTx) error {
+		b := tx.Bucket(bucketJobsRunning)
+		for _, key := range keys {
+			b.Delete([]byte(key))
+		}
+		return nil
+	})
 }
 
 // recoverIncompleteJobs returns all jobs in the live bucket, for use when
 // restarting the server, allowing you start working on any jobs that were
 // stored with storeNewJobs() but not yet archived with archiveJob(). Note that
-// any state changes to the Jobs that may have occurred will be lost: you get
-// back the Jobs exactly as they were when you put them in with storeNewJobs().
+// most state changes to the Jobs that may have occurred will be lost: you get
+// back the Jobs as they were when you put them in with storeNewJobs(). The
+// exception is Jobs that were running (as recorded by storeRunningJob()): these
+// have their ReservedBy, ChildToken, Host, HostID, HostIP, Pid, StartTime and
+// Attempts restored, so you can tell them apart by their ReservedBy being set.
 func (db *db) recoverIncompleteJobs() (jobs []*Job, err error) {
 	err = db.bolt.View(func(tx *bolt.Tx) error {
 		b := tx.Bucket(bucketJobsLive)
-		b.ForEach(func(_, encoded []byte) error {
+		br := tx.Bucket(bucketJobsRunning)
+		b.ForEach(func(key, encoded []byte) error {
 			if encoded != nil {
 				dec := codec.NewDecoderBytes(encoded, db.ch)
 				job := &Job{}
@@ -539,6 +771,22 @@ func (db *db) recoverIncompleteJobs() (jobs []*Job, err error) {
 				if err != nil {
 					return err
 				}
+
+				if encodedRJ := br.Get(key); encodedRJ != nil {
+					dec = codec.NewDecoderBytes(encodedRJ, db.ch)
+					rj := &runningJob{}
+					if dec.Decode(rj) == nil && !uuid.Equal(rj.ReservedBy, uuid.Nil) {
+						job.ReservedBy = rj.ReservedBy
+						job.ChildToken = rj.ChildToken
+						job.Host = rj.Host
+						job.HostID = rj.HostID
+						job.HostIP = rj.HostIP
+						job.Pid = rj.Pid
+						job.StartTime = rj.StartTime
+						job.Attempts = rj.Attempts
+					}
+				}
+
 				jobs = append(jobs, job)
 			}
 			return nil
@@ -551,6 +799,9 @@ func (db *db) recoverIncompleteJobs() (jobs []*Job, err error) {
 // retrieveCompleteJobsByKeys gets jobs with the given keys from the completed
 // jobs bucket (ie. those that have gone through the queue and been Remove()d).
 func (db *db) retrieveCompleteJobsByKeys(keys []string, getstd bool, getenv bool) (jobs []*Job, err error) {
+	if getstd {
+		db.waitForUpdatesAfterJobExit()
+	}
 	err = db.bolt.View(func(tx *bolt.Tx) error {
 		b := tx.Bucket(bucketJobsComplete)
 		for _, key := range keys {
@@ -560,6 +811,13 @@ func (db *db) retrieveCompleteJobsByKeys(keys []string, getstd bool, getenv bool
 				job := &Job{}
 				err = dec.Decode(job)
 				if err == nil {
+					if getstd {
+						job.ResourceSeriesC = append([]byte(nil), tx.Bucket(bucketSeries).Get([]byte(key))...)
+						job.AttemptRecords, err = db.decodeJobAttempts(tx.Bucket(bucketAttempts), key)
+						if err != nil {
+							return err
+						}
+					}
 					jobs = append(jobs, job)
 				}
 			}
@@ -597,6 +855,229 @@ func (db *db) retrieveCompleteJobsByRepGroup(repgroup string) (jobs []*Job, err
 	return
 }
 
+// countCompleteJobsByRepGroup is like retrieveCompleteJobsByRepGroup(), but
+// only tells you how many such jobs there are.
+func (db *db) countCompleteJobsByRepGroup(repgroup string) (count int, err error) {
+	err = db.bolt.View(func(tx *bolt.Tx) error {
+		newJobBucket := tx.Bucket(bucketJobsLive)
+		completeJobBucket := tx.Bucket(bucketJobsComplete)
+		lookupBucket := tx.Bucket(bucketRTK).Cursor()
+		prefix := []byte(repgroup + dbDelimiter)
+		for k, _ := lookupBucket.Seek(prefix); bytes.HasPrefix(k, prefix); k, _ = lookupBucket.Next() {
+			key := bytes.TrimPrefix(k, prefix)
+			if completeJobBucket.Get(key) != nil && newJobBucket.Get(key) == nil {
+				count++
+			}
+		}
+		return nil
+	})
+	return
+}
+
+// addCompleteJobEfficiencies adds the resource usage of the jobs that
+// retrieveCompleteJobsByRepGroup() would return (or of all the jobs that
+// retrieveCompleteJobs() would return, if repgroup is blank) to the given
+// efficiencyGroups. It does this in a single pass through the database,
+// decoding one job at a time, so that the jobs needn't all be held in memory.
+func (db *db) addCompleteJobEfficiencies(groups *efficiencyGroups, repgroup string) error {
+	return db.bolt.View(func(tx *bolt.Tx) error {
+		newJobBucket := tx.Bucket(bucketJobsLive)
+		completeJobBucket := tx.Bucket(bucketJobsComplete)
+		add := func(key, encoded []byte) error {
+			if len(encoded) == 0 || newJobBucket.Get(key) != nil {
+				return nil
+			}
+			dec := codec.NewDecoderBytes(encoded, db.ch)
+			job := &Job{}
+			err := dec.Decode(job)
+			if err != nil {
+				return err
+			}
+			groups.add(job)
+			return nil
+		}
+
+		if repgroup == "" {
+			return completeJobBucket.ForEach(add)
+		}
+		lookupBucket := tx.Bucket(bucketRTK).Cursor()
+		prefix := []byte(repgroup + dbDelimiter)
+		for k, _ := lookupBucket.Seek(prefix); bytes.HasPrefix(k, prefix); k, _ = lookupBucket.Next() {
+			key := bytes.TrimPrefix(k, prefix)
+			err := add(key, completeJobBucket.Get(key))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// retrieveCompleteJobs gets all jobs from the completed jobs bucket, but not
+// those that are also currently live (ie. are being re-run), optionally with
+// their StdOutC, StdErrC, EnvC and ScriptC filled in.
+func (db *db) retrieveCompleteJobs(getStd bool, getEnv bool) (jobs []*Job, err error) {
+	err = db.bolt.View(func(tx *bolt.Tx) error {
+		newJobBucket := tx.Bucket(bucketJobsLive)
+		bo := tx.Bucket(bucketStdO)
+		be := tx.Bucket(bucketStdE)
+		bser := tx.Bucket(bucketSeries)
+		ba := tx.Bucket(bucketAttempts)
+		benv := tx.Bucket(bucketEnvs)
+		bs := tx.Bucket(bucketScripts)
+		return tx.Bucket(bucketJobsComplete).ForEach(func(key, encoded []byte) error {
+			if newJobBucket.Get(key) != nil {
+				return nil
+			}
+			dec := codec.NewDecoderBytes(encoded, db.ch)
+			job := &Job{}
+			errd := dec.Decode(job)
+			if errd != nil {
+				return errd
+			}
+			if getStd {
+				job.StdOutC = append([]byte(nil), bo.Get(key)...)
+				job.StdErrC = append([]byte(nil), be.Get(key)...)
+				job.ResourceSeriesC = append([]byte(nil), bser.Get(key)...)
+				job.AttemptRecords, errd = db.decodeJobAttempts(ba, string(key))
+				if errd != nil {
+					return errd
+				}
+			}
+			if getEnv {
+				job.EnvC = append([]byte(nil), benv.Get([]byte(job.EnvKey))...)
+				if job.ScriptKey != "" {
+					job.ScriptC = append([]byte(nil), bs.Get([]byte(job.ScriptKey))...)
+				}
+			}
+			jobs = append(jobs, job)
+			return nil
+		})
+	})
+	return
+}
+
+// storeCompleteJobs stores jobs directly in the complete bucket, along with
+// their lookups, StdOutC, StdErrC, EnvC and ScriptC, for use when importing
+// jobs that were completed under a different server. Jobs that are already in
+// the live or complete bucket are skipped, and counted in the existed return
+// value.
+func (db *db) storeCompleteJobs(jobs []*Job, queueName string) (stored int, existed int, err error) {
+	err = db.update(func(tx *replTx) error {
+		newJobBucket := tx.Bucket(bucketJobsLive)
+		completeJobBucket := tx.Bucket(bucketJobsComplete)
+		rtk := tx.Bucket(bucketRTK)
+		dtk := tx.Bucket(bucketDTK)
+		rdtk := tx.Bucket(bucketRDTK)
+		ptk := tx.Bucket(bucketPTK)
+		bo := tx.Bucket(bucketStdO)
+		be := tx.Bucket(bucketStdE)
+		bser := tx.Bucket(bucketSeries)
+		ba := tx.Bucket(bucketAttempts)
+		benv := tx.Bucket(bucketEnvs)
+		bs := tx.Bucket(bucketScripts)
+		for _, job := range jobs {
+			key := []byte(job.key())
+			if newJobBucket.Get(key) != nil || completeJobBucket.Get(key) != nil {
+				existed++
+				continue
+			}
+
+			envkey := byteKey(job.EnvC)
+			errp := benv.Put([]byte(envkey), job.EnvC)
+			if errp != nil {
+				return errp
+			}
+			if job.ScriptKey != "" && len(job.ScriptC) > 0 {
+				errp = bs.Put([]byte(job.ScriptKey), job.ScriptC)
+				if errp != nil {
+					return errp
+				}
+			}
+			if len(job.StdOutC) > 0 {
+				errp = bo.Put(key, job.StdOutC)
+				if errp != nil {
+					return errp
+				}
+			}
+			if len(job.StdErrC) > 0 {
+				errp = be.Put(key, job.StdErrC)
+				if errp != nil {
+					return errp
+				}
+			}
+			if len(job.ResourceSeriesC) > 0 {
+				errp = bser.Put(key, job.ResourceSeriesC)
+				if errp != nil {
+					return errp
+				}
+			}
+			for _, attempt := range job.AttemptRecords {
+				errp = db.encodeJobAttempt(ba, job.key(), attempt)
+				if errp != nil {
+					return errp
+				}
+			}
+
+			errp = rtk.Put(db.generateLookupKey(job.RepGroup, key), nil)
+			if errp != nil {
+				return errp
+			}
+			for _, depGroup := range job.DepGroups {
+				if depGroup != "" {
+					errp = dtk.Put(db.generateLookupKey(depGroup, key), nil)
+					if errp != nil {
+						return errp
+					}
+				}
+			}
+			for _, depGroup := range job.Dependencies.DepGroups() {
+				errp = rdtk.Put(db.generateLookupKey(depGroup, key), nil)
+				if errp != nil {
+					return errp
+				}
+			}
+			if job.Parent != "" {
+				errp = ptk.Put(db.generateLookupKey(job.Parent, key), nil)
+				if errp != nil {
+					return errp
+				}
+			}
+
+			job.EnvKey = envkey
+			job.EnvC = nil
+			job.ScriptC = nil
+			job.StdOutC = nil
+			job.StdErrC = nil
+			job.ResourceSeriesC = nil
+			job.AttemptRecords = nil
+			job.Queue = queueName
+			var encoded []byte
+			enc := codec.NewEncoderBytes(&encoded, db.ch)
+			errp = enc.Encode(job)
+			if errp != nil {
+				return errp
+			}
+			errp = completeJobBucket.Put(key, encoded)
+			if errp != nil {
+				return errp
+			}
+			stored++
+		}
+		return nil
+	})
+	if err != nil {
+		stored = 0
+		existed = 0
+		return
+	}
+
+	if stored > 0 {
+		db.backgroundBackup()
+	}
+	return
+}
+
 // retrieveDependentJobs gets previously stored jobs that had a dependency on
 // one for the input depGroups. If the job is found in the live bucket, then it
 // is returned in the jobsToUpdate return value. If it is found in the complete
@@ -678,6 +1159,20 @@ func (db *db) retrieveDependentJobs(depGroups map[string]bool, newJobKeys map[st
 	return
 }
 
+// retrieveChildKeys gets the keys of the jobs (live or complete) that were
+// added as children of the job with the given key.
+func (db *db) retrieveChildKeys(parentKey string) (jobKeys []string, err error) {
+	err = db.bolt.View(func(tx *bolt.Tx) error {
+		lookupBucket := tx.Bucket(bucketPTK).Cursor()
+		prefix := []byte(parentKey + dbDelimiter)
+		for k, _ := lookupBucket.Seek(prefix); bytes.HasPrefix(k, prefix); k, _ = lookupBucket.Next() {
+			jobKeys = append(jobKeys, string(bytes.TrimPrefix(k, prefix)))
+		}
+		return nil
+	})
+	return
+}
+
 // retrieveIncompleteJobKeysByDepGroup gets jobs with the given RepGroup from
 // the live bucket (ie. those that have been added to the queue and not yet
 // Archive()d - even if they've been added and archived in the past).
@@ -738,8 +1233,121 @@ func (db *db) retrieveEnv(envkey string) (envc []byte) {
 	return
 }
 
-// updateJobAfterExit stores the Job's peak RAM usage and wall time against the
-// Job's ReqGroup, allowing recommendedReqGroup*(ReqGroup) to work. It also
+// storeScript stores a Job's ScriptC in db under its ScriptKey.
+func (db *db) storeScript(scriptkey string, script []byte) error {
+	return db.store(bucketScripts, scriptkey, script)
+}
+
+// retrieveScript gets a value from the db that was stored with storeScript().
+func (db *db) retrieveScript(scriptkey string) []byte {
+	return db.retrieve(bucketScripts, scriptkey)
+}
+
+// storeReqGroupEscalation stores the Escalation that should apply to new Jobs
+// in the given ReqGroup. A nil esc removes any previously stored Escalation.
+func (db *db) storeReqGroupEscalation(reqGroup string, esc *Escalation) error {
+	if esc == nil {
+		return db.batch(func(tx *replTx) error {
+			return tx.Bucket(bucketEscalations).Delete([]byte(reqGroup))
+		})
+	}
+	var encoded []byte
+	enc := codec.NewEncoderBytes(&encoded, db.ch)
+	err := enc.Encode(esc)
+	if err != nil {
+		return err
+	}
+	return db.store(bucketEscalations, reqGroup, encoded)
+}
+
+// retrieveReqGroupEscalation gets the Escalation stored with
+// storeReqGroupEscalation() for the given ReqGroup, returning nil if there
+// isn't one.
+func (db *db) retrieveReqGroupEscalation(reqGroup string) (esc *Escalation) {
+	encoded := db.retrieve(bucketEscalations, reqGroup)
+	if encoded == nil {
+		return
+	}
+	dec := codec.NewDecoderBytes(encoded, db.ch)
+	err := dec.Decode(&esc)
+	if err != nil {
+		return nil
+	}
+	return
+}
+
+// storeReqGroupPin stores fixed recommendations for the Jobs in the given
+// ReqGroup. A nil pin removes any previously stored ReqGroupPin.
+func (db *db) storeReqGroupPin(reqGroup string, pin *ReqGroupPin) error {
+	if pin == nil {
+		return db.batch(func(tx *replTx) error {
+			return tx.Bucket(bucketPins).Delete([]byte(reqGroup))
+		})
+	}
+	var encoded []byte
+	enc := codec.NewEncoderBytes(&encoded, db.ch)
+	err := enc.Encode(pin)
+	if err != nil {
+		return err
+	}
+	return db.store(bucketPins, reqGroup, encoded)
+}
+
+// retrieveReqGroupPin gets the ReqGroupPin stored with storeReqGroupPin() for
+// the given ReqGroup, returning nil if there isn't one.
+func (db *db) retrieveReqGroupPin(reqGroup string) (pin *ReqGroupPin) {
+	encoded := db.retrieve(bucketPins, reqGroup)
+	if encoded == nil {
+		return
+	}
+	dec := codec.NewDecoderBytes(encoded, db.ch)
+	err := dec.Decode(&pin)
+	if err != nil {
+		return nil
+	}
+	return
+}
+
+// storeExcludedHost stores the details of a host that runners are no longer
+// being scheduled on, so that it stays excluded if we're restarted. A nil eh
+// removes any previously stored exclusion of the host.
+func (db *db) storeExcludedHost(host string, eh *ExcludedHost) error {
+	if eh == nil {
+		return db.batch(func(tx *replTx) error {
+			return tx.Bucket(bucketExcluded).Delete([]byte(host))
+		})
+	}
+	var encoded []byte
+	enc := codec.NewEncoderBytes(&encoded, db.ch)
+	err := enc.Encode(eh)
+	if err != nil {
+		return err
+	}
+	return db.store(bucketExcluded, host, encoded)
+}
+
+// retrieveExcludedHosts gets all the host exclusions stored with
+// storeExcludedHost().
+func (db *db) retrieveExcludedHosts() (hosts []*ExcludedHost, err error) {
+	err = db.bolt.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketExcluded).ForEach(func(_, encoded []byte) error {
+			dec := codec.NewDecoderBytes(encoded, db.ch)
+			eh := &ExcludedHost{}
+			errd := dec.Decode(eh)
+			if errd != nil {
+				return errd
+			}
+			hosts = append(hosts, eh)
+			return nil
+		})
+	})
+	return
+}
+
+// updateJobAfterExit stores the Job's peak RAM usage, wall time, peak disk
+// usage (if it was measured) and CPU parallelism (as a percentage of a core,
+// if it could be measured) against the Job's ReqGroup, allowing
+// recommendedReqGroup*(ReqGroup) to work. It also
 // updates the stdout/err associated with a job. We don't want to store these in
 // the job, since that would waste a lot of the queue's memory; we store in db
 // instead, and only retrieve when a client needs to see these. To stop the db
@@ -757,12 +1365,28 @@ func (db *db) updateJobAfterExit(job *Job, stdo []byte, stde []byte, forceStorag
 	jrg := job.ReqGroup
 	jpm := job.PeakRAM
 	jec := job.Exitcode
+	jpd := -1
+	if !job.CwdMatters {
+		jpd = job.PeakDisk
+	}
+	jcp := -1
+	if secs > 0 {
+		// (the CPU time of docker containers isn't attributed to the docker
+		// client we run, so we can't learn from them)
+		kind := ""
+		if job.Container != nil {
+			_, kind = job.Container.runtime()
+		}
+		if kind != ContainerRuntimeDocker {
+			jcp = int(math.Ceil(job.CPUtime.Seconds() / job.EndTime.Sub(job.StartTime).Seconds() * 100))
+		}
+	}
 	job.RUnlock()
 	go func() {
 		db.Lock()
 		db.updatingAfterJobExit++
 		db.Unlock()
-		db.bolt.Batch(func(tx *bolt.Tx) error {
+		db.batch(func(tx *replTx) error {
 			bo := tx.Bucket(bucketStdO)
 			be := tx.Bucket(bucketStdE)
 			key := []byte(jobkey)
@@ -789,19 +1413,32 @@ func (db *db) updateJobAfterExit(job *Job, stdo []byte, stde []byte, forceStorag
 			}
 			b = tx.Bucket(bucketJobSecs)
 			err = b.Put([]byte(fmt.Sprintf("%s%s%20d", jrg, dbDelimiter, secs)), []byte(strconv.Itoa(secs)))
-
-			return err
-		})
+			if err != nil {
+				return err
+			}
+			if jpd >= 0 {
+				b = tx.Bucket(bucketJobDisks)
+				err = b.Put([]byte(fmt.Sprintf("%s%s%20d", jrg, dbDelimiter, jpd)), []byte(strconv.Itoa(jpd)))
+				if err != nil {
+					return err
+				}
+			}
+			if jcp >= 0 {
+				b = tx.Bucket(bucketJobCores)
+				err = b.Put([]byte(fmt.Sprintf("%s%s%20d", jrg, dbDelimiter, jcp)), []byte(strconv.Itoa(jcp)))
+			}
+
+			return err
+		})
 		db.Lock()
 		db.updatingAfterJobExit--
 		db.Unlock()
 	}()
 }
 
-// retrieveJobStd gets the values that were stored using updateJobStd() for the
-// given job.
-func (db *db) retrieveJobStd(jobkey string) (stdo []byte, stde []byte) {
-	// first wait for any existing updateJobAfterExit() calls to complete
+// waitForUpdatesAfterJobExit waits for any existing updateJobAfterExit() and
+// storeJobAttempt() calls to complete.
+func (db *db) waitForUpdatesAfterJobExit() {
 	//*** this method of waiting seems really bad and should be improved, but in
 	//    practice we probably never wait
 	for {
@@ -813,6 +1450,12 @@ func (db *db) retrieveJobStd(jobkey string) (stdo []byte, stde []byte) {
 		db.RUnlock()
 		<-time.After(10 * time.Millisecond)
 	}
+}
+
+// retrieveJobStd gets the values that were stored using updateJobStd() for the
+// given job.
+func (db *db) retrieveJobStd(jobkey string) (stdo []byte, stde []byte) {
+	db.waitForUpdatesAfterJobExit()
 
 	db.bolt.View(func(tx *bolt.Tx) error {
 		bo := tx.Bucket(bucketStdO)
@@ -833,6 +1476,236 @@ func (db *db) retrieveJobStd(jobkey string) (stdo []byte, stde []byte) {
 	return
 }
 
+// updateJobResourceSeries stores the (compressed) series of resource usage
+// measurements sent by a client running the given job, replacing any previously
+// stored. Like updateJobAfterExit(), it does this in a goroutine, ignoring
+// errors.
+func (db *db) updateJobResourceSeries(jobkey string, series []byte) {
+	db.Lock()
+	db.updatingSeries++
+	db.Unlock()
+	go func() {
+		db.batch(func(tx *replTx) error {
+			return tx.Bucket(bucketSeries).Put([]byte(jobkey), series)
+		})
+		db.Lock()
+		db.updatingSeries--
+		db.Unlock()
+	}()
+}
+
+// retrieveJobResourceSeries gets the value stored using
+// updateJobResourceSeries() for the given job. Unlike the other retrieveJob*()
+// methods, it only waits for outstanding updateJobResourceSeries() calls, which
+// are frequent while jobs are running, and are counted separately from
+// waitForUpdatesAfterJobExit() so as not to hold those other methods up.
+func (db *db) retrieveJobResourceSeries(jobkey string) []byte {
+	for {
+		db.RLock()
+		if db.updatingSeries == 0 {
+			db.RUnlock()
+			break
+		}
+		db.RUnlock()
+		<-time.After(10 * time.Millisecond)
+	}
+	return db.retrieve(bucketSeries, jobkey)
+}
+
+// storeJobHistory adds the given events to the histories of their jobs. So
+// that recording state changes is cheap, the events are only buffered here;
+// they are written to the database every JobHistoryFlushInterval, and
+// retrieveJobHistory() writes any outstanding ones before it reads.
+func (db *db) storeJobHistory(entries []*jobHistoryEntry) {
+	if len(entries) == 0 {
+		return
+	}
+
+	// our keys are the job key followed by the time of the event, which must
+	// be unique for the job, so we make sure times always increase
+	db.historyMutex.Lock()
+	defer db.historyMutex.Unlock()
+	for _, entry := range entries {
+		nano := entry.event.Time.UnixNano()
+		if nano <= db.lastHistoryNano {
+			nano = db.lastHistoryNano + 1
+		}
+		db.lastHistoryNano = nano
+		db.historyBuffer = append(db.historyBuffer, &bufferedHistory{
+			key:   []byte(fmt.Sprintf("%s%s%019d", entry.key, dbDelimiter, nano)),
+			event: entry.event,
+		})
+	}
+}
+
+// flushJobHistory writes the events buffered by storeJobHistory() to the
+// database, returning once they have been written (including those being
+// written by a concurrent call).
+func (db *db) flushJobHistory() error {
+	db.historyFlushMutex.Lock()
+	defer db.historyFlushMutex.Unlock()
+	db.historyMutex.Lock()
+	buffered := db.historyBuffer
+	db.historyBuffer = nil
+	db.historyMutex.Unlock()
+	if len(buffered) == 0 {
+		return nil
+	}
+
+	return db.update(func(tx *replTx) error {
+		b := tx.Bucket(bucketHistory)
+		for _, bh := range buffered {
+			var encoded []byte
+			enc := codec.NewEncoderBytes(&encoded, db.ch)
+			err := enc.Encode(bh.event)
+			if err != nil {
+				return err
+			}
+			err = b.Put(bh.key, encoded)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// flushJobHistoryPeriodically calls flushJobHistory() every
+// JobHistoryFlushInterval until stop is closed.
+func (db *db) flushJobHistoryPeriodically(stop chan bool) {
+	ticker := time.NewTicker(JobHistoryFlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			db.flushJobHistory()
+		case <-stop:
+			return
+		}
+	}
+}
+
+// retrieveJobHistory gets the events stored using storeJobHistory() for the
+// given job, oldest first.
+func (db *db) retrieveJobHistory(jobkey string) (events []*JobHistoryEvent, err error) {
+	err = db.flushJobHistory()
+	if err != nil {
+		return
+	}
+	err = db.bolt.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(bucketHistory).Cursor()
+		prefix := []byte(jobkey + dbDelimiter)
+		for k, v := c.Seek(prefix); bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			dec := codec.NewDecoderBytes(v, db.ch)
+			event := &JobHistoryEvent{}
+			errd := dec.Decode(event)
+			if errd != nil {
+				return errd
+			}
+			events = append(events, event)
+		}
+		return nil
+	})
+	return
+}
+
+// storeJobAttempt stores the given details of an attempt at running the given
+// job's Cmd, replacing any previously stored details of the same attempt. Like
+// updateJobAfterExit(), it does this in the background, ignoring errors.
+//
+// Since a job's own count of attempts starts again if it is re-added after
+// completing, the attempt is renumbered to follow on from those already stored
+// for the job (see nextJobAttempt()).
+func (db *db) storeJobAttempt(jobkey string, attempt *JobAttempt) {
+	if attempt == nil {
+		return
+	}
+	db.Lock()
+	db.updatingAfterJobExit++
+	db.Unlock()
+	go func() {
+		db.batch(func(tx *replTx) error {
+			b := tx.Bucket(bucketAttempts)
+			num, err := db.nextJobAttempt(b.Bucket, jobkey, attempt)
+			if err != nil {
+				return err
+			}
+			attempt.Attempt = num
+			return db.encodeJobAttempt(b, jobkey, attempt)
+		})
+		db.Lock()
+		db.updatingAfterJobExit--
+		db.Unlock()
+	}()
+}
+
+// encodeJobAttempt puts the given attempt in the given bucket, keyed on the
+// job key followed by the attempt number.
+func (db *db) encodeJobAttempt(b *replBucket, jobkey string, attempt *JobAttempt) error {
+	var encoded []byte
+	enc := codec.NewEncoderBytes(&encoded, db.ch)
+	err := enc.Encode(attempt)
+	if err != nil {
+		return err
+	}
+	return b.Put([]byte(fmt.Sprintf("%s%s%010d", jobkey, dbDelimiter, attempt.Attempt)), encoded)
+}
+
+// nextJobAttempt returns the number the given attempt of the given job should
+// be stored as: one more than the highest number already stored in the given
+// bucket for the job, unless that highest stored attempt started at the same
+// time as this one, in which case it's the same attempt and we reuse its
+// number.
+func (db *db) nextJobAttempt(b *bolt.Bucket, jobkey string, attempt *JobAttempt) (uint32, error) {
+	var last []byte
+	c := b.Cursor()
+	prefix := []byte(jobkey + dbDelimiter)
+	for k, v := c.Seek(prefix); bytes.HasPrefix(k, prefix); k, v = c.Next() {
+		last = v
+	}
+	if last == nil {
+		return 1, nil
+	}
+	prev := &JobAttempt{}
+	dec := codec.NewDecoderBytes(last, db.ch)
+	err := dec.Decode(prev)
+	if err != nil {
+		return 0, err
+	}
+	if prev.StartTime.Equal(attempt.StartTime) {
+		return prev.Attempt, nil
+	}
+	return prev.Attempt + 1, nil
+}
+
+// retrieveJobAttempts gets the attempts stored using storeJobAttempt() for the
+// given job, oldest first.
+func (db *db) retrieveJobAttempts(jobkey string) (attempts []*JobAttempt, err error) {
+	db.waitForUpdatesAfterJobExit()
+	err = db.bolt.View(func(tx *bolt.Tx) error {
+		var errd error
+		attempts, errd = db.decodeJobAttempts(tx.Bucket(bucketAttempts), jobkey)
+		return errd
+	})
+	return
+}
+
+// decodeJobAttempts gets the attempts of the given job from the given bucket.
+func (db *db) decodeJobAttempts(b *bolt.Bucket, jobkey string) (attempts []*JobAttempt, err error) {
+	c := b.Cursor()
+	prefix := []byte(jobkey + dbDelimiter)
+	for k, v := c.Seek(prefix); bytes.HasPrefix(k, prefix); k, v = c.Next() {
+		dec := codec.NewDecoderBytes(v, db.ch)
+		attempt := &JobAttempt{}
+		err = dec.Decode(attempt)
+		if err != nil {
+			return
+		}
+		attempts = append(attempts, attempt)
+	}
+	return
+}
+
 // recommendedReqGroupMemory returns the 95th percentile peak memory usage of
 // all jobs that previously ran with the given reqGroup. If there are too few
 // prior values to calculate a 95th percentile, or if the 95th percentile is
@@ -855,6 +1728,191 @@ func (db *db) recommendedReqGroupTime(reqGroup string) (seconds int, err error)
 	return
 }
 
+// recommendedReqGroupDisk returns the 95th percentile peak disk usage of all
+// jobs that previously ran with the given reqGroup (and did not have
+// CwdMatters), like recommendedReqGroupMemory(), but rounded up to the nearest
+// RecDiskRound MB and returned in GB. Returns 0 if there are no prior values,
+// or if the jobs used less than 1GB, since such small amounts aren't worth
+// checking for.
+func (db *db) recommendedReqGroupDisk(reqGroup string) (gbs int, err error) {
+	mbs, err := db.recommendedReqGroupStat(bucketJobDisks, reqGroup, 1)
+	if err != nil || mbs < 1024 {
+		return
+	}
+	if mbs%RecDiskRound > 0 {
+		mbs = int(math.Ceil(float64(mbs)/float64(RecDiskRound))) * RecDiskRound
+	}
+	gbs = int(math.Ceil(float64(mbs) / 1024))
+	return
+}
+
+// recommendedReqGroupCores returns the 95th percentile CPU parallelism (CPU
+// time divided by wall time) of all jobs that previously ran with the given
+// reqGroup, like recommendedReqGroupMemory(), but rounded up to a whole number
+// of cores. Returns 0 if there are no prior values.
+func (db *db) recommendedReqGroupCores(reqGroup string) (cores int, err error) {
+	percent, err := db.recommendedReqGroupStat(bucketJobCores, reqGroup, 100)
+	if err != nil {
+		return
+	}
+	cores = percent / 100
+	return
+}
+
+// recommendedReqGroupRequirements returns the memory, time, disk and cores
+// that the Jobs in the given ReqGroup should use, based on the
+// recommendedReqGroup*() of prior Jobs, overridden by any ReqGroupPin. Memory
+// and time are only learned together, and disk and cores only alongside them.
+// Zero values mean there is no recommendation for that resource; returns nil
+// if there are no recommendations at all.
+func (db *db) recommendedReqGroupRequirements(reqGroup string) *scheduler.Requirements {
+	req := &scheduler.Requirements{}
+	recm, _ := db.recommendedReqGroupMemory(reqGroup)
+	recs, _ := db.recommendedReqGroupTime(reqGroup)
+	if recm > 0 && recs > 0 {
+		req.RAM = recm
+		req.Time = time.Duration(recs) * time.Second
+		req.Disk, _ = db.recommendedReqGroupDisk(reqGroup)
+		req.Cores, _ = db.recommendedReqGroupCores(reqGroup)
+	}
+
+	if pin := db.retrieveReqGroupPin(reqGroup); pin != nil {
+		if pin.RAM > 0 {
+			req.RAM = pin.RAM
+		}
+		if pin.Time > 0 {
+			req.Time = pin.Time
+		}
+		if pin.Disk > 0 {
+			req.Disk = pin.Disk
+		}
+		if pin.Cores > 0 {
+			req.Cores = pin.Cores
+		}
+	}
+
+	if req.RAM == 0 && req.Time == 0 && req.Disk == 0 && req.Cores == 0 {
+		return nil
+	}
+	return req
+}
+
+// reqGroups returns the names of all the ReqGroups that have learned samples,
+// a ReqGroupPin or an Escalation, sorted.
+func (db *db) reqGroups() (reqGroups []string, err error) {
+	unique := make(map[string]bool)
+	err = db.bolt.View(func(tx *bolt.Tx) error {
+		for _, bucket := range [][]byte{bucketJobMBs, bucketJobSecs, bucketJobDisks, bucketJobCores} {
+			c := tx.Bucket(bucket).Cursor()
+			for k, _ := c.First(); k != nil; {
+				parts := strings.SplitN(string(k), dbDelimiter, 2)
+				unique[parts[0]] = true
+
+				// skip over the rest of this ReqGroup's samples
+				k, _ = c.Seek([]byte(parts[0] + dbDelimiter + "\xff"))
+			}
+		}
+		for _, bucket := range [][]byte{bucketPins, bucketEscalations} {
+			tx.Bucket(bucket).ForEach(func(k, v []byte) error {
+				unique[string(k)] = true
+				return nil
+			})
+		}
+		return nil
+	})
+	for reqGroup := range unique {
+		reqGroups = append(reqGroups, reqGroup)
+	}
+	sort.Strings(reqGroups)
+	return
+}
+
+// reqGroupSamples returns the (sorted) sample values stored in the given stat
+// bucket for the given ReqGroup.
+func (db *db) reqGroupSamples(statBucket []byte, reqGroup string) (samples []int, err error) {
+	prefix := []byte(reqGroup + dbDelimiter)
+	err = db.bolt.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(statBucket).Cursor()
+		for k, v := c.Seek(prefix); bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			sample, errc := strconv.Atoi(string(v))
+			if errc != nil {
+				continue
+			}
+			samples = append(samples, sample)
+		}
+		return nil
+	})
+	return
+}
+
+// reqGroupStats returns a summary of what has been learned about the given
+// ReqGroup.
+func (db *db) reqGroupStats(reqGroup string) (stats *ReqGroupStats, err error) {
+	stats = &ReqGroupStats{
+		ReqGroup:   reqGroup,
+		Pin:        db.retrieveReqGroupPin(reqGroup),
+		Escalation: db.retrieveReqGroupEscalation(reqGroup),
+	}
+
+	kinds := []struct {
+		bucket    []byte
+		recommend func(string) (int, error)
+		stat      **ReqGroupStat
+	}{
+		{bucketJobMBs, db.recommendedReqGroupMemory, &stats.Memory},
+		{bucketJobSecs, db.recommendedReqGroupTime, &stats.Time},
+		{bucketJobDisks, db.recommendedReqGroupDisk, &stats.Disk},
+		{bucketJobCores, db.recommendedReqGroupCores, &stats.Cores},
+	}
+	for _, kind := range kinds {
+		var samples []int
+		samples, err = db.reqGroupSamples(kind.bucket, reqGroup)
+		if err != nil {
+			return
+		}
+		var rec int
+		rec, err = kind.recommend(reqGroup)
+		if err != nil {
+			return
+		}
+		*kind.stat = newReqGroupStat(samples, rec)
+	}
+	return
+}
+
+// trimReqGroup deletes the samples stored for the given ReqGroup in the given
+// stat buckets that are less than min or greater than max (a max of 0 means
+// no maximum), returning the number deleted. To delete all of them, supply a
+// min of -1 and max of -1.
+func (db *db) trimReqGroup(reqGroup string, statBuckets [][]byte, min int, max int) (removed int, err error) {
+	prefix := []byte(reqGroup + dbDelimiter)
+	err = db.update(func(tx *replTx) error {
+		for _, bucket := range statBuckets {
+			b := tx.Bucket(bucket)
+			var toDelete [][]byte
+			c := b.Cursor()
+			for k, v := c.Seek(prefix); bytes.HasPrefix(k, prefix); k, v = c.Next() {
+				sample, errc := strconv.Atoi(string(v))
+				if errc == nil && !(min == -1 && max == -1) && sample >= min && (max == 0 || sample <= max) {
+					continue
+				}
+				key := make([]byte, len(k))
+				copy(key, k)
+				toDelete = append(toDelete, key)
+			}
+			for _, key := range toDelete {
+				errd := b.Delete(key)
+				if errd != nil {
+					return errd
+				}
+				removed++
+			}
+		}
+		return nil
+	})
+	return
+}
+
 // recommendedReqGroupStat is the implementation for the other recommend*()
 // methods.
 func (db *db) recommendedReqGroupStat(statBucket []byte, reqGroup string, roundAmount int) (recommendation int, err error) {
@@ -908,9 +1966,42 @@ func (db *db) recommendedReqGroupStat(statBucket []byte, reqGroup string, roundA
 	return
 }
 
+// batch is like bolt.DB.Batch(), but the changes fn makes are recorded once
+// committed, so that they can be replicated to standby servers.
+func (db *db) batch(fn func(*replTx) error) error {
+	return db.bolt.Batch(db.recorded(fn))
+}
+
+// update is like bolt.DB.Update(), but the changes fn makes are recorded once
+// committed, so that they can be replicated to standby servers.
+func (db *db) update(fn func(*replTx) error) error {
+	return db.bolt.Update(db.recorded(fn))
+}
+
+// recorded turns fn in to a function suitable for bolt.DB.Update() or Batch()
+// that records the changes fn made in our replication log, which will publish
+// them if the transaction gets committed.
+func (db *db) recorded(fn func(*replTx) error) func(*bolt.Tx) error {
+	return func(tx *bolt.Tx) error {
+		// (we can't call tx.ID() once it has been committed)
+		txid := tx.ID()
+		if db.repl.begin(tx, txid) {
+			tx.OnCommit(func() {
+				db.repl.commit(tx, txid)
+			})
+		}
+		rtx := &replTx{Tx: tx}
+		err := fn(rtx)
+		if err == nil {
+			db.repl.record(tx, txid, rtx.ops)
+		}
+		return err
+	}
+}
+
 // store does a basic set of a key/val in a given bucket
 func (db *db) store(bucket []byte, key string, val []byte) (err error) {
-	err = db.bolt.Batch(func(tx *bolt.Tx) error {
+	err = db.batch(func(tx *replTx) error {
 		b := tx.Bucket(bucket)
 		err := b.Put([]byte(key), val)
 		return err
@@ -936,7 +2027,7 @@ func (db *db) retrieve(bucket []byte, key string) (val []byte) {
 // remove does a basic delete of a key from a given bucket. We don't care about
 // errors here.
 func (db *db) remove(bucket []byte, key string) {
-	go db.bolt.Batch(func(tx *bolt.Tx) error {
+	go db.batch(func(tx *replTx) error {
 		b := tx.Bucket(bucket)
 		b.Delete([]byte(key))
 		return nil
@@ -988,7 +2079,7 @@ func (db *db) storeBatched(bucket []byte, data sobsd, storer sobsdStorer) (err e
 // storeLookups is a sobsdStorer for storing Job.[somevalue]->Job.Key() lookups
 // in the db.
 func (db *db) storeLookups(bucket []byte, lookups sobsd) (err error) {
-	err = db.bolt.Batch(func(tx *bolt.Tx) error {
+	err = db.batch(func(tx *replTx) error {
 		lookup := tx.Bucket(bucket)
 		for _, doublet := range lookups {
 			err = lookup.Put(doublet[0], nil)
@@ -1003,7 +2094,7 @@ func (db *db) storeLookups(bucket []byte, lookups sobsd) (err error) {
 
 // storeEncodedJobs is a sobsdStorer for storing Jobs in the db.
 func (db *db) storeEncodedJobs(bucket []byte, encodes sobsd) (err error) {
-	err = db.bolt.Batch(func(tx *bolt.Tx) error {
+	err = db.batch(func(tx *replTx) error {
 		bjobs := tx.Bucket(bucket)
 		for _, doublet := range encodes {
 			err := bjobs.Put(doublet[0], doublet[1])
@@ -1016,6 +2107,403 @@ func (db *db) storeEncodedJobs(bucket []byte, encodes sobsd) (err error) {
 	return
 }
 
+// pruneCompleteJobs removes old jobs from the complete bucket, along with their
+// stored std, first writing them (with their std and env) as gzipped JSON
+// lines to a new file at exportPath. Jobs that ended more than maxAge ago are
+// pruned, as are all but the keepPerRepGroup most recently ended jobs of each
+// RepGroup; a value of 0 for either turns off that kind of pruning. Complete
+// jobs that are currently being re-run are never pruned. Afterwards,
+// removeDangling() is called, passing through orphans. Returns the number of
+// jobs pruned; if 0, no export file is created.
+func (db *db) pruneCompleteJobs(maxAge time.Duration, keepPerRepGroup int, exportPath string, orphans bool) (pruned int, err error) {
+	if maxAge <= 0 && keepPerRepGroup <= 0 {
+		return
+	}
+
+	// first work out which jobs should be pruned
+	type jobEnd struct {
+		key     string
+		endTime time.Time
+	}
+	cutoff := time.Now().Add(-maxAge)
+	byRepGroup := make(map[string][]jobEnd)
+	var toPrune []string
+	err = db.bolt.View(func(tx *bolt.Tx) error {
+		newJobBucket := tx.Bucket(bucketJobsLive)
+		return tx.Bucket(bucketJobsComplete).ForEach(func(k, encoded []byte) error {
+			if newJobBucket.Get(k) != nil {
+				return nil
+			}
+			dec := codec.NewDecoderBytes(encoded, db.ch)
+			job := &Job{}
+			errd := dec.Decode(job)
+			if errd != nil {
+				return errd
+			}
+			if maxAge > 0 && job.EndTime.Before(cutoff) {
+				toPrune = append(toPrune, string(k))
+			} else if keepPerRepGroup > 0 {
+				byRepGroup[job.RepGroup] = append(byRepGroup[job.RepGroup], jobEnd{string(k), job.EndTime})
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return
+	}
+	for _, jes := range byRepGroup {
+		if len(jes) <= keepPerRepGroup {
+			continue
+		}
+		sort.Slice(jes, func(i, j int) bool {
+			return jes[i].endTime.After(jes[j].endTime)
+		})
+		for _, je := range jes[keepPerRepGroup:] {
+			toPrune = append(toPrune, je.key)
+		}
+	}
+	if len(toPrune) == 0 {
+		err = db.removeDangling(orphans)
+		return
+	}
+	sort.Strings(toPrune)
+
+	// archive them before deletion
+	err = db.exportCompleteJobs(toPrune, exportPath)
+	if err != nil {
+		return
+	}
+
+	// now delete them in batches
+	for start := 0; start < len(toPrune); start += 1000 {
+		end := start + 1000
+		if end > len(toPrune) {
+			end = len(toPrune)
+		}
+		err = db.update(func(tx *replTx) error {
+			newJobBucket := tx.Bucket(bucketJobsLive)
+			bc := tx.Bucket(bucketJobsComplete)
+			bo := tx.Bucket(bucketStdO)
+			be := tx.Bucket(bucketStdE)
+			bser := tx.Bucket(bucketSeries)
+			bh := tx.Bucket(bucketHistory)
+			ba := tx.Bucket(bucketAttempts)
+			for _, keyStr := range toPrune[start:end] {
+				key := []byte(keyStr)
+				if newJobBucket.Get(key) != nil {
+					// got re-added since we checked
+					continue
+				}
+				errd := bc.Delete(key)
+				if errd != nil {
+					return errd
+				}
+				bo.Delete(key)
+				be.Delete(key)
+				bser.Delete(key)
+				prefix := []byte(keyStr + dbDelimiter)
+				for _, b := range []*replBucket{bh, ba} {
+					var prefixed [][]byte
+					c := b.Cursor()
+					for k, _ := c.Seek(prefix); bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+						prefixed = append(prefixed, append([]byte(nil), k...))
+					}
+					for _, k := range prefixed {
+						b.Delete(k)
+					}
+				}
+				pruned++
+			}
+			return nil
+		})
+		if err != nil {
+			return
+		}
+	}
+
+	err = db.removeDangling(orphans)
+	return
+}
+
+// exportCompleteJobs writes the jobs with the given keys from the complete
+// bucket, with their StdOutC, StdErrC, EnvC and ScriptC filled in, as gzipped
+// JSON lines to a new file at the given path.
+func (db *db) exportCompleteJobs(keys []string, path string) (err error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, dbFilePermission)
+	if err != nil {
+		return
+	}
+	gz := gzip.NewWriter(f)
+	enc := json.NewEncoder(gz)
+	err = db.bolt.View(func(tx *bolt.Tx) error {
+		bc := tx.Bucket(bucketJobsComplete)
+		bo := tx.Bucket(bucketStdO)
+		be := tx.Bucket(bucketStdE)
+		benv := tx.Bucket(bucketEnvs)
+		bs := tx.Bucket(bucketScripts)
+		for _, keyStr := range keys {
+			key := []byte(keyStr)
+			encoded := bc.Get(key)
+			if encoded == nil {
+				continue
+			}
+			dec := codec.NewDecoderBytes(encoded, db.ch)
+			job := &Job{}
+			errd := dec.Decode(job)
+			if errd != nil {
+				return errd
+			}
+			job.StdOutC = bo.Get(key)
+			job.StdErrC = be.Get(key)
+			job.EnvC = benv.Get([]byte(job.EnvKey))
+			if job.ScriptKey != "" {
+				job.ScriptC = bs.Get([]byte(job.ScriptKey))
+			}
+			errd = enc.Encode(job)
+			if errd != nil {
+				return errd
+			}
+		}
+		return nil
+	})
+	errc := gz.Close()
+	if err == nil {
+		err = errc
+	}
+	errc = f.Close()
+	if err == nil {
+		err = errc
+	}
+	if err != nil {
+		os.Remove(path)
+	}
+	return
+}
+
+// removeDangling deletes entries in the bucket*TK buckets and the std buckets
+// that refer to jobs that are in neither the live nor the complete bucket. If
+// orphans is true, it also deletes envs and scripts that are not referred to
+// by any job in those buckets; since these are stored before the jobs that use
+// them, that must only be done when no jobs could be in the middle of being
+// added, ie. before the server starts handling clients. This is done in a
+// single transaction, so will block other writes to the database while it
+// runs.
+func (db *db) removeDangling(orphans bool) error {
+	return db.update(func(tx *replTx) error {
+		newJobBucket := tx.Bucket(bucketJobsLive)
+		completeJobBucket := tx.Bucket(bucketJobsComplete)
+		jobExists := func(key []byte) bool {
+			return newJobBucket.Get(key) != nil || completeJobBucket.Get(key) != nil
+		}
+
+		// lookups
+		delim := []byte(dbDelimiter)
+		for _, bucket := range [][]byte{bucketRTK, bucketDTK, bucketRDTK, bucketPTK} {
+			b := tx.Bucket(bucket)
+			var dangling [][]byte
+			b.ForEach(func(k, _ []byte) error {
+				i := bytes.LastIndex(k, delim)
+				if i == -1 || !jobExists(k[i+len(delim):]) {
+					dangling = append(dangling, append([]byte(nil), k...))
+				}
+				return nil
+			})
+			for _, k := range dangling {
+				err := b.Delete(k)
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		// history and attempts, keyed on job key followed by time or attempt
+		// number
+		for _, bucket := range [][]byte{bucketHistory, bucketAttempts} {
+			b := tx.Bucket(bucket)
+			var dangling [][]byte
+			b.ForEach(func(k, _ []byte) error {
+				i := bytes.Index(k, delim)
+				if i == -1 || !jobExists(k[:i]) {
+					dangling = append(dangling, append([]byte(nil), k...))
+				}
+				return nil
+			})
+			for _, k := range dangling {
+				err := b.Delete(k)
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		// std and resource series
+		for _, bucket := range [][]byte{bucketStdO, bucketStdE, bucketSeries} {
+			b := tx.Bucket(bucket)
+			var dangling [][]byte
+			b.ForEach(func(k, _ []byte) error {
+				if !jobExists(k) {
+					dangling = append(dangling, append([]byte(nil), k...))
+				}
+				return nil
+			})
+			for _, k := range dangling {
+				err := b.Delete(k)
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		if !orphans {
+			return nil
+		}
+
+		// envs and scripts
+		usedEnvs := make(map[string]bool)
+		usedScripts := make(map[string]bool)
+		for _, b := range []*replBucket{newJobBucket, completeJobBucket} {
+			err := b.ForEach(func(_, encoded []byte) error {
+				dec := codec.NewDecoderBytes(encoded, db.ch)
+				job := &Job{}
+				errd := dec.Decode(job)
+				if errd != nil {
+					return errd
+				}
+				usedEnvs[job.EnvKey] = true
+				if job.ScriptKey != "" {
+					usedScripts[job.ScriptKey] = true
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+		b := tx.Bucket(bucketEnvs)
+		var orphaned []string
+		b.ForEach(func(k, _ []byte) error {
+			if !usedEnvs[string(k)] {
+				orphaned = append(orphaned, string(k))
+			}
+			return nil
+		})
+		for _, envkey := range orphaned {
+			err := b.Delete([]byte(envkey))
+			if err != nil {
+				return err
+			}
+			// the cache must not claim that this env is still stored
+			db.envcache.Remove(envkey)
+		}
+		b = tx.Bucket(bucketScripts)
+		var orphanedScripts [][]byte
+		b.ForEach(func(k, _ []byte) error {
+			if !usedScripts[string(k)] {
+				orphanedScripts = append(orphanedScripts, append([]byte(nil), k...))
+			}
+			return nil
+		})
+		for _, k := range orphanedScripts {
+			err := b.Delete(k)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// compact rewrites the database file so that it only takes up as much space as
+// the data it currently holds (boltdb never shrinks its file after deletions,
+// only reusing the freed space). It only does this if at least minFreeFraction
+// of the file is free space. This must only be called when nothing else could
+// be using the database, ie. before the server starts handling clients.
+func (db *db) compact(minFreeFraction float64) (compacted bool, err error) {
+	path := db.bolt.Path()
+	fi, err := os.Stat(path)
+	if err != nil || fi.Size() == 0 {
+		return
+	}
+	stats := db.bolt.Stats()
+	free := float64((stats.FreePageN + stats.PendingPageN) * db.bolt.Info().PageSize)
+	if free/float64(fi.Size()) < minFreeFraction {
+		return
+	}
+
+	tmpPath := path + ".compact"
+	os.Remove(tmpPath)
+	dst, err := bolt.Open(tmpPath, dbFilePermission, nil)
+	if err != nil {
+		return
+	}
+
+	// copy every bucket over in chunks, so that we don't need to hold
+	// everything in memory in a single transaction
+	err = db.bolt.View(func(tx *bolt.Tx) error {
+		return tx.ForEach(func(name []byte, src *bolt.Bucket) error {
+			var chunk sobsd
+			flush := func() error {
+				errf := dst.Update(func(dtx *bolt.Tx) error {
+					b, errc := dtx.CreateBucketIfNotExists(name)
+					if errc != nil {
+						return fmt.Errorf("create bucket %s: %s", name, errc)
+					}
+					for _, doublet := range chunk {
+						errc = b.Put(doublet[0], doublet[1])
+						if errc != nil {
+							return errc
+						}
+					}
+					return nil
+				})
+				chunk = nil
+				return errf
+			}
+
+			errf := src.ForEach(func(k, v []byte) error {
+				chunk = append(chunk, [2][]byte{k, v})
+				if len(chunk) >= 10000 {
+					return flush()
+				}
+				return nil
+			})
+			if errf != nil {
+				return errf
+			}
+			return flush()
+		})
+	})
+	errc := dst.Close()
+	if err == nil {
+		err = errc
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return
+	}
+
+	// swap in the compacted file
+	err = db.bolt.Close()
+	if err != nil {
+		os.Remove(tmpPath)
+		return
+	}
+	errr := os.Rename(tmpPath, path)
+	if errr != nil {
+		os.Remove(tmpPath)
+	}
+	db.bolt, err = bolt.Open(path, dbFilePermission, nil)
+
+	// the new file has its own transaction ids, so needs a fresh replication
+	// log
+	db.repl = newReplicationLog()
+	if err == nil {
+		err = errr
+		compacted = errr == nil
+	}
+	return
+}
+
 // close shuts down the db, should be used prior to exiting. Ensures any
 // ongoing backgroundBackup() completes first (but does not wait for backup() to
 // complete).
@@ -1025,6 +2513,22 @@ func (db *db) close() {
 	if !db.closed {
 		db.closed = true
 
+		// if we're backing up on a schedule, stop doing so, but do a final
+		// backup if one is due
+		if db.stopBackups != nil {
+			close(db.stopBackups)
+			db.stopBackups = nil
+		}
+
+		// write out any history that hasn't been yet
+		close(db.stopHistory)
+		db.flushJobHistory()
+
+		if db.backupDue && !db.backingUp {
+			db.backupDue = false
+			db.startBackup()
+		}
+
 		// before actually closing, wait for any ongoing backup to complete
 		if db.backingUp {
 			db.backupFinal = true
@@ -1042,14 +2546,26 @@ func (db *db) close() {
 
 // backgroundBackup backs up the database to a file (the location given during
 // initDB()) in a goroutine, doing one backup at a time and queueing a further
-// backup if any other backup requests come in while a backup is running. Any
-// errors are silently ignored.
+// backup if any other backup requests come in while a backup is running. If
+// setBackupPolicy() was given an interval, the backup is instead only done at
+// the next scheduled time. Any errors are sent to the callback supplied to
+// setBackupErrorCallBack().
 func (db *db) backgroundBackup() {
 	db.Lock()
 	defer db.Unlock()
 	if db.closed || !db.backupsEnabled {
 		return
 	}
+	if db.backupInterval > 0 {
+		db.backupDue = true
+		return
+	}
+	db.startBackup()
+}
+
+// startBackup does the work of backgroundBackup(). You must hold the db lock
+// when calling this.
+func (db *db) startBackup() {
 	if db.backingUp {
 		db.backupQueued = true
 		return
@@ -1057,6 +2573,7 @@ func (db *db) backgroundBackup() {
 
 	db.backingUp = true
 	slowBackups := db.slowBackups
+	keep := db.backupKeep
 	go func() {
 		if slowBackups {
 			// just for testing purposes
@@ -1068,7 +2585,6 @@ func (db *db) backgroundBackup() {
 		err := db.bolt.View(func(tx *bolt.Tx) error {
 			return tx.CopyFile(tmpBackupPath, dbFilePermission)
 		})
-		// *** currently not logging the error message anywhere...
 
 		if slowBackups {
 			<-time.After(100 * time.Millisecond)
@@ -1079,11 +2595,23 @@ func (db *db) backgroundBackup() {
 			os.Remove(tmpBackupPath)
 		} else {
 			// backup succeeded, move it over any old backup
-			os.Rename(tmpBackupPath, db.backupPath)
+			err = os.Rename(tmpBackupPath, db.backupPath)
+
+			// and keep a timestamped copy of it
+			if err == nil && keep > 0 {
+				err = db.rotateBackups(keep)
+			}
 		}
 
 		db.Lock()
 		db.backingUp = false
+		errCB := db.backupErrCB
+
+		if err != nil && errCB != nil {
+			db.Unlock()
+			errCB(fmt.Errorf("database backup to %s failed: %s", db.backupPath, err))
+			db.Lock()
+		}
 
 		if db.backupFinal {
 			// close() has been called, don't do any more backups and tell
@@ -1096,14 +2624,207 @@ func (db *db) backgroundBackup() {
 
 		if db.backupQueued {
 			db.backupQueued = false
-			db.Unlock()
-			db.backgroundBackup()
-		} else {
-			db.Unlock()
+			db.startBackup()
 		}
+		db.Unlock()
 	}()
 }
 
+// rotateBackups copies our latest backup to a new file suffixed with the
+// current time, then deletes the oldest such files so that only keep of them
+// remain. It does nothing if the most recent such file is less than
+// DBBackupVersionMinAge old.
+func (db *db) rotateBackups(keep int) error {
+	versions, err := backupVersions(db.backupPath)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	if len(versions) > 0 {
+		newest, errp := time.ParseInLocation(dbBkVersionFormat, strings.TrimPrefix(versions[0], db.backupPath+"."), time.Local)
+		if errp == nil && now.Sub(newest) < DBBackupVersionMinAge {
+			return nil
+		}
+	}
+
+	versioned := db.backupPath + "." + now.Format(dbBkVersionFormat)
+	err = copyFile(db.backupPath, versioned)
+	if err != nil {
+		os.Remove(versioned)
+		return err
+	}
+
+	versions, err = backupVersions(db.backupPath)
+	if err != nil {
+		return err
+	}
+	if len(versions) > keep {
+		for _, old := range versions[keep:] {
+			err = os.Remove(old)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// backupVersions returns the paths of the timestamped backups made by
+// rotateBackups() for the given backup path, most recent first.
+func backupVersions(backupPath string) (versions []string, err error) {
+	matches, err := filepath.Glob(backupPath + ".*")
+	if err != nil {
+		return
+	}
+	for _, path := range matches {
+		suffix := strings.TrimPrefix(path, backupPath+".")
+		if _, errp := time.Parse(dbBkVersionFormat, suffix); errp == nil {
+			versions = append(versions, path)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(versions)))
+	return
+}
+
+// setBackupPolicy sets how many timestamped backups are kept (in addition to
+// the latest backup at the path given to initDB()), and how often backups are
+// done. An interval of 0 means a backup is done after every change to the
+// database.
+func (db *db) setBackupPolicy(keep int, interval time.Duration) {
+	db.Lock()
+	defer db.Unlock()
+	db.backupKeep = keep
+	if db.stopBackups != nil {
+		close(db.stopBackups)
+		db.stopBackups = nil
+	}
+	db.backupInterval = interval
+	if interval <= 0 || db.closed || !db.backupsEnabled {
+		return
+	}
+
+	stop := make(chan bool)
+	db.stopBackups = stop
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				db.Lock()
+				if db.backupDue && !db.closed {
+					db.backupDue = false
+					db.startBackup()
+				}
+				db.Unlock()
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+// setBackupErrorCallBack sets a function that will be called with the error
+// whenever a backgroundBackup() fails.
+func (db *db) setBackupErrorCallBack(cb func(err error)) {
+	db.Lock()
+	defer db.Unlock()
+	db.backupErrCB = cb
+}
+
+// DBBackups returns the paths of the timestamped database backups that have
+// been made for the given DBFileBackup path (see ServerConfig), most recent
+// first. It does not include the latest backup at backupPath itself.
+func DBBackups(backupPath string) ([]string, error) {
+	return backupVersions(backupPath)
+}
+
+// CheckDB checks the integrity of the database file at the given path,
+// returning an error if it is corrupt or doesn't look like one of our
+// databases.
+func CheckDB(path string) error {
+	if _, err := os.Stat(path); err != nil {
+		return err
+	}
+	boltdb, err := bolt.Open(path, dbFilePermission, &bolt.Options{ReadOnly: true, Timeout: 1 * time.Second})
+	if err != nil {
+		return err
+	}
+	defer boltdb.Close()
+	return boltdb.View(func(tx *bolt.Tx) error {
+		// (we must read all the errors for Check() to finish)
+		var checkErr error
+		for err := range tx.Check() {
+			if checkErr == nil {
+				checkErr = err
+			}
+		}
+		if checkErr != nil {
+			return checkErr
+		}
+		for _, bucket := range [][]byte{bucketJobsLive, bucketJobsComplete} {
+			if tx.Bucket(bucket) == nil {
+				return fmt.Errorf("bucket %s is missing", bucket)
+			}
+		}
+		return nil
+	})
+}
+
+// RestoreDB replaces the database file at dbFile with a copy of the one at
+// backupPath, after first confirming the backup passes CheckDB(). Any existing
+// dbFile is kept with a ".pre_restore" suffix. The server using dbFile must not
+// be running when you call this.
+func RestoreDB(backupPath string, dbFile string) error {
+	err := CheckDB(backupPath)
+	if err != nil {
+		return fmt.Errorf("backup %s failed its integrity check: %s", backupPath, err)
+	}
+
+	tmpPath := dbFile + ".restore"
+	err = copyFile(backupPath, tmpPath)
+	if err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	err = CheckDB(tmpPath)
+	if err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("copy of backup %s failed its integrity check: %s", backupPath, err)
+	}
+
+	if _, err = os.Stat(dbFile); err == nil {
+		err = os.Rename(dbFile, dbFile+".pre_restore")
+		if err != nil {
+			os.Remove(tmpPath)
+			return err
+		}
+	}
+	return os.Rename(tmpPath, dbFile)
+}
+
+// snapshot is like backup(), but also tells you the id of our replication log
+// and the sequence number of the last transaction that is in the backup, so
+// that a standby server can then ask our replication log for the changes made
+// since.
+func (db *db) snapshot(w io.Writer) (id string, seq uint64, err error) {
+	db.RLock()
+	if db.closed {
+		db.RUnlock()
+		err = fmt.Errorf("database closed")
+		return
+	}
+	db.RUnlock()
+
+	id = db.repl.id
+	err = db.bolt.View(func(tx *bolt.Tx) error {
+		seq = uint64(tx.ID())
+		_, txErr := tx.WriteTo(w)
+		return txErr
+	})
+	return
+}
+
 // backup backs up the database to the given writer. Can be called at the same
 // time as an active backgroundBackup() or even another backup(). You will get
 // a consistent view of the database at the time you call this. NB: this can be
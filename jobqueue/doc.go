@@ -29,8 +29,8 @@ It provides a job queue and running system which guarantees:
   # Jobs are handled in the desired order (user priority and fifo, after
     dependencies have been satisfied).
   # Jobs still get run despite crashing clients.
-  # Completed jobs are kept forever for historical and "live" dependency
-    purposes.
+  # Completed jobs are kept (forever, or according to a retention policy) for
+    historical and "live" dependency purposes.
 
 You bring up the server, then use a client to add commands (jobs) to the queue.
 The server then interacts with the configured scheduler to start running the
@@ -0,0 +1,267 @@
+// Copyright © 2017 Genome Research Limited
+// Author: Sendu Bala <sb10@sanger.ac.uk>.
+//
+//  This file is part of wr.
+//
+//  wr is free software: you can redistribute it and/or modify
+//  it under the terms of the GNU Lesser General Public License as published by
+//  the Free Software Foundation, either version 3 of the License, or
+//  (at your option) any later version.
+//
+//  wr is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of
+//  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU Lesser General Public License for more details.
+//
+//  You should have received a copy of the GNU Lesser General Public License
+//  along with wr. If not, see <http://www.gnu.org/licenses/>.
+
+package jobqueue
+
+// This file contains the implementation of resource escalation rules, which
+// say how a Job's Requirements grow after it fails due to running out of RAM,
+// time or disk.
+
+import (
+	"code.cloudfoundry.org/bytefmt"
+	"encoding/json"
+	"fmt"
+	jqs "github.com/VertebrateResequencing/wr/jobqueue/scheduler"
+	"math"
+	"time"
+)
+
+// Escalation struct can be set on a Job (or on all Jobs subsequently added to
+// a ReqGroup, with Client.SetReqGroupEscalation()) to control how much its
+// Requirements are increased after it fails due to using too much RAM, time or
+// disk, and the ceiling beyond which it should be buried instead of retried.
+// Zero values get the defaults described below; a zero ceiling means there is
+// no ceiling.
+type Escalation struct {
+	// RAMMultiplier multiplies the peak RAM used by a Cmd that used too much
+	// RAM, to give its new RAM requirement. It defaults to RAMIncreaseMultLow
+	// for peaks up to RAMIncreaseMultBreakpoint, and RAMIncreaseMultHigh
+	// above that.
+	RAMMultiplier float64 `json:",omitempty"`
+
+	// RAMMin is the minimum increase in RAM, in MB. It defaults to
+	// RAMIncreaseMin.
+	RAMMin int `json:",omitempty"`
+
+	// RAMMax is the most RAM, in MB, that the Job may be given.
+	RAMMax int `json:",omitempty"`
+
+	// TimeMultiplier multiplies the time requirement of a Cmd that used too
+	// much time. It defaults to 1, ie. only TimeMin is added.
+	TimeMultiplier float64 `json:",omitempty"`
+
+	// TimeMin is the minimum increase in time. It defaults to TimeIncreaseMin.
+	TimeMin time.Duration `json:",omitempty"`
+
+	// TimeMax is the most time that the Job may be given.
+	TimeMax time.Duration `json:",omitempty"`
+
+	// DiskMultiplier multiplies the disk requirement of a Cmd that ran out of
+	// disk space. It defaults to DiskIncreaseMult.
+	DiskMultiplier float64 `json:",omitempty"`
+
+	// DiskMin is the minimum increase in disk, in GB. It defaults to
+	// DiskIncreaseMin (1GB).
+	DiskMin int `json:",omitempty"`
+
+	// DiskMax is the most disk space, in GB, that the Job may be given.
+	DiskMax int `json:",omitempty"`
+}
+
+// String provides a JSON representation of the Escalation.
+func (e *Escalation) String() string {
+	if e == nil {
+		return ""
+	}
+	b, _ := json.Marshal(e)
+	return string(b)
+}
+
+// ram returns the new RAM requirement (in MB) of a Job that used peakMB and ran
+// out of RAM, capped at the ceiling.
+func (e *Escalation) ram(peakMB int) int {
+	updatedMB := float64(peakMB)
+	minIncrease := RAMIncreaseMin
+	if e != nil && e.RAMMultiplier > 0 {
+		updatedMB *= e.RAMMultiplier
+	} else if updatedMB <= RAMIncreaseMultBreakpoint {
+		updatedMB *= RAMIncreaseMultLow
+	} else {
+		updatedMB *= RAMIncreaseMultHigh
+	}
+	if e != nil && e.RAMMin > 0 {
+		minIncrease = float64(e.RAMMin)
+	}
+	if updatedMB < float64(peakMB)+minIncrease {
+		updatedMB = float64(peakMB) + minIncrease
+	}
+
+	// round up to nearest 100
+	mb := int(math.Ceil(updatedMB/100) * 100)
+	if e != nil && e.RAMMax > 0 && mb > e.RAMMax {
+		mb = e.RAMMax
+	}
+	return mb
+}
+
+// time returns the new time requirement of a Job that had a requirement of d
+// and ran out of time, capped at the ceiling.
+func (e *Escalation) time(d time.Duration) time.Duration {
+	updated := d
+	minIncrease := TimeIncreaseMin
+	if e != nil && e.TimeMultiplier > 0 {
+		updated = time.Duration(float64(d) * e.TimeMultiplier)
+	}
+	if e != nil && e.TimeMin > 0 {
+		minIncrease = e.TimeMin
+	}
+	if updated < d+minIncrease {
+		updated = d + minIncrease
+	}
+	if e != nil && e.TimeMax > 0 && updated > e.TimeMax {
+		updated = e.TimeMax
+	}
+	return updated
+}
+
+// disk returns the new disk requirement (in GB) of a Job that had a
+// requirement of gb, used peakMB and ran out of disk space, capped at the
+// ceiling. The increase is based on the larger of the requirement and what was
+// used, so that Jobs that didn't ask for any disk space only get a little more
+// than they were seen to need.
+func (e *Escalation) disk(gb int, peakMB int) int {
+	if peakGB := int(math.Ceil(float64(peakMB) / 1024)); peakGB > gb {
+		gb = peakGB
+	}
+	mult := DiskIncreaseMult
+	minIncrease := DiskIncreaseMin
+	if e != nil && e.DiskMultiplier > 0 {
+		mult = e.DiskMultiplier
+	}
+	if e != nil && e.DiskMin > 0 {
+		minIncrease = e.DiskMin
+	}
+	updated := int(math.Ceil(float64(gb) * mult))
+	if updated < gb+minIncrease {
+		updated = gb + minIncrease
+	}
+	if e != nil && e.DiskMax > 0 && updated > e.DiskMax {
+		updated = e.DiskMax
+	}
+	return updated
+}
+
+// exceeded returns the FailReason*Ceiling appropriate for a Job with the
+// given Requirements that failed for the given FailReason, if those
+// Requirements were already at (or beyond) our ceiling for the resource it ran
+// out of. Otherwise returns an empty string.
+func (e *Escalation) exceeded(req *jqs.Requirements, failreason string) string {
+	if e == nil || req == nil {
+		return ""
+	}
+	switch failreason {
+	case FailReasonRAM:
+		if e.RAMMax > 0 && req.RAM >= e.RAMMax {
+			return FailReasonRAMCeiling
+		}
+	case FailReasonTime:
+		if e.TimeMax > 0 && req.Time >= e.TimeMax {
+			return FailReasonTimeCeiling
+		}
+	case FailReasonDisk:
+		if e.DiskMax > 0 && req.Disk >= e.DiskMax {
+			return FailReasonDiskCeiling
+		}
+	}
+	return ""
+}
+
+// limit reduces the given Requirements (typically after they were changed by
+// learning from prior Jobs) so that they are not beyond our ceilings.
+func (e *Escalation) limit(req *jqs.Requirements) {
+	if e == nil || req == nil {
+		return
+	}
+	if e.RAMMax > 0 && req.RAM > e.RAMMax {
+		req.RAM = e.RAMMax
+	}
+	if e.TimeMax > 0 && req.Time > e.TimeMax {
+		req.Time = e.TimeMax
+	}
+	if e.DiskMax > 0 && req.Disk > e.DiskMax {
+		req.Disk = e.DiskMax
+	}
+}
+
+// EscalationViaJSON describes an Escalation in a form convenient for supplying
+// as JSON.
+type EscalationViaJSON struct {
+	RAMMultiplier float64 `json:"ram_mult"`
+	// RAMMin and RAMMax are a number and unit suffix, eg. 1G for 1 Gigabyte.
+	RAMMin         string  `json:"ram_min"`
+	RAMMax         string  `json:"ram_max"`
+	TimeMultiplier float64 `json:"time_mult"`
+	// TimeMin and TimeMax are durations with a unit suffix, eg. 1h for 1 hour.
+	TimeMin        string  `json:"time_min"`
+	TimeMax        string  `json:"time_max"`
+	DiskMultiplier float64 `json:"disk_mult"`
+	// DiskMin and DiskMax are numbers of Gigabytes.
+	DiskMin int `json:"disk_min"`
+	DiskMax int `json:"disk_max"`
+}
+
+// Escalation converts to an Escalation, checking that the supplied values are
+// valid.
+func (evj *EscalationViaJSON) Escalation() (*Escalation, error) {
+	e := &Escalation{
+		RAMMultiplier:  evj.RAMMultiplier,
+		TimeMultiplier: evj.TimeMultiplier,
+		DiskMultiplier: evj.DiskMultiplier,
+		DiskMin:        evj.DiskMin,
+		DiskMax:        evj.DiskMax,
+	}
+	if e.RAMMultiplier < 0 || e.TimeMultiplier < 0 || e.DiskMultiplier < 0 || e.DiskMin < 0 || e.DiskMax < 0 {
+		return nil, fmt.Errorf("escalation values can't be negative")
+	}
+
+	var err error
+	e.RAMMin, err = escalationMBs(evj.RAMMin)
+	if err != nil {
+		return nil, fmt.Errorf("escalation ram_min (%s) was not specified correctly: %s", evj.RAMMin, err)
+	}
+	e.RAMMax, err = escalationMBs(evj.RAMMax)
+	if err != nil {
+		return nil, fmt.Errorf("escalation ram_max (%s) was not specified correctly: %s", evj.RAMMax, err)
+	}
+	if evj.TimeMin != "" {
+		e.TimeMin, err = time.ParseDuration(evj.TimeMin)
+		if err != nil {
+			return nil, fmt.Errorf("escalation time_min (%s) was not specified correctly: %s", evj.TimeMin, err)
+		}
+	}
+	if evj.TimeMax != "" {
+		e.TimeMax, err = time.ParseDuration(evj.TimeMax)
+		if err != nil {
+			return nil, fmt.Errorf("escalation time_max (%s) was not specified correctly: %s", evj.TimeMax, err)
+		}
+	}
+	return e, nil
+}
+
+// escalationMBs converts a memory string like "1G" in to a number of MB,
+// returning 0 for an empty string.
+func escalationMBs(mem string) (int, error) {
+	if mem == "" {
+		return 0, nil
+	}
+	mb, err := bytefmt.ToMegabytes(mem)
+	if err != nil {
+		return 0, err
+	}
+	return int(mb), nil
+}
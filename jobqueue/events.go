@@ -0,0 +1,197 @@
+// Copyright © 2017 Genome Research Limited
+// Author: Sendu Bala <sb10@sanger.ac.uk>.
+//
+//  This file is part of wr.
+//
+//  wr is free software: you can redistribute it and/or modify
+//  it under the terms of the GNU Lesser General Public License as published by
+//  the Free Software Foundation, either version 3 of the License, or
+//  (at your option) any later version.
+//
+//  wr is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of
+//  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU Lesser General Public License for more details.
+//
+//  You should have received a copy of the GNU Lesser General Public License
+//  along with wr. If not, see <http://www.gnu.org/licenses/>.
+
+package jobqueue
+
+// This file contains the code for letting clients subscribe to Jobs changing
+// state.
+
+import (
+	"sync"
+	"time"
+)
+
+// ServerJobEventsRetained is how many of the most recent JobEvents the server
+// keeps in memory, so that clients that Subscribe() can catch up after a
+// reconnect.
+var ServerJobEventsRetained = 100000
+
+// ClientSubscribeRetryInterval is how long a subscription started with
+// Client.Subscribe() waits before trying to reconnect to the server after a
+// failure.
+var ClientSubscribeRetryInterval = 1 * time.Second
+
+// JobEvent describes a Job changing from one state to another, as received
+// from Client.Subscribe(). The Job's other properties are as they were at the
+// time of the change.
+type JobEvent struct {
+	// Seq is the sequence number of this event. It increases by 1 for every
+	// event recorded by the server (whether or not it matches your
+	// subscription), and starts from the time the server started (in
+	// nanoseconds since the Unix epoch), so keeps increasing when the server
+	// is restarted.
+	Seq uint64
+
+	// Missed is set on the first event you receive after some events could
+	// not be delivered to you, because they were no longer retained by the
+	// server (eg. you were disconnected for too long, or the server was
+	// restarted).
+	Missed bool
+
+	Time       time.Time
+	Queue      string
+	Key        string
+	RepGroup   string
+	ReqGroup   string
+	FromState  JobState
+	ToState    JobState
+	Host       string
+	Exited     bool
+	Exitcode   int
+	FailReason string
+}
+
+// newJobEvent creates a JobEvent for the given Job, which you must have
+// (read) locked.
+func newJobEvent(qname string, from JobState, to JobState, job *Job) *JobEvent {
+	return &JobEvent{
+		Time:       time.Now(),
+		Queue:      qname,
+		Key:        job.key(),
+		RepGroup:   job.RepGroup,
+		ReqGroup:   job.ReqGroup,
+		FromState:  from,
+		ToState:    to,
+		Host:       job.Host,
+		Exited:     job.Exited,
+		Exitcode:   job.Exitcode,
+		FailReason: job.FailReason,
+	}
+}
+
+// matchesEvent is like matches(), but for a JobEvent. The filter's State is
+// compared to the ToState of the event.
+func (f *JobFilter) matchesEvent(e *JobEvent) bool {
+	switch {
+	case f.RepGroup != "" && e.RepGroup != f.RepGroup,
+		f.State != "" && e.ToState != f.State,
+		f.FailReason != "" && e.FailReason != f.FailReason,
+		f.Exitcode != nil && (!e.Exited || e.Exitcode != *f.Exitcode),
+		f.Host != "" && e.Host != f.Host,
+		f.ReqGroup != "" && e.ReqGroup != f.ReqGroup:
+		return false
+	}
+	return true
+}
+
+// jobEventLog holds the most recent JobEvents in a ring buffer, where the
+// event with sequence number seq is at index seq % max.
+type jobEventLog struct {
+	sync.Mutex
+	events  []*JobEvent
+	held    uint64
+	seq     uint64
+	changed chan struct{}
+}
+
+// newJobEventLog creates a jobEventLog that retains up to max events.
+func newJobEventLog(max int) *jobEventLog {
+	if max < 1 {
+		max = 1
+	}
+	return &jobEventLog{
+		events:  make([]*JobEvent, max),
+		seq:     uint64(time.Now().UnixNano()),
+		changed: make(chan struct{}),
+	}
+}
+
+// add gives the events sequence numbers and records them, overwriting the
+// oldest events if we now have too many, and wakes up anything waiting for new
+// events.
+func (l *jobEventLog) add(events ...*JobEvent) {
+	if len(events) == 0 {
+		return
+	}
+	l.Lock()
+	defer l.Unlock()
+	max := uint64(len(l.events))
+	for _, e := range events {
+		l.seq++
+		e.Seq = l.seq
+		l.events[l.seq%max] = e
+		if l.held < max {
+			l.held++
+		}
+	}
+	close(l.changed)
+	l.changed = make(chan struct{})
+}
+
+// since returns the events in the given queue, with a sequence number greater
+// than seq, that match the filter. It also returns the sequence number of the
+// most recent event, whether any events after seq are no longer retained, and a
+// channel that will be closed when another event is added.
+func (l *jobEventLog) since(qname string, seq uint64, filter *JobFilter) (events []*JobEvent, latest uint64, missed bool, changed chan struct{}) {
+	l.Lock()
+	defer l.Unlock()
+	latest = l.seq
+	changed = l.changed
+	if l.held == 0 || seq >= latest {
+		return
+	}
+	oldest := l.seq - l.held + 1
+	missed = seq+1 < oldest
+	if seq < oldest {
+		seq = oldest - 1
+	}
+	max := uint64(len(l.events))
+	for s := seq + 1; s <= l.seq; s++ {
+		e := l.events[s%max]
+		if e.Queue == qname && filter.matchesEvent(e) {
+			events = append(events, e)
+		}
+	}
+	return
+}
+
+// getJobEvents gets the events in the given queue after seq that match the
+// filter, waiting up to the given time for there to be some. A seq of 0 gets
+// no events, but tells you the sequence number of the most recent event.
+func (s *Server) getJobEvents(qname string, seq uint64, filter *JobFilter, wait time.Duration) (events []*JobEvent, latest uint64, missed bool) {
+	timeout := time.After(wait)
+	for {
+		var changed chan struct{}
+		var gap bool
+		events, latest, gap, changed = s.jobEvents.since(qname, seq, filter)
+		missed = missed || gap
+		if len(events) > 0 || seq == 0 {
+			return
+		}
+
+		// there's no point in the client asking again for events we've
+		// already said don't match
+		seq = latest
+		select {
+		case <-changed:
+			continue
+		case <-timeout:
+			return
+		}
+	}
+}
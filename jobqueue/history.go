@@ -0,0 +1,120 @@
+// Copyright © 2017 Genome Research Limited
+// Author: Sendu Bala <sb10@sanger.ac.uk>.
+//
+//  This file is part of wr.
+//
+//  wr is free software: you can redistribute it and/or modify
+//  it under the terms of the GNU Lesser General Public License as published by
+//  the Free Software Foundation, either version 3 of the License, or
+//  (at your option) any later version.
+//
+//  wr is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of
+//  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU Lesser General Public License for more details.
+//
+//  You should have received a copy of the GNU Lesser General Public License
+//  along with wr. If not, see <http://www.gnu.org/licenses/>.
+
+package jobqueue
+
+// This file contains the code for keeping an audit trail of everything that
+// happened to each Job.
+
+import (
+	"time"
+)
+
+// JobHistory* constants are the kinds of event recorded in a Job's history,
+// as found in JobHistoryEvent.Event.
+const (
+	JobHistoryAdded     = "added"     // added to the queue (State says if ready or dependent)
+	JobHistoryReady     = "ready"     // became ready to run after a delay or when its dependencies completed
+	JobHistoryReserved  = "reserved"  // reserved by the client ClientID, run by User
+	JobHistoryStarted   = "started"   // the Cmd started running on Host with Pid
+	JobHistoryExited    = "exited"    // the Cmd exited with Exitcode
+	JobHistoryReleased  = "released"  // failed with FailReason, and will be retried after a delay
+	JobHistoryBuried    = "buried"    // failed with FailReason, and will not be retried
+	JobHistoryLost      = "lost"      // we lost contact with the client running it
+	JobHistoryFound     = "found"     // we regained contact with the client running it
+	JobHistoryKicked    = "kicked"    // User kicked it out of the buried state to be retried
+	JobHistoryKilled    = "killed"    // User asked for it to be killed
+	JobHistoryDeleted   = "deleted"   // User deleted it
+	JobHistoryCompleted = "completed" // it completed successfully
+)
+
+// JobHistoryEvent describes something that happened to a Job. Only the
+// properties relevant to the Event (see the JobHistory* constants) are set.
+type JobHistoryEvent struct {
+	Time       time.Time
+	Event      string
+	State      JobState // the state the Job was in after the event
+	ClientID   string
+	User       string
+	Host       string
+	Pid        int
+	Exited     bool
+	Exitcode   int
+	FailReason string
+}
+
+// JobHistoryFlushInterval is how often the server writes the job history
+// events it has recorded to its database. (Getting a job's history always
+// includes the latest events, regardless.)
+var JobHistoryFlushInterval = 1 * time.Second
+
+// jobHistoryEntry pairs a JobHistoryEvent with the key of its Job, for
+// storing in the database.
+type jobHistoryEntry struct {
+	key   string
+	event *JobHistoryEvent
+}
+
+// bufferedHistory is a JobHistoryEvent waiting to be written to the database
+// under its key.
+type bufferedHistory struct {
+	key   []byte
+	event *JobHistoryEvent
+}
+
+// recordJobHistory stores a new event in the history of the given Job, which
+// you must not have locked. The event's Time is set for you, as are the
+// properties of the Job relevant to the event (such as the Host it ran on).
+func (s *Server) recordJobHistory(job *Job, event *JobHistoryEvent) {
+	job.RLock()
+	entry := newJobHistoryEntry(job, event)
+	job.RUnlock()
+	s.db.storeJobHistory([]*jobHistoryEntry{entry})
+}
+
+// newJobHistoryEntry is like recordJobHistory(), but just creates the entry.
+// You must have (read) locked the Job.
+func newJobHistoryEntry(job *Job, event *JobHistoryEvent) *jobHistoryEntry {
+	event.Time = time.Now()
+	switch event.Event {
+	case JobHistoryStarted, JobHistoryLost, JobHistoryFound, JobHistoryKilled:
+		event.Host = job.Host
+		event.Pid = job.Pid
+	case JobHistoryExited, JobHistoryCompleted, JobHistoryReleased, JobHistoryBuried:
+		event.Host = job.Host
+		event.Pid = job.Pid
+		event.Exited = job.Exited
+		if job.Exited {
+			event.Exitcode = job.Exitcode
+		}
+		if event.Event != JobHistoryExited && event.Event != JobHistoryCompleted {
+			event.FailReason = job.FailReason
+		}
+	}
+	return &jobHistoryEntry{key: job.key(), event: event}
+}
+
+// getJobHistory gets the history of the job with the given key.
+func (s *Server) getJobHistory(jobkey string) (events []*JobHistoryEvent, srerr string, qerr string) {
+	events, err := s.db.retrieveJobHistory(jobkey)
+	if err != nil {
+		srerr = ErrDBError
+		qerr = err.Error()
+	}
+	return
+}
@@ -0,0 +1,392 @@
+// Copyright © 2017 Genome Research Limited
+// Author: Sendu Bala <sb10@sanger.ac.uk>.
+//
+//  This file is part of wr.
+//
+//  wr is free software: you can redistribute it and/or modify
+//  it under the terms of the GNU Lesser General Public License as published by
+//  the Free Software Foundation, either version 3 of the License, or
+//  (at your option) any later version.
+//
+//  wr is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of
+//  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU Lesser General Public License for more details.
+//
+//  You should have received a copy of the GNU Lesser General Public License
+//  along with wr. If not, see <http://www.gnu.org/licenses/>.
+
+package jobqueue
+
+// This file contains the code for excluding hosts from having runners
+// scheduled on them, eg. because they failed a runner's health checks, or
+// because too many jobs failed on them in ways that suggest the host is bad.
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"time"
+)
+
+// RunnerEventUnhealthy is the event a runner reports with
+// Client.ReportRunner() when its host fails its health checks, with a
+// description of the problem as the msg. The server then excludes that host
+// from having any more runners scheduled on it.
+const RunnerEventUnhealthy = "unhealthy"
+
+// BadHostWindow, BadHostFailures, BadHostReqGroups and BadHostRateFactor
+// control the automatic quarantining of hosts. Jobs can fail on a host for
+// reasons that suggest a problem with the host itself (it couldn't start their
+// Cmds or mount their remote file systems, or we lost contact with it and the
+// user confirmed those jobs dead). If at least BadHostFailures jobs from at
+// least BadHostReqGroups different ReqGroups fail like that on the same host
+// within BadHostWindow, and the proportion of the host's jobs that failed like
+// that is at least BadHostRateFactor times the proportion on all other hosts,
+// runners stop being scheduled on that host. But if most hosts are having
+// failures for the same reason (eg. because remote storage is down for
+// everyone), the problem is not with any particular host, and none get
+// quarantined. Set BadHostFailures to 0 to disable this.
+var (
+	BadHostWindow     = 30 * time.Minute
+	BadHostFailures   = 5
+	BadHostReqGroups  = 2
+	BadHostRateFactor = 3.0
+)
+
+// hostExcludedConfig is the Reason given to hosts that were excluded via
+// ServerConfig.ExcludedHosts.
+const hostExcludedConfig = "excluded by configuration"
+
+// ExcludedHost describes a host that runners are no longer being scheduled
+// on.
+type ExcludedHost struct {
+	Host   string
+	Reason string
+	Date   int64 // seconds since Unix epoch
+
+	// Confirmed is false for hosts that were excluded automatically (because
+	// they failed a runner's health checks or were quarantined due to their
+	// job failures), until the user confirms they should stay excluded. Until
+	// then, they are shown alongside bad servers on the status webpage. (With
+	// the local scheduler, the exclusion of our own host, the only one it
+	// can use, only takes effect once confirmed.)
+	Confirmed bool
+}
+
+// ExcludeHost stops runners being scheduled on the given host (as named by
+// os.Hostname() on it), giving the reason why. Anything already scheduled or
+// running on the host is left alone.
+func (c *Client) ExcludeHost(host string, reason string) error {
+	_, err := c.request(&clientRequest{Method: "hexclude", Host: host, Reason: reason})
+	return err
+}
+
+// IncludeHost undoes ExcludeHost() (or an exclusion due to the host failing a
+// runner's health checks), letting runners be scheduled on the host again.
+func (c *Client) IncludeHost(host string) error {
+	_, err := c.request(&clientRequest{Method: "hinclude", Host: host})
+	return err
+}
+
+// ExcludedHosts tells you which hosts runners are no longer being scheduled
+// on, and why, sorted by host.
+func (c *Client) ExcludedHosts() (hosts []*ExcludedHost, err error) {
+	resp, err := c.request(&clientRequest{Method: "hexcluded"})
+	if err != nil {
+		return
+	}
+	hosts = resp.ExcludedHosts
+	return
+}
+
+// excludeHost stops runners being scheduled on the given host, letting the
+// user know about it via the status webpage. Unconfirmed exclusions are also
+// shown there as bad servers, for the user to confirm or clear. The exclusion
+// is stored in the database, so it survives us being restarted.
+func (s *Server) excludeHost(host string, reason string, confirmed bool) {
+	eh := &ExcludedHost{Host: host, Reason: reason, Date: time.Now().Unix(), Confirmed: confirmed}
+	s.ehmutex.Lock()
+	_, existed := s.excludedHosts[host]
+	s.excludedHosts[host] = eh
+	s.scheduler.ExcludeHosts(s.excludedHostNames())
+	ehc := *eh
+	s.ehmutex.Unlock()
+	s.storeExcludedHost(host, &ehc)
+
+	if !existed {
+		if s.awaitsConfirmation(eh) {
+			s.reportIssue(fmt.Sprintf("Host %s seems bad, but runners will continue to be scheduled on it unless you confirm its exclusion: %s", host, reason))
+		} else {
+			s.reportIssue(fmt.Sprintf("Host %s will no longer have runners scheduled on it: %s", host, reason))
+		}
+	}
+	if !confirmed {
+		s.badServerCaster.Send(eh.toBadServer(true))
+	}
+}
+
+// confirmExcludedHost records that the user wants the given automatically
+// excluded host to stay excluded.
+func (s *Server) confirmExcludedHost(host string) {
+	s.ehmutex.Lock()
+	eh, existed := s.excludedHosts[host]
+	if !existed {
+		s.ehmutex.Unlock()
+		return
+	}
+	eh.Confirmed = true
+	s.scheduler.ExcludeHosts(s.excludedHostNames())
+	ehc := *eh
+	s.ehmutex.Unlock()
+	s.storeExcludedHost(host, &ehc)
+}
+
+// storeExcludedHost stores the given exclusion of the given host in the
+// database (or removes its stored exclusion if eh is nil), letting the user
+// know if that fails. Exclusions from our config aren't stored, since they'll
+// be in the config again the next time we start.
+func (s *Server) storeExcludedHost(host string, eh *ExcludedHost) {
+	if eh != nil && eh.Reason == hostExcludedConfig {
+		return
+	}
+	err := s.db.storeExcludedHost(host, eh)
+	if err != nil {
+		s.reportIssue(fmt.Sprintf("Failed to store the exclusion of host %s in the database: %s", host, err))
+	}
+}
+
+// awaitsConfirmation tells you if the given exclusion should not take effect
+// until the user confirms it, which is the case when it would automatically
+// exclude the only host the local scheduler can use: our own.
+func (s *Server) awaitsConfirmation(eh *ExcludedHost) bool {
+	return !eh.Confirmed && s.ServerInfo.Scheduler == "local" && eh.Host == s.ServerInfo.Host
+}
+
+// includeHost undoes excludeHost(), also forgetting about the host's past job
+// failures so it doesn't immediately get quarantined again.
+func (s *Server) includeHost(host string) {
+	s.hfmutex.Lock()
+	delete(s.hostOutcomes, host)
+	s.hfmutex.Unlock()
+
+	s.ehmutex.Lock()
+	eh, existed := s.excludedHosts[host]
+	if !existed {
+		s.ehmutex.Unlock()
+		return
+	}
+	delete(s.excludedHosts, host)
+	s.scheduler.ExcludeHosts(s.excludedHostNames())
+	s.ehmutex.Unlock()
+	s.storeExcludedHost(host, nil)
+
+	if !eh.Confirmed {
+		// let the status webpage know it's no longer bad
+		s.badServerCaster.Send(eh.toBadServer(false))
+	}
+}
+
+// toBadServer converts an ExcludedHost to the BadServer form we send to the
+// status webpage.
+func (eh *ExcludedHost) toBadServer(isBad bool) *BadServer {
+	return &BadServer{
+		ID:           eh.Host,
+		Name:         eh.Host,
+		Date:         eh.Date,
+		IsBad:        isBad,
+		Problem:      eh.Reason,
+		ExcludedHost: true,
+	}
+}
+
+// getUnconfirmedExcludedHosts returns our automatically excluded hosts that
+// the user hasn't confirmed yet, in BadServer form.
+func (s *Server) getUnconfirmedExcludedHosts() (bs []*BadServer) {
+	s.ehmutex.RLock()
+	defer s.ehmutex.RUnlock()
+	for _, eh := range s.excludedHosts {
+		if !eh.Confirmed {
+			bs = append(bs, eh.toBadServer(true))
+		}
+	}
+	return
+}
+
+// excludedHostNames returns the names of our excluded hosts, ignoring those
+// that awaitsConfirmation(). You must hold the ehmutex lock.
+func (s *Server) excludedHostNames() []string {
+	hosts := make([]string, 0, len(s.excludedHosts))
+	for host, eh := range s.excludedHosts {
+		if s.awaitsConfirmation(eh) {
+			continue
+		}
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+	return hosts
+}
+
+// getExcludedHosts returns copies of our excluded hosts, sorted by host.
+func (s *Server) getExcludedHosts() []*ExcludedHost {
+	s.ehmutex.RLock()
+	hosts := make([]*ExcludedHost, 0, len(s.excludedHosts))
+	for _, eh := range s.excludedHosts {
+		ehc := *eh
+		hosts = append(hosts, &ehc)
+	}
+	s.ehmutex.RUnlock()
+	sort.Slice(hosts, func(i, j int) bool {
+		return hosts[i].Host < hosts[j].Host
+	})
+	return hosts
+}
+
+// hostMinute counts the outcomes of the jobs that ended on a host during a
+// particular minute.
+type hostMinute struct {
+	start     time.Time
+	jobs      int
+	failures  map[string]int  // host-fault FailReasons to counts
+	reqGroups map[string]bool // ReqGroups of jobs with host-fault failures
+}
+
+// hostOutcomes holds the hostMinutes of a host covering the last
+// BadHostWindow, oldest first.
+type hostOutcomes []*hostMinute
+
+// prune drops the hostMinutes that started BadHostWindow or more ago.
+func (ho hostOutcomes) prune(now time.Time) hostOutcomes {
+	i := 0
+	for i < len(ho) && now.Sub(ho[i].start) >= BadHostWindow {
+		i++
+	}
+	return ho[i:]
+}
+
+// totals sums our hostMinutes, returning the number of jobs, the number of
+// them that failed for the given host-fault reason (or for any host-fault
+// reason, if reason is blank) and the number of different ReqGroups of jobs
+// with host-fault failures.
+func (ho hostOutcomes) totals(reason string) (jobs int, failures int, reqGroups int) {
+	rgs := make(map[string]bool)
+	for _, hm := range ho {
+		jobs += hm.jobs
+		for r, count := range hm.failures {
+			if reason == "" || r == reason {
+				failures += count
+			}
+		}
+		for rg := range hm.reqGroups {
+			rgs[rg] = true
+		}
+	}
+	reqGroups = len(rgs)
+	return
+}
+
+// hostFailureReasons are the FailReasons that suggest something is wrong with
+// the host a job was running on, rather than with the job itself.
+var hostFailureReasons = map[string]bool{
+	FailReasonStart: true,
+	FailReasonMount: true,
+	FailReasonLost:  true,
+}
+
+// hostName returns the name of the host we're running on, or "localhost" if
+// that can't be determined.
+func hostName() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "localhost"
+	}
+	return host
+}
+
+// recordHostSuccess notes that a job completed successfully on the given host,
+// which counts towards the number of jobs the host has run when working out
+// its failure rate.
+func (s *Server) recordHostSuccess(host string) {
+	s.recordHostOutcome(host, "", "")
+}
+
+// recordHostFailure notes that a job with the given ReqGroup failed on the
+// given host for the given reason. If the reason suggests a problem with the
+// host, and the host now has an unusually high rate of such failures compared
+// to other hosts (see BadHostRateFactor), it gets quarantined: excluded from
+// having runners scheduled on it, pending the user's confirmation via the
+// status webpage.
+func (s *Server) recordHostFailure(host string, reqGroup string, reason string) {
+	s.recordHostOutcome(host, reqGroup, reason)
+}
+
+// recordHostOutcome implements recordHostSuccess() and recordHostFailure(); a
+// blank reason means the job succeeded.
+func (s *Server) recordHostOutcome(host string, reqGroup string, reason string) {
+	if host == "" || BadHostFailures <= 0 {
+		return
+	}
+	hostFault := hostFailureReasons[reason]
+
+	now := time.Now()
+	minute := now.Truncate(time.Minute)
+	s.hfmutex.Lock()
+	ho := s.hostOutcomes[host].prune(now)
+	if len(ho) == 0 || !ho[len(ho)-1].start.Equal(minute) {
+		ho = append(ho, &hostMinute{start: minute, failures: make(map[string]int), reqGroups: make(map[string]bool)})
+	}
+	hm := ho[len(ho)-1]
+	hm.jobs++
+	if hostFault {
+		hm.failures[reason]++
+		hm.reqGroups[reqGroup] = true
+	}
+	s.hostOutcomes[host] = ho
+
+	if !hostFault {
+		s.hfmutex.Unlock()
+		return
+	}
+
+	jobs, failures, reqGroups := ho.totals("")
+	if failures < BadHostFailures || reqGroups < BadHostReqGroups {
+		s.hfmutex.Unlock()
+		return
+	}
+
+	// compare with the other hosts, noting how many are failing for the same
+	// reason
+	var otherJobs, otherFailures, activeHosts, failingHosts int
+	for other, oho := range s.hostOutcomes {
+		oho = oho.prune(now)
+		if len(oho) == 0 {
+			delete(s.hostOutcomes, other)
+			continue
+		}
+		s.hostOutcomes[other] = oho
+		activeHosts++
+		if _, sameReason, _ := oho.totals(reason); sameReason > 0 {
+			failingHosts++
+		}
+		if other == host {
+			continue
+		}
+		oj, of, _ := oho.totals("")
+		otherJobs += oj
+		otherFailures += of
+	}
+	rate := float64(failures) / float64(jobs)
+	var otherRate float64
+	if otherJobs > 0 {
+		otherRate = float64(otherFailures) / float64(otherJobs)
+	}
+	quarantine := failingHosts*2 <= activeHosts && rate >= BadHostRateFactor*otherRate
+	if quarantine {
+		delete(s.hostOutcomes, host)
+	}
+	s.hfmutex.Unlock()
+
+	if quarantine {
+		s.excludeHost(host, fmt.Sprintf("%d of %d jobs from %d req_grps failed on it within %s, vs %.0f%% on other hosts (most recently because: %s)", failures, jobs, reqGroups, BadHostWindow, otherRate*100, reason), false)
+	}
+}
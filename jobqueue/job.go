@@ -27,10 +27,11 @@ import (
 	"github.com/VertebrateResequencing/wr/queue"
 	"github.com/satori/go.uuid"
 	"github.com/ugorji/go/codec"
-	"math"
+	"io/ioutil"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
 	"syscall"
@@ -85,9 +86,18 @@ var itemsStateToJobState = map[queue.ItemState]JobState{
 // Get*()), you should treat the properties as read-only: changing them will
 // have no effect.
 type Job struct {
-	// Cmd is the actual command line that will be run via the shell.
+	// Cmd is the actual command line that will be run via the shell. If you
+	// SetScript(), Cmd is just used to describe the Job when reporting on it.
 	Cmd string
 
+	// Interpreter is the program that runs Cmd (or the script set with
+	// SetScript()), eg. "bash", "sh", "python" or "Rscript". If not set, Cmd is
+	// run using the shell supplied to Execute(). If set, Cmd or the script is
+	// written to a file in the Job's $TMPDIR (or the system temp directory if
+	// CwdMatters), and the Interpreter is run with the path to that file as its
+	// only argument.
+	Interpreter string
+
 	// Cwd determines the command working directory, the directory we cd to
 	// before running Cmd. When CwdMatters, Cwd is used exactly, otherwise a
 	// unique sub-directory of Cwd is used as the command working directory.
@@ -136,6 +146,16 @@ type Job struct {
 	// Retries is the number of times to retry running a Cmd if it fails.
 	Retries uint8
 
+	// RetryPolicy, if set, controls which failures are retried, how long to
+	// wait before retrying, and which exit codes of Cmd count as success.
+	RetryPolicy *RetryPolicy
+
+	// Escalation, if set, controls how much the Requirements grow after Cmd
+	// fails due to using too much RAM, time or disk, and the most they can
+	// grow to before the Job is buried instead. If not set, the Escalation
+	// set for the ReqGroup (if any) is used when the Job is added.
+	Escalation *Escalation
+
 	// DepGroups are the dependency groups this job belongs to that other jobs
 	// can refer to in their Dependencies.
 	DepGroups []string
@@ -164,6 +184,11 @@ type Job struct {
 	// ActualCwd.
 	MountConfigs MountConfigs
 
+	// Container, if set, describes a container image that Cmd will be run
+	// inside, using Docker or Singularity. The working directory, $TMPDIR and
+	// the mount points of MountConfigs are made available inside the container.
+	Container *ContainerConfig
+
 	// The remaining properties are used to record information about what
 	// happened when Cmd was executed, or otherwise provide its current state.
 	// It is meaningless to set these yourself.
@@ -173,6 +198,9 @@ type Job struct {
 	ActualCwd string
 	// peak RAM (MB) used.
 	PeakRAM int
+	// peak disk space used by the Cmd in its unique working directory (only
+	// measured if CwdMatters is false), in MB.
+	PeakDisk int
 	// true if the Cmd was run and exited.
 	Exited bool
 	// if the job ran and exited, its exit code is recorded here, but check
@@ -203,23 +231,45 @@ type Job struct {
 	// to read, call job.StdOut() instead; if the job ran, its (truncated)
 	// STDOUT will be here.
 	StdOutC []byte
+	// to read, call job.ResourceSeries() instead; if the job ran, a
+	// (downsampled) series of measurements of the resources it used will be
+	// here.
+	ResourceSeriesC []byte
+	// if you got the Job with getStd true, details of every attempt at running
+	// its Cmd, oldest first, will be here.
+	AttemptRecords []*JobAttempt
 	// to read, call job.Env() instead, to get the environment variables as a
 	// []string, where each string is like "key=value".
 	EnvC []byte
 	// if set (using output of CompressEnv()), they will be returned in the
 	// results of job.Env().
 	EnvOverride []byte
+	// to set, call job.SetScript(); to read, call job.Script().
+	ScriptC []byte
+	// on the server we don't store ScriptC with the job, but look it up in db
+	// via this key, which is set by SetScript().
+	ScriptKey string
 	// job's state in the queue: 'delayed', 'ready', 'reserved', 'running',
 	// 'buried', 'complete' or 'dependent'.
 	State JobState
 	// number of times the job had ever entered 'running' state.
 	Attempts uint32
+	// number of times the job was retried because it ran out of RAM or time,
+	// respectively.
+	RAMRetries  uint32
+	TimeRetries uint32
 	// remaining number of Release()s allowed before being buried instead.
 	UntilBuried uint8
 	// we note which client reserved this job, for validating if that client has
 	// permission to do other stuff to this Job; the server only ever sets this
 	// on Reserve(), so clients can't cheat by changing this on their end.
 	ReservedBy uuid.UUID
+	// a random secret the server generates each time this Job is reserved,
+	// that only the reserving client gets told; Execute() gives it to the Cmd
+	// as WR_JOB_TOKEN so that it can AddChildren(). Unlike ReservedBy, it
+	// can't be used to do anything else with the Job (or the client's other
+	// Jobs).
+	ChildToken string
 	// on the server we don't store EnvC with the job, but look it up in db via
 	// this key.
 	EnvKey string
@@ -228,6 +278,9 @@ type Job struct {
 	Similar int
 	// name of the queue the Job was added to.
 	Queue string
+	// the key of the Job that added this Job as one of its children (see
+	// Client.AddChildren()), if any.
+	Parent string
 
 	// we add this internally to match up runners we spawn via the scheduler to
 	// the Jobs they're allowed to ReserveFiltered().
@@ -244,9 +297,35 @@ type Job struct {
 	// killCalled is set for running jobs if Kill() is called on them
 	killCalled bool
 
+	// the server holds on to the STDERR of a failed run here until it knows
+	// why it failed and can record the attempt
+	attemptStdErrC []byte
+
+	// the server holds on to the latest resource series sent by Touch()es of
+	// a running job here, only storing it every ResourceSeriesStoreTouches
+	seriesC       []byte
+	seriesTouches int
+
+	// the server keeps a copy of Requirements here, guarded by its own mutex,
+	// so that Reserve() can match jobs against a runner's capacity while the
+	// queue is locked, without having to lock the job
+	fitReqs      *scheduler.Requirements
+	fitReqsMutex sync.RWMutex
+
+	// the address of the server the Job was got from, which the Client sets
+	// so that Env() can include WR_MANAGER; this is purely client side
+	managerAddr string
+
 	sync.RWMutex
 }
 
+// Key returns the unique key that describes this Job, which is what Env()
+// provides to the Job's Cmd as WR_JOB_KEY, and what you would supply to
+// Client.GetChildren() to get the Jobs that this Job added.
+func (j *Job) Key() string {
+	return j.key()
+}
+
 // WallTime returns the time the job took to run if it ran to completion, or the
 // time taken so far if it is currently running.
 func (j *Job) WallTime() (d time.Duration) {
@@ -265,7 +344,9 @@ func (j *Job) WallTime() (d time.Duration) {
 // is only populated if you got the Job from GetByCmd(_, _, true) or Reserve().
 // If no environment variables were passed in when the job was Add()ed to the
 // queue, returns current environment variables instead. In both cases, alters
-// the return value to apply any overrides stored in job.EnvOverride.
+// the return value to apply any overrides stored in job.EnvOverride, and then
+// to include the WR_* variables that describe the Job to its Cmd (see
+// Execute()).
 func (j *Job) Env() (env []string, err error) {
 	overrideEs := &envStr{}
 	if len(j.EnvOverride) > 0 {
@@ -287,6 +368,7 @@ func (j *Job) Env() (env []string, err error) {
 		if len(overrideEs.Environ) > 0 {
 			env = envOverride(env, overrideEs.Environ)
 		}
+		env = envOverride(env, j.contextEnv())
 		return
 	}
 
@@ -310,10 +392,34 @@ func (j *Job) Env() (env []string, err error) {
 	if len(overrideEs.Environ) > 0 {
 		env = envOverride(env, overrideEs.Environ)
 	}
+	env = envOverride(env, j.contextEnv())
 
 	return
 }
 
+// contextEnv returns the WR_* environment variables that tell a Job's Cmd about
+// the Job it is running as. WR_ATTEMPT is the attempt that is running (or last
+// ran), or the upcoming attempt if the Cmd hasn't been started since the Job
+// was last reserved.
+func (j *Job) contextEnv() []string {
+	attempt := j.Attempts
+	if j.StartTime.IsZero() {
+		attempt++
+	}
+	env := []string{
+		"WR_JOB_KEY=" + j.key(),
+		"WR_REPGROUP=" + j.RepGroup,
+		"WR_ATTEMPT=" + strconv.Itoa(int(attempt)),
+	}
+	if j.Requirements != nil {
+		env = append(env, "WR_CORES="+strconv.Itoa(j.Requirements.Cores), "WR_RAM_MB="+strconv.Itoa(j.Requirements.RAM))
+	}
+	if j.managerAddr != "" {
+		env = append(env, "WR_MANAGER="+j.managerAddr)
+	}
+	return env
+}
+
 // StdOut returns the decompressed job.StdOutC, which is the head and tail of
 // job.Cmd's STDOUT when it ran. If the Cmd hasn't run yet, or if it output
 // nothing to STDOUT, you will get an empty string. Note that StdOutC is only
@@ -348,6 +454,86 @@ func (j *Job) StdErr() (stderr string, err error) {
 	return
 }
 
+// ResourceSeries returns the decompressed job.ResourceSeriesC, which is a series
+// of measurements of the resources used by the Job's Cmd while it was running
+// (for its most recent run). Note that ResourceSeriesC is only populated if you
+// got the Job with getStd true, or are the Client that ran it.
+func (j *Job) ResourceSeries() (samples []*ResourceSample, err error) {
+	if len(j.ResourceSeriesC) == 0 {
+		return
+	}
+	decomp, err := decompress(j.ResourceSeriesC)
+	if err != nil {
+		return
+	}
+	ch := new(codec.BincHandle)
+	dec := codec.NewDecoderBytes(decomp, ch)
+	err = dec.Decode(&samples)
+	return
+}
+
+// SetScript sets a (multi-line) script that will be run by the Job's
+// Interpreter (or the shell supplied to Execute(), if there is no Interpreter)
+// instead of Cmd. You should still set Cmd to something that describes the
+// script, since that is what will be reported on. Jobs with different scripts
+// are different Jobs, even if they have the same Cmd.
+func (j *Job) SetScript(script string) {
+	j.ScriptKey = byteKey([]byte(script))
+	j.ScriptC = compress([]byte(script))
+}
+
+// Script returns the decompressed job.ScriptC, which is the script set with
+// SetScript(). If no script was set, you will get an empty string. Note that
+// ScriptC is only populated if you got the Job from GetByCmd(_, _, true) or
+// Reserve().
+func (j *Job) Script() (script string, err error) {
+	if len(j.ScriptC) == 0 {
+		return
+	}
+	decomp, err := decompress(j.ScriptC)
+	if err != nil {
+		return
+	}
+	script = string(decomp)
+	return
+}
+
+// writeScript writes the Job's script (or Cmd, if it doesn't have a script) to
+// a new file in dir (or the system temp directory if dir is empty), returning
+// the path to the file. Note that ScriptC must have been populated for Jobs
+// with a script, as it is for Jobs you Reserve().
+func (j *Job) writeScript(dir string) (path string, err error) {
+	script := j.Cmd
+	if j.ScriptKey != "" {
+		if len(j.ScriptC) == 0 {
+			err = fmt.Errorf("the job's script was not retrieved")
+			return
+		}
+		script, err = j.Script()
+		if err != nil {
+			return
+		}
+	}
+
+	f, err := ioutil.TempFile(dir, "wr_script.")
+	if err != nil {
+		return
+	}
+	path = f.Name()
+	_, err = f.WriteString(script)
+	if err == nil {
+		err = f.Chmod(0700)
+	}
+	errc := f.Close()
+	if err == nil {
+		err = errc
+	}
+	if err != nil {
+		os.Remove(path)
+	}
+	return
+}
+
 // TriggerBehaviours triggers this Job's Behaviours based on if its Cmd got
 // executed successfully or not. Should only be called as part of or after
 // Execute().
@@ -363,11 +549,9 @@ func (j *Job) TriggerBehaviours(success bool) error {
 // are treated relative to the CacheBase.
 func (j *Job) Mount() error {
 	cwd := j.Cwd
-	defaultMount := filepath.Join(j.Cwd, "mnt")
 	defaultCacheBase := cwd
 	if j.ActualCwd != "" {
 		cwd = j.ActualCwd
-		defaultMount = cwd
 		defaultCacheBase = filepath.Dir(cwd)
 	}
 
@@ -409,14 +593,7 @@ func (j *Job) Mount() error {
 			retries = mc.Retries
 		}
 
-		mount := mc.Mount
-		if mount != "" {
-			if !filepath.IsAbs(mount) {
-				mount = filepath.Join(cwd, mount)
-			}
-		} else {
-			mount = defaultMount
-		}
+		mount := j.mountPoint(mc)
 		cacheBase := mc.CacheBase
 		if cacheBase != "" {
 			if !filepath.IsAbs(cacheBase) {
@@ -468,6 +645,27 @@ func (j *Job) Mount() error {
 	return nil
 }
 
+// mountPoint returns the absolute path of the directory that Mount() will
+// mount the given MountConfig (one of our MountConfigs) on.
+func (j *Job) mountPoint(mc MountConfig) string {
+	cwd := j.Cwd
+	defaultMount := filepath.Join(j.Cwd, "mnt")
+	if j.ActualCwd != "" {
+		cwd = j.ActualCwd
+		defaultMount = cwd
+	}
+
+	mount := mc.Mount
+	if mount != "" {
+		if !filepath.IsAbs(mount) {
+			mount = filepath.Join(cwd, mount)
+		}
+	} else {
+		mount = defaultMount
+	}
+	return mount
+}
+
 // Unmount unmounts any remote filesystems that were previously mounted with
 // Mount(). Returns nil if Mount() had not been called or there were no
 // MountConfigs. Note that for cached writable mounts, created files will only
@@ -518,37 +716,68 @@ func (j *Job) Unmount(stopUploads ...bool) (logs string, err error) {
 	return
 }
 
-// updateRecsAfterFailure checks the FailReason and bumps RAM or Time as
-// appropriate.
+// updateRecsAfterFailure checks the FailReason and bumps RAM, Time or Disk as
+// appropriate, according to our Escalation.
 func (j *Job) updateRecsAfterFailure() {
 	switch j.FailReason {
 	case FailReasonRAM:
-		// increase by 1GB or [100% if under 8GB, 30% if over], whichever is
-		// greater, and round up to nearest 100
+		// by default increase by 1GB or [100% if under 8GB, 30% if over],
+		// whichever is greater, and round up to nearest 100
 		// *** increase to greater than max seen for jobs in our ReqGroup?
-		updatedMB := float64(j.PeakRAM)
-		if updatedMB <= RAMIncreaseMultBreakpoint {
-			updatedMB *= RAMIncreaseMultLow
-		} else {
-			updatedMB *= RAMIncreaseMultHigh
-		}
-		if updatedMB < float64(j.PeakRAM)+RAMIncreaseMin {
-			updatedMB = float64(j.PeakRAM) + RAMIncreaseMin
-		}
-		j.Requirements.RAM = int(math.Ceil(updatedMB/100) * 100)
+		j.Requirements.RAM = j.Escalation.ram(j.PeakRAM)
 		j.Override = uint8(1)
 	case FailReasonTime:
-		j.Requirements.Time += 1 * time.Hour
+		j.Requirements.Time = j.Escalation.time(j.Requirements.Time)
+		j.Override = uint8(1)
+	case FailReasonDisk:
+		j.Requirements.Disk = j.Escalation.disk(j.Requirements.Disk, j.PeakDisk)
 		j.Override = uint8(1)
 	}
+	j.snapshotRequirements()
+}
+
+// snapshotRequirements stores a copy of the job's current Requirements, to be
+// returned by fitRequirements(). Call it whenever Requirements change, while
+// holding the job's lock (or otherwise being its only user).
+func (j *Job) snapshotRequirements() {
+	var req *scheduler.Requirements
+	if j.Requirements != nil {
+		reqCopy := *j.Requirements
+		req = &reqCopy
+	}
+	j.fitReqsMutex.Lock()
+	defer j.fitReqsMutex.Unlock()
+	j.fitReqs = req
+}
+
+// fitRequirements returns the copy of Requirements stored by the last call to
+// snapshotRequirements(). Unlike Requirements itself, it is safe to call this
+// without holding the job's lock.
+func (j *Job) fitRequirements() *scheduler.Requirements {
+	j.fitReqsMutex.RLock()
+	defer j.fitReqsMutex.RUnlock()
+	return j.fitReqs
 }
 
 // key calculates a unique key to describe the job.
 func (j *Job) key() string {
 	if j.CwdMatters {
-		return byteKey([]byte(fmt.Sprintf("%s.%s.%s", j.Cwd, j.Cmd, j.MountConfigs.Key())))
+		return byteKey([]byte(fmt.Sprintf("%s.%s.%s%s", j.Cwd, j.Cmd, j.MountConfigs.Key(), jobKeySuffix(j.Interpreter, j.ScriptKey, j.Container))))
+	}
+	return byteKey([]byte(fmt.Sprintf("%s.%s%s", j.Cmd, j.MountConfigs.Key(), jobKeySuffix(j.Interpreter, j.ScriptKey, j.Container))))
+}
+
+// jobKeySuffix returns the part of a job key that comes from its Container,
+// Interpreter and script, which is nothing if none of those are set (so that
+// jobs without them have the same keys they always had).
+func jobKeySuffix(interpreter string, scriptKey string, cc *ContainerConfig) (suffix string) {
+	if cc != nil {
+		suffix = "." + cc.Key()
 	}
-	return byteKey([]byte(fmt.Sprintf("%s.%s", j.Cmd, j.MountConfigs.Key())))
+	if interpreter != "" || scriptKey != "" {
+		suffix += "." + interpreter + ":" + scriptKey
+	}
+	return
 }
 
 // getScheduledRunner provides a thread-safe way of getting the scheduledRunner
@@ -600,6 +829,16 @@ type JobEssence struct {
 
 	// Mounts should only be set if the Job was created with Mounts
 	MountConfigs MountConfigs
+
+	// Container should only be set if the Job was created with a Container
+	Container *ContainerConfig
+
+	// Interpreter should only be set if the Job was created with one.
+	Interpreter string
+
+	// Script should only be set if the Job was created with SetScript(), in
+	// which case it should be the script supplied to that.
+	Script string
 }
 
 // Key returns the same value that key() on the matching Job would give you.
@@ -608,10 +847,14 @@ func (j *JobEssence) Key() string {
 		return j.JobKey
 	}
 
+	var scriptKey string
+	if j.Script != "" {
+		scriptKey = byteKey([]byte(j.Script))
+	}
 	if j.Cwd != "" {
-		return byteKey([]byte(fmt.Sprintf("%s.%s.%s", j.Cwd, j.Cmd, j.MountConfigs.Key())))
+		return byteKey([]byte(fmt.Sprintf("%s.%s.%s%s", j.Cwd, j.Cmd, j.MountConfigs.Key(), jobKeySuffix(j.Interpreter, scriptKey, j.Container))))
 	}
-	return byteKey([]byte(fmt.Sprintf("%s.%s", j.Cmd, j.MountConfigs.Key())))
+	return byteKey([]byte(fmt.Sprintf("%s.%s%s", j.Cmd, j.MountConfigs.Key(), jobKeySuffix(j.Interpreter, scriptKey, j.Container))))
 }
 
 // Stringify returns a nice printable form of a JobEssence.
@@ -625,3 +868,49 @@ func (j *JobEssence) Stringify() string {
 	}
 	return out
 }
+
+// JobFilter describes a subset of Jobs, used with GetByFilter(). Properties
+// left at their zero value match all Jobs.
+type JobFilter struct {
+	// RepGroup restricts to Jobs with this RepGroup, including complete ones.
+	// When not set, only incomplete Jobs are considered.
+	RepGroup string
+
+	// State restricts to Jobs in this state. JobStateDeletable is allowed, as
+	// it is with GetByRepGroup().
+	State JobState
+
+	// FailReason restricts to Jobs that last failed for this reason.
+	FailReason string
+
+	// Exitcode, when not nil, restricts to Jobs that last exited with this
+	// code.
+	Exitcode *int
+
+	// Host restricts to Jobs that last ran on this host.
+	Host string
+
+	// ReqGroup restricts to Jobs with this ReqGroup.
+	ReqGroup string
+}
+
+// matches tells you if the given Job is described by the properties of this
+// filter other than RepGroup and State (which are dealt with when getting the
+// Jobs in the first place).
+func (f *JobFilter) matches(job *Job) bool {
+	job.RLock()
+	defer job.RUnlock()
+	if f.FailReason != "" && job.FailReason != f.FailReason {
+		return false
+	}
+	if f.Exitcode != nil && (!job.Exited || job.Exitcode != *f.Exitcode) {
+		return false
+	}
+	if f.Host != "" && job.Host != f.Host {
+		return false
+	}
+	if f.ReqGroup != "" && job.ReqGroup != f.ReqGroup {
+		return false
+	}
+	return true
+}
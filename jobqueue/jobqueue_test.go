@@ -19,6 +19,8 @@
 package jobqueue
 
 import (
+	"compress/gzip"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"github.com/VertebrateResequencing/muxfys"
@@ -363,8 +365,10 @@ func TestJobqueue(t *testing.T) {
 				for i := 11; i <= 100; i++ {
 					job := &Job{Cmd: fmt.Sprintf("test cmd %d", i), Cwd: "/fake/cwd", ReqGroup: "fake_group", Requirements: &jqs.Requirements{RAM: 1024, Time: 4 * time.Hour, Cores: 1}, Retries: uint8(3), RepGroup: "manually_added"}
 					job.PeakRAM = i * 100
+					job.PeakDisk = i * 30
 					job.StartTime = time.Now()
 					job.EndTime = job.StartTime.Add(time.Duration(i*100) * time.Second)
+					job.CPUtime = time.Duration(i*250) * time.Second
 					server.db.updateJobAfterExit(job, []byte{}, []byte{}, false)
 				}
 				<-time.After(500 * time.Millisecond)
@@ -374,6 +378,84 @@ func TestJobqueue(t *testing.T) {
 				rtime, err = server.db.recommendedReqGroupTime("fake_group")
 				So(err, ShouldBeNil)
 				So(rtime, ShouldEqual, 10800)
+				rdisk, err := server.db.recommendedReqGroupDisk("fake_group")
+				So(err, ShouldBeNil)
+				So(rdisk, ShouldEqual, 3)
+				rcores, err := server.db.recommendedReqGroupCores("fake_group")
+				So(err, ShouldBeNil)
+				So(rcores, ShouldEqual, 3)
+
+				Convey("You can inspect, trim, pin and reset what was learned", func() {
+					stats, err := jq.GetReqGroupStats("")
+					So(err, ShouldBeNil)
+					var found bool
+					for _, rgs := range stats {
+						if rgs.ReqGroup == "fake_group" {
+							found = true
+						}
+					}
+					So(found, ShouldBeTrue)
+
+					stats, err = jq.GetReqGroupStats("fake_group")
+					So(err, ShouldBeNil)
+					So(len(stats), ShouldEqual, 1)
+					mem := stats[0].Memory
+					So(mem, ShouldNotBeNil)
+					So(mem.Samples, ShouldEqual, 100)
+					So(mem.Min, ShouldEqual, 1)
+					So(mem.Max, ShouldEqual, 10000)
+					So(mem.Median, ShouldEqual, 5000)
+					So(mem.Percentile95, ShouldEqual, 9500)
+					So(mem.Recommendation, ShouldEqual, 9500)
+					So(len(mem.Histogram), ShouldEqual, ReqGroupHistogramBins)
+					total := 0
+					for _, bin := range mem.Histogram {
+						total += bin.Count
+					}
+					So(total, ShouldEqual, 100)
+					So(mem.Histogram[0].Count, ShouldEqual, 10)
+					So(stats[0].Time.Samples, ShouldEqual, 100)
+					So(stats[0].Disk.Samples, ShouldEqual, 91)
+					So(stats[0].Disk.Recommendation, ShouldEqual, 3)
+					So(stats[0].Cores.Samples, ShouldEqual, 2)
+					So(stats[0].Cores.Recommendation, ShouldEqual, 3)
+					So(stats[0].Pin, ShouldBeNil)
+
+					removed, err := jq.TrimReqGroup("fake_group", ReqGroupStatMemory, 100, 0)
+					So(err, ShouldBeNil)
+					So(removed, ShouldEqual, 10)
+					_, err = jq.TrimReqGroup("fake_group", "foo", 100, 0)
+					So(err, ShouldNotBeNil)
+
+					err = jq.PinReqGroup("fake_group", &ReqGroupPin{RAM: 500})
+					So(err, ShouldBeNil)
+					stats, err = jq.GetReqGroupStats("fake_group")
+					So(err, ShouldBeNil)
+					So(stats[0].Memory.Samples, ShouldEqual, 90)
+					So(stats[0].Memory.Min, ShouldEqual, 1100)
+					So(stats[0].Pin, ShouldNotBeNil)
+					So(stats[0].Pin.RAM, ShouldEqual, 500)
+					req := server.db.recommendedReqGroupRequirements("fake_group")
+					So(req, ShouldNotBeNil)
+					So(req.RAM, ShouldEqual, 500)
+					So(req.Time, ShouldEqual, 10800*time.Second)
+
+					removed, err = jq.ResetReqGroup("fake_group")
+					So(err, ShouldBeNil)
+					So(removed, ShouldEqual, 283)
+					stats, err = jq.GetReqGroupStats("fake_group")
+					So(err, ShouldBeNil)
+					So(stats[0].Memory, ShouldBeNil)
+					So(stats[0].Pin, ShouldNotBeNil)
+					req = server.db.recommendedReqGroupRequirements("fake_group")
+					So(req, ShouldNotBeNil)
+					So(req.RAM, ShouldEqual, 500)
+					So(req.Time, ShouldEqual, 0)
+
+					err = jq.PinReqGroup("fake_group", nil)
+					So(err, ShouldBeNil)
+					So(server.db.recommendedReqGroupRequirements("fake_group"), ShouldBeNil)
+				})
 			})
 
 			Convey("You can reserve jobs from the queue in the correct order", func() {
@@ -483,6 +565,49 @@ func TestJobqueue(t *testing.T) {
 					So(err, ShouldBeNil)
 					So(job, ShouldBeNil)
 				})
+
+				Convey("You can reserve only jobs that fit within your capacity", func() {
+					noneFit := func(err error) bool {
+						jqerr, ok := err.(Error)
+						return ok && jqerr.Err == ErrNoneFit
+					}
+					job, err := jq.ReserveFitting(10*time.Millisecond, "2048:60:2:0", &Capacity{RAM: 1024})
+					So(noneFit(err), ShouldBeTrue)
+					So(job, ShouldBeNil)
+					job, err = jq.ReserveFitting(10*time.Millisecond, "2048:60:2:0", &Capacity{Time: 30 * time.Minute, RAM: 4096, Cores: 4})
+					So(noneFit(err), ShouldBeTrue)
+					So(job, ShouldBeNil)
+					job, err = jq.ReserveFitting(10*time.Millisecond, "2048:60:2:0", &Capacity{Cores: 1})
+					So(noneFit(err), ShouldBeTrue)
+					So(job, ShouldBeNil)
+
+					// that's distinct from there being nothing ready at all
+					job, err = jq.ReserveFitting(10*time.Millisecond, "99:1:1:0", &Capacity{Cores: 1})
+					So(err, ShouldBeNil)
+					So(job, ShouldBeNil)
+
+					job, err = jq.ReserveFitting(10*time.Millisecond, "2048:60:2:0", &Capacity{Time: 2 * time.Hour, RAM: 2048, Cores: 2})
+					So(err, ShouldBeNil)
+					So(job, ShouldNotBeNil)
+					So(job.Cmd, ShouldEqual, "test cmd 10")
+
+					job, err = jq.ReserveFitting(10*time.Millisecond, "1024:240:1:0", &Capacity{Time: 3 * time.Hour})
+					So(noneFit(err), ShouldBeTrue)
+					So(job, ShouldBeNil)
+					job, err = jq.ReserveFitting(10*time.Millisecond, "1024:240:1:0", &Capacity{Time: 5 * time.Hour, Cores: 1})
+					So(err, ShouldBeNil)
+					So(job, ShouldNotBeNil)
+					So(job.Cmd, ShouldEqual, "test cmd 9")
+
+					job, err = jq.ReserveFitting(10*time.Millisecond, "1024:240:1:0", nil)
+					So(err, ShouldBeNil)
+					So(job, ShouldNotBeNil)
+					So(job.Cmd, ShouldEqual, "test cmd 8")
+
+					stats, err := jq.GetByRepGroup("manually_added", 0, JobStateReserved, false, false)
+					So(err, ShouldBeNil)
+					So(len(stats), ShouldEqual, 3)
+				})
 			})
 
 			Convey("You can add more jobs, but without any environment variables", func() {
@@ -587,73 +712,1356 @@ func TestJobqueue(t *testing.T) {
 				So(ok, ShouldBeTrue)
 				So(jqerr.Err, ShouldEqual, ErrNoServer)
 
-				server, _, err = Serve(serverConfig)
+				server, _, err = Serve(serverConfig)
+				So(err, ShouldBeNil)
+
+				jq, err = Connect(addr, "test_queue", clientConnectTime)
+				So(err, ShouldBeNil)
+				jq.Disconnect()
+
+				syscall.Kill(os.Getpid(), syscall.SIGINT)
+				<-time.After(ClientTouchInterval)
+				<-time.After(ClientTouchInterval)
+				_, err = Connect(addr, "test_queue", clientConnectTime)
+				So(err, ShouldNotBeNil)
+				jqerr, ok = err.(Error)
+				So(ok, ShouldBeTrue)
+				So(jqerr.Err, ShouldEqual, ErrNoServer)
+			})
+
+			Convey("You get a nice error if you send the server junk", func() {
+				_, err := jq.request(&clientRequest{Method: "junk", Queue: "test_queue"})
+				So(err, ShouldNotBeNil)
+				jqerr, ok := err.(Error)
+				So(ok, ShouldBeTrue)
+				So(jqerr.Err, ShouldEqual, ErrUnknownCommand)
+				jq.Disconnect()
+			})
+		})
+
+		Reset(func() {
+			server.Stop(true)
+		})
+	})
+
+	if server != nil {
+		server.Stop(true)
+	}
+
+	// start these tests anew because I don't want to mess with the timings in
+	// the above tests
+	Convey("Once a new jobqueue server is up", t, func() {
+		ServerItemTTR = 200 * time.Millisecond
+		ClientTouchInterval = 50 * time.Millisecond
+		server, _, err = Serve(serverConfig)
+		So(err, ShouldBeNil)
+
+		Convey("You can connect, and add some real jobs", func() {
+			jq, err := Connect(addr, "test_queue", clientConnectTime)
+			So(err, ShouldBeNil)
+			defer jq.Disconnect()
+			jq2, err := Connect(addr, "test_queue", clientConnectTime)
+			So(err, ShouldBeNil)
+			defer jq2.Disconnect()
+
+			var jobs []*Job
+			jobs = append(jobs, &Job{Cmd: "sleep 0.1 && true", Cwd: "/tmp", ReqGroup: "fake_group", Requirements: standardReqs, Retries: uint8(2), RepGroup: "manually_added"})
+			jobs = append(jobs, &Job{Cmd: "sleep 0.1 && false", Cwd: "/tmp", ReqGroup: "fake_group", Requirements: standardReqs, Retries: uint8(2), RepGroup: "manually_added"})
+			inserts, already, err := jq.Add(jobs, envVars, true)
+			So(err, ShouldBeNil)
+			So(inserts, ShouldEqual, 2)
+			So(already, ShouldEqual, 0)
+
+			Convey("You can't execute a job without reserving it", func() {
+				err := jq.Execute(jobs[0], config.RunnerExecShell)
+				So(err, ShouldNotBeNil)
+				jqerr, ok := err.(Error)
+				So(ok, ShouldBeTrue)
+				So(jqerr.Err, ShouldEqual, ErrMustReserve)
+				jq.Disconnect()
+			})
+
+			Convey("You can execute a job in a container", func() {
+				// we use a stub singularity that just runs the command in the
+				// requested working directory
+				stubDir, err := ioutil.TempDir("", "wr_jobqueue_test_container_")
+				So(err, ShouldBeNil)
+				defer os.RemoveAll(stubDir)
+				argsFile := filepath.Join(stubDir, "args")
+				stub := filepath.Join(stubDir, "singularity")
+				err = ioutil.WriteFile(stub, []byte(`#!/bin/bash
+echo "$@" > `+argsFile+`
+if [ "$1" != "exec" ]; then exit 255; fi
+shift
+while [ "${1:0:2}" == "--" ]; do
+    if [ "$1" == "--pwd" ]; then cd "$2"; fi
+    shift 2
+done
+shift
+exec "$@"
+`), 0700)
+				So(err, ShouldBeNil)
+
+				container := &ContainerConfig{Image: "test.img", Runtime: stub, Binds: []string{"/tmp:/extra:ro"}}
+				cjob := &Job{Cmd: "pwd > pwd.out", Cwd: "/tmp", ReqGroup: "fake_group", Requirements: standardReqs, Priority: 255, RepGroup: "container", Container: container}
+				inserts, already, err := jq.Add([]*Job{cjob}, envVars, true)
+				So(err, ShouldBeNil)
+				So(inserts, ShouldEqual, 1)
+				So(already, ShouldEqual, 0)
+
+				job, err := jq.Reserve(50 * time.Millisecond)
+				So(err, ShouldBeNil)
+				So(job.Cmd, ShouldEqual, "pwd > pwd.out")
+				So(job.Container, ShouldNotBeNil)
+				So(job.Container.Image, ShouldEqual, "test.img")
+
+				err = jq.Execute(job, config.RunnerExecShell)
+				So(err, ShouldBeNil)
+				So(job.State, ShouldEqual, JobStateComplete)
+				So(job.Exitcode, ShouldEqual, 0)
+				So(job.PeakRAM, ShouldBeGreaterThan, 0)
+
+				pwd, err := ioutil.ReadFile(filepath.Join(job.ActualCwd, "pwd.out"))
+				So(err, ShouldBeNil)
+				So(strings.TrimSpace(string(pwd)), ShouldEqual, job.ActualCwd)
+
+				args, err := ioutil.ReadFile(argsFile)
+				So(err, ShouldBeNil)
+				So(string(args), ShouldStartWith, "exec --pwd "+job.ActualCwd+" --bind "+job.ActualCwd+" --bind ")
+				So(string(args), ShouldEndWith, " --bind /tmp:/extra:ro test.img "+config.RunnerExecShell+" -c pwd > pwd.out\n")
+
+				// the container is part of what makes the job unique
+				got, err := jq2.GetByEssence(&JobEssence{Cmd: "pwd > pwd.out"}, false, false)
+				So(err, ShouldBeNil)
+				So(got, ShouldBeNil)
+				got, err = jq2.GetByEssence(&JobEssence{Cmd: "pwd > pwd.out", Container: container}, false, false)
+				So(err, ShouldBeNil)
+				So(got, ShouldNotBeNil)
+				So(got.State, ShouldEqual, JobStateComplete)
+			})
+
+			Convey("You can execute a multi-line script with its own interpreter", func() {
+				script := "# a comment\nfoo='a b'\necho \"$foo\" > script.out\necho $0 >> script.out\n"
+				sjob := &Job{Cmd: "run my script", Interpreter: "sh", Cwd: "/tmp", ReqGroup: "fake_group", Requirements: standardReqs, Priority: 255, RepGroup: "script"}
+				sjob.SetScript(script)
+				inserts, already, err := jq.Add([]*Job{sjob}, envVars, true)
+				So(err, ShouldBeNil)
+				So(inserts, ShouldEqual, 1)
+				So(already, ShouldEqual, 0)
+
+				// the script isn't stored with the job, but is retrieved on
+				// request
+				got, err := jq2.GetByEssence(&JobEssence{Cmd: "run my script", Interpreter: "sh", Script: script}, false, false)
+				So(err, ShouldBeNil)
+				So(got, ShouldNotBeNil)
+				So(got.ScriptC, ShouldBeEmpty)
+				got, err = jq2.GetByEssence(&JobEssence{Cmd: "run my script", Interpreter: "sh"}, false, false)
+				So(err, ShouldBeNil)
+				So(got, ShouldBeNil)
+
+				job, err := jq.Reserve(50 * time.Millisecond)
+				So(err, ShouldBeNil)
+				So(job.Cmd, ShouldEqual, "run my script")
+				So(job.Interpreter, ShouldEqual, "sh")
+				gotScript, err := job.Script()
+				So(err, ShouldBeNil)
+				So(gotScript, ShouldEqual, script)
+
+				err = jq.Execute(job, config.RunnerExecShell)
+				So(err, ShouldBeNil)
+				So(job.State, ShouldEqual, JobStateComplete)
+				So(job.Exitcode, ShouldEqual, 0)
+
+				out, err := ioutil.ReadFile(filepath.Join(job.ActualCwd, "script.out"))
+				So(err, ShouldBeNil)
+				lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+				So(len(lines), ShouldEqual, 2)
+				So(lines[0], ShouldEqual, "a b")
+				So(filepath.Base(lines[1]), ShouldStartWith, "wr_script.")
+
+				got, err = jq2.GetByEssence(&JobEssence{Cmd: "run my script", Interpreter: "sh", Script: script}, false, false)
+				So(err, ShouldBeNil)
+				So(got, ShouldNotBeNil)
+				So(got.State, ShouldEqual, JobStateComplete)
+			})
+
+			Convey("You can execute multiple jobs at once while touching them together", func() {
+				killCmd := "sleep 20 && echo multi"
+				var mjobs []*Job
+				for i := 0; i < 3; i++ {
+					mjobs = append(mjobs, &Job{Cmd: fmt.Sprintf("sleep 1 && echo multi%d", i), Cwd: "/tmp", ReqGroup: "fake_group", Requirements: standardReqs, Priority: 255, RepGroup: "multi"})
+				}
+				mjobs = append(mjobs, &Job{Cmd: killCmd, Cwd: "/tmp", ReqGroup: "fake_group", Requirements: standardReqs, Priority: 255, RepGroup: "multi"})
+				inserts, _, err := jq.Add(mjobs, envVars, true)
+				So(err, ShouldBeNil)
+				So(inserts, ShouldEqual, 4)
+
+				stop := jq.CombineTouches()
+				defer stop()
+
+				var reserved []*Job
+				for i := 0; i < 4; i++ {
+					job, errr := jq.Reserve(50 * time.Millisecond)
+					So(errr, ShouldBeNil)
+					So(job, ShouldNotBeNil)
+					So(job.RepGroup, ShouldEqual, "multi")
+					reserved = append(reserved, job)
+				}
+
+				errs := make(chan error, len(reserved))
+				t := time.Now()
+				for _, job := range reserved {
+					go func(job *Job) {
+						errs <- jq.Execute(job, config.RunnerExecShell)
+					}(job)
+				}
+
+				<-time.After(500 * time.Millisecond)
+				killable, err := jq2.Kill([]*JobEssence{{Cmd: killCmd}})
+				So(err, ShouldBeNil)
+				So(killable, ShouldEqual, 1)
+
+				var failures []string
+				for range reserved {
+					if errr := <-errs; errr != nil {
+						if jqerr, ok := errr.(Error); ok {
+							failures = append(failures, jqerr.Err)
+						} else {
+							failures = append(failures, errr.Error())
+						}
+					}
+				}
+				So(failures, ShouldResemble, []string{FailReasonKilled})
+				So(time.Since(t), ShouldBeLessThan, 3*time.Second)
+
+				// the jobs ran for longer than ServerItemTTR, so would have
+				// been lost had they not been touched
+				for i := 0; i < 3; i++ {
+					job, errg := jq.GetByEssence(&JobEssence{Cmd: mjobs[i].Cmd}, false, false)
+					So(errg, ShouldBeNil)
+					So(job.State, ShouldEqual, JobStateComplete)
+					history, errh := jq.GetHistory(job.Key())
+					So(errh, ShouldBeNil)
+					for _, e := range history {
+						So(e.Event, ShouldNotEqual, JobHistoryLost)
+					}
+				}
+				job, err := jq.GetByEssence(&JobEssence{Cmd: killCmd}, false, false)
+				So(err, ShouldBeNil)
+				So(job.State, ShouldEqual, JobStateBuried)
+				So(job.FailReason, ShouldEqual, FailReasonKilled)
+			})
+
+			Convey("Executed jobs see WR_* variables that describe them", func() {
+				wjob := &Job{Cmd: "echo $WR_JOB_KEY $WR_REPGROUP $WR_ATTEMPT $WR_CORES $WR_RAM_MB $WR_MANAGER && false", Cwd: "/tmp", ReqGroup: "fake_group", Requirements: standardReqs, Priority: 255, Retries: uint8(1), RepGroup: "wrvars"}
+				inserts, already, err := jq.Add([]*Job{wjob}, envVars, true)
+				So(err, ShouldBeNil)
+				So(inserts, ShouldEqual, 1)
+				So(already, ShouldEqual, 0)
+
+				job, err := jq.Reserve(50 * time.Millisecond)
+				So(err, ShouldBeNil)
+				So(job.RepGroup, ShouldEqual, "wrvars")
+				env, err := job.Env()
+				So(err, ShouldBeNil)
+				So(env, ShouldContain, "WR_JOB_KEY="+job.key())
+				So(env, ShouldContain, "WR_REPGROUP=wrvars")
+				So(env, ShouldContain, "WR_ATTEMPT=1")
+				So(env, ShouldContain, fmt.Sprintf("WR_CORES=%d", standardReqs.Cores))
+				So(env, ShouldContain, fmt.Sprintf("WR_RAM_MB=%d", standardReqs.RAM))
+				So(env, ShouldContain, "WR_MANAGER="+addr)
+
+				expected := fmt.Sprintf("%s wrvars %%d %d %d %s", job.key(), standardReqs.Cores, standardReqs.RAM, addr)
+				err = jq.Execute(job, config.RunnerExecShell)
+				So(err, ShouldNotBeNil)
+				So(job.FailReason, ShouldEqual, FailReasonExit)
+				stdout, err := job.StdOut()
+				So(err, ShouldBeNil)
+				So(stdout, ShouldEqual, fmt.Sprintf(expected, 1))
+
+				<-time.After(ClientReleaseDelay + 100*time.Millisecond)
+				job, err = jq.Reserve(50 * time.Millisecond)
+				So(err, ShouldBeNil)
+				So(job, ShouldNotBeNil)
+				So(job.RepGroup, ShouldEqual, "wrvars")
+				So(job.Attempts, ShouldEqual, 1)
+				err = jq.Execute(job, config.RunnerExecShell)
+				So(err, ShouldNotBeNil)
+				stdout, err = job.StdOut()
+				So(err, ShouldBeNil)
+				So(stdout, ShouldEqual, fmt.Sprintf(expected, 2))
+			})
+
+			Convey("Running jobs can add child jobs", func() {
+				pjob := &Job{Cmd: "echo parent", Cwd: "/tmp", ReqGroup: "fake_group", Requirements: standardReqs, Priority: 255, RepGroup: "parent", DepGroups: []string{"parent_dg"}}
+				djob := &Job{Cmd: "echo dependent", Cwd: "/tmp", ReqGroup: "fake_group", Requirements: standardReqs, Priority: 255, RepGroup: "parent", Dependencies: Dependencies{NewDepGroupDependency("parent_dg")}}
+				inserts, already, err := jq.Add([]*Job{pjob, djob}, envVars, true)
+				So(err, ShouldBeNil)
+				So(inserts, ShouldEqual, 2)
+				So(already, ShouldEqual, 0)
+
+				children := []*Job{
+					{Cmd: "echo child1", ReqGroup: "fake_group", Requirements: standardReqs, Priority: 255},
+					{Cmd: "echo child2", ReqGroup: "fake_group", Requirements: standardReqs, Priority: 255, RepGroup: "own"},
+				}
+
+				// the parent must be running
+				_, _, err = jq2.AddChildren(pjob.Key(), "", children, nil, true, true)
+				So(err, ShouldNotBeNil)
+				jqerr, ok := err.(Error)
+				So(ok, ShouldBeTrue)
+				So(jqerr.Err, ShouldEqual, ErrBadParent)
+
+				job, err := jq.Reserve(50 * time.Millisecond)
+				So(err, ShouldBeNil)
+				So(job.Cmd, ShouldEqual, "echo parent")
+
+				// and we must authenticate as it
+				_, _, err = jq2.AddChildren(job.Key(), "foo", children, nil, true, true)
+				So(err, ShouldNotBeNil)
+				jqerr, ok = err.(Error)
+				So(ok, ShouldBeTrue)
+				So(jqerr.Err, ShouldEqual, ErrBadParent)
+
+				// (with the token only it was given, not the id of the client
+				// that reserved it, which would let the Cmd control the client's
+				// other jobs)
+				So(job.ChildToken, ShouldNotBeBlank)
+				_, _, err = jq2.AddChildren(job.Key(), job.ReservedBy.String(), children, nil, true, true)
+				So(err, ShouldNotBeNil)
+				jqerr, ok = err.(Error)
+				So(ok, ShouldBeTrue)
+				So(jqerr.Err, ShouldEqual, ErrBadParent)
+
+				inserts, already, err = jq2.AddChildren(job.Key(), job.ChildToken, children, nil, true, true)
+				So(err, ShouldBeNil)
+				So(inserts, ShouldEqual, 2)
+				So(already, ShouldEqual, 0)
+
+				got, err := jq2.GetChildren(job.Key(), false, true)
+				So(err, ShouldBeNil)
+				So(len(got), ShouldEqual, 2)
+				byCmd := make(map[string]*Job)
+				for _, child := range got {
+					byCmd[child.Cmd] = child
+					So(child.Parent, ShouldEqual, job.Key())
+					So(child.Cwd, ShouldEqual, "/tmp")
+					So(child.DepGroups, ShouldContain, "parent_dg")
+					So(child.EnvKey, ShouldEqual, job.EnvKey)
+				}
+				So(byCmd["echo child1"], ShouldNotBeNil)
+				So(byCmd["echo child1"].RepGroup, ShouldEqual, "parent")
+				So(byCmd["echo child2"], ShouldNotBeNil)
+				So(byCmd["echo child2"].RepGroup, ShouldEqual, "own")
+
+				// since we waited, the parent's dependents wait for the
+				// children as well as the parent
+				err = jq.Execute(job, config.RunnerExecShell)
+				So(err, ShouldBeNil)
+				So(job.State, ShouldEqual, JobStateComplete)
+
+				dep, err := jq2.GetByEssence(&JobEssence{Cmd: "echo dependent"}, false, false)
+				So(err, ShouldBeNil)
+				So(dep, ShouldNotBeNil)
+				So(dep.State, ShouldEqual, JobStateDependent)
+
+				for i := 0; i < 2; i++ {
+					job, err = jq.Reserve(50 * time.Millisecond)
+					So(err, ShouldBeNil)
+					So(job, ShouldNotBeNil)
+					So(job.Cmd, ShouldStartWith, "echo child")
+					err = jq.Execute(job, config.RunnerExecShell)
+					So(err, ShouldBeNil)
+				}
+
+				dep, err = jq2.GetByEssence(&JobEssence{Cmd: "echo dependent"}, false, false)
+				So(err, ShouldBeNil)
+				So(dep, ShouldNotBeNil)
+				So(dep.State, ShouldEqual, JobStateReady)
+
+				got, err = jq2.GetChildren(pjob.Key(), false, false)
+				So(err, ShouldBeNil)
+				So(len(got), ShouldEqual, 2)
+				for _, child := range got {
+					So(child.State, ShouldEqual, JobStateComplete)
+				}
+			})
+
+			Convey("Jobs follow their retry policy", func() {
+				policy := &RetryPolicy{Delay: 300 * time.Millisecond, Backoff: 2, ExitCodes: []int{1}, SuccessExitCodes: []int{3}}
+				rjobs := []*Job{
+					{Cmd: "exit 3", Cwd: "/tmp", ReqGroup: "fake_group", Requirements: standardReqs, Priority: 255, Retries: uint8(2), RepGroup: "retry", RetryPolicy: policy},
+					{Cmd: "exit 2", Cwd: "/tmp", ReqGroup: "fake_group", Requirements: standardReqs, Priority: 254, Retries: uint8(2), RepGroup: "retry", RetryPolicy: policy},
+					{Cmd: "exit 1", Cwd: "/tmp", ReqGroup: "fake_group", Requirements: standardReqs, Priority: 253, Retries: uint8(2), RepGroup: "retry", RetryPolicy: policy},
+				}
+				inserts, already, err := jq.Add(rjobs, envVars, true)
+				So(err, ShouldBeNil)
+				So(inserts, ShouldEqual, 3)
+				So(already, ShouldEqual, 0)
+
+				// a success exit code completes the job
+				job, err := jq.Reserve(50 * time.Millisecond)
+				So(err, ShouldBeNil)
+				So(job.Cmd, ShouldEqual, "exit 3")
+				So(job.RetryPolicy, ShouldNotBeNil)
+				So(job.RetryPolicy.SuccessExitCodes, ShouldResemble, []int{3})
+				err = jq.Execute(job, config.RunnerExecShell)
+				So(err, ShouldBeNil)
+				So(job.State, ShouldEqual, JobStateComplete)
+				So(job.Exitcode, ShouldEqual, 3)
+
+				// an exit code not in ExitCodes is buried straight away
+				job, err = jq.Reserve(50 * time.Millisecond)
+				So(err, ShouldBeNil)
+				So(job.Cmd, ShouldEqual, "exit 2")
+				err = jq.Execute(job, config.RunnerExecShell)
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldContainSubstring, "retry policy")
+				So(job.State, ShouldEqual, JobStateBuried)
+				So(job.FailReason, ShouldEqual, FailReasonExit)
+
+				// an exit code in ExitCodes is retried after a delay that backs
+				// off
+				job, err = jq.Reserve(50 * time.Millisecond)
+				So(err, ShouldBeNil)
+				So(job.Cmd, ShouldEqual, "exit 1")
+				err = jq.Execute(job, config.RunnerExecShell)
+				So(err, ShouldNotBeNil)
+				So(job.State, ShouldEqual, JobStateDelayed)
+
+				<-time.After(150 * time.Millisecond)
+				got, err := jq2.GetByEssence(&JobEssence{Cmd: "exit 1"}, false, false)
+				So(err, ShouldBeNil)
+				So(got.State, ShouldEqual, JobStateDelayed)
+
+				<-time.After(250 * time.Millisecond)
+				job, err = jq.Reserve(50 * time.Millisecond)
+				So(err, ShouldBeNil)
+				So(job, ShouldNotBeNil)
+				So(job.Cmd, ShouldEqual, "exit 1")
+				err = jq.Execute(job, config.RunnerExecShell)
+				So(err, ShouldNotBeNil)
+				So(job.State, ShouldEqual, JobStateDelayed)
+
+				<-time.After(400 * time.Millisecond)
+				got, err = jq2.GetByEssence(&JobEssence{Cmd: "exit 1"}, false, false)
+				So(err, ShouldBeNil)
+				So(got.State, ShouldEqual, JobStateDelayed)
+
+				<-time.After(300 * time.Millisecond)
+				job, err = jq.Reserve(50 * time.Millisecond)
+				So(err, ShouldBeNil)
+				So(job, ShouldNotBeNil)
+				So(job.Cmd, ShouldEqual, "exit 1")
+				err = jq.Execute(job, config.RunnerExecShell)
+				So(err, ShouldNotBeNil)
+				So(job.State, ShouldEqual, JobStateBuried)
+			})
+
+			Convey("Jobs have the disk space they use in their unique working directory measured", func() {
+				cmd := "head -c 2097152 /dev/zero > disk.file"
+				djob := &Job{Cmd: cmd, Cwd: "/tmp", ReqGroup: "fake_group", Requirements: standardReqs, Priority: 255, RepGroup: "disk"}
+				inserts, already, err := jq.Add([]*Job{djob}, envVars, true)
+				So(err, ShouldBeNil)
+				So(inserts, ShouldEqual, 1)
+				So(already, ShouldEqual, 0)
+
+				job, err := jq.Reserve(50 * time.Millisecond)
+				So(err, ShouldBeNil)
+				So(job.Cmd, ShouldEqual, cmd)
+				err = jq.Execute(job, config.RunnerExecShell)
+				So(err, ShouldBeNil)
+				So(job.PeakDisk, ShouldEqual, 2)
+
+				got, err := jq2.GetByEssence(&JobEssence{Cmd: cmd}, false, false)
+				So(err, ShouldBeNil)
+				So(got, ShouldNotBeNil)
+				So(got.State, ShouldEqual, JobStateComplete)
+				So(got.PeakDisk, ShouldEqual, 2)
+			})
+
+			Convey("You can get reports on the resource efficiency of completed jobs", func() {
+				cmd := "sleep 1"
+				rjob := &Job{Cmd: cmd, Cwd: "/tmp", ReqGroup: "report_group", Requirements: standardReqs, Priority: 255, Retries: uint8(3), RepGroup: "report"}
+				inserts, already, err := jq.Add([]*Job{rjob}, envVars, true)
+				So(err, ShouldBeNil)
+				So(inserts, ShouldEqual, 1)
+				So(already, ShouldEqual, 0)
+
+				job, err := jq.Reserve(50 * time.Millisecond)
+				So(err, ShouldBeNil)
+				So(job.Cmd, ShouldEqual, cmd)
+				err = jq.Release(job, FailReasonRAM)
+				So(err, ShouldBeNil)
+
+				<-time.After(ClientReleaseDelay + 100*time.Millisecond)
+				job, err = jq.Reserve(50 * time.Millisecond)
+				So(err, ShouldBeNil)
+				So(job.Cmd, ShouldEqual, cmd)
+				So(job.RAMRetries, ShouldEqual, 1)
+				err = jq.Execute(job, config.RunnerExecShell)
+				So(err, ShouldBeNil)
+
+				reports, err := jq.GetEfficiencyReports(ReportByRepGroup, "report")
+				So(err, ShouldBeNil)
+				So(len(reports), ShouldEqual, 1)
+				report := reports[0]
+				So(report.Group, ShouldEqual, "report")
+				So(report.Jobs, ShouldEqual, 1)
+				So(report.RAMRequested, ShouldEqual, 10)
+				So(report.TimeRequested, ShouldEqual, 10)
+				So(report.WallTime, ShouldBeBetweenOrEqual, 1, 2)
+				So(report.TimeEfficiency, ShouldBeBetweenOrEqual, 0.1, 0.2)
+				So(report.CPUEfficiency, ShouldBeLessThan, 0.5)
+				So(report.CoreHours, ShouldEqual, 0.01)
+				So(report.RAMRetries, ShouldEqual, 1)
+				So(report.TimeRetries, ShouldEqual, 0)
+
+				reports, err = jq.GetEfficiencyReports(ReportByReqGroup, "")
+				So(err, ShouldBeNil)
+				var found bool
+				for _, r := range reports {
+					if r.Group == "report_group" {
+						found = true
+						So(r.Jobs, ShouldEqual, 1)
+						So(r.RAMRetries, ShouldEqual, 1)
+					}
+				}
+				So(found, ShouldBeTrue)
+
+				_, err = jq.GetEfficiencyReports("foo", "")
+				So(err, ShouldNotBeNil)
+			})
+
+			Convey("You can get jobs described by a filter", func() {
+				fjobs := []*Job{
+					{Cmd: "echo filtered ok", Cwd: "/tmp", ReqGroup: "filter_a", Requirements: standardReqs, Priority: 255, RepGroup: "filter"},
+					{Cmd: "exit 2", Cwd: "/tmp", ReqGroup: "filter_b", Requirements: standardReqs, Priority: 254, RepGroup: "filter"},
+					{Cmd: "echo filtered ready", Cwd: "/tmp", ReqGroup: "filter_b", Requirements: standardReqs, RepGroup: "filter"},
+				}
+				inserts, _, err := jq.Add(fjobs, envVars, true)
+				So(err, ShouldBeNil)
+				So(inserts, ShouldEqual, 3)
+
+				job, err := jq.Reserve(50 * time.Millisecond)
+				So(err, ShouldBeNil)
+				So(job.Cmd, ShouldEqual, "echo filtered ok")
+				err = jq.Execute(job, config.RunnerExecShell)
+				So(err, ShouldBeNil)
+
+				job, err = jq.Reserve(50 * time.Millisecond)
+				So(err, ShouldBeNil)
+				So(job.Cmd, ShouldEqual, "exit 2")
+				err = jq.Execute(job, config.RunnerExecShell)
+				So(err, ShouldNotBeNil)
+
+				jobs, err := jq.GetByFilter(&JobFilter{RepGroup: "filter"}, 0, false, false)
+				So(err, ShouldBeNil)
+				So(len(jobs), ShouldEqual, 3)
+
+				jobs, err = jq.GetByFilter(&JobFilter{RepGroup: "filter", State: JobStateComplete}, 0, false, false)
+				So(err, ShouldBeNil)
+				So(len(jobs), ShouldEqual, 1)
+				So(jobs[0].Cmd, ShouldEqual, "echo filtered ok")
+
+				jobs, err = jq.GetByFilter(&JobFilter{RepGroup: "filter", ReqGroup: "filter_b"}, 0, false, false)
+				So(err, ShouldBeNil)
+				So(len(jobs), ShouldEqual, 2)
+
+				exitcode := 2
+				jobs, err = jq.GetByFilter(&JobFilter{RepGroup: "filter", Exitcode: &exitcode}, 0, false, false)
+				So(err, ShouldBeNil)
+				So(len(jobs), ShouldEqual, 1)
+				So(jobs[0].Cmd, ShouldEqual, "exit 2")
+				So(jobs[0].FailReason, ShouldEqual, FailReasonExit)
+				host := jobs[0].Host
+
+				exitcode = 0
+				jobs, err = jq.GetByFilter(&JobFilter{RepGroup: "filter", Exitcode: &exitcode}, 0, false, false)
+				So(err, ShouldBeNil)
+				So(len(jobs), ShouldEqual, 1)
+				So(jobs[0].Cmd, ShouldEqual, "echo filtered ok")
+
+				jobs, err = jq.GetByFilter(&JobFilter{RepGroup: "filter", FailReason: FailReasonExit, Host: host}, 0, false, false)
+				So(err, ShouldBeNil)
+				So(len(jobs), ShouldEqual, 1)
+
+				jobs, err = jq.GetByFilter(&JobFilter{RepGroup: "filter", Host: "not a host"}, 0, false, false)
+				So(err, ShouldBeNil)
+				So(len(jobs), ShouldEqual, 0)
+
+				jobs, err = jq.GetByFilter(&JobFilter{ReqGroup: "filter_b"}, 0, false, false)
+				So(err, ShouldBeNil)
+				So(len(jobs), ShouldEqual, 2)
+
+				jobs, err = jq.GetByFilter(&JobFilter{ReqGroup: "filter_b", State: JobStateReady}, 0, false, false)
+				So(err, ShouldBeNil)
+				So(len(jobs), ShouldEqual, 1)
+				So(jobs[0].Cmd, ShouldEqual, "echo filtered ready")
+
+				status := JobToStatus(jobs[0])
+				So(status.ReqGroup, ShouldEqual, "filter_b")
+				So(status.State, ShouldEqual, JobStateReady)
+			})
+
+			Convey("You can watch a summary of the queue that updates when things change", func() {
+				wjob := &Job{Cmd: "echo watched", Cwd: "/tmp", ReqGroup: "fake_group", Requirements: standardReqs, Priority: 255, RepGroup: "watched"}
+				inserts, _, err := jq.Add([]*Job{wjob}, envVars, true)
+				So(err, ShouldBeNil)
+				So(inserts, ShouldEqual, 1)
+
+				ws, err := jq.WatchStatus(0, "")
+				So(err, ShouldBeNil)
+				var found *RepGroupStateCounts
+				for _, rgc := range ws.RepGroups {
+					if rgc.RepGroup == "watched" {
+						found = rgc
+					}
+				}
+				So(found, ShouldNotBeNil)
+				So(found.Counts[JobStateReady], ShouldEqual, 1)
+				So(found.Counts[JobStateRunning], ShouldEqual, 0)
+
+				type watchResult struct {
+					ws  *WatchStatus
+					err error
+				}
+				results := make(chan *watchResult, 1)
+				before := time.Now()
+				go func() {
+					ws, err := jq2.WatchStatus(1*time.Second, "")
+					results <- &watchResult{ws, err}
+				}()
+
+				<-time.After(100 * time.Millisecond)
+				job, err := jq.Reserve(50 * time.Millisecond)
+				So(err, ShouldBeNil)
+				So(job.Cmd, ShouldEqual, "echo watched")
+
+				result := <-results
+				So(time.Since(before), ShouldBeLessThan, 900*time.Millisecond)
+				So(result.err, ShouldBeNil)
+				found = nil
+				for _, rgc := range result.ws.RepGroups {
+					if rgc.RepGroup == "watched" {
+						found = rgc
+					}
+				}
+				So(found, ShouldNotBeNil)
+				So(found.Counts[JobStateReady], ShouldEqual, 0)
+				So(found.Counts[JobStateRunning], ShouldEqual, 1)
+				var running bool
+				for _, rj := range result.ws.Running {
+					if rj.Cmd == "echo watched" {
+						running = true
+					}
+				}
+				So(running, ShouldBeTrue)
+
+				// the server can restrict the summary to a RepGroup
+				ws, err = jq.WatchStatus(0, "watched")
+				So(err, ShouldBeNil)
+				So(len(ws.RepGroups), ShouldEqual, 1)
+				So(ws.RepGroups[0].RepGroup, ShouldEqual, "watched")
+				So(ws.RepGroups[0].Counts[JobStateRunning], ShouldEqual, 1)
+				So(len(ws.Running), ShouldEqual, 1)
+				So(ws.Running[0].Cmd, ShouldEqual, "echo watched")
+
+				ws, err = jq.WatchStatus(0, "unwatched")
+				So(err, ShouldBeNil)
+				So(len(ws.RepGroups), ShouldEqual, 0)
+				So(len(ws.Running), ShouldEqual, 0)
+
+				// summaries that started after the change being waited on are
+				// shared
+				q := server.qs["test_queue"]
+				since := time.Now()
+				ws1, srerr, _ := server.getSharedWatchStatus(q, since, "")
+				So(srerr, ShouldBeBlank)
+				ws2, srerr, _ := server.getSharedWatchStatus(q, since, "")
+				So(srerr, ShouldBeBlank)
+				So(ws2, ShouldPointTo, ws1)
+				ws3, srerr, _ := server.getSharedWatchStatus(q, time.Now(), "")
+				So(srerr, ShouldBeBlank)
+				So(ws3, ShouldNotPointTo, ws1)
+
+				err = jq.Execute(job, config.RunnerExecShell)
+				So(err, ShouldBeNil)
+			})
+
+			Convey("Runners can report their starts, exits and errors, which get counted", func() {
+				issues, err := jq.GetRunnerIssues()
+				So(err, ShouldBeNil)
+				So(len(issues), ShouldEqual, 0)
+
+				err = jq.ReportRunner(RunnerEventStart, "sgroup", "")
+				So(err, ShouldBeNil)
+				err = jq2.ReportRunner(RunnerEventStart, "sgroup2", "")
+				So(err, ShouldBeNil)
+				err = jq.ReportRunner(RunnerEventError, "sgroup", "could not reserve commands: foo")
+				So(err, ShouldBeNil)
+
+				host, err := os.Hostname()
+				So(err, ShouldBeNil)
+				err = jq2.ReportRunner(RunnerEventError, "sgroup2", "could not reserve commands: foo")
+				So(err, ShouldBeNil)
+
+				// starts are only counted
+				issues, err = jq.GetRunnerIssues()
+				So(err, ShouldBeNil)
+				So(len(issues), ShouldEqual, 1)
+				So(issues[0].Host, ShouldEqual, host)
+				So(issues[0].Event, ShouldEqual, RunnerEventError)
+				So(issues[0].Msg, ShouldEqual, "could not reserve commands: foo")
+				So(issues[0].SchedulerGroup, ShouldEqual, "sgroup2")
+				So(issues[0].Count, ShouldEqual, 2)
+
+				// exits are counted, and their reasons are kept like errors
+				err = jq.ReportRunner(RunnerEventExit, "sgroup", "there are no more commands")
+				So(err, ShouldBeNil)
+				err = jq2.ReportRunner(RunnerEventExit, "sgroup2", "there are no more commands")
+				So(err, ShouldBeNil)
+				sstats, err := jq.ServerStats()
+				So(err, ShouldBeNil)
+				So(sstats.RunnerStarts, ShouldEqual, 2)
+				So(sstats.RunnerExits, ShouldEqual, 2)
+				issues, err = jq.GetRunnerIssues()
+				So(err, ShouldBeNil)
+				So(len(issues), ShouldEqual, 2)
+				var exit *RunnerIssue
+				for _, ri := range issues {
+					if ri.Event == RunnerEventExit {
+						exit = ri
+					}
+				}
+				So(exit, ShouldNotBeNil)
+				So(exit.Msg, ShouldEqual, "there are no more commands")
+				So(exit.Host, ShouldEqual, host)
+				So(exit.Count, ShouldEqual, 2)
+
+				err = jq.ReportRunner(RunnerEventError, "sgroup", "bar")
+				So(err, ShouldBeNil)
+				ws, err := jq.WatchStatus(0, "")
+				So(err, ShouldBeNil)
+				So(len(ws.RunnerIssues), ShouldEqual, 3)
+				So(ws.RunnerIssues[0].LastDate, ShouldBeGreaterThanOrEqualTo, ws.RunnerIssues[1].LastDate)
+
+				// only a limited number of issues are kept
+				origMax := RunnerIssuesMax
+				RunnerIssuesMax = 2
+				defer func() {
+					RunnerIssuesMax = origMax
+				}()
+				err = jq.ReportRunner(RunnerEventError, "sgroup", "baz")
+				So(err, ShouldBeNil)
+				issues, err = jq.GetRunnerIssues()
+				So(err, ShouldBeNil)
+				So(len(issues), ShouldEqual, 2)
+				var msgs []string
+				for _, ri := range issues {
+					msgs = append(msgs, ri.Msg)
+				}
+				So(msgs, ShouldContain, "baz")
+			})
+
+			Convey("Hosts can be excluded from scheduling, including by runners finding them unhealthy", func() {
+				hosts, err := jq.ExcludedHosts()
+				So(err, ShouldBeNil)
+				So(len(hosts), ShouldEqual, 0)
+
+				err = jq.ExcludeHost("badhost", "broken mounts")
+				So(err, ShouldBeNil)
+				err = jq.ReportRunner(RunnerEventUnhealthy, "sgroup", "only 0GB of disk space is free in /tmp")
+				So(err, ShouldBeNil)
+
+				host, err := os.Hostname()
+				So(err, ShouldBeNil)
+				hosts, err = jq.ExcludedHosts()
+				So(err, ShouldBeNil)
+				So(len(hosts), ShouldEqual, 2)
+				byHost := make(map[string]string)
+				for _, eh := range hosts {
+					byHost[eh.Host] = eh.Reason
+					So(eh.Date, ShouldBeGreaterThan, 0)
+				}
+				So(byHost["badhost"], ShouldEqual, "broken mounts")
+				So(byHost[host], ShouldEqual, "only 0GB of disk space is free in /tmp")
+
+				server.simutex.RLock()
+				_, reported := server.schedIssues["Host badhost will no longer have runners scheduled on it: broken mounts"]
+				server.simutex.RUnlock()
+				So(reported, ShouldBeTrue)
+
+				err = jq.IncludeHost(host)
+				So(err, ShouldBeNil)
+				err = jq.IncludeHost("unknown")
+				So(err, ShouldBeNil)
+				hosts, err = jq.ExcludedHosts()
+				So(err, ShouldBeNil)
+				So(len(hosts), ShouldEqual, 1)
+				So(hosts[0].Host, ShouldEqual, "badhost")
+
+				err = jq.ExcludeHost("", "no host")
+				So(err, ShouldNotBeNil)
+			})
+
+			Convey("Host exclusions survive a restart of the server", func() {
+				err := jq.ExcludeHost("badhost", "broken mounts")
+				So(err, ShouldBeNil)
+				server.excludeHost("suspecthost", "failed health checks", false)
+				err = jq.ExcludeHost("fixedhost", "temporarily broken")
+				So(err, ShouldBeNil)
+				err = jq.IncludeHost("fixedhost")
+				So(err, ShouldBeNil)
+
+				server.Stop(true)
+				wipeDevDBOnInit = false
+				server, _, err = Serve(serverConfig)
+				wipeDevDBOnInit = true
+				So(err, ShouldBeNil)
+				jq, err = Connect(addr, "test_queue", clientConnectTime)
+				So(err, ShouldBeNil)
+				defer jq.Disconnect()
+
+				hosts, err := jq.ExcludedHosts()
+				So(err, ShouldBeNil)
+				So(len(hosts), ShouldEqual, 2)
+				So(hosts[0].Host, ShouldEqual, "badhost")
+				So(hosts[0].Reason, ShouldEqual, "broken mounts")
+				So(hosts[0].Confirmed, ShouldBeTrue)
+				So(hosts[1].Host, ShouldEqual, "suspecthost")
+				So(hosts[1].Confirmed, ShouldBeFalse)
+				So(len(server.getUnconfirmedExcludedHosts()), ShouldEqual, 1)
+			})
+
+			Convey("Hosts that many jobs from different ReqGroups fail on get quarantined", func() {
+				origFailures := BadHostFailures
+				origReqGroups := BadHostReqGroups
+				BadHostFailures = 3
+				BadHostReqGroups = 2
+				defer func() {
+					BadHostFailures = origFailures
+					BadHostReqGroups = origReqGroups
+				}()
+
+				qjobs := []*Job{
+					{Cmd: "echo quarantine 1", Cwd: "/tmp", ReqGroup: "qgroup1", Requirements: standardReqs, RepGroup: "quarantine"},
+					{Cmd: "echo quarantine 2", Cwd: "/tmp", ReqGroup: "qgroup1", Requirements: standardReqs, RepGroup: "quarantine"},
+					{Cmd: "echo quarantine 3", Cwd: "/tmp", ReqGroup: "qgroup2", Requirements: standardReqs, RepGroup: "quarantine"},
+					{Cmd: "echo quarantine 4", Cwd: "/tmp", ReqGroup: "qgroup2", Requirements: standardReqs, RepGroup: "quarantine"},
+				}
+				inserts, _, err := jq.Add(qjobs, envVars, true)
+				So(err, ShouldBeNil)
+				So(inserts, ShouldEqual, 4)
+
+				// other hosts have been running jobs fine
+				for i := 0; i < 3; i++ {
+					server.recordHostSuccess(fmt.Sprintf("goodhost%d", i))
+				}
+
+				host, err := os.Hostname()
+				So(err, ShouldBeNil)
+				release := func(failreason string) {
+					job, errr := jq.Reserve(50 * time.Millisecond)
+					So(errr, ShouldBeNil)
+					So(job, ShouldNotBeNil)
+					errr = jq.Release(job, failreason)
+					So(errr, ShouldBeNil)
+				}
+
+				// failures that aren't the host's fault don't count
+				release(FailReasonRAM)
+				release(FailReasonMount)
+				release(FailReasonStart)
+				hosts, err := jq.ExcludedHosts()
+				So(err, ShouldBeNil)
+				So(len(hosts), ShouldEqual, 0)
+
+				release(FailReasonMount)
+				hosts, err = jq.ExcludedHosts()
+				So(err, ShouldBeNil)
+				So(len(hosts), ShouldEqual, 1)
+				So(hosts[0].Host, ShouldEqual, host)
+				So(hosts[0].Confirmed, ShouldBeFalse)
+				So(hosts[0].Reason, ShouldStartWith, "3 of 4 jobs from 2 req_grps failed on it")
+
+				// since it's the local scheduler's only host, it isn't actually
+				// excluded until confirmed
+				server.ehmutex.RLock()
+				So(len(server.excludedHostNames()), ShouldEqual, 0)
+				server.ehmutex.RUnlock()
+
+				bs := server.getBadServers()
+				So(len(bs), ShouldEqual, 1)
+				So(bs[0].ExcludedHost, ShouldBeTrue)
+				So(bs[0].ID, ShouldEqual, host)
+				So(bs[0].IsBad, ShouldBeTrue)
+
+				server.confirmExcludedHost(host)
+				hosts, err = jq.ExcludedHosts()
+				So(err, ShouldBeNil)
+				So(len(hosts), ShouldEqual, 1)
+				So(hosts[0].Confirmed, ShouldBeTrue)
+				So(len(server.getBadServers()), ShouldEqual, 0)
+				server.ehmutex.RLock()
+				So(server.excludedHostNames(), ShouldResemble, []string{host})
+				server.ehmutex.RUnlock()
+
+				err = jq.IncludeHost(host)
+				So(err, ShouldBeNil)
+				hosts, err = jq.ExcludedHosts()
+				So(err, ShouldBeNil)
+				So(len(hosts), ShouldEqual, 0)
+				server.hfmutex.Lock()
+				_, remembered := server.hostOutcomes[host]
+				So(remembered, ShouldBeFalse)
+				server.hfmutex.Unlock()
+			})
+
+			Convey("Hosts aren't quarantined when most hosts fail the same way", func() {
+				origFailures := BadHostFailures
+				origReqGroups := BadHostReqGroups
+				BadHostFailures = 3
+				BadHostReqGroups = 2
+				defer func() {
+					BadHostFailures = origFailures
+					BadHostReqGroups = origReqGroups
+				}()
+
+				hosts := []string{"sharedhost1", "sharedhost2", "sharedhost3"}
+				for i := 0; i < 3; i++ {
+					for _, h := range hosts {
+						server.recordHostSuccess(h)
+						server.recordHostFailure(h, fmt.Sprintf("sgroup%d", i%2), FailReasonMount)
+					}
+				}
+				excluded, err := jq.ExcludedHosts()
+				So(err, ShouldBeNil)
+				So(len(excluded), ShouldEqual, 0)
+
+				Convey("But one that fails much more often than the others is", func() {
+					server.recordHostSuccess("sharedhost4")
+					for i := 0; i < 10; i++ {
+						server.recordHostSuccess("sharedhost5")
+					}
+					for i := 0; i < 3; i++ {
+						server.recordHostFailure("sharedhost6", fmt.Sprintf("sgroup%d", i%2), FailReasonStart)
+					}
+					excluded, err = jq.ExcludedHosts()
+					So(err, ShouldBeNil)
+					So(len(excluded), ShouldEqual, 1)
+					So(excluded[0].Host, ShouldEqual, "sharedhost6")
+					So(excluded[0].Reason, ShouldStartWith, "3 of 3 jobs from 2 req_grps failed on it")
+				})
+
+				Convey("Upload failures don't count against hosts", func() {
+					for i := 0; i < 3; i++ {
+						server.recordHostFailure("uploadhost", fmt.Sprintf("sgroup%d", i%2), FailReasonUpload)
+					}
+					excluded, err = jq.ExcludedHosts()
+					So(err, ShouldBeNil)
+					So(len(excluded), ShouldEqual, 0)
+				})
+			})
+
+			Convey("You can subscribe to jobs changing state, and resume a subscription", func() {
+				events, stop, err := jq.Subscribe(&JobFilter{RepGroup: "subscribed"}, 0)
+				So(err, ShouldBeNil)
+
+				sjobs := []*Job{
+					{Cmd: "echo subscribed", Cwd: "/tmp", ReqGroup: "fake_group", Requirements: standardReqs, Priority: 255, RepGroup: "subscribed"},
+					{Cmd: "echo not subscribed", Cwd: "/tmp", ReqGroup: "fake_group", Requirements: standardReqs, RepGroup: "unsubscribed"},
+				}
+				inserts, _, err := jq.Add(sjobs, envVars, true)
+				So(err, ShouldBeNil)
+				So(inserts, ShouldEqual, 2)
+
+				job, err := jq.Reserve(50 * time.Millisecond)
+				So(err, ShouldBeNil)
+				So(job.Cmd, ShouldEqual, "echo subscribed")
+				err = jq.Execute(job, config.RunnerExecShell)
+				So(err, ShouldBeNil)
+
+				receive := func(events <-chan *JobEvent, n int) (got []*JobEvent) {
+					limit := time.After(5 * time.Second)
+					for len(got) < n {
+						select {
+						case e := <-events:
+							got = append(got, e)
+						case <-limit:
+							return
+						}
+					}
+					return
+				}
+
+				got := receive(events, 3)
+				So(len(got), ShouldEqual, 3)
+				So(got[0].ToState, ShouldEqual, JobStateReady)
+				So(got[1].FromState, ShouldEqual, JobStateReady)
+				So(got[1].ToState, ShouldEqual, JobStateRunning)
+				So(got[2].FromState, ShouldEqual, JobStateRunning)
+				So(got[2].ToState, ShouldEqual, JobStateComplete)
+				So(got[2].Exited, ShouldBeTrue)
+				So(got[2].Exitcode, ShouldEqual, 0)
+				So(got[2].Key, ShouldEqual, job.Key())
+				So(got[0].Seq, ShouldBeLessThan, got[1].Seq)
+				So(got[1].Seq, ShouldBeLessThan, got[2].Seq)
+				for _, e := range got {
+					So(e.RepGroup, ShouldEqual, "subscribed")
+					So(e.Missed, ShouldBeFalse)
+				}
+
+				stop()
+				_, open := <-events
+				So(open, ShouldBeFalse)
+
+				events, stop, err = jq.Subscribe(&JobFilter{RepGroup: "subscribed", State: JobStateComplete}, got[0].Seq)
+				So(err, ShouldBeNil)
+				defer stop()
+				resumed := receive(events, 1)
+				So(len(resumed), ShouldEqual, 1)
+				So(resumed[0].Seq, ShouldEqual, got[2].Seq)
+			})
+
+			Convey("Subscribers are told when events they wanted were no longer retained", func() {
+				l := newJobEventLog(2)
+				for i := 0; i < 3; i++ {
+					l.add(&JobEvent{Queue: "q", RepGroup: "rg"})
+				}
+				events, latest, missed, _ := l.since("q", l.seq-3, &JobFilter{})
+				So(len(events), ShouldEqual, 2)
+				So(missed, ShouldBeTrue)
+				So(latest, ShouldEqual, events[1].Seq)
+
+				events, _, missed, _ = l.since("q", l.seq-2, &JobFilter{RepGroup: "rg"})
+				So(len(events), ShouldEqual, 2)
+				So(missed, ShouldBeFalse)
+
+				events, _, _, _ = l.since("q", l.seq-2, &JobFilter{RepGroup: "other"})
+				So(len(events), ShouldEqual, 0)
+
+				// the log keeps working as it wraps around many times
+				for i := 0; i < 7; i++ {
+					l.add(&JobEvent{Queue: "q", RepGroup: fmt.Sprintf("wrap%d", i)})
+				}
+				events, latest, missed, _ = l.since("q", l.seq-3, &JobFilter{})
+				So(len(events), ShouldEqual, 2)
+				So(missed, ShouldBeTrue)
+				So(events[0].RepGroup, ShouldEqual, "wrap5")
+				So(events[1].RepGroup, ShouldEqual, "wrap6")
+				So(events[0].Seq, ShouldEqual, latest-1)
+			})
+
+			Convey("Everything that happens to a job is recorded in its history", func() {
+				hjob := &Job{Cmd: "false", Cwd: "/tmp", ReqGroup: "fake_group", Requirements: standardReqs, Priority: 255, Retries: uint8(1), RepGroup: "history"}
+				inserts, _, err := jq.Add([]*Job{hjob}, envVars, true)
+				So(err, ShouldBeNil)
+				So(inserts, ShouldEqual, 1)
+
+				for i := 0; i < 2; i++ {
+					job, errr := jq.Reserve(50 * time.Millisecond)
+					So(errr, ShouldBeNil)
+					So(job, ShouldNotBeNil)
+					So(job.Cmd, ShouldEqual, "false")
+					errr = jq.Execute(job, config.RunnerExecShell)
+					So(errr, ShouldNotBeNil)
+					<-time.After(ClientReleaseDelay + 100*time.Millisecond)
+				}
+
+				kicked, err := jq.Kick([]*JobEssence{{Cmd: "false"}})
+				So(err, ShouldBeNil)
+				So(kicked, ShouldEqual, 1)
+
+				history, err := jq.GetHistory(hjob.Key())
+				So(err, ShouldBeNil)
+				var events []string
+				for _, e := range history {
+					events = append(events, e.Event)
+				}
+				So(events, ShouldResemble, []string{
+					JobHistoryAdded,
+					JobHistoryReserved, JobHistoryStarted, JobHistoryExited, JobHistoryReleased,
+					JobHistoryReady,
+					JobHistoryReserved, JobHistoryStarted, JobHistoryExited, JobHistoryBuried,
+					JobHistoryKicked,
+				})
+
+				user, err := internal.Username()
+				So(err, ShouldBeNil)
+				host, err := os.Hostname()
+				So(err, ShouldBeNil)
+				So(history[0].State, ShouldEqual, JobStateReady)
+				So(history[1].ClientID, ShouldNotBeBlank)
+				So(history[1].User, ShouldEqual, user)
+				So(history[2].Host, ShouldEqual, host)
+				So(history[2].Pid, ShouldBeGreaterThan, 0)
+				So(history[3].Exited, ShouldBeTrue)
+				So(history[3].Exitcode, ShouldEqual, 1)
+				So(history[4].State, ShouldEqual, JobStateDelayed)
+				So(history[4].FailReason, ShouldEqual, FailReasonExit)
+				So(history[9].State, ShouldEqual, JobStateBuried)
+				So(history[9].Exitcode, ShouldEqual, 1)
+				So(history[10].User, ShouldEqual, user)
+				So(history[10].State, ShouldEqual, JobStateReady)
+				for i := 1; i < len(history); i++ {
+					So(history[i].Time, ShouldHappenOnOrAfter, history[i-1].Time)
+				}
+			})
+
+			Convey("Re-adding a job that is already queued doesn't add to its history", func() {
+				hjob := &Job{Cmd: "echo readded", Cwd: "/tmp", ReqGroup: "fake_group", Requirements: standardReqs, Priority: 255, RepGroup: "history"}
+				inserts, _, err := jq.Add([]*Job{hjob}, envVars, true)
+				So(err, ShouldBeNil)
+				So(inserts, ShouldEqual, 1)
+
+				job, err := jq.Reserve(50 * time.Millisecond)
+				So(err, ShouldBeNil)
+				So(job.Cmd, ShouldEqual, "echo readded")
+
+				inserts, already, err := jq.Add([]*Job{{Cmd: "echo readded", Cwd: "/tmp", ReqGroup: "fake_group", Requirements: standardReqs, Priority: 255, RepGroup: "history"}}, envVars, false)
+				So(err, ShouldBeNil)
+				So(inserts, ShouldEqual, 0)
+				So(already, ShouldEqual, 1)
+
+				history, err := jq.GetHistory(hjob.Key())
+				So(err, ShouldBeNil)
+				So(len(history), ShouldEqual, 2)
+				So(history[0].Event, ShouldEqual, JobHistoryAdded)
+				So(history[1].Event, ShouldEqual, JobHistoryReserved)
+			})
+
+			Convey("The details of every attempt at running a job are recorded", func() {
+				acmd := "echo attempt failed >&2 && false"
+				ajob := &Job{Cmd: acmd, Cwd: "/tmp", ReqGroup: "fake_group", Requirements: standardReqs, Priority: 255, Retries: uint8(1), RepGroup: "attempts"}
+				inserts, _, err := jq.Add([]*Job{ajob}, envVars, true)
+				So(err, ShouldBeNil)
+				So(inserts, ShouldEqual, 1)
+
+				for i := 0; i < 2; i++ {
+					job, errr := jq.Reserve(50 * time.Millisecond)
+					So(errr, ShouldBeNil)
+					So(job, ShouldNotBeNil)
+					So(job.Cmd, ShouldEqual, acmd)
+					errr = jq.Execute(job, config.RunnerExecShell)
+					So(errr, ShouldNotBeNil)
+					<-time.After(ClientReleaseDelay + 100*time.Millisecond)
+				}
+
+				job, err := jq.GetByEssence(&JobEssence{Cmd: acmd}, false, false)
+				So(err, ShouldBeNil)
+				So(job, ShouldNotBeNil)
+				So(job.State, ShouldEqual, JobStateBuried)
+				So(job.AttemptRecords, ShouldBeNil)
+
+				job, err = jq.GetByEssence(&JobEssence{Cmd: acmd}, true, false)
+				So(err, ShouldBeNil)
+				So(job, ShouldNotBeNil)
+				So(len(job.AttemptRecords), ShouldEqual, 2)
+
+				host, err := os.Hostname()
+				So(err, ShouldBeNil)
+				for i, a := range job.AttemptRecords {
+					So(a.Attempt, ShouldEqual, uint32(i+1))
+					So(a.Host, ShouldEqual, host)
+					So(a.Exited, ShouldBeTrue)
+					So(a.Exitcode, ShouldEqual, 1)
+					So(a.FailReason, ShouldEqual, FailReasonExit)
+					So(a.EndTime, ShouldHappenOnOrAfter, a.StartTime)
+					stderr, errs := a.StdErr()
+					So(errs, ShouldBeNil)
+					So(stderr, ShouldEqual, "attempt failed")
+				}
+				So(job.AttemptRecords[1].StartTime, ShouldHappenAfter, job.AttemptRecords[0].EndTime)
+			})
+
+			Convey("Attempt records of re-added jobs carry on from those of their earlier life", func() {
+				rcmd := "echo reattempted"
+				for i := 0; i < 2; i++ {
+					rjob := &Job{Cmd: rcmd, Cwd: "/tmp", ReqGroup: "fake_group", Requirements: standardReqs, Priority: 255, RepGroup: "reattempted"}
+					inserts, _, err := jq.Add([]*Job{rjob}, envVars, false)
+					So(err, ShouldBeNil)
+					So(inserts, ShouldEqual, 1)
+
+					job, err := jq.Reserve(50 * time.Millisecond)
+					So(err, ShouldBeNil)
+					So(job, ShouldNotBeNil)
+					So(job.Cmd, ShouldEqual, rcmd)
+					err = jq.Execute(job, config.RunnerExecShell)
+					So(err, ShouldBeNil)
+					So(job.Attempts, ShouldEqual, 1)
+				}
+
+				job, err := jq.GetByEssence(&JobEssence{Cmd: rcmd}, true, false)
+				So(err, ShouldBeNil)
+				So(job, ShouldNotBeNil)
+				So(len(job.AttemptRecords), ShouldEqual, 2)
+				So(job.AttemptRecords[0].Attempt, ShouldEqual, 1)
+				So(job.AttemptRecords[1].Attempt, ShouldEqual, 2)
+				So(job.AttemptRecords[1].StartTime, ShouldHappenAfter, job.AttemptRecords[0].EndTime)
+			})
+
+			Convey("Jobs have a time series of their resource usage recorded", func() {
+				cmd := "perl -e '$x = q{x} x 10000000; for (1..3000000) { $y++ }; sleep 2'"
+				sjob := &Job{Cmd: cmd, Cwd: "/tmp", ReqGroup: "fake_group", Requirements: &jqs.Requirements{RAM: 300, Time: 10 * time.Second, Cores: 1}, Priority: 255, RepGroup: "series"}
+				inserts, already, err := jq.Add([]*Job{sjob}, envVars, true)
+				So(err, ShouldBeNil)
+				So(inserts, ShouldEqual, 1)
+				So(already, ShouldEqual, 0)
+
+				job, err := jq.Reserve(50 * time.Millisecond)
+				So(err, ShouldBeNil)
+				So(job.Cmd, ShouldEqual, cmd)
+				err = jq.Execute(job, config.RunnerExecShell)
+				So(err, ShouldBeNil)
+				series, err := job.ResourceSeries()
+				So(err, ShouldBeNil)
+				So(len(series), ShouldBeGreaterThanOrEqualTo, 1)
+				So(series[0].Procs, ShouldBeGreaterThanOrEqualTo, 1)
+				So(series[0].RAM, ShouldBeGreaterThan, 0)
+
+				got, err := jq2.GetByEssence(&JobEssence{Cmd: cmd}, true, false)
+				So(err, ShouldBeNil)
+				So(got, ShouldNotBeNil)
+				So(got.State, ShouldEqual, JobStateComplete)
+				gotSeries, err := got.ResourceSeries()
+				So(err, ShouldBeNil)
+				So(len(gotSeries), ShouldEqual, len(series))
+
+				got, err = jq2.GetByEssence(&JobEssence{Cmd: cmd}, false, false)
+				So(err, ShouldBeNil)
+				So(got.ResourceSeriesC, ShouldBeEmpty)
+			})
+
+			Convey("Resource series sent by touches are only occasionally stored", func() {
+				origTouches := ResourceSeriesStoreTouches
+				ResourceSeriesStoreTouches = 2
+				defer func() {
+					ResourceSeriesStoreTouches = origTouches
+				}()
+
+				cmd := "echo touched series"
+				tjob := &Job{Cmd: cmd, Cwd: "/tmp", ReqGroup: "fake_group", Requirements: standardReqs, Priority: 255, RepGroup: "series"}
+				inserts, _, err := jq.Add([]*Job{tjob}, envVars, true)
+				So(err, ShouldBeNil)
+				So(inserts, ShouldEqual, 1)
+
+				job, err := jq.Reserve(50 * time.Millisecond)
+				So(err, ShouldBeNil)
+				So(job.Cmd, ShouldEqual, cmd)
+				err = jq.Started(job, 123)
+				So(err, ShouldBeNil)
+
+				rs := newResourceSeries(time.Now())
+				rs.add(&ResourceSample{Secs: 1, RAM: 10, Procs: 1})
+				job.ResourceSeriesC = rs.compressed()
+				_, err = jq.Touch(job)
 				So(err, ShouldBeNil)
 
-				jq, err = Connect(addr, "test_queue", clientConnectTime)
+				// the latest is available, though not yet stored
+				got, err := jq2.GetByEssence(&JobEssence{Cmd: cmd}, true, false)
 				So(err, ShouldBeNil)
-				jq.Disconnect()
+				So(got.ResourceSeriesC, ShouldResemble, job.ResourceSeriesC)
+				So(server.db.retrieveJobResourceSeries(job.Key()), ShouldBeEmpty)
 
-				syscall.Kill(os.Getpid(), syscall.SIGINT)
-				<-time.After(ClientTouchInterval)
-				<-time.After(ClientTouchInterval)
-				_, err = Connect(addr, "test_queue", clientConnectTime)
-				So(err, ShouldNotBeNil)
-				jqerr, ok = err.(Error)
-				So(ok, ShouldBeTrue)
-				So(jqerr.Err, ShouldEqual, ErrNoServer)
+				rs.add(&ResourceSample{Secs: 2, RAM: 20, Procs: 1})
+				job.ResourceSeriesC = rs.compressed()
+				_, err = jq.Touch(job)
+				So(err, ShouldBeNil)
+				So(server.db.retrieveJobResourceSeries(job.Key()), ShouldResemble, job.ResourceSeriesC)
 			})
 
-			Convey("You get a nice error if you send the server junk", func() {
-				_, err := jq.request(&clientRequest{Method: "junk", Queue: "test_queue"})
-				So(err, ShouldNotBeNil)
-				jqerr, ok := err.(Error)
-				So(ok, ShouldBeTrue)
-				So(jqerr.Err, ShouldEqual, ErrUnknownCommand)
-				jq.Disconnect()
+			Convey("Long resource usage series get downsampled, keeping the peaks", func() {
+				origMax := ResourceSeriesMaxSamples
+				ResourceSeriesMaxSamples = 4
+				defer func() {
+					ResourceSeriesMaxSamples = origMax
+				}()
+				rs := newResourceSeries(time.Now())
+				for i := 1; i <= 5; i++ {
+					rs.add(&ResourceSample{Secs: i, RAM: i * 10, CPU: 100 - i, Read: uint64(i), Procs: 1})
+				}
+				So(len(rs.samples), ShouldEqual, 3)
+				So(rs.interval, ShouldEqual, ResourceSampleInterval*2)
+				So(rs.samples[0].Secs, ShouldEqual, 2)
+				So(rs.samples[0].RAM, ShouldEqual, 20)
+				So(rs.samples[0].CPU, ShouldEqual, 99)
+				So(rs.samples[0].Read, ShouldEqual, 2)
+				So(rs.samples[2].Secs, ShouldEqual, 5)
 			})
-		})
 
-		Reset(func() {
-			server.Stop(true)
-		})
-	})
+			Convey("Jobs that run out of disk get more, up to their escalation ceiling", func() {
+				err := jq.SetReqGroupEscalation("esc_group", &Escalation{DiskMin: 5, DiskMax: 12})
+				So(err, ShouldBeNil)
+				esc, err := jq2.GetReqGroupEscalation("esc_group")
+				So(err, ShouldBeNil)
+				So(esc, ShouldNotBeNil)
+				So(esc.DiskMax, ShouldEqual, 12)
 
-	if server != nil {
-		server.Stop(true)
-	}
+				cmd := "echo 'No space left on device' >&2 && false"
+				ejob := &Job{Cmd: cmd, Cwd: "/tmp", ReqGroup: "esc_group", Requirements: &jqs.Requirements{RAM: 10, Time: 10 * time.Second, Cores: 1, Disk: 4}, Priority: 255, Retries: uint8(5), RepGroup: "escalation"}
+				inserts, already, err := jq.Add([]*Job{ejob}, envVars, true)
+				So(err, ShouldBeNil)
+				So(inserts, ShouldEqual, 1)
+				So(already, ShouldEqual, 0)
 
-	// start these tests anew because I don't want to mess with the timings in
-	// the above tests
-	Convey("Once a new jobqueue server is up", t, func() {
-		ServerItemTTR = 200 * time.Millisecond
-		ClientTouchInterval = 50 * time.Millisecond
-		server, _, err = Serve(serverConfig)
-		So(err, ShouldBeNil)
+				// the ReqGroup's escalation was applied when it was added
+				job, err := jq.Reserve(50 * time.Millisecond)
+				So(err, ShouldBeNil)
+				So(job.Cmd, ShouldEqual, cmd)
+				So(job.Escalation, ShouldNotBeNil)
+				So(job.Escalation.DiskMax, ShouldEqual, 12)
 
-		Convey("You can connect, and add some real jobs", func() {
-			jq, err := Connect(addr, "test_queue", clientConnectTime)
-			So(err, ShouldBeNil)
-			defer jq.Disconnect()
-			jq2, err := Connect(addr, "test_queue", clientConnectTime)
-			So(err, ShouldBeNil)
-			defer jq2.Disconnect()
+				err = jq.Execute(job, config.RunnerExecShell)
+				So(err, ShouldNotBeNil)
+				So(job.FailReason, ShouldEqual, FailReasonDisk)
+				So(job.State, ShouldEqual, JobStateDelayed)
+				So(job.Requirements.Disk, ShouldEqual, 9)
 
-			var jobs []*Job
-			jobs = append(jobs, &Job{Cmd: "sleep 0.1 && true", Cwd: "/tmp", ReqGroup: "fake_group", Requirements: standardReqs, Retries: uint8(2), RepGroup: "manually_added"})
-			jobs = append(jobs, &Job{Cmd: "sleep 0.1 && false", Cwd: "/tmp", ReqGroup: "fake_group", Requirements: standardReqs, Retries: uint8(2), RepGroup: "manually_added"})
-			inserts, already, err := jq.Add(jobs, envVars, true)
-			So(err, ShouldBeNil)
-			So(inserts, ShouldEqual, 2)
-			So(already, ShouldEqual, 0)
+				// the next increase is capped at the ceiling
+				<-time.After(ClientReleaseDelay + 100*time.Millisecond)
+				job, err = jq.Reserve(50 * time.Millisecond)
+				So(err, ShouldBeNil)
+				So(job, ShouldNotBeNil)
+				So(job.Cmd, ShouldEqual, cmd)
+				So(job.Requirements.Disk, ShouldEqual, 9)
+				err = jq.Execute(job, config.RunnerExecShell)
+				So(err, ShouldNotBeNil)
+				So(job.State, ShouldEqual, JobStateDelayed)
+				So(job.Requirements.Disk, ShouldEqual, 12)
 
-			Convey("You can't execute a job without reserving it", func() {
-				err := jq.Execute(jobs[0], config.RunnerExecShell)
+				// and once at the ceiling, we bury instead of retrying
+				<-time.After(ClientReleaseDelay + 100*time.Millisecond)
+				job, err = jq.Reserve(50 * time.Millisecond)
+				So(err, ShouldBeNil)
+				So(job, ShouldNotBeNil)
+				So(job.Cmd, ShouldEqual, cmd)
+				So(job.Requirements.Disk, ShouldEqual, 12)
+				err = jq.Execute(job, config.RunnerExecShell)
 				So(err, ShouldNotBeNil)
-				jqerr, ok := err.(Error)
-				So(ok, ShouldBeTrue)
-				So(jqerr.Err, ShouldEqual, ErrMustReserve)
-				jq.Disconnect()
+				So(err.Error(), ShouldContainSubstring, FailReasonDiskCeiling)
+				So(job.State, ShouldEqual, JobStateBuried)
+				So(job.FailReason, ShouldEqual, FailReasonDiskCeiling)
+
+				got, err := jq2.GetByEssence(&JobEssence{Cmd: cmd}, false, false)
+				So(err, ShouldBeNil)
+				So(got, ShouldNotBeNil)
+				So(got.State, ShouldEqual, JobStateBuried)
+				So(got.FailReason, ShouldEqual, FailReasonDiskCeiling)
+
+				err = jq.SetReqGroupEscalation("esc_group", nil)
+				So(err, ShouldBeNil)
+				esc, err = jq2.GetReqGroupEscalation("esc_group")
+				So(err, ShouldBeNil)
+				So(esc, ShouldBeNil)
+
+				// by default, increases are based on the disk actually used
+				// when that's more than was requested, so jobs that requested
+				// none only get a little
+				var noEsc *Escalation
+				So(noEsc.disk(0, 0), ShouldEqual, 1)
+				So(noEsc.disk(0, 3000), ShouldEqual, 6)
+				So(noEsc.disk(4, 3000), ShouldEqual, 8)
 			})
 
 			Convey("Once reserved you can execute jobs, and other clients see the correct state on gets", func() {
@@ -1513,6 +2921,74 @@ func TestJobqueue(t *testing.T) {
 				So(len(gottenJobs), ShouldEqual, 1)
 				So(gottenJobs[0].State, ShouldEqual, JobStateComplete)
 
+				Convey("You can export them, and import them in to a fresh server", func() {
+					j2, err := jq.Reserve(50 * time.Millisecond)
+					So(err, ShouldBeNil)
+					So(j2.RepGroup, ShouldEqual, "dep2")
+
+					exported, err := jq.Export(false)
+					So(err, ShouldBeNil)
+					So(len(exported), ShouldEqual, 2)
+					exported, err = jq.Export(true)
+					So(err, ShouldBeNil)
+					So(len(exported), ShouldEqual, 3)
+
+					// go via JSON, like wr export/import do
+					var jsonJobs []*Job
+					for _, job := range exported {
+						So(len(job.EnvC), ShouldBeGreaterThan, 0)
+						encoded, err := json.Marshal(job)
+						So(err, ShouldBeNil)
+						decoded := &Job{}
+						err = json.Unmarshal(encoded, decoded)
+						So(err, ShouldBeNil)
+						jsonJobs = append(jsonJobs, decoded)
+					}
+
+					server.Stop(true)
+					server, _, err = Serve(serverConfig)
+					So(err, ShouldBeNil)
+					jq, err = Connect(addr, "dep_queue", clientConnectTime)
+					So(err, ShouldBeNil)
+
+					gottenJobs, err = jq.GetByRepGroup("dep1", 0, "", false, false)
+					So(err, ShouldBeNil)
+					So(len(gottenJobs), ShouldEqual, 0)
+
+					added, existed, err := jq.Import(jsonJobs)
+					So(err, ShouldBeNil)
+					So(added, ShouldEqual, 3)
+					So(existed, ShouldEqual, 0)
+
+					gottenJobs, err = jq.GetByRepGroup("dep1", 0, "", false, true)
+					So(err, ShouldBeNil)
+					So(len(gottenJobs), ShouldEqual, 1)
+					So(gottenJobs[0].State, ShouldEqual, JobStateComplete)
+					So(gottenJobs[0].Exited, ShouldBeTrue)
+					So(gottenJobs[0].Attempts, ShouldEqual, 1)
+					env, err := gottenJobs[0].Env()
+					So(err, ShouldBeNil)
+					So(len(env), ShouldBeGreaterThan, 0)
+
+					gottenJobs, err = jq.GetByRepGroup("dep2", 0, "", false, false)
+					So(err, ShouldBeNil)
+					So(len(gottenJobs), ShouldEqual, 1)
+					So(gottenJobs[0].State, ShouldEqual, JobStateReady)
+					So(gottenJobs[0].FailReason, ShouldEqual, FailReasonLost)
+					So(gottenJobs[0].UntilBuried, ShouldEqual, 3)
+
+					gottenJobs, err = jq.GetByRepGroup("dep3", 0, "", false, false)
+					So(err, ShouldBeNil)
+					So(len(gottenJobs), ShouldEqual, 1)
+					So(gottenJobs[0].State, ShouldEqual, JobStateReady)
+					So(gottenJobs[0].FailReason, ShouldBeEmpty)
+
+					added, existed, err = jq.Import(jsonJobs)
+					So(err, ShouldBeNil)
+					So(added, ShouldEqual, 0)
+					So(existed, ShouldEqual, 3)
+				})
+
 				Convey("You can then add jobs dependent on the initial jobs and themselves", func() {
 					// https://i-msdn.sec.s-msft.com/dynimg/IC332764.gif
 					jobs = nil
@@ -2308,6 +3784,226 @@ func TestJobqueue(t *testing.T) {
 				So(job.Exited, ShouldBeTrue)
 				So(job.Exitcode, ShouldEqual, 0)
 			})
+
+			Convey("You can start a job, stop the server keeping runners, restart it, and the runner can carry on with the job", func() {
+				job, err := jq.Reserve(50 * time.Millisecond)
+				So(err, ShouldBeNil)
+				So(job.Cmd, ShouldEqual, "echo 1")
+				err = jq.Started(job, os.Getpid())
+				So(err, ShouldBeNil)
+
+				server.StopKeepRunners(true)
+				wipeDevDBOnInit = false
+				server, _, err = Serve(serverConfig)
+				wipeDevDBOnInit = true
+				So(err, ShouldBeNil)
+
+				jq2, err := Connect(addr, "test_queue", clientConnectTime)
+				So(err, ShouldBeNil)
+				defer jq2.Disconnect()
+
+				jobsByRepGroup, err := jq2.GetByRepGroup("manually_added", 0, "", false, false)
+				So(err, ShouldBeNil)
+				So(len(jobsByRepGroup), ShouldEqual, 2)
+				for _, got := range jobsByRepGroup {
+					if got.Cmd == "echo 1" {
+						So(got.State, ShouldEqual, JobStateRunning)
+						So(got.Pid, ShouldEqual, os.Getpid())
+						So(got.Attempts, ShouldEqual, 1)
+					} else {
+						So(got.State, ShouldEqual, JobStateReady)
+					}
+				}
+
+				// the running job can't be reserved by anyone else
+				other, err := jq2.Reserve(50 * time.Millisecond)
+				So(err, ShouldBeNil)
+				So(other, ShouldNotBeNil)
+				So(other.Cmd, ShouldEqual, "echo 2")
+				other, err = jq2.Reserve(50 * time.Millisecond)
+				So(err, ShouldBeNil)
+				So(other, ShouldBeNil)
+
+				// but the original runner (whose connection re-establishes
+				// itself) can carry on with it
+				kc, err := jq.Touch(job)
+				So(err, ShouldBeNil)
+				So(kc, ShouldBeFalse)
+				err = jq.Ended(job, "", 0, 10, 0*time.Second, []byte{}, []byte{})
+				So(err, ShouldBeNil)
+				err = jq.Archive(job)
+				So(err, ShouldBeNil)
+
+				jobsByRepGroup, err = jq2.GetByRepGroup("manually_added", 0, JobStateComplete, false, false)
+				So(err, ShouldBeNil)
+				So(len(jobsByRepGroup), ShouldEqual, 1)
+				So(jobsByRepGroup[0].Cmd, ShouldEqual, "echo 1")
+				So(jobsByRepGroup[0].Attempts, ShouldEqual, 1)
+			})
+
+			Convey("A standby replicates the server's database and takes over when the server stops", func() {
+				job, err := jq.Reserve(50 * time.Millisecond)
+				So(err, ShouldBeNil)
+				So(job.Cmd, ShouldEqual, "echo 1")
+				err = jq.Started(job, os.Getpid())
+				So(err, ShouldBeNil)
+
+				origPoll := ServerStandbyPollTime
+				origTakeover := ServerStandbyTakeoverTime
+				ServerStandbyPollTime = 100 * time.Millisecond
+				ServerStandbyTakeoverTime = 500 * time.Millisecond
+				heedPrimaryStops = false
+				defer func() {
+					ServerStandbyPollTime = origPoll
+					ServerStandbyTakeoverTime = origTakeover
+					heedPrimaryStops = true
+					os.Remove(config.ManagerDbFile + ".standby")
+				}()
+
+				type standbyResult struct {
+					server *Server
+					err    error
+				}
+				results := make(chan standbyResult, 1)
+				go func() {
+					s, _, errs := Standby(addr, serverConfig)
+					results <- standbyResult{s, errs}
+				}()
+				<-time.After(300 * time.Millisecond)
+
+				// changes made after the standby got its initial snapshot are
+				// also replicated
+				inserts, already, err := jq.Add([]*Job{{Cmd: "echo 3", Cwd: "/tmp", ReqGroup: "fake_group", Requirements: standardReqs, Retries: uint8(3), RepGroup: "manually_added"}}, envVars, true)
+				So(err, ShouldBeNil)
+				So(inserts, ShouldEqual, 1)
+				So(already, ShouldEqual, 0)
+				<-time.After(300 * time.Millisecond)
+
+				// (we keep runners and have the standby ignore that we stopped
+				// deliberately, as would be the case if the server crashed)
+				server.StopKeepRunners(true)
+				var result standbyResult
+				select {
+				case result = <-results:
+				case <-time.After(10 * time.Second):
+				}
+				So(result.err, ShouldBeNil)
+				So(result.server, ShouldNotBeNil)
+				server = result.server
+
+				jq2, err := Connect(addr, "test_queue", clientConnectTime)
+				So(err, ShouldBeNil)
+				defer jq2.Disconnect()
+
+				jobsByRepGroup, err := jq2.GetByRepGroup("manually_added", 0, "", false, false)
+				So(err, ShouldBeNil)
+				So(len(jobsByRepGroup), ShouldEqual, 3)
+				for _, got := range jobsByRepGroup {
+					if got.Cmd == "echo 1" {
+						So(got.State, ShouldEqual, JobStateRunning)
+						So(got.Pid, ShouldEqual, os.Getpid())
+					} else {
+						So(got.State, ShouldEqual, JobStateReady)
+					}
+				}
+
+				// the running job's runner can carry on with it
+				kc, err := jq.Touch(job)
+				So(err, ShouldBeNil)
+				So(kc, ShouldBeFalse)
+			})
+
+			Convey("A standby doesn't take over when the server is deliberately stopped", func() {
+				origPoll := ServerStandbyPollTime
+				origTakeover := ServerStandbyTakeoverTime
+				ServerStandbyPollTime = 100 * time.Millisecond
+				ServerStandbyTakeoverTime = 500 * time.Millisecond
+				defer func() {
+					ServerStandbyPollTime = origPoll
+					ServerStandbyTakeoverTime = origTakeover
+					os.Remove(config.ManagerDbFile + ".standby")
+				}()
+
+				type standbyResult struct {
+					server *Server
+					err    error
+				}
+				results := make(chan standbyResult, 1)
+				go func() {
+					s, _, errs := Standby(addr, serverConfig)
+					results <- standbyResult{s, errs}
+				}()
+				<-time.After(300 * time.Millisecond)
+
+				server.Stop(true)
+				var result standbyResult
+				select {
+				case result = <-results:
+				case <-time.After(10 * time.Second):
+				}
+				So(result.server, ShouldBeNil)
+				So(result.err, ShouldNotBeNil)
+				jqerr, ok := result.err.(Error)
+				So(ok, ShouldBeTrue)
+				So(jqerr.Err, ShouldEqual, ErrPrimaryStopped)
+			})
+
+			Convey("You can execute both jobs, then restart with a retention policy and the older job is pruned and archived", func() {
+				for _, cmd := range []string{"echo 1", "echo 2"} {
+					job, err := jq.Reserve(50 * time.Millisecond)
+					So(err, ShouldBeNil)
+					So(job.Cmd, ShouldEqual, cmd)
+					err = jq.Execute(job, config.RunnerExecShell)
+					So(err, ShouldBeNil)
+					So(job.State, ShouldEqual, JobStateComplete)
+					<-time.After(10 * time.Millisecond)
+				}
+
+				jobsByRepGroup, err := jq.GetByRepGroup("manually_added", 0, "", false, false)
+				So(err, ShouldBeNil)
+				So(len(jobsByRepGroup), ShouldEqual, 2)
+
+				server.Stop(true)
+				prunedPrefix := config.ManagerDbFile + "_pruned"
+				defer func() {
+					files, _ := filepath.Glob(prunedPrefix + "*")
+					for _, file := range files {
+						os.Remove(file)
+					}
+				}()
+				pruneConfig := serverConfig
+				pruneConfig.DBKeepPerRepGroup = 1
+				pruneConfig.DBFilePruned = prunedPrefix
+				wipeDevDBOnInit = false
+				server, _, err = Serve(pruneConfig)
+				wipeDevDBOnInit = true
+				So(err, ShouldBeNil)
+				jq, err = Connect(addr, "test_queue", clientConnectTime)
+				So(err, ShouldBeNil)
+
+				jobsByRepGroup, err = jq.GetByRepGroup("manually_added", 0, "", false, false)
+				So(err, ShouldBeNil)
+				So(len(jobsByRepGroup), ShouldEqual, 1)
+				So(jobsByRepGroup[0].Cmd, ShouldEqual, "echo 2")
+
+				files, err := filepath.Glob(prunedPrefix + "*.jsonl.gz")
+				So(err, ShouldBeNil)
+				So(len(files), ShouldEqual, 1)
+				f, err := os.Open(files[0])
+				So(err, ShouldBeNil)
+				defer f.Close()
+				gz, err := gzip.NewReader(f)
+				So(err, ShouldBeNil)
+				dec := json.NewDecoder(gz)
+				archived := &Job{}
+				err = dec.Decode(archived)
+				So(err, ShouldBeNil)
+				So(archived.Cmd, ShouldEqual, "echo 1")
+				So(archived.RepGroup, ShouldEqual, "manually_added")
+				So(len(archived.EnvC), ShouldBeGreaterThan, 0)
+				err = dec.Decode(archived)
+				So(err, ShouldEqual, io.EOF)
+			})
 		})
 
 		Convey("You can connect, add a job, then immediately shutdown, and the db backup still completes", func() {
@@ -2332,6 +4028,83 @@ func TestJobqueue(t *testing.T) {
 			So(info2.Size(), ShouldEqual, 28672)
 		})
 
+		Convey("You can back up on a schedule, keeping timestamped backups, and restore from them", func() {
+			server.Stop(true)
+			bkConfig := serverConfig
+			bkConfig.DBFileBackupKeep = 2
+			bkConfig.DBFileBackupInterval = 100 * time.Millisecond
+			origMinAge := DBBackupVersionMinAge
+			DBBackupVersionMinAge = 200 * time.Millisecond
+			server, _, err = Serve(bkConfig)
+			So(err, ShouldBeNil)
+			defer func() {
+				DBBackupVersionMinAge = origMinAge
+				versions, _ := DBBackups(managerDBBkFile)
+				for _, version := range versions {
+					os.Remove(version)
+				}
+				os.Remove(config.ManagerDbFile + ".pre_restore")
+			}()
+
+			jq, err := Connect(addr, "test_queue", clientConnectTime)
+			So(err, ShouldBeNil)
+			defer jq.Disconnect()
+
+			addJob := func(cmd string) {
+				jobs := []*Job{{Cmd: cmd, Cwd: "/tmp", ReqGroup: "fake_group", Requirements: &jqs.Requirements{RAM: 10, Time: 1 * time.Second, Cores: 1}, Retries: uint8(3), RepGroup: "manually_added"}}
+				inserts, _, errf := jq.Add(jobs, envVars, true)
+				So(errf, ShouldBeNil)
+				So(inserts, ShouldEqual, 1)
+			}
+
+			addJob("echo 1")
+			_, err = os.Stat(managerDBBkFile)
+			So(err, ShouldNotBeNil)
+			<-time.After(300 * time.Millisecond)
+			_, err = os.Stat(managerDBBkFile)
+			So(err, ShouldBeNil)
+			versions, err := DBBackups(managerDBBkFile)
+			So(err, ShouldBeNil)
+			So(len(versions), ShouldEqual, 1)
+
+			addJob("echo 2")
+			<-time.After(300 * time.Millisecond)
+			addJob("echo 3")
+			<-time.After(300 * time.Millisecond)
+			versions, err = DBBackups(managerDBBkFile)
+			So(err, ShouldBeNil)
+			So(len(versions), ShouldEqual, 2)
+			for _, version := range versions {
+				So(CheckDB(version), ShouldBeNil)
+			}
+
+			server.Stop(true)
+
+			corrupt := managerDBBkFile + ".corrupt"
+			err = ioutil.WriteFile(corrupt, []byte("corrupt!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!"), dbFilePermission)
+			So(err, ShouldBeNil)
+			defer os.Remove(corrupt)
+			So(CheckDB(corrupt), ShouldNotBeNil)
+			So(RestoreDB(corrupt, config.ManagerDbFile), ShouldNotBeNil)
+
+			// restore the older backup, made when there were only 2 jobs
+			err = RestoreDB(versions[1], config.ManagerDbFile)
+			So(err, ShouldBeNil)
+			_, err = os.Stat(config.ManagerDbFile + ".pre_restore")
+			So(err, ShouldBeNil)
+
+			wipeDevDBOnInit = false
+			server, _, err = Serve(serverConfig)
+			wipeDevDBOnInit = true
+			So(err, ShouldBeNil)
+			jq, err = Connect(addr, "test_queue", clientConnectTime)
+			So(err, ShouldBeNil)
+
+			jobsByRepGroup, err := jq.GetByRepGroup("manually_added", 0, "", false, false)
+			So(err, ShouldBeNil)
+			So(len(jobsByRepGroup), ShouldEqual, 2)
+		})
+
 		Convey("You can connect and add a non-instant job", func() {
 			jq, err := Connect(addr, "test_queue", clientConnectTime)
 			So(err, ShouldBeNil)
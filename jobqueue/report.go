@@ -0,0 +1,200 @@
+// Copyright © 2017 Genome Research Limited
+// Author: Sendu Bala <sb10@sanger.ac.uk>.
+//
+//  This file is part of wr.
+//
+//  wr is free software: you can redistribute it and/or modify
+//  it under the terms of the GNU Lesser General Public License as published by
+//  the Free Software Foundation, either version 3 of the License, or
+//  (at your option) any later version.
+//
+//  wr is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of
+//  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU Lesser General Public License for more details.
+//
+//  You should have received a copy of the GNU Lesser General Public License
+//  along with wr. If not, see <http://www.gnu.org/licenses/>.
+
+package jobqueue
+
+// This file contains the code for reporting on how efficiently completed Jobs
+// used the resources they requested.
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+)
+
+// ReportBy* constants are the ways Jobs can be grouped together in
+// EfficiencyReports, for use with Client.GetEfficiencyReports().
+const (
+	ReportByRepGroup = "repgroup"
+	ReportByReqGroup = "reqgroup"
+)
+
+// EfficiencyReport summarises how well the completed Jobs in a RepGroup or
+// ReqGroup used the resources they requested. The requested and used values are
+// means over the Jobs.
+type EfficiencyReport struct {
+	Group string
+	Jobs  int
+
+	RAMRequested int // MB
+	RAMPeak      int // MB
+
+	// RAMEfficiency is the total peak RAM of the Jobs divided by the total RAM
+	// they requested.
+	RAMEfficiency float64
+
+	TimeRequested int // seconds
+	WallTime      int // seconds
+
+	// TimeEfficiency is the total wall time of the Jobs divided by the total
+	// time they requested.
+	TimeEfficiency float64
+
+	// CPUEfficiency is the total CPU time of the Jobs divided by the total of
+	// their wall times multiplied by the cores they requested.
+	CPUEfficiency float64
+
+	// CoreHours is the total of the Jobs' wall times (in hours) multiplied by
+	// the cores they requested.
+	CoreHours float64
+
+	// RAMRetries and TimeRetries are the total number of times the Jobs had to
+	// be retried because they ran out of RAM or time, respectively.
+	RAMRetries  int
+	TimeRetries int
+}
+
+// efficiencyTotals accumulates the values needed to make an EfficiencyReport.
+type efficiencyTotals struct {
+	jobs         int
+	ramRequested float64
+	ramPeak      float64
+	timeRequest  float64
+	wallTime     float64
+	cpuTime      float64
+	coreSeconds  float64
+	ramRetries   int
+	timeRetries  int
+}
+
+// add includes the given Job's resource usage in the totals.
+func (t *efficiencyTotals) add(job *Job) {
+	wall := job.EndTime.Sub(job.StartTime).Seconds()
+	if wall < 0 {
+		wall = 0
+	}
+	t.jobs++
+	t.ramPeak += float64(job.PeakRAM)
+	t.wallTime += wall
+	t.cpuTime += job.CPUtime.Seconds()
+	if job.Requirements != nil {
+		t.ramRequested += float64(job.Requirements.RAM)
+		t.timeRequest += job.Requirements.Time.Seconds()
+		t.coreSeconds += wall * float64(job.Requirements.Cores)
+	}
+	t.ramRetries += int(job.RAMRetries)
+	t.timeRetries += int(job.TimeRetries)
+}
+
+// report converts the totals in to an EfficiencyReport for the given group.
+func (t *efficiencyTotals) report(group string) *EfficiencyReport {
+	n := float64(t.jobs)
+	return &EfficiencyReport{
+		Group:          group,
+		Jobs:           t.jobs,
+		RAMRequested:   int(math.Ceil(t.ramRequested / n)),
+		RAMPeak:        int(math.Ceil(t.ramPeak / n)),
+		RAMEfficiency:  efficiencyRatio(t.ramPeak, t.ramRequested),
+		TimeRequested:  int(math.Ceil(t.timeRequest / n)),
+		WallTime:       int(math.Ceil(t.wallTime / n)),
+		TimeEfficiency: efficiencyRatio(t.wallTime, t.timeRequest),
+		CPUEfficiency:  efficiencyRatio(t.cpuTime, t.coreSeconds),
+		CoreHours:      math.Ceil(t.coreSeconds/36) / 100,
+		RAMRetries:     t.ramRetries,
+		TimeRetries:    t.timeRetries,
+	}
+}
+
+// efficiencyRatio returns used/requested rounded to 2 decimal places, or 0 if
+// nothing was requested.
+func efficiencyRatio(used, requested float64) float64 {
+	if requested <= 0 {
+		return 0
+	}
+	return math.Floor(used/requested*100+0.5) / 100
+}
+
+// efficiencyGroups accumulates efficiencyTotals for (completed) Jobs grouped
+// by RepGroup or ReqGroup, so that reports can be made without holding all the
+// Jobs in memory at once.
+type efficiencyGroups struct {
+	by     string
+	totals map[string]*efficiencyTotals
+}
+
+// newEfficiencyGroups creates an efficiencyGroups that groups Jobs according
+// to the ReportBy* constant supplied.
+func newEfficiencyGroups(by string) (*efficiencyGroups, error) {
+	if by != ReportByRepGroup && by != ReportByReqGroup {
+		return nil, fmt.Errorf("unknown way of grouping jobs [%s]", by)
+	}
+	return &efficiencyGroups{by: by, totals: make(map[string]*efficiencyTotals)}, nil
+}
+
+// add includes the given Job's resource usage in the totals of its group.
+func (g *efficiencyGroups) add(job *Job) {
+	group := job.RepGroup
+	if g.by == ReportByReqGroup {
+		group = job.ReqGroup
+	}
+	t, exists := g.totals[group]
+	if !exists {
+		t = &efficiencyTotals{}
+		g.totals[group] = t
+	}
+	t.add(job)
+}
+
+// reports returns an EfficiencyReport for each group, sorted by group name.
+func (g *efficiencyGroups) reports() (reports []*EfficiencyReport) {
+	groups := make([]string, 0, len(g.totals))
+	for group := range g.totals {
+		groups = append(groups, group)
+	}
+	sort.Strings(groups)
+	for _, group := range groups {
+		reports = append(reports, g.totals[group].report(group))
+	}
+	return
+}
+
+// efficiencyReportColumns are the column headings written by
+// WriteEfficiencyReportsTSV().
+var efficiencyReportColumns = []string{
+	"group", "jobs", "ram_requested_mb", "ram_peak_mb", "ram_efficiency",
+	"time_requested_s", "wall_time_s", "time_efficiency", "cpu_efficiency",
+	"core_hours", "ram_retries", "time_retries",
+}
+
+// WriteEfficiencyReportsTSV writes the given EfficiencyReports to w as tab
+// separated values, with a header line.
+func WriteEfficiencyReportsTSV(w io.Writer, reports []*EfficiencyReport) (err error) {
+	_, err = fmt.Fprintln(w, strings.Join(efficiencyReportColumns, "\t"))
+	if err != nil {
+		return
+	}
+	for _, r := range reports {
+		_, err = fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%.2f\t%d\t%d\t%.2f\t%.2f\t%.2f\t%d\t%d\n", r.Group, r.Jobs, r.RAMRequested, r.RAMPeak, r.RAMEfficiency, r.TimeRequested, r.WallTime, r.TimeEfficiency, r.CPUEfficiency, r.CoreHours, r.RAMRetries, r.TimeRetries)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
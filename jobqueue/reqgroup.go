@@ -0,0 +1,170 @@
+// Copyright © 2017 Genome Research Limited
+// Author: Sendu Bala <sb10@sanger.ac.uk>.
+//
+//  This file is part of wr.
+//
+//  wr is free software: you can redistribute it and/or modify
+//  it under the terms of the GNU Lesser General Public License as published by
+//  the Free Software Foundation, either version 3 of the License, or
+//  (at your option) any later version.
+//
+//  wr is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of
+//  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU Lesser General Public License for more details.
+//
+//  You should have received a copy of the GNU Lesser General Public License
+//  along with wr. If not, see <http://www.gnu.org/licenses/>.
+
+package jobqueue
+
+// This file contains the code for inspecting and adjusting what has been
+// learned about the resource usage of the Jobs in each ReqGroup.
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// ReqGroupStat* constants are the names of the kinds of resource usage learned
+// for each ReqGroup, for use with Client.TrimReqGroup().
+const (
+	ReqGroupStatMemory = "memory"
+	ReqGroupStatTime   = "time"
+	ReqGroupStatDisk   = "disk"
+	ReqGroupStatCores  = "cores"
+)
+
+// ReqGroupHistogramBins is the number of bins in each ReqGroupStat.Histogram.
+var ReqGroupHistogramBins = 10
+
+// ReqGroupStats describes what has been learned about the resource usage of
+// the Jobs in a ReqGroup, along with any ReqGroupPin and Escalation that has
+// been set for it.
+type ReqGroupStats struct {
+	ReqGroup string
+
+	// Memory samples are peak RAM usage in MB.
+	Memory *ReqGroupStat `json:",omitempty"`
+
+	// Time samples are wall times in seconds.
+	Time *ReqGroupStat `json:",omitempty"`
+
+	// Disk samples are peak disk usage in MB.
+	Disk *ReqGroupStat `json:",omitempty"`
+
+	// Cores samples are CPU time / wall time, as a percentage of a core.
+	Cores *ReqGroupStat `json:",omitempty"`
+
+	Pin        *ReqGroupPin `json:",omitempty"`
+	Escalation *Escalation  `json:",omitempty"`
+}
+
+// ReqGroupStat summarises the samples of one kind of resource usage learned
+// for a ReqGroup. Note that identical samples are only stored once, so Samples
+// is the number of distinct values.
+type ReqGroupStat struct {
+	Samples      int
+	Min          int
+	Max          int
+	Median       int
+	Percentile95 int
+
+	// Recommendation is what will currently be used for the corresponding
+	// Requirements of the ReqGroup's Jobs (depending on their Override), in
+	// the units of Requirements (ie. MB, seconds, GB or cores), before taking
+	// any ReqGroupPin in to account. 0 means there is no recommendation.
+	Recommendation int
+
+	Histogram []*HistogramBin
+}
+
+// HistogramBin is a bin of a ReqGroupStat.Histogram, holding the number of
+// samples with values from Lower up to (but not including) Upper, except for
+// the last bin which also includes Upper.
+type HistogramBin struct {
+	Lower int
+	Upper int
+	Count int
+}
+
+// ReqGroupPin holds fixed recommendations for the Jobs in a ReqGroup, that
+// take the place of what has been learned from prior Jobs. Zero values mean
+// that resource is not pinned.
+type ReqGroupPin struct {
+	RAM   int           `json:",omitempty"` // MB
+	Time  time.Duration `json:",omitempty"`
+	Cores int           `json:",omitempty"`
+	Disk  int           `json:",omitempty"` // GB
+}
+
+// String provides a human readable description of the ReqGroupPin.
+func (p *ReqGroupPin) String() string {
+	if p == nil {
+		return ""
+	}
+	return fmt.Sprintf("memory: %dMB; time: %s; cpus: %d; disk: %dGB", p.RAM, p.Time, p.Cores, p.Disk)
+}
+
+// newReqGroupStat summarises the given samples, which must be sorted, returning
+// nil if there aren't any.
+func newReqGroupStat(samples []int, recommendation int) *ReqGroupStat {
+	if len(samples) == 0 {
+		return nil
+	}
+	if !sort.IntsAreSorted(samples) {
+		sort.Ints(samples)
+	}
+	n := len(samples)
+	stat := &ReqGroupStat{
+		Samples:        n,
+		Min:            samples[0],
+		Max:            samples[n-1],
+		Median:         samples[(n-1)/2],
+		Percentile95:   samples[int(math.Ceil(0.95*float64(n)))-1],
+		Recommendation: recommendation,
+	}
+
+	bins := ReqGroupHistogramBins
+	width := int(math.Ceil(float64(stat.Max-stat.Min+1) / float64(bins)))
+	if width < 1 {
+		width = 1
+	}
+	for i := 0; i < bins; i++ {
+		lower := stat.Min + i*width
+		if lower > stat.Max {
+			break
+		}
+		stat.Histogram = append(stat.Histogram, &HistogramBin{Lower: lower, Upper: lower + width})
+	}
+	for _, sample := range samples {
+		bin := (sample - stat.Min) / width
+		if bin >= len(stat.Histogram) {
+			bin = len(stat.Histogram) - 1
+		}
+		stat.Histogram[bin].Count++
+	}
+	last := stat.Histogram[len(stat.Histogram)-1]
+	if last.Upper > stat.Max {
+		last.Upper = stat.Max
+	}
+	return stat
+}
+
+// reqGroupStatBucket returns the db bucket that holds samples of the given
+// ReqGroupStat* kind, or nil if the kind is not valid.
+func reqGroupStatBucket(kind string) []byte {
+	switch kind {
+	case ReqGroupStatMemory:
+		return bucketJobMBs
+	case ReqGroupStatTime:
+		return bucketJobSecs
+	case ReqGroupStatDisk:
+		return bucketJobDisks
+	case ReqGroupStatCores:
+		return bucketJobCores
+	}
+	return nil
+}
@@ -75,7 +75,7 @@ func TestREST(t *testing.T) {
 			responseData, err := ioutil.ReadAll(response.Body)
 			So(err, ShouldBeNil)
 
-			var jstati []jstatus
+			var jstati []JStatus
 			err = json.Unmarshal(responseData, &jstati)
 			So(err, ShouldBeNil)
 			So(len(jstati), ShouldEqual, 0)
@@ -95,7 +95,7 @@ func TestREST(t *testing.T) {
 			So(err, ShouldBeNil)
 			responseData, err := ioutil.ReadAll(response.Body)
 			So(err, ShouldBeNil)
-			var jstati []jstatus
+			var jstati []JStatus
 			err = json.Unmarshal(responseData, &jstati)
 			So(err, ShouldBeNil)
 			So(len(jstati), ShouldEqual, 3)
@@ -124,7 +124,7 @@ func TestREST(t *testing.T) {
 				responseData, err := ioutil.ReadAll(response.Body)
 				So(err, ShouldBeNil)
 
-				var jstati []jstatus
+				var jstati []JStatus
 				err = json.Unmarshal(responseData, &jstati)
 				So(err, ShouldBeNil)
 				So(len(jstati), ShouldEqual, 3)
@@ -136,7 +136,7 @@ func TestREST(t *testing.T) {
 				responseData, err := ioutil.ReadAll(response.Body)
 				So(err, ShouldBeNil)
 
-				var jstati []jstatus
+				var jstati []JStatus
 				err = json.Unmarshal(responseData, &jstati)
 				So(err, ShouldBeNil)
 				So(len(jstati), ShouldEqual, 1)
@@ -147,7 +147,7 @@ func TestREST(t *testing.T) {
 				responseData, err = ioutil.ReadAll(response.Body)
 				So(err, ShouldBeNil)
 
-				var jstati2 []jstatus
+				var jstati2 []JStatus
 				err = json.Unmarshal(responseData, &jstati2)
 				So(err, ShouldBeNil)
 				So(len(jstati2), ShouldEqual, 2)
@@ -161,7 +161,7 @@ func TestREST(t *testing.T) {
 				responseData, err := ioutil.ReadAll(response.Body)
 				So(err, ShouldBeNil)
 
-				var jstati []jstatus
+				var jstati []JStatus
 				err = json.Unmarshal(responseData, &jstati)
 				So(err, ShouldBeNil)
 				So(len(jstati), ShouldEqual, 2)
@@ -177,7 +177,7 @@ func TestREST(t *testing.T) {
 					responseData, err := ioutil.ReadAll(response.Body)
 					So(err, ShouldBeNil)
 
-					var jstati []jstatus
+					var jstati []JStatus
 					err = json.Unmarshal(responseData, &jstati)
 					So(err, ShouldBeNil)
 					So(len(jstati), ShouldEqual, 1)
@@ -213,7 +213,7 @@ func TestREST(t *testing.T) {
 					responseData, err := ioutil.ReadAll(response.Body)
 					So(err, ShouldBeNil)
 
-					var jstati []jstatus
+					var jstati []JStatus
 					err = json.Unmarshal(responseData, &jstati)
 					So(err, ShouldBeNil)
 					So(len(jstati), ShouldEqual, 2)
@@ -228,7 +228,7 @@ func TestREST(t *testing.T) {
 					responseData, err = ioutil.ReadAll(response.Body)
 					So(err, ShouldBeNil)
 
-					var jstati2 []jstatus
+					var jstati2 []JStatus
 					err = json.Unmarshal(responseData, &jstati2)
 					So(err, ShouldBeNil)
 					So(len(jstati2), ShouldEqual, 1)
@@ -243,7 +243,7 @@ func TestREST(t *testing.T) {
 					responseData, err = ioutil.ReadAll(response.Body)
 					So(err, ShouldBeNil)
 
-					var jstati3 []jstatus
+					var jstati3 []JStatus
 					err = json.Unmarshal(responseData, &jstati3)
 					So(err, ShouldBeNil)
 					So(len(jstati3), ShouldEqual, 1)
@@ -260,7 +260,7 @@ func TestREST(t *testing.T) {
 					responseData, err := ioutil.ReadAll(response.Body)
 					So(err, ShouldBeNil)
 
-					var jstati []jstatus
+					var jstati []JStatus
 					err = json.Unmarshal(responseData, &jstati)
 					So(err, ShouldBeNil)
 					So(len(jstati), ShouldEqual, 1)
@@ -292,7 +292,7 @@ func TestREST(t *testing.T) {
 			So(err, ShouldBeNil)
 			responseData, err := ioutil.ReadAll(response.Body)
 			So(err, ShouldBeNil)
-			var jstati []jstatus
+			var jstati []JStatus
 			err = json.Unmarshal(responseData, &jstati)
 			So(err, ShouldBeNil)
 			So(len(jstati), ShouldEqual, 1)
@@ -317,20 +317,20 @@ func TestREST(t *testing.T) {
 			responseData, err := ioutil.ReadAll(response.Body)
 			So(err, ShouldBeNil)
 
-			var sis []*schedulerIssue
+			var sis []*SchedulerIssue
 			err = json.Unmarshal(responseData, &sis)
 			So(err, ShouldBeNil)
 			So(len(sis), ShouldEqual, 0)
 
 			Convey("After adding some warnings, you can retrieve them, which also dismisses them", func() {
 				server.simutex.Lock()
-				server.schedIssues["msg1"] = &schedulerIssue{
+				server.schedIssues["msg1"] = &SchedulerIssue{
 					Msg:       "msg1",
 					FirstDate: time.Now().Unix(),
 					LastDate:  time.Now().Unix(),
 					Count:     1,
 				}
-				server.schedIssues["msg2"] = &schedulerIssue{
+				server.schedIssues["msg2"] = &SchedulerIssue{
 					Msg:       "msg2",
 					FirstDate: time.Now().Unix(),
 					LastDate:  time.Now().Unix(),
@@ -344,7 +344,7 @@ func TestREST(t *testing.T) {
 				responseData, err := ioutil.ReadAll(response.Body)
 				So(err, ShouldBeNil)
 
-				var sis []*schedulerIssue
+				var sis []*SchedulerIssue
 				err = json.Unmarshal(responseData, &sis)
 				So(err, ShouldBeNil)
 				So(len(sis), ShouldEqual, 2)
@@ -361,7 +361,7 @@ func TestREST(t *testing.T) {
 			responseData, err := ioutil.ReadAll(response.Body)
 			So(err, ShouldBeNil)
 
-			var servers []*badServer
+			var servers []*BadServer
 			err = json.Unmarshal(responseData, &servers)
 			So(err, ShouldBeNil)
 			So(len(servers), ShouldEqual, 0)
@@ -383,7 +383,7 @@ func TestREST(t *testing.T) {
 				responseData, err := ioutil.ReadAll(response.Body)
 				So(err, ShouldBeNil)
 
-				var servers []*badServer
+				var servers []*BadServer
 				err = json.Unmarshal(responseData, &servers)
 				So(err, ShouldBeNil)
 				So(len(servers), ShouldEqual, 1)
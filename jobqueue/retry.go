@@ -0,0 +1,194 @@
+// Copyright © 2017 Genome Research Limited
+// Author: Sendu Bala <sb10@sanger.ac.uk>.
+//
+//  This file is part of wr.
+//
+//  wr is free software: you can redistribute it and/or modify
+//  it under the terms of the GNU Lesser General Public License as published by
+//  the Free Software Foundation, either version 3 of the License, or
+//  (at your option) any later version.
+//
+//  wr is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of
+//  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU Lesser General Public License for more details.
+//
+//  You should have received a copy of the GNU Lesser General Public License
+//  along with wr. If not, see <http://www.gnu.org/licenses/>.
+
+package jobqueue
+
+// This file contains the implementation of Job retry policies.
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+)
+
+// RetryPolicy struct can be set on a Job to control which of its failures get
+// retried (up to the Job's Retries count), how long to wait before each retry,
+// and which exit codes count as success.
+type RetryPolicy struct {
+	// Delay is how long to wait before the first retry. It defaults to
+	// ClientReleaseDelay.
+	Delay time.Duration `json:",omitempty"`
+
+	// Backoff, if greater than 1, multiplies the delay for each subsequent
+	// retry, so that with a Delay of 30s and Backoff of 2 you would wait 30s,
+	// 60s, 120s and so on.
+	Backoff float64 `json:",omitempty"`
+
+	// MaxDelay, if set, caps the delay between retries.
+	MaxDelay time.Duration `json:",omitempty"`
+
+	// ExitCodes, if set, are the exit codes of Cmd that will be retried; other
+	// non-0 exit codes result in the Job being buried straight away.
+	ExitCodes []int `json:",omitempty"`
+
+	// FailReasons, if set, are the FailReason* that will be retried; other
+	// failures (besides exits with one of the ExitCodes) result in the Job
+	// being buried straight away. FailReasonExit retries all non-0 exits.
+	// FailReasonMount can be listed to retry Jobs whose remote file systems
+	// fail to mount, which would otherwise always be buried. Failures that are
+	// retried because they are listed here always count against the Job's
+	// Retries, even if Cmd never got started.
+	FailReasons []string `json:",omitempty"`
+
+	// SuccessExitCodes are exit codes of Cmd (besides 0) that mean it worked.
+	SuccessExitCodes []int `json:",omitempty"`
+}
+
+// String provides a JSON representation of the RetryPolicy.
+func (rp *RetryPolicy) String() string {
+	if rp == nil {
+		return ""
+	}
+	b, _ := json.Marshal(rp)
+	return string(b)
+}
+
+// listed tells you if the given FailReason is one of our FailReasons.
+func (rp *RetryPolicy) listed(failreason string) bool {
+	if rp == nil {
+		return false
+	}
+	for _, fr := range rp.FailReasons {
+		if fr == failreason {
+			return true
+		}
+	}
+	return false
+}
+
+// retryable tells you if a Job with this RetryPolicy should be retried after
+// failing for the given reason (with the given exit code, if it exited). Any
+// failure is retryable if neither ExitCodes nor FailReasons are set.
+func (rp *RetryPolicy) retryable(failreason string, exitcode int) bool {
+	if rp == nil || (len(rp.ExitCodes) == 0 && len(rp.FailReasons) == 0) {
+		return true
+	}
+	if rp.listed(failreason) {
+		return true
+	}
+	return (failreason == FailReasonExit || failreason == FailReasonDisk) && intInSlice(exitcode, rp.ExitCodes)
+}
+
+// success tells you if the given exit code of Cmd means that it worked.
+func (rp *RetryPolicy) success(exitcode int) bool {
+	if exitcode == 0 {
+		return true
+	}
+	return rp != nil && intInSlice(exitcode, rp.SuccessExitCodes)
+}
+
+// delay returns how long to wait before retrying after the given number of
+// failures (starting at 1).
+func (rp *RetryPolicy) delay(failures int) time.Duration {
+	d := ClientReleaseDelay
+	if rp == nil {
+		return d
+	}
+	if rp.Delay > 0 {
+		d = rp.Delay
+	}
+	if rp.Backoff > 1 && failures > 1 {
+		d = time.Duration(float64(d) * math.Pow(rp.Backoff, float64(failures-1)))
+	}
+	if rp.MaxDelay > 0 && (d > rp.MaxDelay || d < 0) {
+		d = rp.MaxDelay
+	}
+	return d
+}
+
+// validFailReason tells you if the given string is one of our FailReason*
+// constants.
+func validFailReason(failreason string) bool {
+	switch failreason {
+	case FailReasonEnv, FailReasonCwd, FailReasonStart, FailReasonCPerm,
+		FailReasonCFound, FailReasonCExit, FailReasonExit, FailReasonRAM,
+		FailReasonTime, FailReasonAbnormal, FailReasonLost, FailReasonSignal,
+		FailReasonResource, FailReasonMount, FailReasonUpload, FailReasonKilled,
+		FailReasonContainer, FailReasonScript, FailReasonDisk,
+		FailReasonRAMCeiling, FailReasonTimeCeiling, FailReasonDiskCeiling:
+		return true
+	}
+	return false
+}
+
+// intInSlice tells you if i is one of the ints in slice.
+func intInSlice(i int, slice []int) bool {
+	for _, s := range slice {
+		if s == i {
+			return true
+		}
+	}
+	return false
+}
+
+// RetryPolicyViaJSON describes a RetryPolicy in a form convenient for
+// supplying as JSON.
+type RetryPolicyViaJSON struct {
+	// Delay is a duration with a unit suffix, eg. 30s for 30 seconds.
+	Delay   string  `json:"delay"`
+	Backoff float64 `json:"backoff"`
+	// MaxDelay is a duration with a unit suffix, eg. 1h for 1 hour.
+	MaxDelay         string   `json:"max_delay"`
+	ExitCodes        []int    `json:"exit_codes"`
+	FailReasons      []string `json:"fail_reasons"`
+	SuccessExitCodes []int    `json:"success_exit_codes"`
+}
+
+// RetryPolicy converts to a RetryPolicy, checking that the supplied values are
+// valid.
+func (rpvj *RetryPolicyViaJSON) RetryPolicy() (*RetryPolicy, error) {
+	rp := &RetryPolicy{
+		Backoff:          rpvj.Backoff,
+		ExitCodes:        rpvj.ExitCodes,
+		FailReasons:      rpvj.FailReasons,
+		SuccessExitCodes: rpvj.SuccessExitCodes,
+	}
+	var err error
+	if rpvj.Delay != "" {
+		rp.Delay, err = time.ParseDuration(rpvj.Delay)
+		if err != nil {
+			return nil, fmt.Errorf("retry delay (%s) was not specified correctly: %s", rpvj.Delay, err)
+		}
+	}
+	if rpvj.MaxDelay != "" {
+		rp.MaxDelay, err = time.ParseDuration(rpvj.MaxDelay)
+		if err != nil {
+			return nil, fmt.Errorf("retry max delay (%s) was not specified correctly: %s", rpvj.MaxDelay, err)
+		}
+	}
+	if rp.Backoff < 0 {
+		return nil, fmt.Errorf("retry backoff (%g) can't be negative", rp.Backoff)
+	}
+	for _, fr := range rp.FailReasons {
+		if !validFailReason(fr) {
+			return nil, fmt.Errorf("retry fail reason [%s] is not a known fail reason", fr)
+		}
+	}
+	return rp, nil
+}
@@ -0,0 +1,179 @@
+// Copyright © 2017 Genome Research Limited
+// Author: Sendu Bala <sb10@sanger.ac.uk>.
+//
+//  This file is part of wr.
+//
+//  wr is free software: you can redistribute it and/or modify
+//  it under the terms of the GNU Lesser General Public License as published by
+//  the Free Software Foundation, either version 3 of the License, or
+//  (at your option) any later version.
+//
+//  wr is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of
+//  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU Lesser General Public License for more details.
+//
+//  You should have received a copy of the GNU Lesser General Public License
+//  along with wr. If not, see <http://www.gnu.org/licenses/>.
+
+package jobqueue
+
+// This file contains the code that lets runners report their starts, exits
+// and errors to the server, so that they can be seen centrally instead of
+// being lost along with the runner's own STDERR. Starts are normal, so are just
+// counted; exits are also counted, but their reasons are kept alongside errors
+// as issues for the user to look at.
+
+import (
+	"sort"
+	"time"
+)
+
+// RunnerEvent* are the kinds of event a runner can report with
+// Client.ReportRunner(). See also RunnerEventUnhealthy.
+const (
+	RunnerEventStart = "start"
+	RunnerEventExit  = "exit"
+	RunnerEventError = "error"
+)
+
+// RunnerIssuesMax is the most RunnerIssues the server keeps, and
+// RunnerIssueMaxAge is how long it keeps them for since they were last
+// reported; the least recently reported are forgotten first.
+var (
+	RunnerIssuesMax   = 100
+	RunnerIssueMaxAge = 24 * time.Hour
+)
+
+// RunnerIssue is the details of errors and exit reasons reported by runners
+// (see Client.ReportRunner()) that we send to the status webpage (and to
+// Client.WatchStatus()). Like SchedulerIssues, repeats of the same Event with
+// the same Msg from the same Host are counted.
+type RunnerIssue struct {
+	Key            string // identifies the Event+Host+Msg combination
+	Event          string // RunnerEventError, RunnerEventExit or RunnerEventUnhealthy
+	Host           string
+	SchedulerGroup string // of the runner that most recently reported this
+	Msg            string // the error or exit reason
+	FirstDate      int64  // seconds since Unix epoch
+	LastDate       int64
+	Count          int
+}
+
+// ReportRunner is for use by runners to tell the server that they have
+// started, are exiting (with the reason as msg) or encountered an error (msg).
+// event should be one of the RunnerEvent* constants. Starts are only counted
+// (see ServerStats), while exits are counted and, like errors, aggregated with
+// others of the same event and msg from this host, as RunnerIssues.
+func (c *Client) ReportRunner(event string, schedulerGroup string, msg string) error {
+	_, err := c.request(&clientRequest{Method: "rreport", RunnerIssue: &RunnerIssue{
+		Event:          event,
+		Host:           hostName(),
+		SchedulerGroup: schedulerGroup,
+		Msg:            msg,
+	}})
+	return err
+}
+
+// GetRunnerIssues gets the errors and exit reasons reported by runners, most
+// recent first.
+func (c *Client) GetRunnerIssues() (issues []*RunnerIssue, err error) {
+	resp, err := c.request(&clientRequest{Method: "rissues"})
+	if err != nil {
+		return
+	}
+	issues = resp.RunnerIssues
+	return
+}
+
+// reportRunnerIssue records an event reported by a runner. Starts and exits are
+// counted, and anything other than a start is sent to the status webpage, with
+// repeats counted.
+func (s *Server) reportRunnerIssue(report *RunnerIssue) {
+	s.rimutex.Lock()
+	if report.Event == RunnerEventStart || report.Event == RunnerEventExit {
+		s.runnerCounts[report.Event]++
+		if report.Event == RunnerEventStart {
+			s.rimutex.Unlock()
+			return
+		}
+	}
+
+	key := report.Event + ":" + report.Host + ":" + report.Msg
+	now := time.Now().Unix()
+	ri, existed := s.runnerIssues[key]
+	if existed {
+		ri.LastDate = now
+		ri.Count++
+		ri.SchedulerGroup = report.SchedulerGroup
+	} else {
+		// make room for the new issue
+		s.pruneRunnerIssues(now, RunnerIssuesMax-1)
+		ri = &RunnerIssue{
+			Key:            key,
+			Event:          report.Event,
+			Host:           report.Host,
+			SchedulerGroup: report.SchedulerGroup,
+			Msg:            report.Msg,
+			FirstDate:      now,
+			LastDate:       now,
+			Count:          1,
+		}
+		s.runnerIssues[key] = ri
+	}
+	ric := *ri
+	s.rimutex.Unlock()
+	s.runnerCaster.Send(&ric)
+
+	if report.Event == RunnerEventUnhealthy {
+		s.excludeHost(report.Host, report.Msg, false)
+	}
+}
+
+// pruneRunnerIssues forgets issues last reported more than RunnerIssueMaxAge
+// ago, and then the least recently reported ones if we have more than max. You
+// must hold the rimutex lock.
+func (s *Server) pruneRunnerIssues(now int64, max int) {
+	oldest := now - int64(RunnerIssueMaxAge.Seconds())
+	issues := make([]*RunnerIssue, 0, len(s.runnerIssues))
+	for key, ri := range s.runnerIssues {
+		if ri.LastDate < oldest {
+			delete(s.runnerIssues, key)
+			continue
+		}
+		issues = append(issues, ri)
+	}
+	if len(issues) <= max {
+		return
+	}
+	sort.Slice(issues, func(i, j int) bool {
+		return issues[i].LastDate < issues[j].LastDate
+	})
+	for _, ri := range issues[:len(issues)-max] {
+		delete(s.runnerIssues, ri.Key)
+	}
+}
+
+// getRunnerEventCounts returns how many runners have reported starting and
+// exiting.
+func (s *Server) getRunnerEventCounts() (starts, exits int) {
+	s.rimutex.RLock()
+	defer s.rimutex.RUnlock()
+	return s.runnerCounts[RunnerEventStart], s.runnerCounts[RunnerEventExit]
+}
+
+// getRunnerIssues returns copies of the events reported by runners, most recent
+// first.
+func (s *Server) getRunnerIssues() []*RunnerIssue {
+	s.rimutex.RLock()
+	issues := make([]*RunnerIssue, 0, len(s.runnerIssues))
+	for _, ri := range s.runnerIssues {
+		ric := *ri
+		issues = append(issues, &ric)
+	}
+	s.rimutex.RUnlock()
+	sort.Slice(issues, func(i, j int) bool {
+		return issues[i].LastDate > issues[j].LastDate
+	})
+	return issues
+}
@@ -28,6 +28,7 @@ import (
 	"github.com/shirou/gopsutil/mem"
 	"log"
 	"math"
+	"os"
 	"os/exec"
 	"runtime"
 	"sync"
@@ -92,6 +93,8 @@ type local struct {
 	autoProcessing   bool
 	stopAuto         chan bool
 	debugMode        bool
+	hostname         string
+	excludedHosts    map[string]bool
 }
 
 // ConfigLocal represents the configuration options required by the local
@@ -122,6 +125,10 @@ func (s *local) initialize(config interface{}) (err error) {
 	if err != nil {
 		return
 	}
+	s.hostname, err = os.Hostname()
+	if err != nil {
+		return
+	}
 
 	// make our queue
 	s.queue = queue.New(localPlace)
@@ -336,6 +343,9 @@ func (s *local) canCount(req *Requirements) (canCount int) {
 	// cmds were /supposed/ to use. This could be bad for misbehaving cmds that
 	// use too much RAM, but we will end up killing cmds that do this, so it
 	// shouldn't be too much of an issue.
+	if s.excludedHosts[s.hostname] {
+		return 0
+	}
 	canCount = int(math.Floor(float64(s.maxRAM-s.ram) / float64(req.RAM)))
 	if canCount >= 1 {
 		canCount2 := int(math.Floor(float64(s.maxCores-s.cores) / float64(req.Cores)))
@@ -456,6 +466,18 @@ func (s *local) hostToID(host string) string {
 	return ""
 }
 
+// excludeHosts stores the hosts we shouldn't run cmds on. Since we only run
+// cmds on the local host, that means we stop running new cmds entirely if it
+// gets excluded.
+func (s *local) excludeHosts(hosts []string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.excludedHosts = make(map[string]bool, len(hosts))
+	for _, host := range hosts {
+		s.excludedHosts[host] = true
+	}
+}
+
 // setMessageCallBack does nothing at the moment, since we don't generate any
 // messages for the user.
 func (s *local) setMessageCallBack(cb MessageCallBack) {
@@ -31,6 +31,7 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -44,6 +45,8 @@ type lsf struct {
 	queues             map[string]map[string]int
 	sortedqs           map[int][]string
 	sortedqKeys        []int
+	excludedHosts      []string
+	ehmutex            sync.RWMutex
 }
 
 // ConfigLSF represents the configuration options required by the LSF scheduler.
@@ -410,7 +413,13 @@ func (s *lsf) schedule(cmd string, req *Requirements, count int) error {
 
 	megabytes := req.RAM
 	m := float32(megabytes) * s.memLimitMultiplier
-	bsubArgs = append(bsubArgs, "-q", queue, "-M", fmt.Sprintf("%0.0f", m), "-R", fmt.Sprintf("'select[mem>%d] rusage[mem=%d] span[hosts=1]'", megabytes, megabytes))
+	selection := fmt.Sprintf("mem>%d", megabytes)
+	s.ehmutex.RLock()
+	for _, host := range s.excludedHosts {
+		selection += fmt.Sprintf(" && hname!='%s'", host)
+	}
+	s.ehmutex.RUnlock()
+	bsubArgs = append(bsubArgs, "-q", queue, "-M", fmt.Sprintf("%0.0f", m), "-R", fmt.Sprintf("'select[%s] rusage[mem=%d] span[hosts=1]'", selection, megabytes))
 	if req.Cores > 1 {
 		bsubArgs = append(bsubArgs, "-n", fmt.Sprintf("%d", req.Cores))
 	}
@@ -670,6 +679,13 @@ func (s *lsf) hostToID(host string) string {
 	return ""
 }
 
+// excludeHosts stores the hosts that we'll tell bsub not to run cmds on.
+func (s *lsf) excludeHosts(hosts []string) {
+	s.ehmutex.Lock()
+	defer s.ehmutex.Unlock()
+	s.excludedHosts = append([]string(nil), hosts...)
+}
+
 // setMessageCallBack does nothing at the moment, since we don't generate any
 // messages for the user.
 func (s *lsf) setMessageCallBack(cb MessageCallBack) {
@@ -528,7 +528,7 @@ func (s *opst) canCount(req *Requirements) (canCount int) {
 	// the biggest object is first and the smallest last. Insert each object one
 	// by one in to the first bin that has room for it.”
 	for _, server := range s.servers {
-		if !server.IsBad() {
+		if !server.IsBad() && !s.excludedHosts[server.Name] {
 			space := server.HasSpaceFor(req.Cores, req.RAM, req.Disk)
 			canCount += space
 		}
@@ -721,7 +721,7 @@ func (s *opst) runCmd(cmd string, req *Requirements) error {
 	s.debug("a %s lock, %d servers, %d standins\n", uniqueDebug, len(s.servers), len(s.standins))
 	var server *cloud.Server
 	for sid, thisServer := range s.servers {
-		if !thisServer.IsBad() && thisServer.OS == osPrefix && bytes.Equal(thisServer.Script, osScript) && thisServer.HasSpaceFor(req.Cores, req.RAM, req.Disk) > 0 {
+		if !thisServer.IsBad() && !s.excludedHosts[thisServer.Name] && thisServer.OS == osPrefix && bytes.Equal(thisServer.Script, osScript) && thisServer.HasSpaceFor(req.Cores, req.RAM, req.Disk) > 0 {
 			server = thisServer
 			server.Allocate(req.Cores, req.RAM, req.Disk)
 			s.debug("b %s using existing server %s\n", uniqueDebug, sid)
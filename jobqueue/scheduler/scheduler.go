@@ -145,6 +145,7 @@ type scheduleri interface {
 	reserveTimeout() int                                     // achieve the aims of ReserveTimeout()
 	maxQueueTime(req *Requirements) time.Duration            // achieve the aims of MaxQueueTime()
 	hostToID(host string) string                             // achieve the aims of HostToID()
+	excludeHosts(hosts []string)                             // achieve the aims of ExcludeHosts()
 	setMessageCallBack(MessageCallBack)                      // achieve the aims of SetMessageCallBack()
 	setBadServerCallBack(BadServerCallBack)                  // achieve the aims of SetBadServerCallBack()
 	cleanup()                                                // do any clean up once you've finished using the job scheduler
@@ -268,6 +269,15 @@ func (s *Scheduler) HostToID(host string) string {
 	return s.impl.hostToID(host)
 }
 
+// ExcludeHosts sets the hosts (as named by os.Hostname() on them) that cmds
+// should no longer be run on, eg. because they have been found to be
+// unhealthy. Each call replaces the hosts given in the previous call, so supply
+// an empty slice to allow all hosts again. It only affects subsequent
+// Schedule() calls; anything already scheduled is left alone.
+func (s *Scheduler) ExcludeHosts(hosts []string) {
+	s.impl.excludeHosts(hosts)
+}
+
 // Cleanup means you've finished using a scheduler and it can delete any
 // remaining jobs in its system and clean up any other used resources.
 func (s *Scheduler) Cleanup() {
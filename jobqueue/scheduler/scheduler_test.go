@@ -80,6 +80,31 @@ func TestLocal(t *testing.T) {
 			So(serr.Err, ShouldEqual, ErrImpossible)
 		})
 
+		Convey("Schedule() doesn't run anything once the local host is excluded", func() {
+			tmpdir, err := ioutil.TempDir("", "wr_schedulers_local_test_excluded_output_dir_")
+			if err != nil {
+				log.Fatal(err)
+			}
+			defer os.RemoveAll(tmpdir)
+			cmd := fmt.Sprintf("perl -MFile::Temp=tempfile -e '@a = tempfile(DIR => q[%s]); exit(0);'", tmpdir)
+
+			host, err := os.Hostname()
+			So(err, ShouldBeNil)
+			s.ExcludeHosts([]string{host})
+			err = s.Schedule(cmd, possibleReq, 1)
+			So(err, ShouldBeNil)
+			<-time.After(500 * time.Millisecond)
+			So(testDirForFiles(tmpdir, 1), ShouldEqual, 0)
+
+			Convey("But runs it once the host is no longer excluded", func() {
+				s.ExcludeHosts([]string{})
+				err = s.Schedule(cmd, possibleReq, 1)
+				So(err, ShouldBeNil)
+				So(waitToFinish(s, 5, 100), ShouldBeTrue)
+				So(testDirForFiles(tmpdir, 1), ShouldEqual, 1)
+			})
+		})
+
 		Convey("Schedule() lets you schedule more jobs than localhost CPUs", func() {
 			tmpdir, err := ioutil.TempDir("", "wr_schedulers_local_test_immediate_output_dir_")
 			if err != nil {
@@ -0,0 +1,147 @@
+// Copyright © 2017 Genome Research Limited
+// Author: Sendu Bala <sb10@sanger.ac.uk>.
+//
+//  This file is part of wr.
+//
+//  wr is free software: you can redistribute it and/or modify
+//  it under the terms of the GNU Lesser General Public License as published by
+//  the Free Software Foundation, either version 3 of the License, or
+//  (at your option) any later version.
+//
+//  wr is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of
+//  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU Lesser General Public License for more details.
+//
+//  You should have received a copy of the GNU Lesser General Public License
+//  along with wr. If not, see <http://www.gnu.org/licenses/>.
+
+package jobqueue
+
+// This file contains the code for recording a time series of the resources
+// used by a Job's Cmd while it runs.
+
+import (
+	"github.com/ugorji/go/codec"
+	"time"
+)
+
+// ResourceSampleInterval is how often Execute() initially measures the
+// resources being used by a Cmd. ResourceSeriesMaxSamples is the most samples
+// that will be kept for a Job: when a Cmd runs long enough to exceed this, pairs
+// of adjacent samples are merged and the interval is doubled, so that the
+// series always covers the whole run at decreasing resolution.
+// ResourceSeriesStoreTouches is how many of a running Job's Touch()es (which
+// send its series so far) the server waits for before storing the latest
+// series in its database; in between it just keeps the latest in memory. The
+// final series is always stored when the Cmd ends.
+var (
+	ResourceSampleInterval     = 5 * time.Second
+	ResourceSeriesMaxSamples   = 120
+	ResourceSeriesStoreTouches = 10
+)
+
+// ResourceSample is a measurement of the resources being used by a Job's Cmd
+// (along with all its child processes) while it was running. When samples are
+// merged, RAM, CPU and Procs are the highest of the merged values, so that
+// brief spikes remain visible.
+type ResourceSample struct {
+	Secs  int    // seconds since the Cmd started
+	RAM   int    // MB
+	CPU   int    // percent of a core used since the previous sample
+	Read  uint64 // total bytes read from storage so far
+	Write uint64 // total bytes written to storage so far
+	Procs int    // number of processes
+}
+
+// resourceSeries accumulates ResourceSamples, downsampling as necessary.
+type resourceSeries struct {
+	samples  []*ResourceSample
+	interval time.Duration
+	start    time.Time
+	last     time.Time
+	lastCPU  float64
+}
+
+// newResourceSeries creates a resourceSeries for a Cmd that started at the
+// given time.
+func newResourceSeries(start time.Time) *resourceSeries {
+	return &resourceSeries{
+		interval: ResourceSampleInterval,
+		start:    start,
+		last:     start,
+	}
+}
+
+// due tells you if it's time to take another sample. The first sample is due
+// straight away.
+func (rs *resourceSeries) due(now time.Time) bool {
+	return len(rs.samples) == 0 || now.Sub(rs.last) >= rs.interval
+}
+
+// sample measures the resources currently used by the process tree rooted at
+// pid (whose memory usage, in MB, you have already measured) and adds the
+// result to the series.
+func (rs *resourceSeries) sample(now time.Time, pid int, ram int) {
+	cpu, read, write, procs := processTreeUsage(pid)
+	pct := 0
+	if elapsed := now.Sub(rs.last).Seconds(); elapsed > 0 && cpu > rs.lastCPU {
+		pct = int((cpu - rs.lastCPU) / elapsed * 100)
+	}
+	rs.add(&ResourceSample{
+		Secs:  int(now.Sub(rs.start).Seconds()),
+		RAM:   ram,
+		CPU:   pct,
+		Read:  read,
+		Write: write,
+		Procs: procs,
+	})
+	rs.last = now
+	rs.lastCPU = cpu
+}
+
+// add appends a sample to the series, merging pairs of samples and doubling
+// our interval if we now have too many.
+func (rs *resourceSeries) add(s *ResourceSample) {
+	rs.samples = append(rs.samples, s)
+	if len(rs.samples) <= ResourceSeriesMaxSamples {
+		return
+	}
+
+	var merged []*ResourceSample
+	for i := 0; i < len(rs.samples); i += 2 {
+		if i+1 == len(rs.samples) {
+			merged = append(merged, rs.samples[i])
+			break
+		}
+		a, b := rs.samples[i], rs.samples[i+1]
+		m := &ResourceSample{Secs: b.Secs, RAM: b.RAM, CPU: b.CPU, Read: b.Read, Write: b.Write, Procs: b.Procs}
+		if a.RAM > m.RAM {
+			m.RAM = a.RAM
+		}
+		if a.CPU > m.CPU {
+			m.CPU = a.CPU
+		}
+		if a.Procs > m.Procs {
+			m.Procs = a.Procs
+		}
+		merged = append(merged, m)
+	}
+	rs.samples = merged
+	rs.interval *= 2
+}
+
+// compressed returns the series in the form stored in Job.ResourceSeriesC, or
+// nil if there are no samples.
+func (rs *resourceSeries) compressed() []byte {
+	if len(rs.samples) == 0 {
+		return nil
+	}
+	var encoded []byte
+	enc := codec.NewEncoderBytes(&encoded, new(codec.BincHandle))
+	err := enc.Encode(rs.samples)
+	if err != nil {
+		return nil
+	}
+	return compress(encoded)
+}
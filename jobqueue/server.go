@@ -31,6 +31,7 @@ import (
 	"github.com/go-mangos/mangos/protocol/rep"
 	"github.com/go-mangos/mangos/transport/tcp"
 	"github.com/grafov/bcast" // *** must be commit e9affb593f6c871f9b4c3ee6a3c77d421fe953df or status web page updates break in certain cases
+	"github.com/satori/go.uuid"
 	"github.com/ugorji/go/codec"
 	"io"
 	"log"
@@ -63,6 +64,9 @@ const (
 	ErrMustReserve    = "you must Reserve() a Job before passing it to other methods"
 	ErrDBError        = "failed to use database"
 	ErrWrongUser      = "you did not start this server: permission denied"
+	ErrBadParent      = "parent job is not running, or you are not running it"
+	ErrPrimaryStopped = "the server being replicated was deliberately stopped"
+	ErrNoneFit        = "none of the ready jobs fit within the given capacity"
 	ServerModeNormal  = "started"
 	ServerModeDrain   = "draining"
 )
@@ -71,11 +75,16 @@ const (
 // probably shouldn't change them (*** and they should probably be re-factored
 // as fields of a config struct...)
 var (
-	ServerInterruptTime   = 1 * time.Second
-	ServerItemTTR         = 60 * time.Second
-	ServerReserveTicker   = 1 * time.Second
-	ServerCheckRunnerTime = 1 * time.Minute
-	ServerLogClientErrors = true
+	ServerInterruptTime       = 1 * time.Second
+	ServerItemTTR             = 60 * time.Second
+	ServerReserveTicker       = 1 * time.Second
+	ServerCheckRunnerTime     = 1 * time.Minute
+	ServerLogClientErrors     = true
+	ServerDBPruneInterval     = 24 * time.Hour
+	ServerStandbyPollTime     = 5 * time.Second
+	ServerStandbyTakeoverTime = 30 * time.Second
+	ServerStandbyChecks       = 3
+	ServerStandbyLogSize      = 10000
 )
 
 // Error records an error and the operation, item and queue that caused it.
@@ -100,14 +109,32 @@ type itemErr struct {
 // serverResponse is the struct that the server sends to clients over the
 // network in response to their clientRequest.
 type serverResponse struct {
-	Err        string // string instead of error so we can decode on the client side
-	Added      int
-	Existed    int
-	KillCalled bool
-	Job        *Job
-	Jobs       []*Job
-	SStats     *ServerStats
-	DB         []byte
+	Err           string // string instead of error so we can decode on the client side
+	Added         int
+	Existed       int
+	KillCalled    bool
+	Job           *Job
+	Jobs          []*Job
+	SStats        *ServerStats
+	DB            []byte
+	ReplID        string
+	ReplSeq       uint64
+	Txns          []*dbTxn
+	ReplStopping  bool
+	Escalation    *Escalation
+	ReqGroupStats []*ReqGroupStats
+	Removed       int
+	Reports       []*EfficiencyReport
+	WatchStatus   *WatchStatus
+	Events        []*JobEvent
+	EventSeq      uint64
+	EventsMissed  bool
+	History       []*JobHistoryEvent
+	KillCalledFor []string
+	TouchErrors   map[string]string
+	NoneFit       bool
+	RunnerIssues  []*RunnerIssue
+	ExcludedHosts []*ExcludedHost
 }
 
 // ServerInfo holds basic addressing info about the server.
@@ -132,6 +159,11 @@ type ServerStats struct {
 	Running    int           // how many jobs are currently running
 	Buried     int           // how many jobs are no longer being processed because of seemingly permanent errors
 	ETC        time.Duration // how long until the the slowest of the currently running jobs is expected to complete
+
+	// RunnerStarts and RunnerExits are how many runners have reported
+	// starting and exiting.
+	RunnerStarts int
+	RunnerExits  int
 }
 
 type rgToKeys struct {
@@ -148,21 +180,27 @@ type jstateCount struct {
 	Count     int // num in FromState drop by this much, num in ToState rise by this much
 }
 
-// badServer is the details of servers that have gone bad that we send to the
-// status webpage. Previously bad servers can also be sent if they become good
-// again, hence the IsBad boolean.
-type badServer struct {
+// BadServer is the details of servers that have gone bad that we send to the
+// status webpage (and to Client.WatchStatus()). Previously bad servers can also
+// be sent if they become good again, hence the IsBad boolean.
+type BadServer struct {
 	ID      string
 	Name    string
 	IP      string
 	Date    int64 // seconds since Unix epoch
 	IsBad   bool
 	Problem string
+
+	// ExcludedHost is true when this is not a cloud server, but a host that
+	// runners were automatically excluded from (see ExcludedHost), in which
+	// case ID and Name are the host name.
+	ExcludedHost bool
 }
 
-// schedulerIssue is the details of scheduler problems encountered that we send
-// to the status webpage.
-type schedulerIssue struct {
+// SchedulerIssue is the details of scheduler problems (or other problems, like
+// failed database backups) encountered that we send to the status webpage (and
+// to Client.WatchStatus()).
+type SchedulerIssue struct {
 	Msg       string
 	FirstDate int64 // seconds since Unix epoch
 	LastDate  int64
@@ -188,23 +226,40 @@ type Server struct {
 	sgroupcounts    map[string]int
 	sgrouptrigs     map[string]int
 	sgtr            map[string]*scheduler.Requirements
+	sgslots         map[string]int
 	sgcmutex        sync.Mutex
 	racmutex        sync.RWMutex
 	rc              string // runner command string compatible with fmt.Sprintf(..., queueName, schedulerGroup, deployment, serverAddr, reserveTimeout, maxMinsAllowed)
+	runnerSlots     int
 	httpServer      *http.Server
 	statusCaster    *bcast.Group
 	badServerCaster *bcast.Group
 	schedCaster     *bcast.Group
+	runnerCaster    *bcast.Group
 	racCheckTimer   *time.Timer
 	racChecking     bool
 	racCheckReady   int
 	bsmutex         sync.RWMutex
 	badServers      map[string]*cloud.Server
 	simutex         sync.RWMutex
-	schedIssues     map[string]*schedulerIssue
+	schedIssues     map[string]*SchedulerIssue
+	rimutex         sync.RWMutex
+	runnerIssues    map[string]*RunnerIssue
+	runnerCounts    map[string]int
+	ehmutex         sync.RWMutex
+	excludedHosts   map[string]*ExcludedHost
+	hfmutex         sync.Mutex
+	hostOutcomes    map[string]hostOutcomes
 	krmutex         sync.RWMutex
 	killRunners     bool
+	keepRunners     bool
 	stopServing     chan bool
+	keepDays        int
+	keepPerRepGroup int
+	prunedPrefix    string
+	stopPruning     chan bool
+	jobEvents       *jobEventLog
+	watchShare      *watchShare
 }
 
 // ServerConfig is supplied to Serve() to configure your jobqueue server. All
@@ -242,6 +297,21 @@ type ServerConfig struct {
 	// be done you will have to run your runner client yourself manually.
 	RunnerCmd string
 
+	// RunnerSlots, if greater than 1, makes the server spawn fewer, larger
+	// runners for groups of small (single core) jobs: each runner is given the
+	// resources of RunnerSlots such jobs and runs up to that many of them at
+	// once. The resources are appended to your RunnerCmd as "--ram %d --cores
+	// %d --disk %d" (in MB, cores and GB respectively), so your runner client
+	// must accept those options (as `wr runner` does). Values of 1 or less
+	// (wr's config defaults to 1) mean every runner runs 1 job at a time.
+	RunnerSlots int
+
+	// ExcludedHosts are the hosts (as named by os.Hostname() on them) that
+	// runners should never be scheduled on. More can be excluded later with
+	// Client.ExcludeHost(), and hosts are also excluded automatically when a
+	// runner reports that they failed its health checks.
+	ExcludedHosts []string
+
 	// Absolute path to where the database file should be saved. The database is
 	// used to ensure no loss of added commands, to keep a permanent history of
 	// all jobs completed, and to keep various stats, amongst other things.
@@ -250,6 +320,37 @@ type ServerConfig struct {
 	// Absolute path to where the database file should be backed up to.
 	DBFileBackup string
 
+	// DBFileBackupKeep is the number of timestamped copies of the backup to
+	// keep (as DBFileBackup suffixed with the date and time of the backup), so
+	// that you can go back to an older backup if the latest one was made from
+	// a bad database. The default of 0 means only DBFileBackup itself is kept.
+	// New copies are only made every DBBackupVersionMinAge.
+	DBFileBackupKeep int
+
+	// DBFileBackupInterval is how often the database is backed up. The default
+	// of 0 means a backup is done after every change to the database.
+	DBFileBackupInterval time.Duration
+
+	// DBKeepDays is the number of days after completion that completed jobs
+	// are kept in the database. Older jobs are pruned: removed from the
+	// database after being archived to a gzipped JSON lines file with the path
+	// prefix DBFilePruned. Pruning happens on start up (when the database file
+	// also gets compacted) and then every ServerDBPruneInterval. The default of
+	// 0 means completed jobs are kept forever.
+	DBKeepDays int
+
+	// DBKeepPerRepGroup is the maximum number of completed jobs that are kept
+	// in the database for each RepGroup; the most recently completed are kept
+	// and the others are pruned as per DBKeepDays. The default of 0 means there
+	// is no limit.
+	DBKeepPerRepGroup int
+
+	// Absolute path prefix for the files that pruned jobs are archived to. Each
+	// pruning creates a new file with this prefix, suffixed with a timestamp.
+	// Only used if DBKeepDays or DBKeepPerRepGroup are set; defaults to DBFile
+	// suffixed with "_pruned".
+	DBFilePruned string
+
 	// Name of the deployment ("development" or "production"); development
 	// databases are deleted and recreated on start up by default.
 	Deployment string
@@ -276,6 +377,13 @@ type ServerConfig struct {
 // determines the command line to execute for your runner client from the
 // configured RunnerCmd string you supplied.
 func Serve(config ServerConfig) (s *Server, msg string, err error) {
+	return serve(config, false)
+}
+
+// serve implements Serve(). If replicated is true, config.DBFile is assumed to
+// have just been replicated from another server by Standby(), and so will not
+// be deleted even in development.
+func serve(config ServerConfig, replicated bool) (s *Server, msg string, err error) {
 	// for security purposes we need to know who will be allowed to access us
 	// in the future
 	owner, err := internal.Username()
@@ -353,10 +461,13 @@ func Serve(config ServerConfig) (s *Server, msg string, err error) {
 	}
 
 	// we need to persist stuff to disk, and we do so using boltdb
-	db, msg, err := initDB(config.DBFile, config.DBFileBackup, config.Deployment)
+	db, msg, err := initDB(config.DBFile, config.DBFileBackup, config.Deployment, replicated)
 	if err != nil {
 		return
 	}
+	if config.DBFilePruned == "" {
+		config.DBFilePruned = config.DBFile + "_pruned"
+	}
 
 	s = &Server{
 		ServerInfo:      &ServerInfo{AllowedUsers: allowedUsers, Addr: ip + ":" + config.Port, Host: host, Port: config.Port, WebPort: config.WebPort, PID: os.Getpid(), Deployment: config.Deployment, Scheduler: config.SchedulerName, Mode: ServerModeNormal},
@@ -373,12 +484,93 @@ func Serve(config ServerConfig) (s *Server, msg string, err error) {
 		sgroupcounts:    make(map[string]int),
 		sgrouptrigs:     make(map[string]int),
 		sgtr:            make(map[string]*scheduler.Requirements),
+		sgslots:         make(map[string]int),
 		rc:              config.RunnerCmd,
+		runnerSlots:     config.RunnerSlots,
 		statusCaster:    bcast.NewGroup(),
 		badServerCaster: bcast.NewGroup(),
 		badServers:      make(map[string]*cloud.Server),
 		schedCaster:     bcast.NewGroup(),
-		schedIssues:     make(map[string]*schedulerIssue),
+		schedIssues:     make(map[string]*SchedulerIssue),
+		runnerCaster:    bcast.NewGroup(),
+		runnerIssues:    make(map[string]*RunnerIssue),
+		runnerCounts:    make(map[string]int),
+		excludedHosts:   make(map[string]*ExcludedHost),
+		hostOutcomes:    make(map[string]hostOutcomes),
+		keepDays:        config.DBKeepDays,
+		keepPerRepGroup: config.DBKeepPerRepGroup,
+		prunedPrefix:    config.DBFilePruned,
+		stopPruning:     make(chan bool, 1),
+		jobEvents:       newJobEventLog(ServerJobEventsRetained),
+		watchShare:      newWatchShare(),
+	}
+
+	// don't schedule runners on hosts we've been told to avoid, or that were
+	// excluded before we were last stopped
+	priorExcluded, err := db.retrieveExcludedHosts()
+	if err != nil {
+		return
+	}
+	for _, eh := range priorExcluded {
+		s.excludedHosts[eh.Host] = eh
+	}
+	for _, host := range config.ExcludedHosts {
+		s.excludedHosts[host] = &ExcludedHost{Host: host, Reason: hostExcludedConfig, Date: time.Now().Unix(), Confirmed: true}
+	}
+	if len(s.excludedHosts) > 0 {
+		sch.ExcludeHosts(s.excludedHostNames())
+	}
+
+	// back up on the desired schedule, and let the user know about failed
+	// backups
+	db.setBackupPolicy(config.DBFileBackupKeep, config.DBFileBackupInterval)
+	db.setBackupErrorCallBack(func(berr error) {
+		log.Println(berr)
+		s.reportIssue(berr.Error())
+	})
+
+	// if we're not keeping completed jobs forever, get rid of the old ones
+	// now, and compact the db file while nothing else is using it, then
+	// periodically prune again
+	if s.keepDays > 0 || s.keepPerRepGroup > 0 {
+		var pruneMsg string
+		pruneMsg, err = s.pruneDB(true)
+		if err != nil {
+			return
+		}
+		var compacted bool
+		compacted, err = db.compact(0.25)
+		if err != nil {
+			return
+		}
+		if compacted {
+			pruneMsg += "; compacted db file " + config.DBFile
+		}
+		if pruneMsg != "" {
+			if msg != "" {
+				msg += "; "
+			}
+			msg += pruneMsg
+		}
+
+		go func() {
+			defer s.logPanic("jobqueue db pruning", false)
+			ticker := time.NewTicker(ServerDBPruneInterval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ticker.C:
+					pmsg, perr := s.pruneDB(false)
+					if perr != nil {
+						log.Printf("db pruning failed: %s\n", perr)
+					} else if pmsg != "" {
+						log.Println(pmsg)
+					}
+				case <-s.stopPruning:
+					return
+				}
+			}
+		}()
 	}
 
 	// if we're restarting from a state where there were incomplete jobs, we
@@ -389,8 +581,19 @@ func Serve(config ServerConfig) (s *Server, msg string, err error) {
 	}
 	if len(priorJobs) > 0 {
 		jobsByQueue := make(map[string][]*queue.ItemDef)
+		runningByQueue := make(map[string][]string)
 		for _, job := range priorJobs {
-			jobsByQueue[job.Queue] = append(jobsByQueue[job.Queue], &queue.ItemDef{Key: job.key(), ReserveGroup: job.getSchedulerGroup(), Data: job, Priority: job.Priority, Delay: 0 * time.Second, TTR: ServerItemTTR, Dependencies: job.Dependencies.incompleteJobKeys(s.db)})
+			// jobs that were running when we stopped may still be running, so
+			// we put those back in the running state, giving their runners
+			// until their ttr to get back in touch with us (if they don't,
+			// they'll become lost, as normal). To avoid them getting reserved
+			// by anyone else in the meantime, they start off delayed
+			delay := 0 * time.Second
+			if !uuid.Equal(job.ReservedBy, uuid.Nil) {
+				delay = ServerItemTTR
+				runningByQueue[job.Queue] = append(runningByQueue[job.Queue], job.key())
+			}
+			jobsByQueue[job.Queue] = append(jobsByQueue[job.Queue], &queue.ItemDef{Key: job.key(), ReserveGroup: job.getSchedulerGroup(), Data: job, Priority: job.Priority, Delay: delay, TTR: ServerItemTTR, Dependencies: job.Dependencies.incompleteJobKeys(s.db)})
 		}
 		for qname, itemdefs := range jobsByQueue {
 			q := s.getOrCreateQueue(qname)
@@ -398,6 +601,12 @@ func Serve(config ServerConfig) (s *Server, msg string, err error) {
 			if err != nil {
 				return
 			}
+
+			for _, key := range runningByQueue[qname] {
+				if _, errr := q.ReserveKey(key); errr == nil {
+					q.SetDelay(key, ClientReleaseDelay)
+				}
+			}
 		}
 	}
 
@@ -417,7 +626,7 @@ func Serve(config ServerConfig) (s *Server, msg string, err error) {
 				m, rerr := sock.RecvMsg()
 				if rerr != nil {
 					s.krmutex.RLock()
-					inShutdown := s.killRunners
+					inShutdown := s.killRunners || s.keepRunners
 					s.krmutex.RUnlock()
 					if !inShutdown && rerr != mangos.ErrRecvTimeout {
 						log.Println(rerr)
@@ -433,7 +642,7 @@ func Serve(config ServerConfig) (s *Server, msg string, err error) {
 					herr := s.handleRequest(m)
 					if ServerLogClientErrors && herr != nil {
 						s.krmutex.RLock()
-						inShutdown := s.killRunners
+						inShutdown := s.killRunners || s.keepRunners
 						s.krmutex.RUnlock()
 						if !inShutdown {
 							log.Println(herr)
@@ -477,6 +686,8 @@ func Serve(config ServerConfig) (s *Server, msg string, err error) {
 		mux.HandleFunc(restJobsEndpoint, restJobs(s, cmdsQ))
 		mux.HandleFunc(restWarningsEndpoint, restWarnings(s))
 		mux.HandleFunc(restBadServersEndpoint, restBadServers(s))
+		mux.HandleFunc(restReqGroupsEndpoint, restReqGroups(s))
+		mux.HandleFunc(restReportEndpoint, restReport(s))
 		srv := &http.Server{Addr: "0.0.0.0:" + config.WebPort, Handler: mux}
 		go srv.ListenAndServe() // *** should use ListenAndServeTLS, which needs certs (http package has cert creation)...
 		s.httpServer = srv
@@ -484,6 +695,7 @@ func Serve(config ServerConfig) (s *Server, msg string, err error) {
 		go s.statusCaster.Broadcasting(0)
 		go s.badServerCaster.Broadcasting(0)
 		go s.schedCaster.Broadcasting(0)
+		go s.runnerCaster.Broadcasting(0)
 
 		badServerCB := func(server *cloud.Server) {
 			s.bsmutex.Lock()
@@ -502,7 +714,7 @@ func Serve(config ServerConfig) (s *Server, msg string, err error) {
 			s.bsmutex.Unlock()
 
 			if !skip {
-				s.badServerCaster.Send(&badServer{
+				s.badServerCaster.Send(&BadServer{
 					ID:      server.ID,
 					Name:    server.Name,
 					IP:      server.IP,
@@ -514,26 +726,7 @@ func Serve(config ServerConfig) (s *Server, msg string, err error) {
 		}
 		s.scheduler.SetBadServerCallBack(badServerCB)
 
-		messageCB := func(msg string) {
-			s.simutex.Lock()
-			var si *schedulerIssue
-			var existed bool
-			if si, existed = s.schedIssues[msg]; existed {
-				si.LastDate = time.Now().Unix()
-				si.Count = si.Count + 1
-			} else {
-				si = &schedulerIssue{
-					Msg:       msg,
-					FirstDate: time.Now().Unix(),
-					LastDate:  time.Now().Unix(),
-					Count:     1,
-				}
-				s.schedIssues[msg] = si
-			}
-			s.simutex.Unlock()
-			s.schedCaster.Send(si)
-		}
-		s.scheduler.SetMessageCallBack(messageCB)
+		s.scheduler.SetMessageCallBack(s.reportIssue)
 
 		// wait a while for ListenAndServe() to start listening
 		<-time.After(10 * time.Millisecond)
@@ -544,6 +737,28 @@ func Serve(config ServerConfig) (s *Server, msg string, err error) {
 	return
 }
 
+// reportIssue records a problem that we want the user to know about, sending
+// it to the status webpage. Repeats of the same msg are counted.
+func (s *Server) reportIssue(msg string) {
+	s.simutex.Lock()
+	var si *SchedulerIssue
+	var existed bool
+	if si, existed = s.schedIssues[msg]; existed {
+		si.LastDate = time.Now().Unix()
+		si.Count = si.Count + 1
+	} else {
+		si = &SchedulerIssue{
+			Msg:       msg,
+			FirstDate: time.Now().Unix(),
+			LastDate:  time.Now().Unix(),
+			Count:     1,
+		}
+		s.schedIssues[msg] = si
+	}
+	s.simutex.Unlock()
+	s.schedCaster.Send(si)
+}
+
 // Block makes you block while the server does the job of serving clients. This
 // will return with an error indicating why it stopped blocking, which will
 // be due to receiving a signal or because you called Stop()
@@ -592,6 +807,21 @@ func (s *Server) Stop(wait ...bool) (err error) {
 	return
 }
 
+// StopKeepRunners is like Stop(), except that runners are not killed and the
+// job scheduler is not cleaned up: any commands that are currently running will
+// carry on running. If a new server is then started using the same database,
+// those runners will re-attach to their jobs (which will be restored in the
+// running state) and be able to report on their completion, so nothing needs
+// to be re-run. This is useful for eg. upgrading the server without downtime.
+// NB: with cloud schedulers, any servers that the old server spawned will not
+// be known about by the new server, so will not be automatically destroyed.
+func (s *Server) StopKeepRunners(wait ...bool) (err error) {
+	s.krmutex.Lock()
+	s.keepRunners = true
+	s.krmutex.Unlock()
+	return s.Stop(wait...)
+}
+
 // Drain will stop the server spawning new runners and stop Reserve*() from
 // returning any more Jobs. Once all current runners exit, we Stop().
 func (s *Server) Drain() (err error) {
@@ -663,7 +893,8 @@ func (s *Server) GetServerStats() *ServerStats {
 		}
 	}
 
-	return &ServerStats{ServerInfo: s.ServerInfo, Delayed: delayed, Ready: ready, Running: running, Buried: buried, ETC: etc.Truncate(time.Minute).Sub(time.Now().Truncate(time.Minute))}
+	starts, exits := s.getRunnerEventCounts()
+	return &ServerStats{ServerInfo: s.ServerInfo, Delayed: delayed, Ready: ready, Running: running, Buried: buried, ETC: etc.Truncate(time.Minute).Sub(time.Now().Truncate(time.Minute)), RunnerStarts: starts, RunnerExits: exits}
 }
 
 // BackupDB lets you do a manual live backup of the server's database to a given
@@ -716,23 +947,17 @@ func (s *Server) getOrCreateQueue(qname string) *queue.Queue {
 			for _, inter := range allitemdata {
 				job := inter.(*Job)
 
-				// depending on job.Override, get memory and time
-				// recommendations, which are rounded to get fewer larger
-				// groups
+				// depending on job.Override, get memory, time, disk and cores
+				// recommendations (learned from prior jobs, or pinned), which
+				// are rounded to get fewer larger groups
 				noRec := false
 				if job.Override != 2 {
 					var recommendedReq *scheduler.Requirements
 					if rec, existed := groupToReqs[job.ReqGroup]; existed {
 						recommendedReq = rec
 					} else {
-						recm, _ := s.db.recommendedReqGroupMemory(job.ReqGroup)
-						recs, _ := s.db.recommendedReqGroupTime(job.ReqGroup)
-						if recm == 0 || recs == 0 {
-							groupToReqs[job.ReqGroup] = nil
-						} else {
-							recommendedReq = &scheduler.Requirements{RAM: recm, Time: time.Duration(recs) * time.Second}
-							groupToReqs[job.ReqGroup] = recommendedReq
-						}
+						recommendedReq = s.db.recommendedReqGroupRequirements(job.ReqGroup)
+						groupToReqs[job.ReqGroup] = recommendedReq
 					}
 
 					if recommendedReq != nil {
@@ -743,13 +968,33 @@ func (s *Server) getOrCreateQueue(qname string) *queue.Queue {
 							if recommendedReq.Time > job.Requirements.Time {
 								job.Requirements.Time = recommendedReq.Time
 							}
+							if recommendedReq.Disk > job.Requirements.Disk {
+								job.Requirements.Disk = recommendedReq.Disk
+							}
+							if recommendedReq.Cores > job.Requirements.Cores {
+								job.Requirements.Cores = recommendedReq.Cores
+							}
 						} else {
-							job.Requirements.RAM = recommendedReq.RAM
-							job.Requirements.Time = recommendedReq.Time
+							if recommendedReq.RAM > 0 {
+								job.Requirements.RAM = recommendedReq.RAM
+							}
+							if recommendedReq.Time > 0 {
+								job.Requirements.Time = recommendedReq.Time
+							}
+							if recommendedReq.Disk > 0 {
+								job.Requirements.Disk = recommendedReq.Disk
+							}
+							if recommendedReq.Cores > 0 {
+								job.Requirements.Cores = recommendedReq.Cores
+							}
 						}
 					} else {
 						noRec = true
 					}
+
+					// learned requirements mustn't go beyond the ceilings
+					job.Escalation.limit(job.Requirements)
+					job.snapshotRequirements()
 				}
 
 				var req *scheduler.Requirements
@@ -912,13 +1157,15 @@ func (s *Server) getOrCreateQueue(qname string) *queue.Queue {
 			groups := make(map[string]int)
 			groupsLost := make(map[string]int)
 			lost := 0
+			var noLongerRunning []string
 			for _, inter := range data {
 				job := inter.(*Job)
 
 				// if we change from running, mark that we have not scheduled a
-				// runner for the job
+				// runner for the job, and forget that it was running
 				if from == JobStateRunning {
 					job.setScheduledRunner(false)
+					noLongerRunning = append(noLongerRunning, job.key())
 
 					job.RLock()
 					l := job.Lost
@@ -933,6 +1180,34 @@ func (s *Server) getOrCreateQueue(qname string) *queue.Queue {
 				groups[job.RepGroup]++
 			}
 
+			if len(noLongerRunning) > 0 {
+				s.db.deleteRunningJobs(noLongerRunning)
+			}
+
+			// record each job's transition for subscribers, and in the history
+			// of jobs that became ready by themselves (other transitions are
+			// recorded when they are made to happen)
+			events := make([]*JobEvent, len(data))
+			var history []*jobHistoryEntry
+			for i, inter := range data {
+				job := inter.(*Job)
+				job.RLock()
+				jFrom, jTo := from, to
+				if from == JobStateRunning && job.Lost {
+					jFrom = JobStateLost
+				}
+				if toQ == queue.SubQueueRemoved && job.State != JobStateComplete {
+					jTo = JobStateDeleted
+				}
+				events[i] = newJobEvent(q.Name, jFrom, jTo, job)
+				if toQ == queue.SubQueueReady && (fromQ == queue.SubQueueDelay || fromQ == queue.SubQueueDependent) {
+					history = append(history, newJobHistoryEntry(job, &JobHistoryEvent{Event: JobHistoryReady, State: to}))
+				}
+				job.RUnlock()
+			}
+			s.jobEvents.add(events...)
+			s.db.storeJobHistory(history)
+
 			// send out the counts
 			s.statusCaster.Send(&jstateCount{"+all+", from, to, len(data) - lost})
 			for group, count := range groups {
@@ -964,6 +1239,8 @@ func (s *Server) getOrCreateQueue(qname string) *queue.Queue {
 
 				// since our changed callback won't be called, send out this
 				// transition from running to lost state
+				defer s.jobEvents.add(newJobEvent(q.Name, JobStateRunning, JobStateLost, job))
+				s.db.storeJobHistory([]*jobHistoryEntry{newJobHistoryEntry(job, &JobHistoryEvent{Event: JobHistoryLost, State: JobStateLost})})
 				defer s.statusCaster.Send(&jstateCount{"+all+", JobStateRunning, JobStateLost, 1})
 				defer s.statusCaster.Send(&jstateCount{job.RepGroup, JobStateRunning, JobStateLost, 1})
 
@@ -979,6 +1256,15 @@ func (s *Server) getOrCreateQueue(qname string) *queue.Queue {
 
 // enqueueItems adds new items to a queue, for when we have new jobs to handle.
 func (s *Server) enqueueItems(q *queue.Queue, itemdefs []*queue.ItemDef) (added int, dups int, err error) {
+	// Reserve() may match these jobs against runner capacities as soon as
+	// they're in the queue, so they need their Requirements snapshot first
+	for _, itemdef := range itemdefs {
+		job := itemdef.Data.(*Job)
+		job.Lock()
+		job.snapshotRequirements()
+		job.Unlock()
+	}
+
 	added, dups, err = q.AddMany(itemdefs)
 	if err != nil {
 		return
@@ -1000,14 +1286,47 @@ func (s *Server) enqueueItems(q *queue.Queue, itemdefs []*queue.ItemDef) (added
 
 // createJobs creates new jobs, adding them to the database and the in-memory
 // queue. It returns 2 errors; the first is one of our Err constant strings,
-// the second is the actual error with more details.
+// the second is the actual error with more details. If envkey is empty, the
+// jobs are assumed to have been prepared by importJobs(), and keep their own
+// EnvKey and UntilBuried.
 func (s *Server) createJobs(q *queue.Queue, inputJobs []*Job, envkey string, ignoreComplete bool) (added, dups, alreadyComplete int, srerr string, qerr error) {
 	// create itemdefs for the jobs
+	storedScripts := make(map[string]bool)
+	escalations := make(map[string]*Escalation)
 	for _, job := range inputJobs {
 		job.Lock()
-		job.EnvKey = envkey
-		job.UntilBuried = job.Retries + 1
+
+		// like envs, scripts are stored separately to the jobs, since many jobs
+		// could share the same (large) script
+		if job.ScriptKey != "" && len(job.ScriptC) > 0 {
+			if !storedScripts[job.ScriptKey] {
+				err := s.db.storeScript(job.ScriptKey, job.ScriptC)
+				if err != nil {
+					job.Unlock()
+					srerr = ErrDBError
+					qerr = err
+					return
+				}
+				storedScripts[job.ScriptKey] = true
+			}
+			job.ScriptC = nil
+		}
+
+		if envkey != "" {
+			job.EnvKey = envkey
+			job.UntilBuried = job.Retries + 1
+		} else if job.UntilBuried <= 0 {
+			job.UntilBuried = job.Retries + 1
+		}
 		job.Queue = q.Name
+		if job.Escalation == nil {
+			if esc, cached := escalations[job.ReqGroup]; cached {
+				job.Escalation = esc
+			} else {
+				job.Escalation = s.db.retrieveReqGroupEscalation(job.ReqGroup)
+				escalations[job.ReqGroup] = job.Escalation
+			}
+		}
 		if s.rc != "" {
 			job.schedulerGroup = job.Requirements.Stringify()
 		}
@@ -1045,8 +1364,22 @@ func (s *Server) createJobs(q *queue.Queue, inputJobs []*Job, envkey string, ign
 		// previously Archive()d jobs that were resurrected because of one of
 		// their DepGroup dependencies being in cr.Jobs
 		var itemdefs []*queue.ItemDef
+		var history []*jobHistoryEntry
 		for _, job := range jobsToQueue {
-			itemdefs = append(itemdefs, &queue.ItemDef{Key: job.key(), ReserveGroup: job.getSchedulerGroup(), Data: job, Priority: job.Priority, Delay: 0 * time.Second, TTR: ServerItemTTR, Dependencies: job.Dependencies.incompleteJobKeys(s.db)})
+			itemdef := &queue.ItemDef{Key: job.key(), ReserveGroup: job.getSchedulerGroup(), Data: job, Priority: job.Priority, Delay: 0 * time.Second, TTR: ServerItemTTR, Dependencies: job.Dependencies.incompleteJobKeys(s.db)}
+			itemdefs = append(itemdefs, itemdef)
+
+			// jobs already in the queue will be dups that don't get added
+			if _, errg := q.Get(itemdef.Key); errg == nil {
+				continue
+			}
+			state := JobStateReady
+			if len(itemdef.Dependencies) > 0 {
+				state = JobStateDependent
+			}
+			job.RLock()
+			history = append(history, newJobHistoryEntry(job, &JobHistoryEvent{Event: JobHistoryAdded, State: state}))
+			job.RUnlock()
 		}
 
 		// storeNewJobs also returns jobsToUpdate, which are those jobs
@@ -1063,7 +1396,10 @@ func (s *Server) createJobs(q *queue.Queue, inputJobs []*Job, envkey string, ign
 		if qerr != nil {
 			srerr = ErrInternalError
 		} else {
-			// add the jobs to the in-memory job queue
+			// add the jobs to the in-memory job queue, noting in their history
+			// that we did so first, so that this comes before anything that
+			// happens to them once queued
+			s.db.storeJobHistory(history)
 			added, dups, qerr = s.enqueueItems(q, itemdefs)
 			if qerr != nil {
 				srerr = ErrInternalError
@@ -1074,6 +1410,230 @@ func (s *Server) createJobs(q *queue.Queue, inputJobs []*Job, envkey string, ign
 	return
 }
 
+// addChildJobs adds jobs as the children of the running job with the given
+// key, after checking that token is the ChildToken it was given when reserved.
+// Children get the parent's RepGroup, Cwd and MountConfigs unless they have
+// their own, and the parent's environment if env is empty. If waitChildren,
+// children also join the parent's DepGroups, so that anything that depends on
+// the parent will also wait for them. It returns 2 errors as per createJobs().
+func (s *Server) addChildJobs(q *queue.Queue, parentKey string, token string, children []*Job, env []byte, waitChildren bool, ignoreComplete bool) (added, dups, alreadyComplete int, srerr string, qerr error) {
+	item, err := q.Get(parentKey)
+	if err != nil || item.Stats().State != queue.ItemStateRun {
+		srerr = ErrBadParent
+		qerr = fmt.Errorf("job [%s] is not running", parentKey)
+		return
+	}
+	parent := item.Data.(*Job)
+	parent.RLock()
+	childToken := parent.ChildToken
+	repGroup := parent.RepGroup
+	cwd := parent.Cwd
+	mounts := parent.MountConfigs
+	envkey := parent.EnvKey
+	depGroups := parent.DepGroups
+	parent.RUnlock()
+	if token == "" || token != childToken {
+		srerr = ErrBadParent
+		qerr = fmt.Errorf("incorrect token for job [%s]", parentKey)
+		return
+	}
+
+	if len(env) > 0 {
+		envkey, err = s.db.storeEnv(env)
+		if err != nil {
+			srerr = ErrDBError
+			qerr = err
+			return
+		}
+	}
+
+	for _, job := range children {
+		job.Lock()
+		job.Parent = parentKey
+		if job.RepGroup == "" {
+			job.RepGroup = repGroup
+		}
+		if job.Cwd == "" {
+			job.Cwd = cwd
+		}
+		if len(job.MountConfigs) == 0 {
+			job.MountConfigs = mounts
+		}
+		if waitChildren {
+			for _, depGroup := range depGroups {
+				member := false
+				for _, dg := range job.DepGroups {
+					if dg == depGroup {
+						member = true
+						break
+					}
+				}
+				if !member {
+					job.DepGroups = append(job.DepGroups, depGroup)
+				}
+			}
+		}
+		job.Unlock()
+	}
+
+	return s.createJobs(q, children, envkey, ignoreComplete)
+}
+
+// getReqGroupStats gets a summary of what has been learned about the given
+// ReqGroup, or about all ReqGroups if reqGroup is blank.
+func (s *Server) getReqGroupStats(reqGroup string) (stats []*ReqGroupStats, err error) {
+	reqGroups := []string{reqGroup}
+	if reqGroup == "" {
+		reqGroups, err = s.db.reqGroups()
+		if err != nil {
+			return
+		}
+	}
+	for _, rg := range reqGroups {
+		var rgs *ReqGroupStats
+		rgs, err = s.db.reqGroupStats(rg)
+		if err != nil {
+			return
+		}
+		stats = append(stats, rgs)
+	}
+	return
+}
+
+// trimReqGroup deletes the samples learned for the given ReqGroup of the given
+// ReqGroupStat* kind (or of all kinds if blank) that are outside of min..max,
+// or all of them if min and max are -1. Returns the number of samples deleted.
+func (s *Server) trimReqGroup(reqGroup string, kind string, min int, max int) (removed int, srerr string, err error) {
+	buckets := [][]byte{bucketJobMBs, bucketJobSecs, bucketJobDisks, bucketJobCores}
+	if kind != "" {
+		bucket := reqGroupStatBucket(kind)
+		if bucket == nil {
+			srerr = ErrBadRequest
+			err = fmt.Errorf("unknown kind of stat [%s]", kind)
+			return
+		}
+		buckets = [][]byte{bucket}
+	}
+	removed, err = s.db.trimReqGroup(reqGroup, buckets, min, max)
+	if err != nil {
+		srerr = ErrDBError
+	}
+	return
+}
+
+// getEfficiencyReports gets an EfficiencyReport for each RepGroup or ReqGroup
+// (according to the ReportBy* constant supplied) of the completed jobs,
+// optionally only considering those in the given RepGroup.
+func (s *Server) getEfficiencyReports(by string, repGroup string) (reports []*EfficiencyReport, srerr string, err error) {
+	groups, err := newEfficiencyGroups(by)
+	if err != nil {
+		srerr = ErrBadRequest
+		return
+	}
+	err = s.db.addCompleteJobEfficiencies(groups, repGroup)
+	if err != nil {
+		srerr = ErrDBError
+		return
+	}
+	reports = groups.reports()
+	return
+}
+
+// getJobsByParent gets the children (current and complete) of the job with the
+// given key.
+func (s *Server) getJobsByParent(q *queue.Queue, parentKey string, getStd bool, getEnv bool) (jobs []*Job, srerr string, qerr string) {
+	keys, err := s.db.retrieveChildKeys(parentKey)
+	if err != nil {
+		srerr = ErrDBError
+		qerr = err.Error()
+		return
+	}
+	if len(keys) == 0 {
+		return
+	}
+	return s.getJobsByKeys(q, keys, getStd, getEnv)
+}
+
+// importJobs adds jobs that were exported from another server (see the
+// "export" request), keeping their history. Complete jobs are stored directly
+// in the database as complete, while the others are added to the queue (as
+// per createJobs(), but using their own env). Jobs that were mid-run when
+// exported are treated as if they were lost and confirmed dead, and buried
+// jobs as if kicked. It returns 2 errors; the first is one of our Err constant
+// strings, the second is the actual error with more details.
+func (s *Server) importJobs(q *queue.Queue, jobs []*Job) (added, existed int, srerr string, qerr error) {
+	var complete, incomplete []*Job
+	for _, job := range jobs {
+		if len(job.EnvC) == 0 {
+			srerr = ErrBadRequest
+			qerr = fmt.Errorf("job [%s] has no environment", job.Cmd)
+			return
+		}
+		if job.State == JobStateComplete {
+			complete = append(complete, job)
+		} else {
+			incomplete = append(incomplete, job)
+		}
+	}
+
+	// first store the complete ones, so that any incomplete jobs that depend
+	// on them will see their dependencies as satisfied
+	if len(complete) > 0 {
+		var stored, dups int
+		stored, dups, qerr = s.db.storeCompleteJobs(complete, q.Name)
+		if qerr != nil {
+			srerr = ErrDBError
+			return
+		}
+		added += stored
+		existed += dups
+	}
+
+	if len(incomplete) == 0 {
+		return
+	}
+	for _, job := range incomplete {
+		envkey, err := s.db.storeEnv(job.EnvC)
+		if err != nil {
+			srerr = ErrDBError
+			qerr = err
+			return
+		}
+
+		job.Lock()
+		job.EnvKey = envkey
+		job.EnvC = nil
+		job.StdOutC = nil
+		job.StdErrC = nil
+		job.ResourceSeriesC = nil
+		switch job.State {
+		case JobStateReserved, JobStateRunning, JobStateLost:
+			// the cmd might even still be running under the other server, but
+			// we can't know what will become of it, so we treat it as dead,
+			// though we always allow at least one more attempt
+			job.Exited = true
+			job.Exitcode = -1
+			if job.EndTime.IsZero() || job.EndTime.Before(job.StartTime) {
+				job.EndTime = time.Now()
+			}
+			job.FailReason = FailReasonLost
+			job.UntilBuried--
+			if job.UntilBuried <= 0 {
+				job.UntilBuried = 1
+			}
+		case JobStateBuried:
+			job.UntilBuried = job.Retries + 1
+		}
+		job.Lost = false
+		job.Unlock()
+	}
+
+	thisAdded, dups, alreadyComplete, srerr, qerr := s.createJobs(q, incomplete, "", true)
+	added += thisAdded
+	existed += dups + alreadyComplete
+	return
+}
+
 // killJob sets the killCalled property on a job, to change the subsequent
 // behaviour of touching, which should result in an executing job killing
 // itself.
@@ -1102,10 +1662,21 @@ func (s *Server) killJob(q *queue.Queue, jobkey string) (eligible bool, err erro
 		job.Exitcode = -1
 		job.EndTime = time.Now()
 		job.FailReason = FailReasonLost
+		s.db.storeJobAttempt(job.key(), newJobAttempt(job))
+		retryable := job.RetryPolicy.retryable(FailReasonLost, -1)
+		if retryable && ub > 0 && job.RetryPolicy != nil {
+			q.SetDelay(item.Key, job.RetryPolicy.delay(int(job.Retries)+1-int(ub)))
+		}
+		host, reqGroup := job.Host, job.ReqGroup
 		job.Unlock()
 		s.db.updateJobAfterExit(job, []byte{}, []byte{}, false)
 
-		if ub <= 0 {
+		// now that the loss is confirmed, it counts against the host (we don't
+		// count it when the job first becomes lost, since that happens to
+		// lots of jobs at once if we or the network merely stall for a while)
+		s.recordHostFailure(host, reqGroup, FailReasonLost)
+
+		if ub <= 0 || !retryable {
 			err = q.Bury(item.Key)
 			if err != nil {
 				return
@@ -1216,6 +1787,31 @@ func (s *Server) getJobsCurrent(q *queue.Queue, limit int, state JobState, getSt
 	return
 }
 
+// getJobsByFilter gets the jobs described by the filter: current ones, and
+// complete ones as well if the filter has a RepGroup.
+func (s *Server) getJobsByFilter(q *queue.Queue, filter *JobFilter, limit int, getStd bool, getEnv bool) (jobs []*Job, srerr string, qerr string) {
+	var candidates []*Job
+	if filter.RepGroup != "" {
+		candidates, srerr, qerr = s.getJobsByRepGroup(q, filter.RepGroup, 0, filter.State, false, false)
+		if srerr != "" {
+			return
+		}
+	} else {
+		candidates = s.getJobsCurrent(q, 0, "", false, false)
+	}
+
+	for _, job := range candidates {
+		if filter.matches(job) {
+			jobs = append(jobs, job)
+		}
+	}
+
+	if limit > 0 || filter.State != "" || getStd || getEnv {
+		jobs = s.limitJobs(jobs, limit, filter.State, getStd, getEnv)
+	}
+	return
+}
+
 // limitJobs handles the limiting of jobs for getJobsByRepGroup() and
 // getJobsCurrent(). States 'reserved' and 'running' are treated as the same
 // state.
@@ -1301,12 +1897,23 @@ func (s *Server) scheduleRunners(q *queue.Queue, group string) {
 		s.sgroupcounts[group] = 0
 		doClear = true
 	}
+	cmd, runnerReq, slots := s.runnerCmdAndReq(rc, q, group, req)
 	s.sgcmutex.Unlock()
 
 	if !doClear {
-		err := s.scheduler.Schedule(fmt.Sprintf(rc, q.Name, group, s.ServerInfo.Deployment, s.ServerInfo.Addr, s.scheduler.ReserveTimeout(), int(s.scheduler.MaxQueueTime(req).Minutes())), req, groupCount)
+		err := s.scheduler.Schedule(cmd, runnerReq, (groupCount+slots-1)/slots)
 		if err != nil {
 			problem := true
+			if serr, ok := err.(scheduler.Error); ok && serr.Err == scheduler.ErrImpossible && slots > 1 {
+				// the jobs might still be possible to run one at a time, so
+				// give up on multi-slot runners for this group
+				s.scheduler.Schedule(cmd, runnerReq, 0)
+				s.sgcmutex.Lock()
+				s.sgslots[group] = 1
+				s.sgcmutex.Unlock()
+				s.scheduleRunners(q, group)
+				return
+			}
 			if serr, ok := err.(scheduler.Error); ok && serr.Err == scheduler.ErrImpossible {
 				// bury all jobs in this scheduler group
 				problem = false
@@ -1399,20 +2006,55 @@ func (s *Server) clearSchedulerGroup(schedulerGroup string, q *queue.Queue) {
 			s.sgcmutex.Unlock()
 			return
 		}
+		cmd, runnerReq, _ := s.runnerCmdAndReq(s.rc, q, schedulerGroup, req)
 		delete(s.sgroupcounts, schedulerGroup)
 		delete(s.sgrouptrigs, schedulerGroup)
 		delete(s.sgtr, schedulerGroup)
+		delete(s.sgslots, schedulerGroup)
 		s.sgcmutex.Unlock()
-		s.scheduler.Schedule(fmt.Sprintf(s.rc, q.Name, schedulerGroup, s.ServerInfo.Deployment, s.ServerInfo.Addr, s.scheduler.ReserveTimeout(), int(s.scheduler.MaxQueueTime(req).Minutes())), req, 0)
+		s.scheduler.Schedule(cmd, runnerReq, 0)
+	}
+}
+
+// runnerCmdAndReq returns the command line and resource requirements of the
+// runners we schedule for the given scheduler group (of jobs that need req),
+// along with how many of the group's jobs each runner will run at once. That
+// is decided the first time this is called for a group, and stays the same
+// until the group is cleared. You must hold sgcmutex.
+func (s *Server) runnerCmdAndReq(rc string, q *queue.Queue, group string, req *scheduler.Requirements) (cmd string, runnerReq *scheduler.Requirements, slots int) {
+	slots, decided := s.sgslots[group]
+	if !decided {
+		slots = 1
+		if s.runnerSlots > 1 && req.Cores == 1 {
+			slots = s.runnerSlots
+		}
+		s.sgslots[group] = slots
+	}
+
+	runnerReq = req
+	if slots > 1 {
+		runnerReq = &scheduler.Requirements{
+			RAM:   req.RAM * slots,
+			Time:  req.Time,
+			Cores: req.Cores * slots,
+			Disk:  req.Disk * slots,
+			Other: req.Other,
+		}
 	}
+
+	cmd = fmt.Sprintf(rc, q.Name, group, s.ServerInfo.Deployment, s.ServerInfo.Addr, s.scheduler.ReserveTimeout(), int(s.scheduler.MaxQueueTime(runnerReq).Minutes()))
+	if slots > 1 {
+		cmd += fmt.Sprintf(" --ram %d --cores %d --disk %d", runnerReq.RAM, runnerReq.Cores, runnerReq.Disk)
+	}
+	return
 }
 
 // getBadServers converts the slice of cloud.Server objects we hold in to a
-// slice of badServer structs.
-func (s *Server) getBadServers() (bs []*badServer) {
+// slice of BadServer structs.
+func (s *Server) getBadServers() (bs []*BadServer) {
 	s.bsmutex.RLock()
 	for _, server := range s.badServers {
-		bs = append(bs, &badServer{
+		bs = append(bs, &BadServer{
 			ID:      server.ID,
 			Name:    server.Name,
 			IP:      server.IP,
@@ -1422,6 +2064,7 @@ func (s *Server) getBadServers() (bs []*badServer) {
 		})
 	}
 	s.bsmutex.RUnlock()
+	bs = append(bs, s.getUnconfirmedExcludedHosts()...)
 	return
 }
 
@@ -1431,24 +2074,36 @@ func (s *Server) getBadServers() (bs []*badServer) {
 // For now it also kills all currently running jobs so that their runners don't
 // stay alive uselessly. *** This adds 15s to our shutdown time...
 func (s *Server) shutdown() {
+	// we're being deliberately stopped, so any standby servers mustn't take
+	// over from us
+	s.db.repl.announceShutdown(ServerStandbyPollTime)
+
 	// change touch to always return a kill signal
 	s.racmutex.Lock()
 	s.drain = true
 	s.ServerInfo.Mode = ServerModeDrain
 	s.racmutex.Unlock()
 	s.krmutex.Lock()
-	s.killRunners = true
+	keepRunners := s.keepRunners
+	if !keepRunners {
+		s.killRunners = true
+	}
 	s.krmutex.Unlock()
-	if s.HasRunners() {
+	if !keepRunners && s.HasRunners() {
 		// wait until everything must have attempted a touch
 		<-time.After(ClientTouchInterval)
 	}
 	s.stopServing <- true
+	if s.keepDays > 0 || s.keepPerRepGroup > 0 {
+		s.stopPruning <- true
+	}
 
 	s.Lock()
 	s.sock.Close()
 	s.db.close()
-	s.scheduler.Cleanup()
+	if !keepRunners {
+		s.scheduler.Cleanup()
+	}
 	s.httpServer.Shutdown(context.Background())
 
 	// wait until the ports are really no longer being listened to (which isn't
@@ -1480,9 +2135,27 @@ func (s *Server) shutdown() {
 
 	s.krmutex.Lock()
 	s.killRunners = false
+	s.keepRunners = false
 	s.krmutex.Unlock()
 }
 
+// pruneDB removes old completed jobs from the database according to our
+// keepDays and keepPerRepGroup settings, archiving them to a new file. Envs
+// and scripts no longer used by any job are only removed if atStartup is true,
+// since otherwise they could belong to jobs that are in the middle of being
+// added. It returns a message saying what was done, if anything was pruned.
+func (s *Server) pruneDB(atStartup bool) (msg string, err error) {
+	path := s.prunedPrefix + "." + time.Now().Format("20060102-150405") + ".jsonl.gz"
+	pruned, err := s.db.pruneCompleteJobs(time.Duration(s.keepDays)*24*time.Hour, s.keepPerRepGroup, path, atStartup)
+	if err != nil {
+		return
+	}
+	if pruned > 0 {
+		msg = fmt.Sprintf("pruned %d completed jobs from the db, archiving them to %s", pruned, path)
+	}
+	return
+}
+
 // logPanic is for (ideally temporary) use in a go routine, deferred at the
 // start of it, to figure out what is causing runtime panics that are killing
 // the server. If the die bool is true, the program exits, otherwise it
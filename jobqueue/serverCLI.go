@@ -65,6 +65,29 @@ func (s *Server) handleRequest(m *mangos.Message) error {
 			// do nothing - not returning an error to client means ping success
 		case "sstats":
 			sr = &serverResponse{SStats: s.GetServerStats()}
+		case "rreport":
+			// record a runner's start, exit or error
+			if cr.RunnerIssue == nil {
+				srerr = ErrBadRequest
+			} else {
+				s.reportRunnerIssue(cr.RunnerIssue)
+			}
+		case "rissues":
+			sr = &serverResponse{RunnerIssues: s.getRunnerIssues()}
+		case "hexclude":
+			if cr.Host == "" {
+				srerr = ErrBadRequest
+			} else {
+				s.excludeHost(cr.Host, cr.Reason, true)
+			}
+		case "hinclude":
+			if cr.Host == "" {
+				srerr = ErrBadRequest
+			} else {
+				s.includeHost(cr.Host)
+			}
+		case "hexcluded":
+			sr = &serverResponse{ExcludedHosts: s.getExcludedHosts()}
 		case "backup":
 			// make an io.Writer that writes to a byte slice, so we can return
 			// the db as that
@@ -76,6 +99,25 @@ func (s *Server) handleRequest(m *mangos.Message) error {
 			} else {
 				sr = &serverResponse{DB: b.Bytes()}
 			}
+		case "replicate":
+			// send a standby server the changes to our database it doesn't
+			// have yet, or if it can't catch up that way, a complete snapshot
+			txns, ok, stopping := s.db.repl.since(cr.ReplID, cr.ReplSeq, cr.Timeout)
+			if stopping {
+				sr = &serverResponse{ReplStopping: true}
+				defer s.db.repl.shutdownAnnounced()
+			} else if ok {
+				sr = &serverResponse{ReplID: cr.ReplID, Txns: txns}
+			} else {
+				var b bytes.Buffer
+				id, seq, err := s.db.snapshot(&b)
+				if err != nil {
+					srerr = ErrDBError
+					qerr = err.Error()
+				} else {
+					sr = &serverResponse{ReplID: id, ReplSeq: seq, DB: b.Bytes()}
+				}
+			}
 		case "drain":
 			err := s.Drain()
 			if err != nil {
@@ -85,7 +127,12 @@ func (s *Server) handleRequest(m *mangos.Message) error {
 				sr = &serverResponse{SStats: s.GetServerStats()}
 			}
 		case "shutdown":
-			err := s.Stop()
+			var err error
+			if cr.KeepRunners {
+				err = s.StopKeepRunners()
+			} else {
+				err = s.Stop()
+			}
 			if err != nil {
 				srerr = ErrInternalError
 				qerr = err.Error()
@@ -114,11 +161,102 @@ func (s *Server) handleRequest(m *mangos.Message) error {
 					}
 				}
 			}
+		case "addc":
+			// add jobs to the queue as the children of a running job, on behalf
+			// of that job's Cmd
+			if cr.Parent == "" || cr.Jobs == nil {
+				srerr = ErrBadRequest
+			} else {
+				added, dups, alreadyComplete, thisSrerr, err := s.addChildJobs(q, cr.Parent, cr.ParentToken, cr.Jobs, cr.Env, cr.WaitChildren, cr.IgnoreComplete)
+				if err != nil {
+					srerr = thisSrerr
+					qerr = err.Error()
+				} else {
+					sr = &serverResponse{Added: added, Existed: dups + alreadyComplete}
+				}
+			}
+		case "sesc":
+			// set the Escalation for jobs subsequently added to a ReqGroup
+			if cr.ReqGroup == "" {
+				srerr = ErrBadRequest
+			} else {
+				err := s.db.storeReqGroupEscalation(cr.ReqGroup, cr.Escalation)
+				if err != nil {
+					srerr = ErrDBError
+					qerr = err.Error()
+				}
+			}
+		case "gesc":
+			if cr.ReqGroup == "" {
+				srerr = ErrBadRequest
+			} else {
+				sr = &serverResponse{Escalation: s.db.retrieveReqGroupEscalation(cr.ReqGroup)}
+			}
+		case "rgstats":
+			// get what has been learned about ReqGroups
+			stats, err := s.getReqGroupStats(cr.ReqGroup)
+			if err != nil {
+				srerr = ErrDBError
+				qerr = err.Error()
+			} else {
+				sr = &serverResponse{ReqGroupStats: stats}
+			}
+		case "report":
+			// summarise the resource efficiency of complete jobs
+			var repGroup string
+			if cr.Job != nil {
+				repGroup = cr.Job.RepGroup
+			}
+			reports, thisSrerr, err := s.getEfficiencyReports(cr.ReportBy, repGroup)
+			if err != nil {
+				srerr = thisSrerr
+				qerr = err.Error()
+			} else {
+				sr = &serverResponse{Reports: reports}
+			}
+		case "rgtrim":
+			// delete some or all of the samples learned for a ReqGroup
+			if cr.ReqGroup == "" {
+				srerr = ErrBadRequest
+			} else {
+				removed, thisSrerr, err := s.trimReqGroup(cr.ReqGroup, cr.Stat, cr.Min, cr.Max)
+				if err != nil {
+					srerr = thisSrerr
+					qerr = err.Error()
+				} else {
+					sr = &serverResponse{Removed: removed}
+				}
+			}
+		case "rgpin":
+			// set fixed recommendations for a ReqGroup
+			if cr.ReqGroup == "" {
+				srerr = ErrBadRequest
+			} else {
+				err := s.db.storeReqGroupPin(cr.ReqGroup, cr.Pin)
+				if err != nil {
+					srerr = ErrDBError
+					qerr = err.Error()
+				}
+			}
 		case "reserve":
 			// return the next ready job
 			if cr.ClientID.String() == "00000000-0000-0000-0000-000000000000" {
 				srerr = ErrBadRequest
 			} else if !s.drain {
+				// if the client told us what capacity it has, only give it a
+				// job that fits. We can't lock the jobs while checking their
+				// Requirements, since we're called with the queue locked and
+				// elsewhere jobs get locked before the queue, so we check the
+				// copy of their Requirements they keep for this purpose
+				reserve := func() (*queue.Item, error) {
+					if cr.Capacity != nil {
+						return q.ReserveMatching(func(data interface{}) bool {
+							return cr.Capacity.fits(data.(*Job).fitRequirements())
+						}, cr.SchedulerGroup)
+					}
+					return q.Reserve(cr.SchedulerGroup)
+				}
+
 				// first just try to Reserve normally
 				var item *queue.Item
 				var err error
@@ -137,17 +275,21 @@ func (s *Server) handleRequest(m *mangos.Message) error {
 					}
 
 					if !skip {
-						item, err = q.Reserve(cr.SchedulerGroup)
+						item, err = reserve()
 					}
 				} else {
-					item, err = q.Reserve()
+					item, err = reserve()
 				}
 
+				// (when reserving jobs that fit a capacity, we note if we only
+				// failed to because nothing ready fit)
+				noneFit := false
 				if err != nil {
-					if qerr, ok := err.(queue.Error); ok && qerr.Err == queue.ErrNothingReady {
+					if qerr, ok := err.(queue.Error); ok && (qerr.Err == queue.ErrNothingReady || qerr.Err == queue.ErrNoneMatch) {
 						// there's nothing in the ready sub queue right now, so every
 						// second try and Reserve() from the queue until either we get
 						// an item, or we exceed the client's timeout
+						noneFit = qerr.Err == queue.ErrNoneMatch
 						var stop <-chan time.Time
 						if cr.Timeout.Nanoseconds() > 0 {
 							stop = time.After(cr.Timeout)
@@ -161,9 +303,10 @@ func (s *Server) handleRequest(m *mangos.Message) error {
 							for {
 								select {
 								case <-ticker.C:
-									item, err := q.Reserve(cr.SchedulerGroup)
+									item, err := reserve()
 									if err != nil {
-										if qerr, ok := err.(queue.Error); ok && qerr.Err == queue.ErrNothingReady {
+										if qerr, ok := err.(queue.Error); ok && (qerr.Err == queue.ErrNothingReady || qerr.Err == queue.ErrNoneMatch) {
+											noneFit = qerr.Err == queue.ErrNoneMatch
 											continue
 										}
 										ticker.Stop()
@@ -196,6 +339,8 @@ func (s *Server) handleRequest(m *mangos.Message) error {
 					sjob := item.Data.(*Job)
 					sjob.Lock()
 					sjob.ReservedBy = cr.ClientID //*** we should unset this on moving out of run state, to save space
+					token, _ := uuid.NewV4()
+					sjob.ChildToken = token.String()
 					sjob.Exited = false
 					sjob.Pid = 0
 					sjob.Host = ""
@@ -205,13 +350,17 @@ func (s *Server) handleRequest(m *mangos.Message) error {
 					sjob.PeakRAM = 0
 					sjob.Exitcode = -1
 					sjob.Unlock()
+					s.recordJobHistory(sjob, &JobHistoryEvent{Event: JobHistoryReserved, State: JobStateReserved, ClientID: cr.ClientID.String(), User: cr.User})
 
 					q.SetDelay(item.Key, ClientReleaseDelay)
 
 					// make a copy of the job with some extra stuff filled in (that
 					// we don't want taking up memory here) for the client
 					job := s.itemToJob(item, false, true)
+					job.ChildToken = token.String()
 					sr = &serverResponse{Job: job}
+				} else if srerr == "" && noneFit {
+					sr = &serverResponse{NoneFit: true}
 				}
 			} // else we'll return nothing, as if there were no jobs in the queue
 		case "jstart":
@@ -237,45 +386,49 @@ func (s *Server) handleRequest(m *mangos.Message) error {
 					job.Lost = false
 				}
 				job.Unlock()
+
+				// remember that it's running, so that if we get restarted
+				// the runner can carry on with it; this isn't critical, so
+				// we ignore errors
+				if srerr == "" {
+					s.db.storeRunningJob(job)
+					s.recordJobHistory(job, &JobHistoryEvent{Event: JobHistoryStarted, State: JobStateRunning})
+				}
 			}
 		case "jtouch":
 			var job *Job
 			var item *queue.Item
 			item, job, srerr = s.getij(cr, q)
 			if srerr == "" {
-				// if kill has been called for this job, just return KillCalled
-				job.Lock()
-				killCalled := job.killCalled
-				lost := job.Lost
-				job.Unlock()
-
-				if !killCalled {
-					// also just return killCalled if server has been set to
-					// kill all jobs
-					s.krmutex.RLock()
-					killCalled = s.killRunners
-					s.krmutex.RUnlock()
-				}
-
-				if !killCalled {
-					// else, update the job's ttr
-					err = q.Touch(item.Key)
-					if err != nil {
-						srerr = ErrInternalError
-						qerr = err.Error()
-					} else if lost {
-						job.Lock()
-						job.Lost = false
-						job.EndTime = time.Time{}
-						job.Unlock()
-
-						// since our changed callback won't be called, send out
-						// this transition from lost to running state
-						s.statusCaster.Send(&jstateCount{"+all+", JobStateLost, JobStateRunning, 1})
-						s.statusCaster.Send(&jstateCount{job.RepGroup, JobStateLost, JobStateRunning, 1})
+				var killCalled bool
+				killCalled, srerr, qerr = s.touchJob(q, item, job, cr.Job.ResourceSeriesC)
+				sr = &serverResponse{KillCalled: killCalled}
+			}
+		case "jtouches":
+			// like jtouch, but for many jobs at once; jobs that are no longer
+			// running are skipped, and failures to touch individual jobs are
+			// reported per job, so that kill requests for the others still
+			// get through
+			if cr.Jobs == nil {
+				srerr = ErrBadRequest
+			} else {
+				var killed []string
+				touchErrs := make(map[string]string)
+				for _, cjob := range cr.Jobs {
+					item, job, errs := s.getJobItem(cr.ClientID, cjob, q)
+					if errs != "" {
+						continue
+					}
+					killCalled, errs, _ := s.touchJob(q, item, job, cjob.ResourceSeriesC)
+					if errs != "" {
+						touchErrs[item.Key] = errs
+						continue
+					}
+					if killCalled {
+						killed = append(killed, item.Key)
 					}
 				}
-				sr = &serverResponse{KillCalled: killCalled}
+				sr = &serverResponse{KillCalledFor: killed, TouchErrors: touchErrs}
 			}
 		case "jend":
 			// update the job's cmd-ended-related properties
@@ -286,11 +439,26 @@ func (s *Server) handleRequest(m *mangos.Message) error {
 				job.Exited = true
 				job.Exitcode = cr.Job.Exitcode
 				job.PeakRAM = cr.Job.PeakRAM
+				job.PeakDisk = cr.Job.PeakDisk
 				job.CPUtime = cr.Job.CPUtime
 				job.EndTime = time.Now()
 				job.ActualCwd = cr.Job.ActualCwd
+				job.attemptStdErrC = nil
+				if job.Exitcode != 0 {
+					job.attemptStdErrC = cr.Job.StdErrC
+				}
+				series := job.seriesC
+				if len(cr.Job.ResourceSeriesC) > 0 {
+					series = cr.Job.ResourceSeriesC
+				}
+				job.seriesC = nil
+				job.seriesTouches = 0
 				job.Unlock()
+				s.recordJobHistory(job, &JobHistoryEvent{Event: JobHistoryExited, State: JobStateRunning})
 				s.db.updateJobAfterExit(job, cr.Job.StdOutC, cr.Job.StdErrC, false)
+				if len(series) > 0 {
+					s.db.updateJobResourceSeries(job.key(), series)
+				}
 			}
 		case "jarchive":
 			// remove the job from the queue, rpl and live bucket and add to
@@ -307,7 +475,7 @@ func (s *Server) handleRequest(m *mangos.Message) error {
 				if running := item.Stats().State == queue.ItemStateRun; !running {
 					srerr = ErrBadJob
 					job.Unlock()
-				} else if !job.Exited || job.Exitcode != 0 || job.StartTime.IsZero() || job.EndTime.IsZero() {
+				} else if !job.Exited || !job.RetryPolicy.success(job.Exitcode) || job.StartTime.IsZero() || job.EndTime.IsZero() {
 					// the job must also have gone through jend
 					srerr = ErrBadRequest
 					job.Unlock()
@@ -315,7 +483,11 @@ func (s *Server) handleRequest(m *mangos.Message) error {
 					key := job.key()
 					job.State = JobStateComplete
 					job.FailReason = ""
+					attempt := newJobAttempt(job)
+					job.attemptStdErrC = nil
+					defer s.recordHostSuccess(job.Host)
 					job.Unlock()
+					s.db.storeJobAttempt(key, attempt)
 					err := s.db.archiveJob(key, job)
 					if err != nil {
 						srerr = ErrDBError
@@ -332,6 +504,7 @@ func (s *Server) handleRequest(m *mangos.Message) error {
 							}
 							s.rpl.Unlock()
 							s.decrementGroupCount(job.schedulerGroup, q)
+							s.recordJobHistory(job, &JobHistoryEvent{Event: JobHistoryCompleted, State: JobStateComplete})
 						}
 					}
 				}
@@ -345,14 +518,18 @@ func (s *Server) handleRequest(m *mangos.Message) error {
 			if srerr == "" {
 				job.Lock()
 				job.FailReason = cr.Job.FailReason
-				if !job.StartTime.IsZero() {
+				defer s.recordHostFailure(cr.Host, job.ReqGroup, job.FailReason)
+				if !job.StartTime.IsZero() || job.RetryPolicy.listed(job.FailReason) {
 					// obey jobs's Retries count by adjusting UntilBuried if a
 					// client reserved this job and started to run the job's cmd
+					// (or if its RetryPolicy says this failure counts)
 					job.UntilBuried--
 				}
 				if job.Exited && job.Exitcode != 0 {
 					job.updateRecsAfterFailure()
 				}
+				s.db.storeJobAttempt(job.key(), newJobAttempt(job))
+				job.attemptStdErrC = nil
 				if job.UntilBuried <= 0 {
 					job.Unlock()
 					err = q.Bury(item.Key)
@@ -361,8 +538,18 @@ func (s *Server) handleRequest(m *mangos.Message) error {
 						qerr = err.Error()
 					} else {
 						s.decrementGroupCount(job.getSchedulerGroup(), q)
+						s.recordJobHistory(job, &JobHistoryEvent{Event: JobHistoryBuried, State: JobStateBuried})
 					}
 				} else {
+					if job.RetryPolicy != nil {
+						q.SetDelay(item.Key, job.RetryPolicy.delay(int(job.Retries)+1-int(job.UntilBuried)))
+					}
+					switch job.FailReason {
+					case FailReasonRAM:
+						job.RAMRetries++
+					case FailReasonTime:
+						job.TimeRetries++
+					}
 					job.Unlock()
 					err = q.Release(item.Key)
 					if err != nil {
@@ -370,6 +557,7 @@ func (s *Server) handleRequest(m *mangos.Message) error {
 						qerr = err.Error()
 					} else {
 						s.decrementGroupCount(job.getSchedulerGroup(), q)
+						s.recordJobHistory(job, &JobHistoryEvent{Event: JobHistoryReleased, State: JobStateDelayed})
 					}
 				}
 			}
@@ -381,6 +569,12 @@ func (s *Server) handleRequest(m *mangos.Message) error {
 			if srerr == "" {
 				job.Lock()
 				job.FailReason = cr.Job.FailReason
+				defer s.recordHostFailure(cr.Host, job.ReqGroup, job.FailReason)
+				if len(cr.Job.StdErrC) > 0 {
+					job.attemptStdErrC = cr.Job.StdErrC
+				}
+				s.db.storeJobAttempt(job.key(), newJobAttempt(job))
+				job.attemptStdErrC = nil
 				job.Unlock()
 				err = q.Bury(item.Key)
 				if err != nil {
@@ -388,6 +582,7 @@ func (s *Server) handleRequest(m *mangos.Message) error {
 					qerr = err.Error()
 				} else {
 					s.decrementGroupCount(job.getSchedulerGroup(), q)
+					s.recordJobHistory(job, &JobHistoryEvent{Event: JobHistoryBuried, State: JobStateBuried})
 
 					if len(cr.Job.StdErrC) > 0 {
 						s.db.updateJobAfterExit(job, cr.Job.StdOutC, cr.Job.StdErrC, true)
@@ -413,6 +608,7 @@ func (s *Server) handleRequest(m *mangos.Message) error {
 						job.Lock()
 						job.UntilBuried = job.Retries + 1
 						job.Unlock()
+						s.recordJobHistory(job, &JobHistoryEvent{Event: JobHistoryKicked, State: JobStateReady, User: cr.User})
 						kicked++
 					}
 				}
@@ -441,6 +637,7 @@ func (s *Server) handleRequest(m *mangos.Message) error {
 					err = q.Remove(jobkey)
 					if err == nil {
 						deleted++
+						s.recordJobHistory(item.Data.(*Job), &JobHistoryEvent{Event: JobHistoryDeleted, State: JobStateDeleted, User: cr.User})
 						s.db.deleteLiveJob(jobkey) //*** probably want to batch this up to delete many at once
 					}
 				}
@@ -462,6 +659,9 @@ func (s *Server) handleRequest(m *mangos.Message) error {
 					}
 					if k {
 						killable++
+						if item, errg := q.Get(jobkey); errg == nil {
+							s.recordJobHistory(item.Data.(*Job), &JobHistoryEvent{Event: JobHistoryKilled, State: JobStateRunning, User: cr.User})
+						}
 					}
 				}
 				sr = &serverResponse{Existed: killable}
@@ -488,12 +688,92 @@ func (s *Server) handleRequest(m *mangos.Message) error {
 					sr = &serverResponse{Jobs: jobs}
 				}
 			}
+		case "getbp":
+			// get the children of a job
+			if cr.Parent == "" {
+				srerr = ErrBadRequest
+			} else {
+				var jobs []*Job
+				jobs, srerr, qerr = s.getJobsByParent(q, cr.Parent, cr.GetStd, cr.GetEnv)
+				if len(jobs) > 0 {
+					sr = &serverResponse{Jobs: jobs}
+				}
+			}
+		case "getbf":
+			// get jobs described by a filter
+			if cr.Filter == nil {
+				srerr = ErrBadRequest
+			} else {
+				var jobs []*Job
+				jobs, srerr, qerr = s.getJobsByFilter(q, cr.Filter, cr.Limit, cr.GetStd, cr.GetEnv)
+				if len(jobs) > 0 {
+					sr = &serverResponse{Jobs: jobs}
+				}
+			}
+		case "history":
+			// get the history of a job
+			if len(cr.Keys) != 1 {
+				srerr = ErrBadRequest
+			} else {
+				var history []*JobHistoryEvent
+				history, srerr, qerr = s.getJobHistory(cr.Keys[0])
+				if srerr == "" {
+					sr = &serverResponse{History: history}
+				}
+			}
+		case "events":
+			// get the jobs' state changes that happened after the given
+			// sequence number, after waiting for there to be some
+			if cr.Filter == nil {
+				srerr = ErrBadRequest
+			} else {
+				events, latest, missed := s.getJobEvents(q.Name, cr.Since, cr.Filter, cr.Timeout)
+				sr = &serverResponse{Events: events, EventSeq: latest, EventsMissed: missed}
+			}
+		case "wstatus":
+			// summarise the state of the jobqueue, after waiting for it to
+			// change, sharing the summary with others waiting on the same
+			// change
+			since := s.waitForStatusChange(cr.Timeout, cr.RepGroup)
+			var ws *WatchStatus
+			ws, srerr, qerr = s.getSharedWatchStatus(q, since, cr.RepGroup)
+			if srerr == "" {
+				sr = &serverResponse{WatchStatus: ws}
+			}
 		case "getin":
 			// get all jobs in the jobqueue
 			jobs := s.getJobsCurrent(q, cr.Limit, cr.State, cr.GetStd, cr.GetEnv)
 			if len(jobs) > 0 {
 				sr = &serverResponse{Jobs: jobs}
 			}
+		case "export":
+			// get all jobs in the jobqueue, and optionally the complete ones,
+			// with everything needed to import them elsewhere
+			jobs := s.getJobsCurrent(q, 0, "", true, true)
+			if !cr.IgnoreComplete {
+				complete, err := s.db.retrieveCompleteJobs(true, true)
+				if err != nil {
+					srerr = ErrDBError
+					qerr = err.Error()
+				}
+				jobs = append(jobs, complete...)
+			}
+			if srerr == "" {
+				sr = &serverResponse{Jobs: jobs}
+			}
+		case "import":
+			// add previously exported jobs, keeping their history
+			if cr.Jobs == nil {
+				srerr = ErrBadRequest
+			} else {
+				added, existed, thisSrerr, err := s.importJobs(q, cr.Jobs)
+				if err != nil {
+					srerr = thisSrerr
+					qerr = err.Error()
+				} else {
+					sr = &serverResponse{Added: added, Existed: existed}
+				}
+			}
 		default:
 			srerr = ErrUnknownCommand
 		}
@@ -535,21 +815,84 @@ func (s *Server) getij(cr *clientRequest, q *queue.Queue) (item *queue.Item, job
 		errs = ErrBadRequest
 		return
 	}
+	return s.getJobItem(cr.ClientID, cr.Job, q)
+}
 
-	item, err := q.Get(cr.Job.key())
+// getJobItem is like getij(), but for any one of the Jobs a client sent us.
+func (s *Server) getJobItem(clientID uuid.UUID, cjob *Job, q *queue.Queue) (item *queue.Item, job *Job, errs string) {
+	item, err := q.Get(cjob.key())
 	if err != nil || item.Stats().State != queue.ItemStateRun {
 		errs = ErrBadJob
 		return
 	}
 	job = item.Data.(*Job)
 
-	if !uuid.Equal(cr.ClientID, job.ReservedBy) {
+	if !uuid.Equal(clientID, job.ReservedBy) {
 		errs = ErrMustReserve
 	}
 
 	return
 }
 
+// touchJob updates the ttr of a running job (that must have been checked with
+// getij()) and stores any resource usage measurements the client sent, unless
+// kill has been called for the job, in which case it just returns killCalled
+// true.
+func (s *Server) touchJob(q *queue.Queue, item *queue.Item, job *Job, series []byte) (killCalled bool, srerr string, qerr string) {
+	// if kill has been called for this job, just return KillCalled
+	job.Lock()
+	killCalled = job.killCalled
+	lost := job.Lost
+	job.Unlock()
+
+	if !killCalled {
+		// also just return killCalled if server has been set to kill all jobs
+		s.krmutex.RLock()
+		killCalled = s.killRunners
+		s.krmutex.RUnlock()
+	}
+
+	if killCalled {
+		return
+	}
+
+	// else, update the job's ttr, and remember any resource usage
+	// measurements the client sent, only occasionally storing them
+	if len(series) > 0 {
+		job.Lock()
+		job.seriesC = series
+		job.seriesTouches++
+		store := job.seriesTouches >= ResourceSeriesStoreTouches
+		if store {
+			job.seriesTouches = 0
+		}
+		job.Unlock()
+		if store {
+			s.db.updateJobResourceSeries(job.key(), series)
+		}
+	}
+	err := q.Touch(item.Key)
+	if err != nil {
+		srerr = ErrInternalError
+		qerr = err.Error()
+	} else if lost {
+		job.Lock()
+		job.Lost = false
+		job.EndTime = time.Time{}
+		job.Unlock()
+
+		// since our changed callback won't be called, send out this
+		// transition from lost to running state
+		job.RLock()
+		s.jobEvents.add(newJobEvent(q.Name, JobStateLost, JobStateRunning, job))
+		job.RUnlock()
+		s.recordJobHistory(job, &JobHistoryEvent{Event: JobHistoryFound, State: JobStateRunning})
+		s.statusCaster.Send(&jstateCount{"+all+", JobStateLost, JobStateRunning, 1})
+		s.statusCaster.Send(&jstateCount{job.RepGroup, JobStateLost, JobStateRunning, 1})
+	}
+	return
+}
+
 // for the many get* methods in handleRequest, we do this common stuff to get
 // an item's job from the in-memory queue formulated for the client.
 func (s *Server) itemToJob(item *queue.Item, getStd bool, getEnv bool) (job *Job) {
@@ -573,14 +916,20 @@ func (s *Server) itemToJob(item *queue.Item, getStd bool, getEnv bool) (job *Job
 		ReqGroup:     sjob.ReqGroup,
 		DepGroups:    sjob.DepGroups,
 		Cmd:          sjob.Cmd,
+		Interpreter:  sjob.Interpreter,
+		ScriptKey:    sjob.ScriptKey,
 		Cwd:          sjob.Cwd,
 		CwdMatters:   sjob.CwdMatters,
 		ChangeHome:   sjob.ChangeHome,
 		ActualCwd:    sjob.ActualCwd,
 		Requirements: sjob.Requirements,
+		Override:     sjob.Override,
 		Priority:     sjob.Priority,
 		Retries:      sjob.Retries,
+		RetryPolicy:  sjob.RetryPolicy,
+		Escalation:   sjob.Escalation,
 		PeakRAM:      sjob.PeakRAM,
+		PeakDisk:     sjob.PeakDisk,
 		Exited:       sjob.Exited,
 		Exitcode:     sjob.Exitcode,
 		FailReason:   sjob.FailReason,
@@ -593,6 +942,8 @@ func (s *Server) itemToJob(item *queue.Item, getStd bool, getEnv bool) (job *Job
 		CPUtime:      sjob.CPUtime,
 		State:        state,
 		Attempts:     sjob.Attempts,
+		RAMRetries:   sjob.RAMRetries,
+		TimeRetries:  sjob.TimeRetries,
 		UntilBuried:  sjob.UntilBuried,
 		ReservedBy:   sjob.ReservedBy,
 		EnvKey:       sjob.EnvKey,
@@ -600,26 +951,43 @@ func (s *Server) itemToJob(item *queue.Item, getStd bool, getEnv bool) (job *Job
 		Dependencies: sjob.Dependencies,
 		Behaviours:   sjob.Behaviours,
 		MountConfigs: sjob.MountConfigs,
+		Container:    sjob.Container,
+		Parent:       sjob.Parent,
 	}
 
 	if !sjob.StartTime.IsZero() && state == JobStateReserved {
 		job.State = JobStateRunning
 	}
+	series := sjob.seriesC
 	sjob.RUnlock()
 	s.jobPopulateStdEnv(job, getStd, getEnv)
+	if getStd && len(series) > 0 {
+		// the latest series from Touch() may not have been stored yet
+		job.ResourceSeriesC = series
+	}
 	return
 }
 
-// jobPopulateStdEnv fills in the StdOutC, StdErrC and EnvC values for a Job,
-// extracting them from the database.
+// jobPopulateStdEnv fills in the StdOutC, StdErrC, ResourceSeriesC,
+// AttemptRecords, EnvC and ScriptC values for a Job, extracting them from the
+// database.
 func (s *Server) jobPopulateStdEnv(job *Job, getStd bool, getEnv bool) {
 	job.Lock()
 	defer job.Unlock()
 	if getStd && ((job.Exited && job.Exitcode != 0) || job.State == JobStateBuried) {
 		job.StdOutC, job.StdErrC = s.db.retrieveJobStd(job.key())
 	}
+	if getStd && !job.StartTime.IsZero() {
+		job.ResourceSeriesC = s.db.retrieveJobResourceSeries(job.key())
+	}
+	if getStd && job.Attempts > 0 {
+		job.AttemptRecords, _ = s.db.retrieveJobAttempts(job.key())
+	}
 	if getEnv {
 		job.EnvC = s.db.retrieveEnv(job.EnvKey)
+		if job.ScriptKey != "" {
+			job.ScriptC = s.db.retrieveScript(job.ScriptKey)
+		}
 	}
 }
 
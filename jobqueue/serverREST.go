@@ -41,38 +41,49 @@ import (
 const restJobsEndpoint = "/rest/v1/jobs/"
 const restWarningsEndpoint = "/rest/v1/warnings/"
 const restBadServersEndpoint = "/rest/v1/servers/"
+const restReqGroupsEndpoint = "/rest/v1/reqgroups/"
+const restReportEndpoint = "/rest/v1/report/"
 
 // JobViaJSON describes the properties of a JOB that a user wishes to add to the
 // queue, convenient if they are supplying JSON.
 type JobViaJSON struct {
-	Cmd          string       `json:"cmd"`
-	Cwd          string       `json:"cwd"`
-	CwdMatters   bool         `json:"cwd_matters"`
-	ChangeHome   bool         `json:"change_home"`
-	MountConfigs MountConfigs `json:"mounts"`
-	ReqGrp       string       `json:"req_grp"`
+	Cmd string `json:"cmd"`
+	// Interpreter is the program to run Cmd or Script with, eg. "python".
+	Interpreter string `json:"interpreter"`
+	// Script is the text of a script to run instead of Cmd; if Cmd is not
+	// also specified, it defaults to the first line of the script that isn't
+	// blank or a comment.
+	Script       string           `json:"script"`
+	Cwd          string           `json:"cwd"`
+	CwdMatters   bool             `json:"cwd_matters"`
+	ChangeHome   bool             `json:"change_home"`
+	MountConfigs MountConfigs     `json:"mounts"`
+	Container    *ContainerConfig `json:"container"`
+	ReqGrp       string           `json:"req_grp"`
 	// Memory is a number and unit suffix, eg. 1G for 1 Gigabyte.
 	Memory string `json:"memory"`
 	// Time is a duration with a unit suffix, eg. 1h for 1 hour.
 	Time string `json:"time"`
 	CPUs *int   `json:"cpus"`
 	// Disk is the number of Gigabytes the cmd will use.
-	Disk        *int              `json:"disk"`
-	Override    *int              `json:"override"`
-	Priority    *int              `json:"priority"`
-	Retries     *int              `json:"retries"`
-	RepGrp      string            `json:"rep_grp"`
-	DepGrps     []string          `json:"dep_grps"`
-	Deps        []string          `json:"deps"`
-	CmdDeps     Dependencies      `json:"cmd_deps"`
-	OnFailure   BehavioursViaJSON `json:"on_failure"`
-	OnSuccess   BehavioursViaJSON `json:"on_success"`
-	OnExit      BehavioursViaJSON `json:"on_exit"`
-	Env         []string          `json:"env"`
-	CloudOS     string            `json:"cloud_os"`
-	CloudUser   string            `json:"cloud_username"`
-	CloudScript string            `json:"cloud_script"`
-	CloudOSRam  *int              `json:"cloud_ram"`
+	Disk        *int                `json:"disk"`
+	Override    *int                `json:"override"`
+	Priority    *int                `json:"priority"`
+	Retries     *int                `json:"retries"`
+	RetryPolicy *RetryPolicyViaJSON `json:"retry_policy"`
+	Escalation  *EscalationViaJSON  `json:"escalation"`
+	RepGrp      string              `json:"rep_grp"`
+	DepGrps     []string            `json:"dep_grps"`
+	Deps        []string            `json:"deps"`
+	CmdDeps     Dependencies        `json:"cmd_deps"`
+	OnFailure   BehavioursViaJSON   `json:"on_failure"`
+	OnSuccess   BehavioursViaJSON   `json:"on_success"`
+	OnExit      BehavioursViaJSON   `json:"on_exit"`
+	Env         []string            `json:"env"`
+	CloudOS     string              `json:"cloud_os"`
+	CloudUser   string              `json:"cloud_username"`
+	CloudScript string              `json:"cloud_script"`
+	CloudOSRam  *int                `json:"cloud_ram"`
 }
 
 // JobDefaults is supplied to JobViaJSON.Convert() to provide default values for
@@ -91,18 +102,22 @@ type JobDefaults struct {
 	// Time is the amount of time each cmd will run for. Defaults to 1 hour.
 	Time time.Duration
 	// Disk is the number of Gigabytes cmds will use.
-	Disk      int
-	Override  int
-	Priority  int
-	Retries   int
-	DepGroups []string
-	Deps      Dependencies
+	Disk        int
+	Override    int
+	Priority    int
+	Retries     int
+	RetryPolicy *RetryPolicy
+	Escalation  *Escalation
+	DepGroups   []string
+	Deps        Dependencies
 	// Env is a comma separated list of key=val pairs.
 	Env          string
 	OnFailure    Behaviours
 	OnSuccess    Behaviours
 	OnExit       Behaviours
 	MountConfigs MountConfigs
+	Container    *ContainerConfig
+	Interpreter  string
 	CloudOS      string
 	CloudUser    string
 	// CloudScript is the local path to a script.
@@ -179,6 +194,9 @@ func (jvj *JobViaJSON) Convert(jd *JobDefaults) (job *Job, err error) {
 	var deps Dependencies
 	var behaviours Behaviours
 	var mounts MountConfigs
+	var container *ContainerConfig
+	var retryPolicy *RetryPolicy
+	var escalation *Escalation
 
 	if jvj.RepGrp == "" {
 		repg = jd.RepGrp
@@ -187,11 +205,19 @@ func (jvj *JobViaJSON) Convert(jd *JobDefaults) (job *Job, err error) {
 	}
 
 	cmd = jvj.Cmd
+	if cmd == "" && jvj.Script != "" {
+		cmd = scriptDescription(jvj.Script)
+	}
 	if cmd == "" {
 		err = fmt.Errorf("cmd was not specified")
 		return
 	}
 
+	interpreter := jd.Interpreter
+	if jvj.Interpreter != "" {
+		interpreter = jvj.Interpreter
+	}
+
 	if jvj.Cwd == "" {
 		cwd = jd.DefaultCwd()
 	} else {
@@ -329,6 +355,34 @@ func (jvj *JobViaJSON) Convert(jd *JobDefaults) (job *Job, err error) {
 		mounts = jd.MountConfigs
 	}
 
+	if jvj.Container != nil {
+		container = jvj.Container
+	} else if jd.Container != nil {
+		container = jd.Container
+	}
+	if container != nil && container.Image == "" {
+		err = fmt.Errorf("container was specified without an image")
+		return
+	}
+
+	if jvj.RetryPolicy != nil {
+		retryPolicy, err = jvj.RetryPolicy.RetryPolicy()
+		if err != nil {
+			return
+		}
+	} else if jd.RetryPolicy != nil {
+		retryPolicy = jd.RetryPolicy
+	}
+
+	if jvj.Escalation != nil {
+		escalation, err = jvj.Escalation.Escalation()
+		if err != nil {
+			return
+		}
+	} else if jd.Escalation != nil {
+		escalation = jd.Escalation
+	}
+
 	// scheduler-specific options
 	other := make(map[string]string)
 	if jvj.CloudOS != "" {
@@ -374,15 +428,34 @@ func (jvj *JobViaJSON) Convert(jd *JobDefaults) (job *Job, err error) {
 		Override:     uint8(override),
 		Priority:     uint8(priority),
 		Retries:      uint8(retries),
+		RetryPolicy:  retryPolicy,
+		Escalation:   escalation,
 		DepGroups:    depGroups,
 		Dependencies: deps,
 		EnvOverride:  envOverride,
 		Behaviours:   behaviours,
 		MountConfigs: mounts,
+		Container:    container,
+		Interpreter:  interpreter,
+	}
+	if jvj.Script != "" {
+		job.SetScript(jvj.Script)
 	}
 	return
 }
 
+// scriptDescription returns the first line of the given script that isn't
+// blank or a comment, for use as the Cmd of a Job that runs the script.
+func scriptDescription(script string) string {
+	for _, line := range strings.Split(script, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") {
+			return line
+		}
+	}
+	return ""
+}
+
 // restJobs lets you do CRUD on jobs in the "cmds" queue.
 func restJobs(s *Server, q *queue.Queue) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -407,10 +480,10 @@ func restJobs(s *Server, q *queue.Queue) http.HandlerFunc {
 			return
 		}
 
-		// convert jobs to jstatus
-		jstati := make([]jstatus, len(jobs), len(jobs))
+		// convert jobs to JStatus
+		jstati := make([]JStatus, len(jobs), len(jobs))
 		for i, job := range jobs {
-			jstati[i] = jobToStatus(job)
+			jstati[i] = JobToStatus(job)
 		}
 
 		// return job details as JSON
@@ -511,8 +584,9 @@ func restJobsStatus(r *http.Request, s *Server, q *queue.Queue) (jobs []*Job, st
 // It optionally takes parameters to use as defaults for the job properties,
 // which correspond to the json properties of a JobViaJSON (except for cmd and
 // cmd_deps). For dep_grps, deps and env, which normally take []string, provide
-// a comma-separated list. mounts, on_failure, on_success and on_exit values
-// should be supplied as url query escaped JSON strings.
+// a comma-separated list. mounts, container, retry_policy, escalation,
+// on_failure, on_success and on_exit values should be supplied as url query
+// escaped JSON strings.
 func restJobsAdd(r *http.Request, s *Server, q *queue.Queue) (jobs []*Job, status int, err error) {
 	// handle possible ?query parameters
 	jd := &JobDefaults{
@@ -526,6 +600,7 @@ func restJobsAdd(r *http.Request, s *Server, q *queue.Queue) (jobs []*Job, statu
 		Retries:     urlStringToInt(r.Form.Get("retries")),
 		DepGroups:   urlStringToSlice(r.Form.Get("dep_grps")),
 		Env:         r.Form.Get("env"),
+		Interpreter: r.Form.Get("interpreter"),
 		CloudOS:     r.Form.Get("cloud_os"),
 		CloudUser:   r.Form.Get("cloud_username"),
 		CloudScript: r.Form.Get("cloud_script"),
@@ -603,6 +678,41 @@ func restJobsAdd(r *http.Request, s *Server, q *queue.Queue) (jobs []*Job, statu
 			jd.MountConfigs = mcs
 		}
 	}
+	if r.Form.Get("container") != "" {
+		cc := &ContainerConfig{}
+		err = urlStringToStruct(r.Form.Get("container"), cc)
+		if err != nil {
+			status = http.StatusBadRequest
+			return
+		}
+		jd.Container = cc
+	}
+	if r.Form.Get("retry_policy") != "" {
+		rpvj := &RetryPolicyViaJSON{}
+		err = urlStringToStruct(r.Form.Get("retry_policy"), rpvj)
+		if err != nil {
+			status = http.StatusBadRequest
+			return
+		}
+		jd.RetryPolicy, err = rpvj.RetryPolicy()
+		if err != nil {
+			status = http.StatusBadRequest
+			return
+		}
+	}
+	if r.Form.Get("escalation") != "" {
+		evj := &EscalationViaJSON{}
+		err = urlStringToStruct(r.Form.Get("escalation"), evj)
+		if err != nil {
+			status = http.StatusBadRequest
+			return
+		}
+		jd.Escalation, err = evj.Escalation()
+		if err != nil {
+			status = http.StatusBadRequest
+			return
+		}
+	}
 
 	// decode the posted JSON
 	var jvjs []*JobViaJSON
@@ -660,7 +770,7 @@ func restJobsAdd(r *http.Request, s *Server, q *queue.Queue) (jobs []*Job, statu
 func restWarnings(s *Server) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// carry out a different action based on the HTTP Verb
-		sis := []*schedulerIssue{}
+		sis := []*SchedulerIssue{}
 		switch r.Method {
 		case http.MethodGet:
 			s.simutex.Lock()
@@ -695,7 +805,7 @@ func restBadServers(s *Server) http.HandlerFunc {
 		case http.MethodGet:
 			servers := s.getBadServers()
 			if len(servers) == 0 {
-				servers = []*badServer{}
+				servers = []*BadServer{}
 			}
 			w.Header().Set("Content-Type", "application/json; charset=UTF-8")
 			w.WriteHeader(http.StatusOK)
@@ -729,6 +839,163 @@ func restBadServers(s *Server) http.HandlerFunc {
 	}
 }
 
+// restReqGroups lets you see and adjust what has been learned about the
+// resource usage of the jobs in each ReqGroup. The request url can be suffixed
+// with a ReqGroup name, which is required for anything but GET.
+//
+// GET returns a []*ReqGroupStats as JSON. DELETE with no parameters deletes all
+// the learned samples of the ReqGroup; with a min and/or max parameter it only
+// deletes samples outside of that range (in the units described in
+// ReqGroupStats), optionally only those of the kind given by the stat parameter
+// (memory|time|disk|cores); with pin=true it instead removes any pinned
+// recommendations. Those DELETEs return the number of samples removed as JSON.
+// POST pins recommendations for the ReqGroup, taking memory (eg. 2G), time (eg.
+// 1h), cpus and disk (GB) parameters.
+func restReqGroups(s *Server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+
+		var reqGroup string
+		if len(r.URL.Path) > len(restReqGroupsEndpoint) {
+			reqGroup = r.URL.Path[len(restReqGroupsEndpoint):]
+		}
+		if reqGroup == "" && r.Method != http.MethodGet {
+			http.Error(w, "a ReqGroup is required", http.StatusBadRequest)
+			return
+		}
+
+		// carry out a different action based on the HTTP Verb
+		var result interface{}
+		switch r.Method {
+		case http.MethodGet:
+			stats, err := s.getReqGroupStats(reqGroup)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			if len(stats) == 0 {
+				stats = []*ReqGroupStats{}
+			}
+			result = stats
+		case http.MethodDelete:
+			if r.Form.Get("pin") == "true" {
+				err := s.db.storeReqGroupPin(reqGroup, nil)
+				if err != nil {
+					http.Error(w, err.Error(), http.StatusInternalServerError)
+					return
+				}
+				w.WriteHeader(http.StatusOK)
+				return
+			}
+			min, max := -1, -1
+			if r.Form.Get("min") != "" || r.Form.Get("max") != "" {
+				min = urlStringToInt(r.Form.Get("min"))
+				max = urlStringToInt(r.Form.Get("max"))
+			}
+			removed, srerr, err := s.trimReqGroup(reqGroup, r.Form.Get("stat"), min, max)
+			if err != nil {
+				status := http.StatusInternalServerError
+				if srerr == ErrBadRequest {
+					status = http.StatusBadRequest
+				}
+				http.Error(w, err.Error(), status)
+				return
+			}
+			result = removed
+		case http.MethodPost:
+			pin := &ReqGroupPin{
+				Cores: urlStringToInt(r.Form.Get("cpus")),
+				Disk:  urlStringToInt(r.Form.Get("disk")),
+			}
+			if r.Form.Get("memory") != "" {
+				mb, err := bytefmt.ToMegabytes(r.Form.Get("memory"))
+				if err != nil {
+					http.Error(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+				pin.RAM = int(mb)
+			}
+			if r.Form.Get("time") != "" {
+				var err error
+				pin.Time, err = time.ParseDuration(r.Form.Get("time"))
+				if err != nil {
+					http.Error(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+			}
+			err := s.db.storeReqGroupPin(reqGroup, pin)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			result = pin
+		default:
+			http.Error(w, "Only GET, DELETE and POST are supported", http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		w.WriteHeader(http.StatusOK)
+		encoder := json.NewEncoder(w)
+		encoder.SetEscapeHTML(false)
+		encoder.Encode(result)
+	}
+}
+
+// restReport lets you see how efficiently complete jobs used the resources they
+// requested. The request url can be suffixed with a RepGroup to only consider
+// jobs in that RepGroup. Possible query parameters are by (repgroup|reqgroup,
+// defaulting to repgroup), to choose how jobs are grouped in the report, and
+// format (json|tsv, defaulting to json). The json format is a
+// []*EfficiencyReport.
+func restReport(s *Server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+
+		if r.Method != http.MethodGet {
+			http.Error(w, "Only GET is supported", http.StatusBadRequest)
+			return
+		}
+
+		var repGroup string
+		if len(r.URL.Path) > len(restReportEndpoint) {
+			repGroup = r.URL.Path[len(restReportEndpoint):]
+		}
+		by := r.Form.Get("by")
+		if by == "" {
+			by = ReportByRepGroup
+		}
+
+		reports, srerr, err := s.getEfficiencyReports(by, repGroup)
+		if err != nil {
+			status := http.StatusInternalServerError
+			if srerr == ErrBadRequest {
+				status = http.StatusBadRequest
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+
+		switch r.Form.Get("format") {
+		case "tsv":
+			w.Header().Set("Content-Type", "text/tab-separated-values; charset=UTF-8")
+			w.WriteHeader(http.StatusOK)
+			WriteEfficiencyReportsTSV(w, reports)
+		case "", "json":
+			if len(reports) == 0 {
+				reports = []*EfficiencyReport{}
+			}
+			w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+			w.WriteHeader(http.StatusOK)
+			encoder := json.NewEncoder(w)
+			encoder.SetEscapeHTML(false)
+			encoder.Encode(reports)
+		default:
+			http.Error(w, "format must be json or tsv", http.StatusBadRequest)
+		}
+	}
+}
+
 // urlStringToInt takes a possible string from a url parameter value and
 // converts it to an int. If the value is "", or if the value isn't a number,
 // returns 0.
@@ -41,7 +41,13 @@ type jstatusReq struct {
 	// remove = remove non-running jobs.
 	// kill = kill running jobs or confirm lost jobs are dead.
 	// confirmBadServer = confirm that the server with ID ServerID is bad.
+	// confirmBadHost = confirm that the automatically excluded host named
+	//                  ServerID should stay excluded.
+	// clearBadHost = let runners be scheduled on the excluded host named
+	//                ServerID again.
 	// dismissMsg = dismiss the given Msg.
+	// dismissRunnerIssue = dismiss the runner issue with the given Msg as its
+	//                      Key.
 	Request string
 
 	// sending Key means "give me detailed info about this single job", and
@@ -56,24 +62,29 @@ type jstatusReq struct {
 	State      JobState // A Job.State to limit RepGroup by in details mode
 	Exitcode   int
 	FailReason string
-	ServerID   string // required argument for confirmBadServer
-	Msg        string // required argument for dismissMsg
+	ServerID   string // required argument for confirmBadServer, confirmBadHost and clearBadHost
+	Msg        string // required argument for dismissMsg and dismissRunnerIssue
 }
 
-// jstatus is the job info we send to the status webpage (only real difference
-// to Job is that some of the values are converted to easy-to-display forms).
-type jstatus struct {
+// JStatus is the job info we send to the status webpage, and that `wr status`
+// outputs as JSON (only real difference to Job is that some of the values are
+// converted to easy-to-display forms).
+type JStatus struct {
 	Key          string
 	RepGroup     string
+	ReqGroup     string
 	DepGroups    []string
 	Dependencies []string
 	Cmd          string
+	Interpreter  string
 	State        JobState
 	Cwd          string
 	CwdBase      string
 	HomeChanged  bool
 	Behaviours   string
 	Mounts       string
+	Container    string
+	Parent       string
 	// ExpectedRAM is in Megabytes.
 	ExpectedRAM int
 	// ExpectedTime is in seconds.
@@ -82,6 +93,7 @@ type jstatus struct {
 	RequestedDisk int
 	Cores         int
 	PeakRAM       int
+	PeakDisk      int
 	Exited        bool
 	Exitcode      int
 	FailReason    string
@@ -98,6 +110,28 @@ type jstatus struct {
 	// Env        []string //*** not sending Env until we have https implemented
 	Attempts uint32
 	Similar  int
+	// ResourceSeries, History and AttemptRecords are only sent when the job's
+	// details are requested.
+	ResourceSeries []*ResourceSample
+	History        []*JobHistoryEvent
+	AttemptRecords []JAttemptStatus
+}
+
+// JAttemptStatus is the JStatus form of a JobAttempt.
+type JAttemptStatus struct {
+	Attempt    uint32
+	Host       string
+	HostID     string
+	Started    int64
+	Ended      int64
+	Walltime   float64
+	CPUtime    float64
+	Exited     bool
+	Exitcode   int
+	FailReason string
+	PeakRAM    int
+	PeakDisk   int
+	StdErr     string
 }
 
 // webInterfaceStatic is a http handler for our static documents in static.go
@@ -109,6 +143,8 @@ func webInterfaceStatic(w http.ResponseWriter, r *http.Request) {
 	path := r.URL.Path
 	if path == "/" || path == "/status" {
 		path = "/status.html"
+	} else if path == "/report" {
+		path = "/report.html"
 	}
 
 	// during development, to avoid having to rebuild and restart manager on
@@ -235,6 +271,11 @@ func webInterfaceStatusWS(s *Server) http.HandlerFunc {
 						}
 						s.simutex.RUnlock()
 
+						// and of runner reports
+						for _, ri := range s.getRunnerIssues() {
+							s.runnerCaster.Send(ri)
+						}
+
 						writeMutex.Unlock()
 						if failed {
 							break
@@ -248,8 +289,9 @@ func webInterfaceStatusWS(s *Server) http.HandlerFunc {
 							writeMutex.Lock()
 							failed := false
 							for _, job := range jobs {
-								status := jobToStatus(job)
+								status := JobToStatus(job)
 								status.RepGroup = req.RepGroup // since we want to return the group the user asked for, not the most recent group the job was made for
+								status.History, _, _ = s.getJobHistory(job.key())
 								err = conn.WriteJSON(status)
 								if err != nil {
 									failed = true
@@ -315,19 +357,34 @@ func webInterfaceStatusWS(s *Server) http.HandlerFunc {
 								server.Destroy()
 							}
 						}
+					case "confirmBadHost":
+						if req.ServerID != "" {
+							s.confirmExcludedHost(req.ServerID)
+						}
+					case "clearBadHost":
+						if req.ServerID != "" {
+							s.includeHost(req.ServerID)
+						}
 					case "dismissMsg":
 						if req.Msg != "" {
 							s.simutex.Lock()
 							delete(s.schedIssues, req.Msg)
 							s.simutex.Unlock()
 						}
+					case "dismissRunnerIssue":
+						if req.Msg != "" {
+							s.rimutex.Lock()
+							delete(s.runnerIssues, req.Msg)
+							s.rimutex.Unlock()
+						}
 					default:
 						continue
 					}
 				case req.Key != "":
 					jobs, _, errstr := s.getJobsByKeys(q, []string{req.Key}, true, true)
 					if errstr == "" && len(jobs) == 1 {
-						status := jobToStatus(jobs[0])
+						status := JobToStatus(jobs[0])
+						status.History, _, _ = s.getJobHistory(req.Key)
 						writeMutex.Lock()
 						err = conn.WriteJSON(status)
 						writeMutex.Unlock()
@@ -385,12 +442,47 @@ func webInterfaceStatusWS(s *Server) http.HandlerFunc {
 			}
 			schedIssueReceiver.Close()
 		}(conn)
+
+		go func(conn *websocket.Conn) {
+			defer s.logPanic("jobqueue websocket runner issue updating", true)
+			runnerIssueReceiver := s.runnerCaster.Join()
+			for ri := range runnerIssueReceiver.In {
+				writeMutex.Lock()
+				err := conn.WriteJSON(ri)
+				writeMutex.Unlock()
+				if err != nil {
+					break
+				}
+			}
+			runnerIssueReceiver.Close()
+		}(conn)
 	}
 }
 
-func jobToStatus(job *Job) jstatus {
+// JobToStatus converts a Job to the JStatus form.
+func JobToStatus(job *Job) JStatus {
 	stderr, _ := job.StdErr()
 	stdout, _ := job.StdOut()
+	series, _ := job.ResourceSeries()
+	var attempts []JAttemptStatus
+	for _, a := range job.AttemptRecords {
+		attemptStderr, _ := a.StdErr()
+		attempts = append(attempts, JAttemptStatus{
+			Attempt:    a.Attempt,
+			Host:       a.Host,
+			HostID:     a.HostID,
+			Started:    a.StartTime.Unix(),
+			Ended:      a.EndTime.Unix(),
+			Walltime:   a.EndTime.Sub(a.StartTime).Seconds(),
+			CPUtime:    a.CPUtime.Seconds(),
+			Exited:     a.Exited,
+			Exitcode:   a.Exitcode,
+			FailReason: a.FailReason,
+			PeakRAM:    a.PeakRAM,
+			PeakDisk:   a.PeakDisk,
+			StdErr:     attemptStderr,
+		})
+	}
 	// env, _ := job.Env()
 	var cwdLeaf string
 	job.RLock()
@@ -403,23 +495,28 @@ func jobToStatus(job *Job) jstatus {
 	if state == JobStateRunning && job.Lost {
 		state = JobStateLost
 	}
-	return jstatus{
+	return JStatus{
 		Key:           job.key(),
 		RepGroup:      job.RepGroup,
+		ReqGroup:      job.ReqGroup,
 		DepGroups:     job.DepGroups,
 		Dependencies:  job.Dependencies.Stringify(),
 		Cmd:           job.Cmd,
+		Interpreter:   job.Interpreter,
 		State:         state,
 		CwdBase:       job.Cwd,
 		Cwd:           cwdLeaf,
 		HomeChanged:   job.ChangeHome,
 		Behaviours:    job.Behaviours.String(),
 		Mounts:        job.MountConfigs.String(),
+		Container:     job.Container.String(),
+		Parent:        job.Parent,
 		ExpectedRAM:   job.Requirements.RAM,
 		ExpectedTime:  job.Requirements.Time.Seconds(),
 		RequestedDisk: job.Requirements.Disk,
 		Cores:         job.Requirements.Cores,
 		PeakRAM:       job.PeakRAM,
+		PeakDisk:      job.PeakDisk,
 		Exited:        job.Exited,
 		Exitcode:      job.Exitcode,
 		FailReason:    job.FailReason,
@@ -436,6 +533,8 @@ func jobToStatus(job *Job) jstatus {
 		StdErr:        stderr,
 		StdOut:        stdout,
 		// Env:           env,
+		ResourceSeries: series,
+		AttemptRecords: attempts,
 	}
 }
 
@@ -0,0 +1,413 @@
+// Copyright © 2017 Genome Research Limited
+// Author: Sendu Bala <sb10@sanger.ac.uk>.
+//
+//  This file is part of wr.
+//
+//  wr is free software: you can redistribute it and/or modify
+//  it under the terms of the GNU Lesser General Public License as published by
+//  the Free Software Foundation, either version 3 of the License, or
+//  (at your option) any later version.
+//
+//  wr is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of
+//  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU Lesser General Public License for more details.
+//
+//  You should have received a copy of the GNU Lesser General Public License
+//  along with wr. If not, see <http://www.gnu.org/licenses/>.
+
+package jobqueue
+
+// This file contains the functions to implement hot-standby servers, which
+// replicate every change made to the database of a running server, and take
+// over from it if it stops responding.
+
+import (
+	"fmt"
+	"github.com/boltdb/bolt"
+	"github.com/satori/go.uuid"
+	"io/ioutil"
+	"net"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// replicationMaxTxns is the maximum number of transactions we send to a
+// standby server in one go.
+const replicationMaxTxns = 1000
+
+// heedPrimaryStops is only turned off by tests, to have a standby treat a
+// deliberately stopped server as if it had crashed.
+var heedPrimaryStops = true
+
+// dbOp is a single change made to the database, recorded so that it can be
+// replicated to standby servers.
+type dbOp struct {
+	Bucket []byte
+	Key    []byte
+	Value  []byte
+	Delete bool
+}
+
+// dbTxn is the set of dbOps made in a committed database transaction. Seq is
+// the boltdb transaction id.
+type dbTxn struct {
+	Seq uint64
+	Ops []*dbOp
+}
+
+// pendingTxn holds the dbOps made in a transaction that has not yet been
+// committed.
+type pendingTxn struct {
+	tx  *bolt.Tx
+	ops []*dbOp
+}
+
+// replicationLog holds the most recently committed changes to the database, in
+// the order they were committed, so that standby servers can get them.
+//
+// Because boltdb only calls OnCommit handlers after it has released its write
+// lock, handlers for consecutive transactions can run out of order. We use the
+// transaction ids to put them back in order, and only publish transactions once
+// all transactions before them have been committed. Transactions that get
+// rolled back never call their handler, but we spot this because the next
+// transaction will reuse the same id.
+//
+// When the server is deliberately stopped, it uses the log to tell any standby
+// servers, so that they don't take over.
+type replicationLog struct {
+	id        string
+	inFlight  map[int]*pendingTxn
+	txns      []*dbTxn
+	trimmedTo uint64
+	changed   chan bool
+	standbys  bool
+	stopping  bool
+	announced chan bool
+	sync.Mutex
+}
+
+// newReplicationLog creates a replicationLog with a unique id.
+func newReplicationLog() *replicationLog {
+	u, _ := uuid.NewV4()
+	return &replicationLog{
+		id:        u.String(),
+		inFlight:  make(map[int]*pendingTxn),
+		changed:   make(chan bool),
+		announced: make(chan bool),
+	}
+}
+
+// begin notes that the given writable transaction has started. It returns true
+// the first time it is called for tx, in which case you should arrange for
+// commit() to be called when tx is committed.
+func (l *replicationLog) begin(tx *bolt.Tx, txid int) bool {
+	l.Lock()
+	defer l.Unlock()
+	if p, exists := l.inFlight[txid]; exists && p.tx == tx {
+		return false
+	}
+	// (if a different tx had this id, it must have been rolled back)
+	l.inFlight[txid] = &pendingTxn{tx: tx}
+	return true
+}
+
+// record stores the changes made to tx, to be published when it is committed.
+func (l *replicationLog) record(tx *bolt.Tx, txid int, ops []*dbOp) {
+	l.Lock()
+	defer l.Unlock()
+	if p, exists := l.inFlight[txid]; exists && p.tx == tx {
+		p.ops = append(p.ops, ops...)
+	}
+}
+
+// commit publishes the changes made to tx.
+func (l *replicationLog) commit(tx *bolt.Tx, txid int) {
+	l.Lock()
+	defer l.Unlock()
+	p, exists := l.inFlight[txid]
+	if !exists || p.tx != tx {
+		return
+	}
+	delete(l.inFlight, txid)
+
+	if len(p.ops) > 0 {
+		txn := &dbTxn{Seq: uint64(txid), Ops: p.ops}
+		i := sort.Search(len(l.txns), func(i int) bool {
+			return l.txns[i].Seq > txn.Seq
+		})
+		l.txns = append(l.txns, nil)
+		copy(l.txns[i+1:], l.txns[i:])
+		l.txns[i] = txn
+
+		// we don't want to trim on every commit, so only do so when we've
+		// got twice as many as we need to keep
+		if len(l.txns) > 2*ServerStandbyLogSize {
+			drop := len(l.txns) - ServerStandbyLogSize
+			l.trimmedTo = l.txns[drop-1].Seq
+			l.txns = append([]*dbTxn(nil), l.txns[drop:]...)
+		}
+	}
+
+	close(l.changed)
+	l.changed = make(chan bool)
+}
+
+// since returns the committed transactions that came after the one with the
+// given sequence number, waiting up to the given duration for there to be some.
+// If ok is false, the id doesn't match ours or the transactions you need are
+// no longer held, and you should get a snapshot of the database instead. If
+// stopping is true, the server is being deliberately stopped; once you've told
+// the standby that asked, call shutdownAnnounced().
+func (l *replicationLog) since(id string, seq uint64, wait time.Duration) (txns []*dbTxn, ok bool, stopping bool) {
+	deadline := time.After(wait)
+	for {
+		l.Lock()
+		l.standbys = true
+		if l.stopping {
+			l.Unlock()
+			return nil, true, true
+		}
+		if id != l.id || seq < l.trimmedTo {
+			l.Unlock()
+			return nil, false, false
+		}
+
+		// we can only send transactions that come before any that are still
+		// in flight
+		stable := ^uint64(0)
+		for txid := range l.inFlight {
+			if uint64(txid) <= stable {
+				stable = uint64(txid) - 1
+			}
+		}
+
+		i := sort.Search(len(l.txns), func(i int) bool {
+			return l.txns[i].Seq > seq
+		})
+		for ; i < len(l.txns) && l.txns[i].Seq <= stable && len(txns) < replicationMaxTxns; i++ {
+			txns = append(txns, l.txns[i])
+		}
+		changed := l.changed
+		l.Unlock()
+
+		if len(txns) > 0 {
+			return txns, true, false
+		}
+
+		select {
+		case <-changed:
+			continue
+		case <-deadline:
+			return nil, true, false
+		}
+	}
+}
+
+// announceShutdown makes since() tell standby servers that we're being
+// deliberately stopped, then waits up to the given duration for one of them to
+// have been told. It returns immediately if no standby has ever asked us for
+// changes.
+func (l *replicationLog) announceShutdown(wait time.Duration) {
+	l.Lock()
+	if !l.standbys {
+		l.Unlock()
+		return
+	}
+	l.stopping = true
+	close(l.changed)
+	l.changed = make(chan bool)
+	announced := l.announced
+	l.Unlock()
+
+	select {
+	case <-announced:
+	case <-time.After(wait):
+	}
+}
+
+// shutdownAnnounced notes that a standby server has been told that we're being
+// deliberately stopped.
+func (l *replicationLog) shutdownAnnounced() {
+	l.Lock()
+	defer l.Unlock()
+	select {
+	case <-l.announced:
+	default:
+		close(l.announced)
+	}
+}
+
+// copyBytes returns a copy of the given byte slice, since those from boltdb
+// are only valid during their transaction.
+func copyBytes(b []byte) []byte {
+	if b == nil {
+		return nil
+	}
+	c := make([]byte, len(b))
+	copy(c, b)
+	return c
+}
+
+// Standby makes this process a hot-standby for the server that is already
+// running at addr (host:port), which you'd typically have started with the
+// same config as you supply here. The server must allow the current user.
+//
+// It blocks while it replicates every change the server makes to its database
+// in to a replica file (config.DBFile with ".standby" appended). If the server
+// fails to respond ServerStandbyChecks times in a row over at least
+// ServerStandbyTakeoverTime, and it is no longer listening on addr or holding
+// the lock on config.DBFile (if that exists on our host), the replica becomes
+// config.DBFile and we Serve(config) in the server's place, returning the same
+// things as Serve() would. As normal, Serve() will recover the incomplete jobs
+// from the database, so nothing is lost, and any runners that were running
+// jobs will re-attach to us.
+//
+// So that runners can find us, this process should either be on the same host
+// as the server (in which case config.Port must be the same as the server's),
+// or you should arrange for the server's address to move to our host.
+//
+// If the server is deliberately stopped, it tells us so, and we return an
+// Error with Err ErrPrimaryStopped instead of taking over.
+func Standby(addr string, config ServerConfig) (s *Server, msg string, err error) {
+	c, err := Connect(addr, "cmds", 2*ServerStandbyPollTime)
+	if err != nil {
+		return
+	}
+
+	replica := config.DBFile + ".standby"
+	var rdb *bolt.DB
+	var id string
+	var seq uint64
+	lastContact := time.Now()
+	failures := 0
+	for {
+		sr, errr := c.replicate(id, seq, ServerStandbyPollTime)
+		if jqerr, ok := errr.(Error); heedPrimaryStops && ((errr == nil && sr.ReplStopping) || (ok && jqerr.Err == ErrClosedStop)) {
+			c.Disconnect()
+			if rdb != nil {
+				rdb.Close()
+			}
+			err = Error{"", "Standby", addr, ErrPrimaryStopped}
+			return
+		}
+		if errr != nil {
+			failures++
+			if failures >= ServerStandbyChecks && time.Since(lastContact) >= ServerStandbyTakeoverTime {
+				if primaryGone(addr, config.DBFile) {
+					break
+				}
+
+				// it's still there, just slow to respond
+				failures = 0
+				lastContact = time.Now()
+			}
+			<-time.After(ServerStandbyPollTime / 10)
+			continue
+		}
+		failures = 0
+		lastContact = time.Now()
+
+		if sr.DB != nil {
+			if rdb != nil {
+				rdb.Close()
+			}
+			rdb, err = replaceReplica(replica, sr.DB)
+			if err != nil {
+				c.Disconnect()
+				return
+			}
+			id = sr.ReplID
+			seq = sr.ReplSeq
+		}
+
+		if len(sr.Txns) > 0 && rdb != nil {
+			err = rdb.Update(func(tx *bolt.Tx) error {
+				for _, txn := range sr.Txns {
+					for _, op := range txn.Ops {
+						b, errc := tx.CreateBucketIfNotExists(op.Bucket)
+						if errc != nil {
+							return errc
+						}
+						if op.Delete {
+							errc = b.Delete(op.Key)
+						} else {
+							errc = b.Put(op.Key, op.Value)
+						}
+						if errc != nil {
+							return errc
+						}
+					}
+				}
+				return nil
+			})
+			if err != nil {
+				rdb.Close()
+				c.Disconnect()
+				err = fmt.Errorf("failed to update replica database %s: %s", replica, err)
+				return
+			}
+			seq = sr.Txns[len(sr.Txns)-1].Seq
+		}
+	}
+	c.Disconnect()
+
+	if rdb == nil {
+		err = fmt.Errorf("server at %s stopped responding before its database could be replicated", addr)
+		return
+	}
+	err = rdb.Close()
+	if err != nil {
+		return
+	}
+	err = os.Rename(replica, config.DBFile)
+	if err != nil {
+		return
+	}
+
+	s, msg, err = serve(config, true)
+	takeover := fmt.Sprintf("took over from unresponsive server at %s", addr)
+	if msg != "" {
+		msg = takeover + "; " + msg
+	} else {
+		msg = takeover
+	}
+	return
+}
+
+// primaryGone checks that the server we're a standby for has really gone,
+// and isn't just slow to respond: nothing must be listening on its addr, and
+// nothing must hold the lock on its database file, if that's on our host.
+func primaryGone(addr string, dbFile string) bool {
+	conn, err := net.DialTimeout("tcp", addr, ServerStandbyPollTime)
+	if err == nil {
+		conn.Close()
+		return false
+	}
+
+	if _, err = os.Stat(dbFile); err == nil {
+		bdb, err := bolt.Open(dbFile, dbFilePermission, &bolt.Options{ReadOnly: true, Timeout: ServerStandbyPollTime})
+		if err != nil {
+			return false
+		}
+		bdb.Close()
+	}
+	return true
+}
+
+// replaceReplica writes the given snapshot of a database to path, replacing
+// anything already there, and opens it.
+func replaceReplica(path string, snapshot []byte) (*bolt.DB, error) {
+	tmpPath := path + ".tmp"
+	err := ioutil.WriteFile(tmpPath, snapshot, dbFilePermission)
+	if err != nil {
+		return nil, err
+	}
+	err = os.Rename(tmpPath, path)
+	if err != nil {
+		return nil, err
+	}
+	return bolt.Open(path, dbFilePermission, nil)
+}
@@ -3645,155 +3645,232 @@ EQAA
 `,
 	},
 
+	"/report.html": {
+		local:   "static/report.html",
+		size:    7137,
+		modtime: 1792337395,
+		compressed: `
+H4sIAAAAAAAC/7xZbXPbxhH+7l+xRl2TTARAttNphwaYcWS3STpqXFlO0ul0OgdgSZx4uIPuFmQw
+Gf/3zB34AgEgRdtR9EHCAfvy7HN7D+6g6PHrHy6u//P2DeRUiNmjyP4BweQi9lB6s0cAAFGOLGsu
+3bBAYpDmTBuk2Kto7v/Naz0mTgJnP13BFRpV6RThCkulKQqbJ4/2po99H27+XaGuYa40rJjmqjJQ
+ERec6jNgMgOJmGEGSQ2JUmRIszK4MeD7rZQm1bwkMDqNvfDGhDe3Nqb/PHgefBUUXAY3xptFYWPW
+BfDNNqzDUGo0KIkRV9LlN1QLLhd3EzoKcqLSx9uKr2LvZ//9K/9CFSUjngj0IFWSUFLsffcmxmyB
+XtdbsgJjb8VxbclpOax5Rnmc4Yqn6LvBGXDJiTPhm5QJjJ+1gwkul6BRxJ5FiiZHJA9yjfPYC1Nj
+wh1t/ovgRfBXx0dqjHeEvyGXYxT+U6p0qSpyDOIKJUHOZNbnrZtouXH0XwRfBeen5WnmihQUbImQ
+VERKGjdVlHO5MLBWegnP/TWrIUFaI0rY5nFmu+pOwNaw8Cx4ETy/F907VSCoOahKg1pLWKBEzQTk
+KErUMK9karvqnt5da/88OA+edVKdPt+7APtJjsL9Eo4SldVt6BlfAc9iT7KVB6lgxrjrhGlo/vgZ
+zlklyAOtBLqHfOEWSKuHdqE2EWw7My5Rd2y6dpsUFt+gbcNRyWTHIdFMZt6gzFjrgaRhxlcDtyvR
+irytuHWp+SKnQ8AEn0VsS74hRpXxZu/c3yhksygUfAhJJWaPjmDbDPtTpHGjFcc5dvYZI+YnXGax
+N1caWZpPofFHrdV908IEagL328+YXNj+ZRkCl4eoKNsZCX+hKTyxd2wTlydNR7fsLqZEEanCL5he
+DML4h1ZVCakqSoGEmb0qmMwMJPXRWhOS/sL6ek0JpBYL2+cbaTk49yxB0YoBNs5mqbhrU3htTlJj
+pvArsJT4CqeQ1OMJxDGMNJYu+wg+nEEqeLqc7rRiPIFfreXeaAIfDgCyPxGXZUVAdYmxp1nGlbd5
+0SS1N7Pz//+FLofrCV1BD17s7SnF3v4uxd5+fLGHVIJ9RO2MSNvirSxMYRRqNBSunoXN6gu/nitd
+MIrJrJ4mdTyCLxt6Pniz12othWIZXL/70erHH4Ljxih5EMj37374Vw/J4EK1b8ClAj6fgvXsvvqH
+Fp61s+I1pAU2XLhUvRjtPI83icYTePp0I25mPAkEygXltt/OhzGUs+scNQLTCFINSQapTTxQMugJ
+2EnoenhmA3AiYonALSHNwP32UyUzlAazzdiQ5uVulKvV8KuV7m7W7z7TRxYT5X0BH9Cor93ALqwR
+TN16dtd2Eik/Gn72vUrM/VZRtWUjU0SY3WlpUkoQL21Xu8PEFEaXyCQUWChd2yVfobETGUJh75fI
+ltuHlcFsZJv70o2jsJr9/miuFTHRSwshkHvQxdnCAzif85SjTB8ImiOKeIF9mtZMCPfI4bnmBT4k
+O7tsO1ruotqBeHBKGjwXb9/fhTPeI/wCUqXR7MFNHDrr8seAO4rFHgwhV5U2DSqlsRk+DKIrJM3R
+QMpcUyc16EpKq/SqIlDzbXuH+17auBzHE4VDyhSFB7QsInuEObLBPbhlOy6AWV8A3ZbSaVv2kZ5W
+7E5wnDVnm5771avLq+00B0Xy3ZuL8cSGs9YzCOGw21tky57HJxTwRCtFQYk6RUnjq1eXb3b9PjkD
+t79rTPbr4MI2U8f0MziwErAngdTrSrNmn3gCEz8xIWyAYb/P5sOGPpWQjq33+dkv3r4/Nfld00/K
+bWXlW6sqn9vPTgpOmLtm4u9aH858UD2aDx2dm3b3dMJmrncE33ymaQ4bFmZ4w1asuduRmzAE+1mv
+UBkK90Us46YUrLZCSTluROqOy/YItPmG8SPH9aV1t0eiXmkrpsGgmEMMlHPzsmdgHwatsz7EsFSB
+Sgzqla3/ldasHk+OOpqPc9pu+TtO4zkTBg85JXXPvnXQfflo2GuzCCDeHxzd+h6iyv5opEpLuGSU
+B1pVMmvM4Qt4dn4+gS9h9OdRH+CHfvYwhDVCzhe5sN+EwOE0QDmjZpcn0LihhJyJOag5rO0zyrEe
+CrZ7jw/X2VnJJ9fL59AYuAOHPRi5QQTnwV8O+bR4GjWfewYoaWg55noaj7uGaZd0CFm7u8akq6Fm
+Gur5QGOhVvhKiPEBjyfBAsmeb8e9o/HozH2JmG7b1B6KJweJCzIlcbyrxMrZMZ67C61xeHnQ/ljm
+OeNin/nm9udvrz4m9f0kHaS3rEw+Hv2dcYEZkIIFUkvcpmC/Jjg8gUZTKmnwGn+hTyyTiTWrzfje
+ZhlsmkMStE98etcmdWCqxKp+guNdlmOi2CW2E3mpAlaWov6GSwvWjCWu+y+BM3gyHv2poXY0+e/5
+/1pB7/6LIAqbt14UNv/Q+20APwDBrOEbAAA=
+`,
+	},
+
 	"/status.html": {
 		local:   "static/status.html",
-		size:    63693,
-		modtime: 1509012280,
+		size:    78286,
+		modtime: 1792342831,
 		compressed: `
-H4sIAAAJbogA/+x9f3fcthHg//kU8F6bXcW7Kzlprj1ZUp8tOY0vdq2z0/T69PRa7hLapcUlNyS4a13q
-734z+MGfIAlwuZLShi+xJBIYzAwGg8EAmDl5cvHu/Md/XL4iS7byz744wR/Ed4LF6YAGg7MvCDwnS+q4
-4lf+54oyh8yXThRTdjpI2M3kT4PcZ+Yxn579/T35wByWxCeH4kVaICv5ZDIhH/9PQqM7chNGZONEXpjE
-JGGe77G7MXEClwSUutQlszsyC0MWs8hZTz/GZDLJtRjPI2/NSBzNTweHH+PDjz8jzMnX06+nf5iuvAAq
-DM5ODkWxOkReKvAcl3VEYxoAAV4YcDxidud7waLYMOfEkrH1hP6ceJvTwf+d/O3F5DxcraHizKcDMg8D
-BnBOB69fnVJ3QQfl2oGzoqeDjUe36zBiuQpbz2XLU5duvDmd8D/GxAs85jn+JJ47Pj19lgcGyN2SiPqn
-A8SUxktKAdoyojfAk3kcH6bsm3wz/Wb6R84XeD9o4KOuigkrfwjC+W2YMM5JugFyyBJ4WOVfucFbWRHa
-+8P0yK490XcsJCvnlpJZwlgYxLzr2BIajsk2jG7J15OtA6JE2ZbSgKj2eLGUWgMcBVeeAVe+NsbyQ7ii
-JLwhYRKRcBuQBQ1o5PhkSf01jchNEsxR2lpkextNjoA1z2qabJeDFEDW+SeH2Qg/mYXunfg1A+p6G+K5
-p4PA2YCE+k4c899nTkTEj4lLb5zEh5aiECQTP3oLPnhy8pWCkhBQ1B0PmFAqUy4nm0ActWUFn9ZOUKow
-i6BbB3lNhIU0bR1CYyU0i69Kf1YZE/MGBm2UlcrTKAojqOU6zJnMvAA+wIihznx5THIlWtgDqiACCcZ/
-Jy5obpQl4BQoizperfMtMvqJHZPf4RsUqHUX/hSYUiB05rhAxIbWkZn73jeVucrQ7dQn/F8Y/1EA+qCm
-lrYmF73mOvh84IQ0FuHgURnchsS7OSaXUQjTxIqcnpLBoDDwGyEkCj03ZIy6BdayMPSZtz4mvxA+8R6T
-4esb1IExgf8+JjFwkTC6gunGgYkXRDWgoHg2MONCgTihY1F4RePYWVCy9XyfLELicMUJZVhM/ZvpkHwe
-nK28xZKBNiUuMOjkMGlHnxN/CNSb0Jrn1JP7YdWPSxoBzQ7MHGADiBaTGCcuzhQhq1Pymgm+BCEnHwaq
-i1NPlAQkZACCfAxnMRQLNjRmqAlBUBnMTEHi+D7w8IbchQnxvVvg9oziaCBLjzHRDiX/+gGBe+xfch4T
-3Ib2g5D4IRf+JHYAuf54rhnRhc+VMYHzRMuA+CvYNsdSNVc0Dn7kMxjq5JNZ1Azq9UUtoNcXFmAu68Fc
-moPZbQi/CWEM8ilizmrRuQCZmbIQf4wOUsza+1oIDGF3a5iGxR/ptDRjAYH/lf5cJ74/iXAIF0bF3Pfm
-tzAjRGAPTQHNGy9aXcD4FuptcPaaDWOwMLggi3EvmjFgmcnA33HQqxo0mIcJmNIRdWt5LMua93tNA8T5
-Nfaj1DE9dl+DDqn5tItpISeoGsMi/frrNyvmS+omgCF5jdOz1ax5jiI6OiBn5JnxlHkFggIKKqK4IG0W
-7u+wpF7Crx/vtFRDzNt4Ya4J3htw540jmDM6sFQAu/QgYq6Qq8WMA01xAeMHRktP2ttEb8mxYqS4XC9e
-gVn6VgznwdmF+Ltda92fMuILbemwOSbPjo5+/zwleUtByeI/k3gFFuJ6snKihVa55EGJQsfkiDgJC5/X
-qaLlt5UKz0EduahU4HeYqmGOWq19CuZnYYEMqy7gZVUuvODGx+4AeWWOn42Gw+W37dowR10eMgpxES6X
-5iNTTRmFiwg6f1AkFcY5dP/quBFOHawJOi7yf0xiFnlrHM24EqLFb0qzS9eG+gafCnRy9HApIeUgpdml
-vnN3OcdB/JQMf89NeSslXoREXcE/8yWQXgeUoWbqQL7oby3XosR5kUfQTWsauDRgPXWVhNZ7Z0m4+e6S
-r35lHQY0hZ17C6w8t59BxSH13EscZtZD2D8gmo++f7r3RhL00xdJgGO4794QULP+kC8efX8Uv4nlSec+
-8sO4H9WGgHruIQSZdY+f8488wj7asR9mSdSP4gJAXu/GgACa9YX4+9564X6MdkT2q6++4t7bO8qIhzby
-CmbQEqV5eYjCLRE2Z4sJn24B+ZNP8eTbOtv9JoxWBXlJZisPeiKiPyc0ZrCE+0sUJmtDK9kL1gmbLFpq
-8Fr5dUCu2gSWDaGy3Fm4WKBwSwe5fJvuasECAlfZwml+OniFXjACUD20QrwbD/5iIXH8OCQxpdyjLbaz
-cPvTgQURrEpWTuDGBBoFbbf12BJKOSwHYTo4y/4wWjRzYuTCE6U6XYMhqznyMGILY3Tj+AlFlrfyupFz
-sKQ1GcNyZVz24amNU4G4EAMYf/nGFv7deukBBST9bbIGG30y96K5n/Oim7nyWpjZOAaRl10HYVk3VAel
-VHFxGDHc3VCDIB4dTH0aLEBK6nTdybLksKnXSNUhrt1/1eCA70Zqn37kj6MD0O8RZUkUEH/quYBdhD/+
-TJ6RYzJ5Rj4ftCz0W30GTV5IK2eBYFKbw6BuesjNCEaOBFP/QUpLuw/BzHVQBrir+6AOXqe1KeGeLYcf
-DNJYD07kOROuk1ZecDo4KrxxPp0OQEwabYyqp2FMlDNt7USgTafxMtyCSHPFdSHW+WPiMBYhmGHWXhBu
-hwWAJmYKZ1ZuHHfzV3Aoejns7KpIMTPeem63FnmxX49o6LwbLeIhqzQKSAFsNyHp5inhcOrEZAcnSYrf
-oxMVdJjsW06qfpVGGXmPxRvkIweui2x08c1wGHq56OiWSbF6DBKx7/4veXKae1/4UZr6X4Hr1PudvEEc
-Sk3/d3UEpZg9BgnQOCP2LRUV31GjWOD5lgaZyIB1EYoO3icOQi8ROzieUsQeRCbup98rvqrGfn/JfUUN
-PZ+B69LznfxdHIi+7zu6ulK0HrDf97Z8oIyW+rtpbZCW7rg4gPr9Lg4QYGFxQMsuvUbMHka7J/M5/L7v
-oawOApgP53NZo0EGikC7SIGC0J8YKIiZHKg3DyII3R3e+NTXkzxM/VUuZY7nx+0OeK23RRxiq3eSFI7n
-xDEXhsK5NxAGvGRB8aTmUK7Kh+Tf/y68lUuw4VhVxhVNoSa30LPv68gDVO6KRYTNlhUSKrFQRqjyUvs4
-u2e15LArVFOCYrgp0/E8X1q/zRunObe14uqtyZtWwVA2EW5odOOH28mnY+4nNESSg4lXju+fnXh17sHz
-rfvSiXN+6NpiqYTNQz8EnQIK7i7nJvTwV96YGX1mepgXzemct3j6rX2kFKr3w0kOKuXmiuNRe0hPoNmd
-O7wxSw7tcwZMj2eSW3oHk4juaK4eiA3BLjt7wfB6C4sBSWZT0632gQKFveC6xlJpKr+2lL36tKZzPHX6
-/sXbHqhT4ADadDV7/epcHFB9TIT+6K1oj5QiODyMm0T8cuLe6M1pm/diQ5e6F158a2/k2HBOVGBnaZME
-27Rjn4Dh1unwAjWZifWXl+Zs5C3YsdJULQnkLWXtHEyoPnQFh7N/efoOzLz31InDYM+ClGuzan1ZtZ3h
-wM4uI7rht/yRjiSyHNwpYjYSUahYoOhJHxTJzsC77g9Ak04SMxGxEUcObn/j0lrQX33yUIXtXVtiO7BG
-dLt0m36u8RiC2x/veekSp7BFlGe76UUQYck0UYmdfWDuu4TZc03Ut+BMoWJhECMCnQZuCi5/miHngqm7
-joIOEmh2ip9G/NI8rCgFHkMwJr702XMs8uWCPTe96ldLZRd9UACgblH2wSikLAgDipTdP0l2I0nWsNYF
-3cfBqyh62HEACDyKcQB4PO5xsCuj/rPHQSfkOs26l9S5tV/GitqaSRfBdVzGcph2XOpCMFiceCezJ3ol
-tMLF0kdG8KvA7Y1cDusxE/t3x/eZta9C1NbQq8B19lVwyPsn+/zybz1SLaE9dqK/D+MOVq+W4u/l+YNH
-SCF5fdkjkSK6yv0sh3h7F7gYsggUlFHQ0QoUPLvobAbW8O3Clm8c3GOd9L2+JoRLcST91+rbeKK8G19+
-SUapd22AQSejDUapyu9cDtS5teJbfnbpYP+d9l9nuOwwl+t8pqKjOroX92UbpNj25kjtm8w33oYqUkUE
-lfsn9jdjotzsb8aEHQW/GRNmGP9mTKTPTsZENuvI463ipbXbq6Ol0M0R2skJ+sg8lr9e8blQN1z3LyBp
-U49YRlIc/8tlgp/VnHv0fsQibe1xS0aK5n+jcJgVtD7qFWysD99YcrpDXwNWu3XxPo4B7W24n2/v4ZzF
-95j/4HyJp7X7MtwQpIT4azbgXtKlg4eionvQtVlbj1jTZkj+h+jZzuelb4Al/J4fdaIb71OHGzYfvJXn
-O3bW/9O6M+kSWHbwU0S7V/F8Oq2ws8XLbueYeBSh2AElQ9WJLjKqoSN/RosTcsBTwETZUb4bcZRvf+ve
-bhUqzkUVEsNOc+wnuvh7ugo3lIcVGZyJP8xCEvXME3HP//Fw5JJiHpoHZEgWEOPxMAWs6YcVErWp8gg4
-gqH4RUD+B2GFvedeXmL6EXOifAxnxFmvYYKKeT6IMSYtEelS5mHiuzw/TEJ5SLhc4hmea4bEyXxJeLaV
-gDLM0IWxcKTufY55UjB4HLYA0Jw5E+lTbryAjjGhCs/BEtENxvAX6Vd4LJ2YU4Z3s1YO8+a8znZJAw5M
-ZXUBgDChUneqLlUZZX9IGbC//AyDs3PxB7kwzq5R6N/dBUL5Dq2vyGUMELHx8rRbmm3mDDZUOHgqvpvG
-scJJ3lk1QIpFfJqEH/boPODFvrYbzW3N9RzI0+HR+MgqdB3N9edyfD9e7Jj8Uml+48WYsfFYwnuL5X4S
-78aVwq7n+OHiHC9CDznESbwaVouJjHV4WRoxwJ++M6N+oY3veRnymXyu1sfLklgr4HmUhrlaL+HLj6BK
-fRixw7EEL75fyIvgGnhiMaGH+B3/1gazAPIzX3NXOk1mLMwCcR5iUtEBz9lSQ4IuSmIh8gcOjtEB34WT
-w0evnF5ElGfWihP5y9YJ+NRQsw4Q+OQyYSxpfVyBQs6MNISpDF5K89FPB7UBqFSkUQlmoB8hOdpp+y0Y
-Hjl16bi5dU9N+1jgPL/s4asenG4xxSqdO0lMa5G/KdwqEuj/uR7/RhVQ2L0yILFDO+0fy9J1aiVd9y4q
-xIFWc3m3qjxpJlln3tTy4RYt0vr+ExbTiIlseWiFgZHniHCMKqEdEjpfAdkxC9fQyXSeYIK758S5QZcG
-toDG2tYBoQV+eb6y9WIURXQWCjPkoPbWe7cujrgF0E4cL+f4GLM47UE51Da05PiQ8QWRnpCbmSvBlRhG
-VsDQZIXB04GQaoJXgaGZii3q9JaA1anNVhdythC1xSwpUV8G02rlsRecrsL2LYsSegA/ZDgn0cfTubP2
-mON7/4/y9FRvKAMmiJg3GHx6ODCIk7xnxG/AVLHE/Fkr3lZaV/UgDIgH7UI7TuzOAqNVhQrJzamRCaik
-6QiLMyeY04Z1utaO1Y3iqikbMzdM2CGNov7MWYBpa8v6izGRVi1zbcxa1ZaJTauqynznvPK7hGEI989G
-dmaVfa4MXBUL7Htgnruw550Nw4bplvIdEYcOhkYrARps6pcB7uIndMV05mG28d8bG+n6vvgIaPfBQrre
-gYezbDuvLw4CyD1zMNty64F/gO4O/APQvTFOobk/xr0KNl4UBpg+hPyEAfKgmT54CB+NedhoEOpaqbMF
-dZkQZGr4GltZu3hJs8nXJ9PsOE2W0zwUv8rdUo+jj7/q6BQ2+JfzcH33nHx99Ox/jvHfP5K/0AAXHe9p
-TJ1oviRvvBWuS6daqx0zYWAD2dsSQdn7at98dDaOeFvC7zachmu0keIpGCE0+tsaGAmK+JSbus/rKT88
-BJGnWxBg6vPtS7BaMEOI8oYnxa1ZlcOCu3yT+Ceo+hargkGoGUtORDDlO2Kx9OLqrWeeD975OfEiaE4m
-ejnltMzwOgsOiBdR5NyNDmrqijpg9gDiVhVnjisysls2qJID29VSS/JyrdoKMiajCqgJ9YbD5qLSBd9a
-7t2Lmu9bkFQMciUkJzIrhXwI6Ja0kA9F+ZiA0t98e1QtVcc1XG6/VHmnoXIqfSPP1QlcWjXrXgklS74i
-3tfVxkfmZZEZ+19f4FLHc/V39j9raP5sRZ/MT1ugbhUvGslTUlglDjM+82TPJgSmhadv4wVSCe32T6bK
-EAYU6lFKo34el0bH0cEUlB6YZ6NfSCpDx2WZ+nxQnS4lWBU2tGfAItZo30BlNKmewfLYpT3DlEFSe+8u
-kTJmb2KwB9gqS8UehGEPUGX8/D2Iwz54EPruP3nqJgB81CQz/8TouwkYwFCuqqVq5iSlla6Goo1rMTdL
-UG6mUusUqXdDRiVIRWyujeaYAoCM5OsaPax9q32JxheHBYTp8IQBfM09hJWPSmtqPwvdp/8kNZj2I9dD
-2i9Sm1zrzAfFaEHIGTlq4ilSvEow7aDvcXPh2dERORRMqI+4AwbwlsJc6Pj8oMn/+hM/brIJPZc4ZJYs
-iBfA6itkMYucdRqAvQncDBdf26UHKwF5zCQGrBAOblPwIw2TFV7Tg4JNcG7QD0ojvjWQMNxNoJ+8GAbU
-nI4J3fBTKWGyWCL+AR5laQImOIgRiJEtjTzkvHCBf2sazUEQPuDf0ehqlGPuVw0ydTAmLUVzEtZWOJW3
-1oKZ9LUVVbLYVi6TzIPrMUhGjYAqvoGljsFmMsa95y+ikWAoLBYbAOjYiUr1eiTBXh1d21TPzXkZiGcW
-INKpLav+tU11MYNllb+xqKwmqqz2Hyxqq/koq/1tXW29PsWn3vjWqmtc4tbrGanta0p8NpwnzddN6lre
-KbnSTCSFQm/C8JYvMH+pmykr+Szt1r7eIsA9XX0DlRegqWLKCGCEunJLZ3EIOrCaUwcnha0XuOF2+nc6
-+8ALwQrmlGCH4ym/5vVhzm8wXSfxcjT4R5hEZBaFW3hL3BBW+JjjN07WayCfpG3EA91KiFA/pk3tbdVC
-OQU0Gmzj48PDAcyHfjjn4QmmSxB7dPDBu8Fx4QtHAt4eCsT/udXikWtuGgYhqIDcqrLWkMnXilEK//eH
-d3+dYuqoYOHd3IFQytDNx2QwT6KIHwb+fFA3otrQmsPgLq52WxGr9tZ5GARUVIfZGkVl5QQOHplcOngI
-AChHHfKkjkv4HB5iJmeYO8VZ03UIUzUeamHRHT8SSidAM8i3F4ujF/O0zel0aqFNMtJXmqV+40L9I94p
-OCW8Q9ZgVdARnaKD9KC2Bo4LrDUFPrzbBpcRSEHE7kbD76JwxX1Ew4OmFgVj5DnYIFnN0MfDjy3MxW2p
-xprRArDF5q+GSlsMrxtr8HlTerkaCyJhEXdSDJ46vv900EYFxyfznxVUd3P8RTmcUwO/qCrLnI0WrQzV
-oZIq6StNG1fR4rpmBVBC0qrh9sL4DD1c2kcL/ZKrUno/zhtNM/tx5lQa2odzp9rIXpw9lWb24PyptLEX
-Z1ClFZmRa9/NpHl89k9Ona/LdjzsBKXBf2UuyTvVr/dJmcvfrpyUici6g8hlM9sFD75BUwYgrWtDIAZO
-M03DbU608lNj5OUfNe109q+VH24ApEAtXG1VzPhiLIPV6nUrP/WrxvxjVCjvpStRlzro8u+LvrnsS94t
-l3tb8Mhl73POuOxl5u0otSk0b/l9qiprHXflx9iRp2OSvWOv/Bg4+mxgVX2CZcefDbROPkINnFafoQ2w
-knvR1IdYfsx9itoRUPHS1YyHhnL1TkTtWGkoVes61I2jRszTUdVQKj/GWl2QOrbv5JIsP1aio4YWvzgp
-2salLw4ROzggcvy0vxI74jBYpt/Bet0LmOWYxYiQY+KGeJqbuHQeUTx8hdATcV7Gaqjh+eLn0k0UUXHl
-1IvVRYsl9ddW8AS/YjxJ5AWwAIchG+MAzoa02XwuweFVWjBJV6hK6hwWugfF5pbecedhZqeOSxbnOGc7
-jlMrcJzZc+PMMhvnbaxx0VoynHHxwcNJI8TOA9SOnsOPE/In+PH0qc1cgk/BlEBar7zra34pQTmMPSuL
-Qj1VmDl4djkfzEwMu5L7Z+DJfy4De7T5tJZn8wZCFUTThkL56b7BYE1f0bclvLWKXgP4Nb6witNs6tNg
-wZZkQp71gDRqS3mFEfQtbgT4vOlxepeO4CYICSO3YZM1B22VgOWGE4Nwmoq4BmBGiTuleMdLnrI0yDGO
-3tgQA1+P4ScCcXz4iYzlk2wAk0WqmU2AlVaWZl1S2QOy6tnmsdPqH76JwtUYiG0sGG89Nl+OhPM5c3Yb
-qaG5Az2fOTKNRiAipV+zmY3gGUyft+1FFWqp87MrcqmhvAf0pMu0G2rSNt8HWsrJ2hExtSDYA2rCMdsN
-L7EE2QNSypPbDS217OkNsR20RnY2i28+l7dsyjtUBxhCLFf+qlzgWg/hxzBVMm0Arko1rsmZ2ik7xwuV
-ZooK1LfcTucrjSELh4RFThB76Eobp7MYfA0W7YsPXHQALOko4LMb3wHlkwwfl8SZ8/uesIQE69EIv/ZJ
-yJJRkxKj2gWs1P0mjZyemrukxGLGkgxzF9m72Uc6Z1M0gZupOFBWkA3ypgT05QltLmG8i1mY3nPjzozo
-LhM8PmBg7TDF42OogPNI2k31WjQtJ/tOiNpM+hokrab9bghaTf86FO0MgE5IWhgCGgxtTIFO6FmZBBoE
-7YyCTihmW7bGbcizJE+szpLknxKVmZvWnE5z/0QHFSL3yh+MIal3+wH50TLxdDcuazchuQuH/Jk8I8dE
-c8kt/yDD0YI24TMugQO6lQY3/hgdkEkXm0hBObOwF3h7sqKBA8d4QseHuzZWFL3ycc6OjUGOA7BMI2+j
-jFNTcNyGfQ4G7ND3CcigsJPDgJIFHhOMcD9rjDauKcCVE91ir6ZmN4aBpHjdPY+xKTQeSpJH2kKKvYDg
-jWqz/T3svyfEZlFjM4YbTcGas7u6x2wUt9rnetryXp3eiLuqwL4mT61XHJx2G9HvhFc3tPpzXHNdcGSi
-BXbQvU3q1UCrstBENFgIBfmBhuIavKtHIndkVHv61vDkrf352XQopde70RUhDsrqbpIbehlQz+GJa36c
-msdjoi7GJ3MKhx1MfQJQy4mYN0/83Gnf58RxXa5aGQZB41gazWKCP3JQFAIymyoFQIkfaVZJApEyFQGY
-R5HDAMBmSUvQ4xHIfWSjCjibb1W7qrMVIqZjGoHM6MIJ5K0CkRDTvG4QbiuhCDI4hoAE6vlki7sf8sJH
-7m2lTHpKRiNAmBs9nOgDcogHAUwUET4m+1r4aOMbiH0OaL7+yH3+yc3SJUjWE1apPnBW3neJKXsdMOw2
-vxuD8UEpcHD/5410IdWQLzxMdluvun3mXFuddpzx0WJ45V3bi656DK0aXnRsJXM7TpH4FNW6EEQcc3ub
-pF5fGt0N8dgwJtTjUSwdrn5mjiujZYzBAsc9SX7oDNRpG6yspgiQ6cVcN+EFrtZpT+if1/FLxzXzBJYj
-gxhz1NhJqYlaotC8ABz31G9v40XHjuMRQBIf/pZXk3j/yY3qNnAwdYtTU3x9BUuuKNs2yIIINUJBJSFg
-4BE1HtGxtXwuAk8hFkrb/KfTSmkcFanmyNOnnumSPEY4CgBoIcNtCU/FWhFygX1n7MaGym+cmHFVJ2df
-+WebcOUgcFN3VDR7jepmHYUhpcx3+R7OUyNmY4m3cb+mUXHMb0xhLx7ne9Tw5D0PUcr7T9XO3pjCSEWg
-fPGgIiGGAIVQ6KEpgWkHZTi/pSOQK+Nc+KLOk5z2S/WtQfQiXLnMmUolwpdDkbgJi1lH1NGgSjU5C/CC
-77PAX6mpBopo9crnK4Y6mZyHQRz6dOqHi9FAgsLFCbRJxGW+gYqjodAA20cLyvBm71CErhuOiUL5uAyf
-3/nVDzjgFN6lxRNPdxQ4hu5wpA+0hTzRLq/njtOr1Uvd3GB4Q7zcK3yBGsv42TD33NxQvKXMA+fxI7S1
-UTtEtA4+N7T1KGaSU6voC7ERmO9VVbn52jnA4KX46jWtM872JnW3y6uvNAjJLb9eUVLbiB2Res9Ngf4Q
-EluGXZGR6/s+0eGGJPaZ8O3ivQ8vmPuJC1KX7h52wvYNXv3oD1W+T9iRcS/5Fl6PyMg9wY7onMu9th4R
-SrfvLFHKoOmQGYtL9K3ho9IVXpOBkpa29Cp0itmYf6TPgWeRTL0OWkya4dS4NHTRKtVTP8cX+Ta6sovu
-UtsdquemnlvnPuXnvFSyq0r4zabe4EEVwjVBwWlaFqVISMD11JU50RIsVFelKWiorvy7Fy2Fhe9u596o
-ISvXQfomdLTx7movzkkrM7+hmoFJpS705m2qHAljkTbtWAqUNqJKNytV2kOwQMfQvrnkADW6rBjov+IZ
-FpkW6hRhMXK/aYDbLGa/cQ0YOB9YYSJC+26MHvuWWD95DHmlptg5KWYjAHyFpesGoI55I55ZZE8deaFO
-3+s5VMw5YNeNMvS/XfBlkdk+DQqV9ktbj4jGsNg0rd/E4yJh+2Zxmg6ghlPFlASWbJbpAbrwOcuuYMNq
-0aDidQqjkd1FCvfK7yx5gJ72UvoCO26rZALW3M6lVbfgtWxudIXMzkA0qo8SfXvlNQ02epJLqQ7smKyy
-DVgz+VWwseGubIdLMlRtYmqJnr0wFX3eoXgt80iKtD6x9HjoQEmDAOsVN9L1TKrmk7TrmWquSNO5tpi7
-sc4NKkqVHYU1vkHBHcPCt/TOsGSUWlZGxWNhcRmVFckFLQpjfkTD4llGRMMK/BZGpazxchUGzY/hi1Kv
-5ofeWPbmWHZU41AsiIf8ayR+NA3LYjWZsUs2Z1wNRIOrgB/onXml1ImJNZUxbl6dSw2vK5Z5xhWVVAil
-xeVph8qYn9O8eiZiHMB36Z/mIESqN063t/Lw8M5T8szCSZLP3ZaXN8f36+SLh0LhC6icaq1dsTcASpGo
-W6g1uivSRVy9vLfsgZTc6DXy2AJELRDrRLKluhIaLYRUvFqAfJdTVc1i1gCoPlxo2976Q/bhDzgN1eig
-TsSae0BESCAqRkHi9eBC3M1pZulosnEyGTuYaoyiWiOoXi1ladRtLNDqJCpmzmGEkIbpL+0KNu+PGAo8
-pJv9XKaFNQXSZuK2sUClt++JDwhumP1mzQmsxTXOA7EClsiPiRPZtt5DMOMS2n5M3LiUeZIfRjAwP/Uj
-Yobagr5fZvyA+Sz64ALm8B6qn5Yc4Eio/dz7pf8CUOiVfgnXlgXnolpKPb/Hj8jtjw1GvhGBlsx27qij
-nV7M0863craS560lWZvpRoxsIj2TCYwXv7y+OCZZmrdau017rjOtZ7QKN+GezBdN8XSRPBdV40UWBauZ
-40Zx7fFF400rCTteAJfg32MijyyacEdiJE85tjKmaGryw3ebldydruSXLFa9DafOeu3fvfT4nBCPoOaY
-/G40/B8iWcDwoJgvpZgh9OQQ86mefXHCk52effH/AwAA//9BfgWuzfgAAA==
+H4sIAAAAAAAC/+y9e3cbN84w/r8/Baq9SEp0cXrZ3cey3JPY6Ta/Jk/zc7rd9zk5PvtQM5DEeDTU
+khwperv57u8hOVdpLpzRyHF32z8aSyJBAARBEASByy9ufrz+6X/evoSlXHlXZ5fqH/CIv5h20O9c
+nQEAXC6RuOZP/XGFkoCzJFygnHYCOR/+pZP6WVLp4dXfb+GdJDIQl2PzRdwgafnFcAgf/v8A+Q7m
+jMOGcMoCAYGkHpW7ARDfBR/RRRdmO5gxJoXkZD36IGA4TI0oHE7XEgR3pp3xBzH+8E8Fc/jl6MvR
+16MV9UcfROfqcmyaFSHyIgKvcVlzFOhLIinzNR5C7jzqL7IDa04spVwP8Z8B3Uw7/2f4t+fDa7Za
+E0lnHnbAYb5EX047r15O0V1gZ7+3T1Y47WwobteMy1SHLXXlcurihjo41B8GQH0qKfGGwiEeTp+l
+gXnUvweO3rSjMEWxRJQdWHKcTztjR4hxzL7hV6OvRn/WfHGE6JTwMa+LDSt/8JlzzwKpOYkb9CUs
+ie8e8m9/wPuw4/Cr0dej83rjaURBMliRe4RZICXzhZ46uaT+QsCW8Xv4crglO5ih3CL6EI2nm8XU
+WuBouPJs9NXoS2ss37EVApsDCziwrQ8L9JETD5borZHDPPAdJW0Vsr3lw/PR+ehZwZDVchADSCb/
+cpys8MsZc3fmzwSoSzdA3WnHJ5sOOB4RQv89IxzMP0MX5yTwZAc481D/SBd68XQS3GJQIQQl6oT6
+yPfa7LcLh1A45rY1fFoTf6/DjBPf7aQ1kWqUM9bYpZucrwMvBTAiNPUnp4ulLMLHo1eXJOI7R724
+r25RsIA7COaLyzG5uhx7NA+lwLs6K0Fy7+PhVAlNcqeK13vtkXPGRQdcIslwRn132pkzjsRZXkCq
+RcWEEQ+5BP3/oUv8hZJu4iJQv4hb6/SIEj/KC/i9+kaJ+NpqxnK+yid0RlyBfINFZKZ+b5tKpQXu
+GdD5Bbz86HiBi+73TMjMgi8abU189ED/f7gl3Kf+omCY3J569ZT3AQBQ6JQ2MAsjAu0yKdHN8FEy
+5km6voBfQO/7F9C9DXwfuYAt9TzwGXhMM2uGIJwluoGHLjBfqWkBSybkCF75GxRSaRAEKmFF/IB4
+3g7oHHYsAI/e4wBmqOYMXHSoogy2S5RL5CAZ3COuVUcMuQxMf+14SDhQsy8FAoEsCPVHXfjUuVoS
+ATO1J0R9LsdBCX/zVUYx+5VWreD9f5MVXoSK7GA1qB87V6EGu5zxclC3SATzC4G95Wzm4coeXiSt
+QGQh0BsicSSZ+qfXj0GXgr00uzTI3RqnHfMh1lgz6cNM+tHSWgeeF2rc9NiOR537C/g9Z0yOHObP
+KV+9IHpZda5+UGKQzKcZoAWUROA4KIQdTkrmYoyu1adqTErEq+wnpV/G96xYn8QKyGfy0eqgd1r7
+VmuhlDYNBRqmU+h0Ckk5Vo+9mhsdRQV8CIQEAhJXa8YJ3ymz3UdH0g2VO6BCBDgwjVcoBFmgUX4L
+BkTbnzugUqA3N7pnpUQIZgguknK9Yz3VRZz64mFY9dMSOcKWCCCwDkcMhNLSmilmgx3BKxltCpr8
+QKALkgEPfGBal39gM1FvO1hSKc04CP/7gwJO5f+GxwHDbSpSm1AgyMzD9nj+yDeGVzeFgF7d1ADz
+thjMW3swxy3h10ptabvWaXVXOuHOdIPENeqtc/VKdgVw1IJs1r3V/mS78I9c9FEP9B0W+BI5uu2Z
+EwUDtGxdPNA8hjqmxek7we5/zIEp3MEKjkvxr20flh7c7nildu1am+m1ktxeH67gmfVO+n5OuZCh
+A6Bc5r9TLfMF/+7x7lYFxLwRizrnl2ruvCaGOb1+3VPHETOoMI+QK8RMA41xAUlXKFpS6jbqLFwh
+VvrMpWJFhXhjFnHn6sZ8PsUJ5RgVxLUHQZvVBWoo08JGFUXaJ8MaIS7gpfZXT6fQxY9UduFb6Ooe
+Q+rPWRcuoJvWYt1Ho76Ml6VIJjVViUwyv6ihOada76+/aUN7ZfNGLOALawusDT3ayFp8Fzrl+F85
+C9a9fg2c466wUH0L5zI7xAno+W0DedgNxCgfbUE9yk1E34WFd6oX8Oz8/A+TmOwteh6o/w3FCiRb
+D1eEL3Lt0jQo0+gCzoEEkk2KtoHlNwcdJrAmrlLoF3DeuXrlO2y19lBi9g5rRtRt8aFsUH/uqSkZ
+SSaJlyyd8fKb6p0oRV0aMp3vw9USfW67S3G24ChEJ0vqcMakZKuLUjhFsIbqbjH9YSgkp2u1opWX
+DbO/RbtqePsY/TYjPEOnRk+5qUI5iGl20SO7t45ayE+h+wftJqq192UhoWv4Z+9ey9cD+1Dj2Ybw
+i/b8hBV732OZpjX6LvqypakKobU+WSHc9HSFX/3KJkyZvI1niyNx21lUGlLLs6RhJjOk5of6i0c/
+P81nI/DbmYvAV2u47dkwUJP5CL/4la0XcyZsPEceE+2oNgWo5Rny0udC8FK+90c4R0fOwyzg7Siu
+WcBp68aAAZrMhfn8YLPwMEa7QvbJkyf6ZnCHEqiykVfoyz1K0/LA2RaMzVlhwscxUd7woxh+U2S7
+zxlfZeQlmK2ovACO/wxQyFtch8dXKyuZ+utADhcVPWA/li3VbUhcl0WWu2SLhYfx5Wv4bRzmNe1o
+54S5kJ12XqobFiA+UGWF0Dk1cTLEEwwEor4tNfFdwOZAPA8ctloR3xVAXBdd2FK5BLkkMgVh1LlK
+PlgdnDUx4eFTSXV8BlOs1shz5mXW6IZ4ASqWV/K6lHMz6Xfsb4v274ei2EaDuBGDzlVmsIW3Wy+p
+w3yI/xquPbIbOpQ7XuqG1u6aqIKZpWtQ8bLpItzXDWdFKk4wLtXNebQIRK8/8tBfyGWhrrtc8qtD
+eLka6XCJ5wYk5uCgvutFobQ9b8D78AtwlAH3wRtRF66Aq3++hWdwAcNn8KlfcdCv9BmUeYBrOQvA
+ymFQtD2kdgQrR4Kt/wDsfQh2rgNo2X0AbZ5NQXu3iI7dz7EeCKdkqHXSivrTznnmG/Jx2nl2fl5q
+Yxx6GgYQOdTWhKMvR2LJtre41orrxpzzB0Ck5ApMNxnPZ9tuBqCNmbK/jpv5K0rMlMauCqgd1lRt
+Lf7KRCPPu1EhHmGXUgHJgG0mJM08JaVicoST5PGKinKYnFpODv0qpTJyq5qXyEcKXBPZaOKbKZGL
+hm6ZRyURp57/wK8x+8aPUjb/gX/E7DfyBpXNf1NH0OPVCcYZcWqpOPAdlYqFip0skYkEWBOhaOB9
+KpGIIxxPn1cmHmbeD3xVpfP+QvuKSmY+Addk5hv5u0rmvqGr6zHM+8mODyhxb77LzgZx64aHA5Qt
+Hw5Qpic0/OKxa/fwvc+Jl3IUCGC/nK/DHiUykAXaRAoiCO2JQQQxkYPom88iCM0d3rDvq8rjYeyv
+clES6olqB3yut8UEEBY7SQ4CGX+BbibmsHuh30Gjjm0MT+Vd+Ne/Mt+GR7DuIOqsox7TPbWFnvy+
+5nRF+C7bxNhsSaMwVjLdxqjyvfHV7p70CpddplskKJaXMg1jKa29cTmxWyu3U+FNO8AwHIJtkM89
+th1+vNB+wk6dhbYinnd1SYvcg9db9wURKT90YbNYwhzmMX4BC47JgexyTNWfejA7+uz08L7OeaMi
+4EQ9XdMOJ7PcXGk8CgP1DJrNudOEQ6fcAeOoVrjH3YZ4wnaduHUIduXVc6meTkpxOXZlnZ7u4RxE
+oNQsuK61VHonouzlxzU6El24ff6mBeoicLfP34xWs1cvr3v9x0boT3SFLVKqwKmA3IDr/CEnozel
+bW7NhS66N1Tc1zdy6nAu4l48JKgx67EvZGGRDs9QE+sm+OsLezY2YKWtWmoka9eMYxu6QsM5vTx9
+R6hnUjCcWJDSUfsH1letsdPcfstxoxNxKToCjg2ks65EFFP0RRsUhZOh0n58BpryJDERkTrieOJ1
+WVvQX36kEt3Ta0s1DjjMxUaKMm+voVKBOx3v8zilRlTyfN5AhLxmgv9Ouj8Gsj7XQs7V73S4iBUC
+jRZuDC4dzZBywRQ9SVEOknfSHamfejqL1AC6Bo9uv3P1R09OVJM/LuTE9plbq/ogj01ftMEoRZnP
+fFSUPTxJ9VZS/dV07Dp4yfnnXQcvOX8U6+Al5497HRzLqH/vddAIuUa77lsk9/WPsVC06SpwDY+x
+cNTeqwbWJ7uH4Zjb9ECXzzKF+QPy7EEk650k6gFsS1wKoWVe8Z6QS40MWN9tjVwN6zET+3fiebK2
+U6iQ3ghcY6fQA5F9/fZvLVIdQnvsRKt0ES1RHGWeeIQUwqu3LRL56u2JyUztfXq8mxopHI7iV5Zn
+N43t7QK+3dTlGzxm64q2tSG8pW5dxjwmJ9IXkRvpj3+EXuzG7KgE/HyDbidzRdyJAgSz3+ogsf5v
+hkvrBB+xl+c5p81ENfTjnso2gNY91m2T+ZpuMCK11/88xP5mTAD8Zkz8Zkz8Zkw0Z8rpjYmowsY7
+5BSFMimy3ySPVJ/Vm5X9YL7Qx6qBfkfRc8WDrYjDSfPIDPVbzof1b4vNwsSuTjvPvjnvwBJVgoJp
+5+vz+KGsWBN+71EfO0c4vtfMU8WXEObU86Yd5dvtgJCc3eO087uvvvozmf05+mIYITT6JuMajwJk
+14zqiK5w/iL0elF4bVZcBjBXc6sDZy/HER4N2TUWm0XDrjNeFO3RXZGP0IWnSjJXRPbScvmGfKyg
+q0Yq5ZxV/7iU5+mVy/dUSMZ3SquEf1a9eW9VyYdjNgsWqj+/Da6bQgyPv1z69W5AYTjiLTqMu3oD
+yn7TeANqIjHNwiwfVGSS8M3/XJlJe0CSo3L4+Ml8WftStKF7o9k1+YNJywnvs3+94nMT5T85vYDE
+Qz1iGYlx/A+XCf2Sx6H4MGIRj/a4JSNG8z9ROE70EMDf1A7NrsnpBnP90t8cN8WnCBI/2XK/3j5A
+FO73bIVwvVRv+dzWPJgrDCH+mr1OL3BJVMg8fwBdm4z1iDVtguS/iZ5t/JpuzpjUWSCQ8Dn92OD9
+9Tu6oh6pZ/0/Lay8YIClKoHoOntRtsfGse3m8HJclLvOMSnICgGjeH/oFdUzSUXwa0L6QHwXePLQ
+Y24eejwif1P+jWiUMK2e5jhNXbNbXLEN6qRznSvzwS5hZcs8MVmgHg9H3qIqJP4ZGZKkS3tMYrL+
+vEISRYI8Ao6oIoCmFOBnYUX9cIPwiftPS1XMls2ArNdIuNCVKAcwC6Qp1OqwwHNhhuAGCJJlSt7q
+YlsgAmcJRAABH+WW8XvqLyLdOwGqSuaiHoEKII40hVvn1McB0LD6K8cNchkWflVTqlMRo365vyKS
+OrrPdom+BhbVk6UC5vQjuqPoyb1V3ckHqAzZubo2H+DGuq5nywIR+Q5rJ1BIGGAyJ6dpr2m22TPY
+UuGoN5PNNE4tnMKMJhZISa63SanuZOqi8xnTPlQ0qRyu5TTvROdqhhVzSU5ynP3sz7rZBfxyMPyG
+Cjrz8CKE90a1+9l8Nzho7FLiscW1EOoCVbUcilX3sJnKFoP62lhhoP7VN+2ZMb7XbeATfDrsr1Jp
+qF6+ruDcTfV6wdzdT7hae0RidxCCN7/fhGmCcuCZw0Q+xO/0b1UwMyD1pfbhpAmH03U6Tft4KVde
+R1diLCAhL4d2Ji+cWhxhKblw+eQrp+ccdU1vEYR/bImvt4aCc4DBJ1UrbYnFWacyVdWiI0+U2h7T
+ufE7helJ19mSdZ2zKqWM1W+kdV79JXFT556C8VWD6/SxR5961HaLapt2SCCwEPl55s25Qf/bs2Yq
+IHN7ZUFig3Gqf9yXrmkt6XpwUQHCMV3x+9uaJOeZN4V8uFcWafH8GYupJ02dfmWFzRCISdYdldJX
+hDorV4CQbA34EZ1AldafAJlL5KBGUMballAJgS+pF9l6QomichYaM6RfmBOp2RRzbQFUE6fbEQ/Y
+PJnBcKltcM/xEWafVvQwbWauDFcE9dCXymQl1GtAyOXYaNNmKjar0yvKmcQ2W6d6zTqWZSvbMphW
+Kyqfa7oy17eSB9gfQJTs08zxyCFrKolH/y/qmq+vUUrkJiMiEM/rdiyqaJwY8TnxRE3Mn1XiXUvr
+RjM4nX7eKazHieNZYHWqiAq2aGrCEqWh6di5uia+gyXn9Fw7Nm8VH5qyQroskGPkvD1zVki3ri3r
+LQZm/KGQbh2zNhrLxqaNuqpkzOhL3fnHQK4DqfpZ2JmH7HPDtKbCYN8C89xFfd7VYVg3vlLegQk6
+6FqdBNDfFB8D3MXPhIvmPEwu/ltjI64fio8UW2Ehro/g4TKM82yLfSG8E7MwDL60414apVwOhg10
+3fgWzo1F4+XZNLqoU5wAV3/Q/1cFwlz0Bbo5vUxPRWVurSi9W5XVXZJVpcdlzvW1j1vQT/dU0kL1
+Lus1c4iH7yTXRREUm6TbAG5crb9Rb+0GbNzb7PfhfIV+A7PZV9JzOS7i4uVYT03eZqunttl2S8LY
+0dYWagTwxCs1inm1W6oZpPL9RtmY4+OdPEUjtrxaFYeOWpLLq99djuWyuln8btemsXmGaNMylZfO
+pvlbc3Nh2TjOvmPTOnmnK5cNF2jxbDy4Xg0XSGMdFuvlcN7hiarfd96iho7elzZT78Zf9m0SL3EB
+3W5jcNlcko1A5ORmagSn8D12s20DmmVRqxS6EH8LKsOW4DBPOfGmnT91ri7XHPN2XYWbonXN0YZ/
+1VSXX8KcYkedJYFsbe2pM1yeeDtNgs1aODnMcHnEyQH9TWuMi9A8HeNe+hvKma/KKsPPqnDIzGvl
+9IX+xpqHpYZI3ihFXtC6u1OB2z7sEmX0z/W5N3MQLXnZr+ZqT1Nt/syj03if/+iw9W4CX54/+9NA
+/f/P8Ff0lbv9FgUS7izhNV1RmYqM2MPVDHB1VkDQWcncfCAbYr7dw++ejdha6XsxcnGO/G9rl0gU
+MNVO3kkx5eMxbChuV8xFTwfuuVSoyslRHEiQDUqMavvqU04gfqa4faO69vp5a4lwEOjNYarDWSYH
+DdSPI/LPgCpzwIwGU03LTGWfUQviOedk1+sX9DV9kHPG63WcEVc1RF5zwBUKQRZYs5cKrEGuI3bq
+9gyvsfZ7FXYIq9xEJYpgCt1uedMwbKWy3Y/PC37fEs9TJ3Ajc9yulYCpthMryCcS9WqCKXz1zflh
+qyKuqSuqF8R9p+cYponc9qibJ6o5ghFCScpZm++LegNAVOnaNBy9uoHpFKg7yW3/KYfmT7Xoe2Nk
+MUPdSixKyYvk95A4Z4nuKyWhNgTGjUdvxEJRuRKLU5F5qxePHi1D6j3uSklNL7oDcjm1IJPT0Q+4
+U+Td46598qg/91RkF0wLUInLRF3sLf7z/gg/SvTd3i8QL5GL/SXzqT8oAhvVmWoZsClO1TbQsPxA
+y2B1sauWYYZVtVqfLlNj/GRi8NY5jSScAm7gnwBqWHD1BOJwCh4wz/2HrvXfvYDzMpn5hyrXFkhU
+7Q611KRcK73vmjHujOkRgnITFVqkQOkcenuQstjcWW2hGQAJyXcFejj329wvlVWqYcEU8vBEt3un
+gwYOfoy0Zu7PRvfl/xRqsNwftR7K/SXUJnd51lHEaEPIFZyX8VRRvAo8Sdce1dbQs/NzGBsmTAp7
+jcewRRDKXweSwX/9Rf2fbBh1gcAsWAD1YcaYFJKTdVyxswzcjHAB2yV1llHkuQg8qeDIJYKOch6u
+mJCqYRmcuQqNQK6jhQIJbA74kQqJvoMDwI0OVGfBYqnw91V0exkww0FVsk6xpZSHmhcuTGGN3EFf
+vlOfee99L8XcJyUy1R9ARdOUhFU1juWtsmEifVVNI1msapdIZv9uAP/1l/6klG+cBb6bZtyt/oL3
+DEMH8GUJgDx2KqV61wvBvj+/q9M9teclIJ7VABFvbUn3L+t0D/xs569qdI42qqT31zV6R/tR0vub
+u34dfVqoU4vVNUzL9Eyo7QtafLLcJ+2PhVGmjim8v6s4cb9m7F6fn38p2ikF49rXe5sCW+NoTxc+
+41gwwFmOphIoIVhrXbnFmWDOPcqzvE1hS32XbUd/x9k73Qim0ymoCVcPf8qPvymHymgdiGWv8z8s
+4DDjbCuQg8tQgM8kiGC9ZlxCPIbo5J2EAD2BZeNtIz9ADKjX2YqL8bgDT8Fjjr7DGC2ZkMrzCU+h
+c5H5RSPxFDpjg/g/trl4pIYbMZ+t0U+dJHtlW2fUSygp/P/e/fjfI6Hvruh81/slqvV3AR0n4Fy/
+D/zUL1pRVWg5HhPZE24lYoezdc18H013ybSorIhPFshhSQTMEH1dw/+LTr9s43/y5AlsMXx+tmae
+B8R3QfKdAspxiEIJPhUmGtuJxxyNRjW0SUL6KseTUeqH+CCYD1PQE7ImXGAPRzpGorCHWheq12hJ
+xI9b/y1na+Ry1+t+x9lKu8C6/bIRozWoWoIfrGbIhYlkdkwChdKefAFTjfT7bqQtunelPfS+GTrx
+Shsqwrj2wXSeEs972qmiAgBiyPsm8KS0Z7icYwM/qyr3OcsX/SaoxEr6fc4Y7/ni7s4KyVoDVzcG
+AOhSdbTni4Fd69M4b3KGOY0z52CgUzh3Dgc5ibPnYJgTOH8OxjiJMyhPylCefpi48PvpySnyddVd
+D0dBKfFf2UvyUf2LfVL28ncsJ9WMHwUiEpsj8dD3T92LXOvaEoiF0yxfGEudaAebz8R622nsX8s1
+AGKgNVxtBYexBFal1+2A/PbyO6W9dHvUxQ669PdZ31zyS9otl/o245FLvk8545IvE2/H3phG8+5/
+H6vKQsdd3gxaOfLymFTfsdfA0VcH1qFPcN/xVwdaIx9hE59hHWB77kVbH2Le9Nn5FHNXwIGXrmA9
+lLQrdiLmrpWSVoWuw7x1VIp5vKpKWqXXWKULMo/tR7kkGym0SHSipaVzqZix1dFXLZF6cCQxD4Aj
+sQMigfg7k2K/5ppVQaMDcBkQzwMXHY4qKk1BD0wgUa2lpp4cTkI3EUeThYaK6O31Er11LXiGX0KF
+WFFfSPV+UKgFnCzpQS39FEigElZKlRQ5LIrE5h532nmY2KmDPYtzkLIdB7EVOEjsuUFimQ3SNtYg
+ay3d2YufitrqKewoTOF8AhQu4S8ToE+f1tlLDkwJRet7enen3ylHDmN6VxdmxuaJYabgTWqB+3TW
+fsvTM/Dy35eBLdp8uZZn+QVCgQ1r2aP5BUNt+rK+LeOtjejtT+p1T3xhB06zqL7BEJ61gPR4HGc1
+YXNQFwGeHnoQp9cAdQkCjLvIbaCtAiH1xmCcpibV2RbDNDMq7UMYfoquDTg1uNqsBVNAiCcYKMbq
+TdYH6sea2QbY3snSbkoO7oBqzWz52qn0D885Ww1AstKGYkuls+wZ53Pi7LZSQw4RmHJknlkpU85W
++Wc2uxU840juJ9aoxc7PpsjFhvIJ0Atdps1QC23zU6AVOVkbIhYdCE6AmnHMNsPLHEFOgFTkyW2G
+VnTsaQ2xI7RGEpulL5/3r2z2b6j6Kqtwqv37/QZ3+RB+YrGSqQLwfq/HHVxFN2XXKseKnaIaj8EM
+YE4aXcm6IDnxBVWutEG8i8mlemhgA45wjBwFencD4rtmk9HrEoijU8Cgq6xHK/yk3Y5iz6jhHqOq
+BWxv+m0GmU7tXVLmMFOTDHsX2Y+zD+jIkTKBy6noR1ZQHeRtCWjLE/qpnVvMzPaeWnd2RDfZ4AEA
+JDtmi6+hgKHxVp+LZs3NvhGidTb9HCRrbfvNEKy1/eehWM8AaIRkDUMgB8M6pkAj9GqZBDkI1jMK
+GqGYXNlajxHGknxRK5akhMrETTs5gdumgQoJ78o/G0Ni7/Zn5MenUxmXhZeQ2oUD38IzuIDzSaWB
+qixoGz6rI7CP29DgVv/0+jBsYhNFUK5q2At6vLCjhQPHekOHyLWxQuWVFyk7VoBDfCCc001knNqC
+0zbsBLbY9TzwMMwvz3yEhQoT5Oo+awDEd20Brgi/B8kSsxthzVHlAUhjbAtNZ5fXyVwUxdQH9dSc
+W1uGX0CdQ02dNVxqChbE7jZfxZX2eT5taa9Oa8S9P4B9B09rnzhqi34jvJqh1Z7jWuuC8/5pdW+Z
+erXQqpLZiIZkPcl0QEP2DN7UI5EKGc2NvrWMvK0fPxsvpfj1unJFcB0om/dQ3tLLoPRcIMJwap2i
+FV1gPpBMsIOtT4DAmnBJncBLRftOgLiuVq1SQIil1S5m+BMuikyNlr79vqNDmqM8QoqyEIZJLK1q
+ggxtQVE/vEe2DvjZRuNGkx0hYrumFZAZLogP0yQLVd++r8+2B5kWEjiWgAzqr+kGE/SPDfKC5G4r
+ZtJT6PV8ttVGjya6D2OTaMtSAVq2y03fYO45fLbt192l9yDV3rD2+sMUwvcuAuUrX6pp85oxOJIC
+ou5/XocupALyjYep3tVr3j1zaqxGN86FE/Se3tUX3Vg0apxPBrVk7uz4Flm1bgRRrbmTbVKv3lq9
+DaGyKwCpTmxPtPqZETdMBjIAxoH4JuiM+osqWElPkzOfCq2b1AOuM7t94JV4QVw7T+B+4hNrjlo7
+KXOSskRo3nTv+ieaN53HteHUmewhalNmXOrZi6+pWRU0EzOlT1db7PLk0iDJrVR5t2tguDA1Kd4r
+2++lGDrIgFK1C+bppnT/SOHB06fU9nDOFaw0kPfU8kCeZF4xUvID7uyd2pyO3qkENYGHXJuKkWWU
++bJK7FLQXhMhtRoNd/bwYw0I2ozuZU1qq76JGKg8XvY3iJ/PC2R2+hBva0nJ5PixfI6lhOIiLSCW
+Yf1GLUQ9zSfbvt8zkXTVH2x7vhGLuKP627bfntTuv6IoEG1L4Lo6hJblCLXkG1sE4+WQj1pqtVgC
+NAskH1q0eAZt2REZHWdidxJZPJlFoQSg2b4kopmG8NFsem+qAifZZ9mbUknzMknImuxJUd8m+5FQ
+cCIA1nuRoFGSs9TitRkSAEAcvXuI33aPynmN09HZbx5N1fFvGjNegVpbpvIGNlaWljkDPlllySCO
+jOreakcdNzkaBJA4aLUo8YduGPkX0xki5oyvXnral1Ukkw7zBfNw5LFFrxOCUm4zjmvQbj3oRBme
+IjR6/f7ZETknuibbbHcAEcoX+/B1Nor8BTceg8ry4DMJO5RAV2tDH7rRW6swccQgTvqxzNsbPk0a
+zYp2nYqw2Bu4dD5HlT9D57rVjzsK80mZPFJ6b6iaUbFk28i/e2NCVNKzGnUuT4iyZFvdSvtV4z6D
+JGomL+/JxAahMBilVZSiAJeGSN1qU6A9hEwwS1NkQs9zm+hoF4eaM3PrqF4kUt/xAhdFEhjTCNvX
+TLQ5lTqCpSHjXujgkhaRCaNVGqJzHUaBtIhQHFhSE6UEWh4yA5PepTKxYex7LGqZaV3T390oWXL6
+v9Ab7nhIeOwPz8VkUhuRgjTR1Xt8lm+99/XyjhVORzRzI+oWXezpCOSoMvtB3uuy2dDpftgalOCU
+HYtiJELAxdRBvSzdeV3KsnXnM7uisblVOno2CshKTdDkzJY2PV2TMyvS9pk/OcqkCoFkbKoUCQNT
+3OvCYJif6+vTMfaQZDobf6qSZVHe+0xVyoM7S1MWdFLeOSwzaZtZPikwad1jybbvZGYjUvbdQN0l
+V2ShS2OoO5VldYsx631gs/eq9V1/Ug09ZF5Pl8FtOpFrj+mXa8BRsIA7aj7Ve259QyuQU2POmttz
+AWJN+L1H/ZwX1QY33eM7ip6rHxrnUvELzFWDC+jePn/THcQFRcwndVgh8iLh+aYPv4TLHDZRjaFJ
+fp2RLPjrt39LgTefysHDU+j+oWsFXNmaaeTNx1LwvY2+4f76L9/8+U/9WpT8nVOJqdGizyca7i1n
+jkgNpz6jECgqGTg5KN8KAHA3KROXN+Rjeo2ZLwcGmaJ1pv1y5GNx7FCe881AHnl219XGAlI93tO7
+9xqbO7hSo5b1AoAQsf2+k6M9CMl+p4awWfKGy9GibcTlLXV1HMGzb84nhY2WGFYC+LqkUcSW9MTv
+4/Gvf8GzYgge0YeUkLOZ6VRPhu9G79ARFTB0fomiFKrtSI7qrEhNxMcgNtYE9OGJYWp51mF1jg3Z
+OoTeoSSOjSQ+gV7c6st+v/zltKHdeL4+jiT7jn5Et/dMlxgfdOEp7FJf9if1xTIc4AOjfq8L3VPZ
+F+r7qMwumwNR+1O+7OcUz61nbWQr2dar7rNk27CsbnrlVVkPmRFV61EIpD+p7NTMMEhDCGu2phHG
+MvXgxu2L1pPSozj6myg/O4ZwjGx2ZztQ4hh2qyOIZrRrj6IvX93Yj+joHuGocff6I6tLVftRmR+O
+qLrBUwXgLVW1HbvQW1M3/FF99RS6/a6u9NhvgJWpGWmPF0alSUMMolKTDYZO6ktaD5/p1EALRcCM
+GpqcVg/FZYTLFVFeceB6mmivTm9tVRTVDa6ji7JjamX0PPNVf1LZWbR3WqmYjpsoi0U+E7Ll/Osx
+P6yqX5vpN6nk6tZcN4Npbsf9yxjtLh6SxXGl/QJOZav912RzWHm/CZ9jvGqx2gwY8TqGUcpuXD8Y
+v5PqpPm079VHrcftqFppbW4nWNXhdThc771idgKi1NmxR99JeY3+Jp/kvVqq9ZgclTOtzeSX/qYO
+d8NxtCS/9DdlTN2j5yRMZT4CM18TTQDMAimZL8L72TxQ4b5tdtL0g5SCDVHDPWJDTfrX9CWanomp
+nD8tptV+WENBJIPhjmXje9xZtuSxH9iquTD+Yau2qG3KGo2vmWsLex7bf5YddDaTg7bWl2sf2Own
+9nxvVtNLbxDO5iCcqHKrKQ0o/NQz/5TaS5luZpxeOJx1t3vcaRXwA+7sO8UhF6pndHVg311Lje5r
+LqWsO0ZSYZSWOaM071xyQMnpnoiYBlB54sgB4ZjANkU3XVGPcHgKz2pc6TpstaLSiF1a3ojnFcmX
+Timsr3tSqrXw9FUCCKqulQp7AUBy5VQs7xURW3tBPwXyWAEkus4qEsmK7pHQXJSKVwWQ71KqqlzM
+SgAVl92peqPyOefwB9zl9lY6qBGx9ve1eh0INKsgoC0EPBx3xV/zWrzOlbj1dXiBUVRoBBWrJX9O
++eoWZT3H5eEmanbOLleQuvEffTv0w9vTrsEjDAq6ZqsV8V3R7dfjQZGJW8UC9eZLrfCW+KDAdZO/
+anNC9dIa5zOx4gbXj4kTSRDi52DGW/Tdx8QNhY8KOPw8guGR3eMSDRMw+7DM+IF67aiKe+p53ejf
+mhzQSETRpw9L/w0St1X6Q7h1WXBtusXUA+FKJIh7OjZY+UYMWsI8iibRE2kqwEXiVnLWvDzeu8Xf
+FN+r2YaNhUPEb5u7AzB/vLq5CHEcvbopttty30fH/fon4p56ja5Dn11QBTZBLHW9RyFzkl+Px3Hj
+Ui6/IO73e4HQy5ILvfoMVtAz7FXg6zM37NUaa9XrOxE+YxUgGcwwfqpnEsT4xQxUaVcU08iC5JTt
+MOxV0bynYm4K9iNkrUvFigqBAkj0eK/g7sM0fHNQTLQn6LE8imCLRXcAb8TiAsJngTa8CTEKXxKe
+hDGZdAWl7LnNPLVOYuDbYlEKfsSq8DW/DatSvcMkAJXs+nS2H1IhNqswYP6dLpD7M8XtG+ait++P
+vmcjsl57uxdUG36iJzarAfy+1/2dqazb7WeLi1+OhcPpWl6dmU8z5u6uzi7HS7nyrs7+3wDzjPnu
+zjEBAA==
 `,
 	},
 
@@ -0,0 +1,207 @@
+// Copyright © 2017 Genome Research Limited
+// Author: Sendu Bala <sb10@sanger.ac.uk>.
+//
+//  This file is part of wr.
+//
+//  wr is free software: you can redistribute it and/or modify
+//  it under the terms of the GNU Lesser General Public License as published by
+//  the Free Software Foundation, either version 3 of the License, or
+//  (at your option) any later version.
+//
+//  wr is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of
+//  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU Lesser General Public License for more details.
+//
+//  You should have received a copy of the GNU Lesser General Public License
+//  along with wr. If not, see <http://www.gnu.org/licenses/>.
+
+package jobqueue
+
+// This file contains the code that lets a Client Execute() many Jobs at once
+// while touching them all with a single request to the server.
+
+import (
+	"sync"
+	"time"
+)
+
+// touchGroup is the combined touch loop of a Client, touching all the Jobs
+// that are registered with it every ClientTouchInterval.
+type touchGroup struct {
+	c    *Client
+	jobs map[string]*touchedJob
+	stop chan bool
+	sync.Mutex
+}
+
+// touchedJob holds the details of a registered Job that we need to touch it.
+type touchedJob struct {
+	job    *Job // just enough of the Job to get its key()
+	series []byte
+	killed func()
+}
+
+// CombineTouches makes subsequent Execute() calls on this Client (typically
+// made concurrently in multiple goroutines) stop touching their Jobs
+// themselves. Instead, a single goroutine touches all the Jobs currently
+// being executed with one request to the server every ClientTouchInterval,
+// which greatly reduces the load on the server when running many small Jobs
+// at once. Call the returned function once you're done executing Jobs, to
+// stop the combined touching.
+func (c *Client) CombineTouches() (stop func()) {
+	tg := &touchGroup{
+		c:    c,
+		jobs: make(map[string]*touchedJob),
+		stop: make(chan bool),
+	}
+	c.teMutex.Lock()
+	c.touches = tg
+	c.teMutex.Unlock()
+
+	go tg.loop()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			c.teMutex.Lock()
+			c.touches = nil
+			c.teMutex.Unlock()
+			close(tg.stop)
+		})
+	}
+}
+
+// combinedTouches returns the combined touch loop started by
+// CombineTouches(), or nil if there isn't one.
+func (c *Client) combinedTouches() *touchGroup {
+	c.teMutex.Lock()
+	defer c.teMutex.Unlock()
+	return c.touches
+}
+
+// TouchJobs is like Touch(), but touches multiple Jobs with a single request to
+// the server. Jobs that are no longer running (eg. they have already been
+// Archive()d) are ignored. The keys of any of the Jobs that Kill() has been
+// called for are returned. If some of the Jobs couldn't be touched, failed
+// holds the reasons why, keyed on the keys of those Jobs; err is only set if
+// the request as a whole failed.
+func (c *Client) TouchJobs(jobs []*Job) (killCalled []string, failed map[string]error, err error) {
+	c.teMutex.Lock()
+	defer c.teMutex.Unlock()
+	resp, err := c.request(&clientRequest{Method: "jtouches", Jobs: jobs})
+	if err != nil {
+		return
+	}
+	killCalled = resp.KillCalledFor
+	for key, errs := range resp.TouchErrors {
+		if failed == nil {
+			failed = make(map[string]error)
+		}
+		failed[key] = Error{c.queue, "TouchJobs", key, errs}
+	}
+	return
+}
+
+// add registers a Job to be touched, calling killed if Kill() gets called for
+// it.
+func (tg *touchGroup) add(job *Job, killed func()) {
+	tg.Lock()
+	defer tg.Unlock()
+	tg.jobs[job.key()] = &touchedJob{
+		job: &Job{
+			Cmd:          job.Cmd,
+			Cwd:          job.Cwd,
+			CwdMatters:   job.CwdMatters,
+			MountConfigs: job.MountConfigs,
+			Interpreter:  job.Interpreter,
+			ScriptKey:    job.ScriptKey,
+			Container:    job.Container,
+		},
+		killed: killed,
+	}
+}
+
+// setSeries sets the compressed resource usage measurements that will be sent
+// along with the next touch of the given Job.
+func (tg *touchGroup) setSeries(job *Job, series []byte) {
+	tg.Lock()
+	defer tg.Unlock()
+	if tj, exists := tg.jobs[job.key()]; exists {
+		tj.series = series
+	}
+}
+
+// remove stops the given Job from being touched.
+func (tg *touchGroup) remove(job *Job) {
+	tg.Lock()
+	defer tg.Unlock()
+	delete(tg.jobs, job.key())
+}
+
+// touch touches all registered Jobs, returning false if we couldn't contact
+// the server.
+func (tg *touchGroup) touch() bool {
+	tg.Lock()
+	if len(tg.jobs) == 0 {
+		tg.Unlock()
+		return true
+	}
+	jobs := make([]*Job, 0, len(tg.jobs))
+	for _, tj := range tg.jobs {
+		jobs = append(jobs, &Job{
+			Cmd:             tj.job.Cmd,
+			Cwd:             tj.job.Cwd,
+			CwdMatters:      tj.job.CwdMatters,
+			MountConfigs:    tj.job.MountConfigs,
+			Interpreter:     tj.job.Interpreter,
+			ScriptKey:       tj.job.ScriptKey,
+			Container:       tj.job.Container,
+			ResourceSeriesC: tj.series,
+		})
+	}
+	tg.Unlock()
+
+	// (jobs that failed to be touched will be touched again next time, and
+	// any problem with them will also be noticed by their own Execute())
+	killCalled, _, err := tg.c.TouchJobs(jobs)
+	if err != nil {
+		return false
+	}
+
+	tg.Lock()
+	var killed []func()
+	for _, key := range killCalled {
+		if tj, exists := tg.jobs[key]; exists {
+			killed = append(killed, tj.killed)
+		}
+	}
+	tg.Unlock()
+	for _, kill := range killed {
+		kill()
+	}
+	return true
+}
+
+// loop touches all registered Jobs every ClientTouchInterval until stopped. If
+// we lose contact with the server, we retry more frequently than normal, like
+// Execute() does.
+func (tg *touchGroup) loop() {
+	ticker := time.NewTicker(ClientTouchInterval)
+	defer ticker.Stop()
+	retry := time.NewTicker(1 * time.Second)
+	defer retry.Stop()
+	touchPending := false
+	for {
+		select {
+		case <-ticker.C:
+			touchPending = !tg.touch()
+		case <-retry.C:
+			if touchPending {
+				touchPending = !tg.touch()
+			}
+		case <-tg.stop:
+			return
+		}
+	}
+}
@@ -29,6 +29,7 @@ import (
 	"github.com/dgryski/go-farm"
 	"io"
 	"io/ioutil"
+	"math"
 	"net"
 	"os"
 	"os/exec"
@@ -215,6 +216,149 @@ func currentMemory(pid int) (int, error) {
 	return mem, nil
 }
 
+// diskUsage returns the total size, in MB, of the regular files within dir,
+// not descending in to any of the skip directories (such as mount points),
+// along with the number of files and directories we looked at. Files that
+// disappear while we're looking are ignored.
+func diskUsage(dir string, skip map[string]bool) (mb int, files int) {
+	var size int64
+	filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		files++
+		if err != nil {
+			return nil
+		}
+		if info.IsDir() {
+			if skip[path] {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.Mode().IsRegular() {
+			size += info.Size()
+		}
+		return nil
+	})
+	mb = int(math.Ceil(float64(size) / 1048576))
+	return
+}
+
+// processTreeMemory is like currentMemory(), but also includes the memory
+// usage of all the descendants of pid.
+func processTreeMemory(pid int) (int, error) {
+	mem, err := currentMemory(pid)
+	if err != nil {
+		return 0, err
+	}
+	for _, child := range descendantPids(pid) {
+		cmem, errc := currentMemory(child)
+		if errc == nil {
+			// (the child may have exited since we found it)
+			mem += cmem
+		}
+	}
+	return mem, nil
+}
+
+// descendantPids returns the pids of all the descendants of the given pid,
+// relying on linux /proc/*/stat.
+func descendantPids(pid int) (pids []int) {
+	stats, err := filepath.Glob("/proc/[0-9]*/stat")
+	if err != nil {
+		return
+	}
+	children := make(map[int][]int)
+	for _, stat := range stats {
+		content, err := ioutil.ReadFile(stat)
+		if err != nil {
+			continue
+		}
+
+		// the 2nd field is the command name in parentheses, which could
+		// contain spaces, so we look for the fields after the last ")"
+		i := bytes.LastIndexByte(content, ')')
+		if i == -1 {
+			continue
+		}
+		fields := strings.Fields(string(content[i+1:]))
+		if len(fields) < 2 {
+			continue
+		}
+		ppid, err := strconv.Atoi(fields[1])
+		if err != nil {
+			continue
+		}
+		child, err := strconv.Atoi(filepath.Base(filepath.Dir(stat)))
+		if err != nil {
+			continue
+		}
+		children[ppid] = append(children[ppid], child)
+	}
+
+	todo := children[pid]
+	for len(todo) > 0 {
+		child := todo[0]
+		todo = todo[1:]
+		pids = append(pids, child)
+		todo = append(todo, children[child]...)
+	}
+	return
+}
+
+// clockTicks is the number of clock ticks per second that linux uses for the
+// cpu times in /proc/*/stat (USER_HZ, which is 100 on all the systems we care
+// about).
+const clockTicks = 100
+
+// processTreeUsage returns the total cpu time (in seconds, including that of
+// exited children that were waited for), bytes read from and written to
+// storage, and number of processes, of the process tree rooted at pid, relying
+// on linux /proc/*/stat and /proc/*/io. Processes that we can't read the
+// details of are ignored.
+func processTreeUsage(pid int) (cpu float64, read uint64, write uint64, procs int) {
+	for _, p := range append([]int{pid}, descendantPids(pid)...) {
+		content, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/stat", p))
+		if err != nil {
+			continue
+		}
+		i := bytes.LastIndexByte(content, ')')
+		if i == -1 {
+			continue
+		}
+		procs++
+
+		// after the command name, utime, stime, cutime and cstime are the
+		// 12th to 15th fields
+		fields := strings.Fields(string(content[i+1:]))
+		if len(fields) >= 15 {
+			for _, field := range fields[11:15] {
+				ticks, errp := strconv.ParseUint(field, 10, 64)
+				if errp == nil {
+					cpu += float64(ticks) / clockTicks
+				}
+			}
+		}
+
+		f, err := os.Open(fmt.Sprintf("/proc/%d/io", p))
+		if err != nil {
+			continue
+		}
+		r := bufio.NewScanner(f)
+		for r.Scan() {
+			var bytesDone uint64
+			line := r.Text()
+			if strings.HasPrefix(line, "read_bytes:") {
+				fmt.Sscanf(line[11:], "%d", &bytesDone)
+				read += bytesDone
+			} else if strings.HasPrefix(line, "write_bytes:") {
+				fmt.Sscanf(line[12:], "%d", &bytesDone)
+				write += bytesDone
+			}
+		}
+		f.Close()
+	}
+	return
+}
+
 // this prefixSuffixSaver-related code is taken from os/exec, since they are not
 // exported. prefixSuffixSaver is an io.Writer which retains the first N bytes
 // and the last N bytes written to it. The Bytes() methods reconstructs it with
@@ -0,0 +1,243 @@
+// Copyright © 2017 Genome Research Limited
+// Author: Sendu Bala <sb10@sanger.ac.uk>.
+//
+//  This file is part of wr.
+//
+//  wr is free software: you can redistribute it and/or modify
+//  it under the terms of the GNU Lesser General Public License as published by
+//  the Free Software Foundation, either version 3 of the License, or
+//  (at your option) any later version.
+//
+//  wr is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of
+//  MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//  GNU Lesser General Public License for more details.
+//
+//  You should have received a copy of the GNU Lesser General Public License
+//  along with wr. If not, see <http://www.gnu.org/licenses/>.
+
+package jobqueue
+
+// This file contains the code for giving clients a live summary of the state
+// of the queue, as seen in the status web page.
+
+import (
+	"github.com/VertebrateResequencing/wr/queue"
+	"sort"
+	"sync"
+	"time"
+)
+
+// WatchStatusSettle is how long the server waits after seeing the first change
+// in the state of the queue before responding to Client.WatchStatus(), so that
+// a burst of changes results in a single response.
+var WatchStatusSettle = 250 * time.Millisecond
+
+// WatchStatus is a summary of the current state of the queue, as returned by
+// Client.WatchStatus().
+type WatchStatus struct {
+	// RepGroups has the counts of jobs in each state for each RepGroup that has
+	// incomplete jobs, sorted by RepGroup. Reserved jobs are counted as
+	// running, and complete jobs are counted as well.
+	RepGroups []*RepGroupStateCounts
+
+	// Running are the jobs that are currently running (or that we've lost
+	// contact with), in the order they started.
+	Running []*Job
+
+	// BadServers are the servers that have gone bad.
+	BadServers []*BadServer
+
+	// SchedulerIssues are the problems encountered by the scheduler (or by the
+	// manager generally), most recent first.
+	SchedulerIssues []*SchedulerIssue
+
+	// RunnerIssues are the errors and exit reasons reported by runners, most
+	// recent first.
+	RunnerIssues []*RunnerIssue
+}
+
+// RepGroupStateCounts holds the number of jobs in each state for a RepGroup.
+type RepGroupStateCounts struct {
+	RepGroup string
+	Counts   map[JobState]int
+}
+
+// watchShare lets concurrent Client.WatchStatus() calls share the work of
+// summarising a queue: a summary that started after the change a caller was
+// waiting for is as good as one of its own, so a burst of changes seen by many
+// callers only results in a single summary.
+type watchShare struct {
+	sync.Mutex
+	latest map[string]*watchSummary // keyed on queue name
+}
+
+// watchSummary is a summary of a queue that was started at a certain time. The
+// other fields are only set once done is closed.
+type watchSummary struct {
+	started time.Time
+	done    chan struct{}
+	ws      *WatchStatus
+	srerr   string
+	qerr    string
+}
+
+// newWatchShare creates a new watchShare.
+func newWatchShare() *watchShare {
+	return &watchShare{latest: make(map[string]*watchSummary)}
+}
+
+// waitForStatusChange waits up to the given time for a change in the state of
+// the queue (only considering jobs in the given RepGroup, if not blank), bad
+// servers, scheduler issues or runner issues to be broadcast to the status web
+// page, then waits WatchStatusSettle longer for further changes. It returns the
+// time of the first change (or when we started waiting, if there wasn't one),
+// since when the queue must be summarised for the caller to see the changes.
+func (s *Server) waitForStatusChange(wait time.Duration, repGroup string) time.Time {
+	since := time.Now()
+	if wait <= 0 {
+		return since
+	}
+
+	statusReceiver := s.statusCaster.Join()
+	defer statusReceiver.Close()
+	badserverReceiver := s.badServerCaster.Join()
+	defer badserverReceiver.Close()
+	schedIssueReceiver := s.schedCaster.Join()
+	defer schedIssueReceiver.Close()
+	runnerIssueReceiver := s.runnerCaster.Join()
+	defer runnerIssueReceiver.Close()
+
+	timeout := time.After(wait)
+	changed := false
+	for !changed {
+		select {
+		case change := <-statusReceiver.In:
+			// jstateCounts are sent for both the "+all+" pseudo group and the
+			// real RepGroup, so we only need to look at the latter
+			jsc, ok := change.(*jstateCount)
+			changed = repGroup == "" || !ok || jsc.RepGroup == repGroup
+		case <-badserverReceiver.In:
+			changed = true
+		case <-schedIssueReceiver.In:
+			changed = true
+		case <-runnerIssueReceiver.In:
+			changed = true
+		case <-timeout:
+			return since
+		}
+	}
+	since = time.Now()
+
+	// keep reading so that we don't hold up the broadcasts while we settle
+	settled := time.After(WatchStatusSettle)
+	for {
+		select {
+		case <-statusReceiver.In:
+		case <-badserverReceiver.In:
+		case <-schedIssueReceiver.In:
+		case <-runnerIssueReceiver.In:
+		case <-settled:
+			return since
+		}
+	}
+}
+
+// getSharedWatchStatus is like getWatchStatus(), but only for the given
+// RepGroup (if not blank), and instead of always summarising the queue, it
+// returns (a filtered copy of) any summary started no earlier than since,
+// waiting for it to finish if necessary.
+func (s *Server) getSharedWatchStatus(q *queue.Queue, since time.Time, repGroup string) (ws *WatchStatus, srerr string, qerr string) {
+	share := s.watchShare
+	share.Lock()
+	sum := share.latest[q.Name]
+	if sum == nil || sum.started.Before(since) {
+		sum = &watchSummary{started: time.Now(), done: make(chan struct{})}
+		share.latest[q.Name] = sum
+		share.Unlock()
+		sum.ws, sum.srerr, sum.qerr = s.getWatchStatus(q)
+		close(sum.done)
+	} else {
+		share.Unlock()
+		<-sum.done
+	}
+
+	if sum.srerr != "" {
+		return nil, sum.srerr, sum.qerr
+	}
+	ws = sum.ws
+	if repGroup == "" {
+		return
+	}
+
+	// other callers have the same summary, so we make a copy to filter
+	wsc := *ws
+	wsc.RepGroups = nil
+	for _, rgc := range ws.RepGroups {
+		if rgc.RepGroup == repGroup {
+			wsc.RepGroups = append(wsc.RepGroups, rgc)
+		}
+	}
+	wsc.Running = nil
+	for _, job := range ws.Running {
+		if job.RepGroup == repGroup {
+			wsc.Running = append(wsc.Running, job)
+		}
+	}
+	ws = &wsc
+	return
+}
+
+// getWatchStatus summarises the current state of the given queue. You should
+// treat the returned WatchStatus as read-only, since it may be shared by
+// getSharedWatchStatus().
+func (s *Server) getWatchStatus(q *queue.Queue) (ws *WatchStatus, srerr string, qerr string) {
+	ws = &WatchStatus{}
+	counts := make(map[string]map[JobState]int)
+	for _, job := range s.getJobsCurrent(q, 0, "", false, false) {
+		state := job.State
+		switch state {
+		case JobStateReserved:
+			state = JobStateRunning
+			fallthrough
+		case JobStateRunning, JobStateLost:
+			ws.Running = append(ws.Running, job)
+		}
+		if _, exists := counts[job.RepGroup]; !exists {
+			counts[job.RepGroup] = make(map[JobState]int)
+		}
+		counts[job.RepGroup][state]++
+	}
+
+	for rg, rgCounts := range counts {
+		complete, err := s.db.countCompleteJobsByRepGroup(rg)
+		if err != nil {
+			srerr = ErrDBError
+			qerr = err.Error()
+			return
+		}
+		if complete > 0 {
+			rgCounts[JobStateComplete] = complete
+		}
+		ws.RepGroups = append(ws.RepGroups, &RepGroupStateCounts{RepGroup: rg, Counts: rgCounts})
+	}
+	sort.Slice(ws.RepGroups, func(i, j int) bool {
+		return ws.RepGroups[i].RepGroup < ws.RepGroups[j].RepGroup
+	})
+	sort.SliceStable(ws.Running, func(i, j int) bool {
+		return ws.Running[i].StartTime.Before(ws.Running[j].StartTime)
+	})
+
+	ws.BadServers = s.getBadServers()
+	s.simutex.RLock()
+	for _, si := range s.schedIssues {
+		sic := *si
+		ws.SchedulerIssues = append(ws.SchedulerIssues, &sic)
+	}
+	s.simutex.RUnlock()
+	sort.Slice(ws.SchedulerIssues, func(i, j int) bool {
+		return ws.SchedulerIssues[i].LastDate > ws.SchedulerIssues[j].LastDate
+	})
+	ws.RunnerIssues = s.getRunnerIssues()
+	return
+}
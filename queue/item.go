@@ -279,6 +279,16 @@ func (item *Item) switchReadyRun() {
 	item.state = ItemStateRun
 }
 
+// update after we've switched from the delay to the run sub-queue
+func (item *Item) switchDelayRun() {
+	item.mutex.Lock()
+	defer item.mutex.Unlock()
+	item.queueIndexes[0] = -1
+	item.readyAt = time.Time{}
+	item.reserves++
+	item.state = ItemStateRun
+}
+
 // update after we've switched from the ready to the dependent sub-queue
 func (item *Item) switchReadyDependent() {
 	item.mutex.Lock()
@@ -105,6 +105,7 @@ const (
 var (
 	ErrQueueClosed   = errors.New("queue closed")
 	ErrNothingReady  = errors.New("ready queue is empty")
+	ErrNoneMatch     = errors.New("no ready item matches")
 	ErrAlreadyExists = errors.New("already exists")
 	ErrNotFound      = errors.New("not found")
 	ErrNotReady      = errors.New("not ready")
@@ -720,11 +721,30 @@ func (queue *Queue) SetReserveGroup(key string, newGroup string) (err error) {
 // able to later, you can manually call Release(), which moves it to the delay
 // sub-queue.
 func (queue *Queue) Reserve(reserveGroup ...string) (item *Item, err error) {
+	return queue.reserve("Reserve", nil, reserveGroup...)
+}
+
+// ReserveMatching is like Reserve(), but you get the highest priority item
+// (in the optional reserveGroup) out of only those items whose Data makes the
+// given matches function return true. This lets you avoid reserving items you
+// would not be able to handle, eg. because they need more resources than you
+// have available. If there are no ready items you get an ErrNothingReady error,
+// while if there are some but none of them match, you get an ErrNoneMatch
+// error.
+//
+// matches is called while the queue is locked, so it must be quick and must not
+// call any methods of this queue.
+func (queue *Queue) ReserveMatching(matches func(data interface{}) bool, reserveGroup ...string) (item *Item, err error) {
+	return queue.reserve("ReserveMatching", matches, reserveGroup...)
+}
+
+// reserve implements Reserve() and ReserveMatching(); matches is optional.
+func (queue *Queue) reserve(op string, matches func(data interface{}) bool, reserveGroup ...string) (item *Item, err error) {
 	queue.mutex.Lock()
 
 	if queue.closed {
 		queue.mutex.Unlock()
-		err = Error{queue.Name, "Reserve", "", ErrQueueClosed}
+		err = Error{queue.Name, op, "", ErrQueueClosed}
 		return
 	}
 
@@ -734,10 +754,21 @@ func (queue *Queue) Reserve(reserveGroup ...string) (item *Item, err error) {
 	}
 
 	// pop an item from the ready queue and add it to the run queue
-	item = queue.readyQueue.pop(group)
+	var rejected bool
+	if matches == nil {
+		item = queue.readyQueue.pop(group)
+	} else {
+		item, rejected = queue.readyQueue.popMatching(func(i *Item) bool {
+			return matches(i.Data)
+		}, group)
+	}
 	if item == nil {
 		queue.mutex.Unlock()
-		err = Error{queue.Name, "Reserve", "", ErrNothingReady}
+		if rejected {
+			err = Error{queue.Name, op, "", ErrNoneMatch}
+		} else {
+			err = Error{queue.Name, op, "", ErrNothingReady}
+		}
 		return
 	}
 
@@ -752,6 +783,55 @@ func (queue *Queue) Reserve(reserveGroup ...string) (item *Item, err error) {
 	return
 }
 
+// ReserveKey is like Reserve(), but instead of getting the next item in the
+// ready sub-queue, you get the item with the given key, which must currently be
+// in either the ready or the delay sub-queue. This is useful for re-establishing
+// a reservation that was made before your queue was last destroyed, eg. when a
+// process that was working on the item outlived the previous queue.
+func (queue *Queue) ReserveKey(key string) (item *Item, err error) {
+	queue.mutex.Lock()
+
+	if queue.closed {
+		queue.mutex.Unlock()
+		err = Error{queue.Name, "ReserveKey", key, ErrQueueClosed}
+		return
+	}
+
+	// check it's actually in the queue first
+	item, ok := queue.items[key]
+	if !ok {
+		queue.mutex.Unlock()
+		err = Error{queue.Name, "ReserveKey", key, ErrNotFound}
+		return
+	}
+
+	// switch from the ready or delay queue to the run queue
+	var from SubQueue
+	switch item.state {
+	case ItemStateReady:
+		queue.readyQueue.remove(item)
+		item.switchReadyRun()
+		from = SubQueueReady
+	case ItemStateDelay:
+		queue.delayQueue.remove(item)
+		item.switchDelayRun()
+		from = SubQueueDelay
+	default:
+		queue.mutex.Unlock()
+		item = nil
+		err = Error{queue.Name, "ReserveKey", key, ErrNotReady}
+		return
+	}
+	item.touch()
+	queue.runQueue.push(item)
+
+	queue.mutex.Unlock()
+	queue.ttrNotificationTrigger(item)
+	queue.changed(from, SubQueueRun, []*Item{item})
+
+	return
+}
+
 // Touch is a thread-safe way to extend the amount of time a Reserve()d item
 // is allowed to run.
 func (queue *Queue) Touch(key string) (err error) {
@@ -750,6 +750,80 @@ func TestQueue(t *testing.T) {
 		})
 	})
 
+	Convey("You can reserve only the items that match a predicate", t, func() {
+		queue := New("myqueue")
+		defer queue.Destroy()
+		for i := 0; i < 20; i++ {
+			group := "a"
+			if i%2 == 1 {
+				group = "b"
+			}
+			_, err := queue.Add(fmt.Sprintf("key_%d", i), group, i, uint8(i%5), 0*time.Millisecond, 1*time.Minute)
+			So(err, ShouldBeNil)
+		}
+		<-time.After(10 * time.Millisecond)
+
+		atMost := func(max int) func(data interface{}) bool {
+			return func(data interface{}) bool {
+				return data.(int) <= max
+			}
+		}
+
+		// group a has keys 0..18 in even steps, with priorities 0,2,4,1,3,0...;
+		// of those with data <= 9, key_4 and then key_8 have the highest
+		// priorities
+		item, err := queue.ReserveMatching(atMost(9), "a")
+		So(err, ShouldBeNil)
+		So(item, ShouldNotBeNil)
+		So(item.Key, ShouldEqual, "key_4")
+		item, err = queue.ReserveMatching(atMost(9), "a")
+		So(err, ShouldBeNil)
+		So(item, ShouldNotBeNil)
+		So(item.Key, ShouldEqual, "key_8")
+		item, err = queue.ReserveMatching(atMost(9), "a")
+		So(err, ShouldBeNil)
+		So(item, ShouldNotBeNil)
+		So(item.Key, ShouldEqual, "key_2")
+
+		item, err = queue.ReserveMatching(atMost(1), "a")
+		So(err, ShouldBeNil)
+		So(item, ShouldNotBeNil)
+		So(item.Key, ShouldEqual, "key_0")
+
+		item, err = queue.ReserveMatching(atMost(5), "a")
+		So(err, ShouldNotBeNil)
+		So(item, ShouldBeNil)
+		qerr, ok := err.(Error)
+		So(ok, ShouldBeTrue)
+		So(qerr.Err, ShouldEqual, ErrNoneMatch)
+
+		item, err = queue.ReserveMatching(atMost(5), "c")
+		So(err, ShouldNotBeNil)
+		So(item, ShouldBeNil)
+		qerr, ok = err.(Error)
+		So(ok, ShouldBeTrue)
+		So(qerr.Err, ShouldEqual, ErrNothingReady)
+
+		item, err = queue.ReserveMatching(atMost(1), "b")
+		So(err, ShouldBeNil)
+		So(item, ShouldNotBeNil)
+		So(item.Key, ShouldEqual, "key_1")
+
+		stats := queue.Stats()
+		So(stats.Ready, ShouldEqual, 15)
+		So(stats.Running, ShouldEqual, 5)
+
+		// the normal ordering of the remaining items is unaffected
+		item, err = queue.Reserve("a")
+		So(err, ShouldBeNil)
+		So(item, ShouldNotBeNil)
+		So(item.Key, ShouldEqual, "key_14")
+		item, err = queue.Reserve("a")
+		So(err, ShouldBeNil)
+		So(item, ShouldNotBeNil)
+		So(item.Key, ShouldEqual, "key_18")
+	})
+
 	Convey("Once an item been added to the queue", t, func() {
 		queue := New("myqueue")
 		defer queue.Destroy()
@@ -879,6 +953,55 @@ func TestQueue(t *testing.T) {
 				So(item.State(), ShouldEqual, ItemStateReady)
 			})
 		})
+
+		Convey("It can be reserved by key whilst delayed", func() {
+			So(item.State(), ShouldEqual, ItemStateDelay)
+			gotItem, err := queue.ReserveKey("item1")
+			So(err, ShouldBeNil)
+			if gotItem == nil {
+				So(false, ShouldBeTrue)
+			} else {
+				So(gotItem.Key, ShouldEqual, "item1")
+			}
+			So(item.State(), ShouldEqual, ItemStateRun)
+
+			stats := queue.Stats()
+			So(stats.Delayed, ShouldEqual, 0)
+			So(stats.Running, ShouldEqual, 1)
+
+			Convey("But not again once running", func() {
+				_, err := queue.ReserveKey("item1")
+				So(err, ShouldNotBeNil)
+				qerr, ok := err.(Error)
+				So(ok, ShouldBeTrue)
+				So(qerr.Err, ShouldEqual, ErrNotReady)
+			})
+
+			Convey("It then gets auto-released after its ttr", func() {
+				<-time.After(55 * time.Millisecond)
+				So(item.State(), ShouldEqual, ItemStateReady)
+			})
+		})
+
+		Convey("It can be reserved by key once ready", func() {
+			<-time.After(55 * time.Millisecond)
+			So(item.State(), ShouldEqual, ItemStateReady)
+			_, err := queue.ReserveKey("item1")
+			So(err, ShouldBeNil)
+			So(item.State(), ShouldEqual, ItemStateRun)
+
+			stats := queue.Stats()
+			So(stats.Ready, ShouldEqual, 0)
+			So(stats.Running, ShouldEqual, 1)
+		})
+
+		Convey("Non-existent items can't be reserved by key", func() {
+			_, err := queue.ReserveKey("item2")
+			So(err, ShouldNotBeNil)
+			qerr, ok := err.(Error)
+			So(ok, ShouldBeTrue)
+			So(qerr.Err, ShouldEqual, ErrNotFound)
+		})
 	})
 
 	Convey("Once a thousand items with no delay have been added to the queue", t, func() {
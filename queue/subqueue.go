@@ -80,6 +80,41 @@ func (q *subQueue) pop(reserveGroup ...string) *Item {
 	return heap.Pop(q).(*Item)
 }
 
+// popMatching is like pop(), but removes the next item out of only those for
+// which matches returns true. Returns nil if none match, in which case rejected
+// is true if there were items that didn't match. Only for use on the ready
+// sub-queue.
+func (q *subQueue) popMatching(matches func(*Item) bool, reserveGroup ...string) (item *Item, rejected bool) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	var group string
+	if len(reserveGroup) == 1 {
+		group = reserveGroup[0]
+	}
+	itemList, existed := q.groupedItems[group]
+	if !existed || len(itemList) == 0 {
+		return nil, false
+	}
+	q.reserveGroup = group
+
+	// visit the items in "priority" order by walking down the heap, always
+	// considering next the best of the children of the items we've rejected so
+	// far, so that we can stop at the first match
+	candidates := &heapIndexes{q: q, indexes: []int{0}}
+	for candidates.Len() > 0 {
+		i := heap.Pop(candidates).(int)
+		if matches(itemList[i]) {
+			return heap.Remove(q, i).(*Item), false
+		}
+		for _, child := range []int{2*i + 1, 2*i + 2} {
+			if child < len(itemList) {
+				heap.Push(candidates, child)
+			}
+		}
+	}
+	return nil, true
+}
+
 // remove removes a given item from the queue
 func (q *subQueue) remove(item *Item) {
 	q.mutex.Lock()
@@ -250,3 +285,33 @@ func (q *subQueue) Pop() interface{} {
 	}
 	return item
 }
+
+// heapIndexes is a heap of indexes in to a subQueue's current item list,
+// ordered the same way as the subQueue itself, used by popMatching().
+type heapIndexes struct {
+	q       *subQueue
+	indexes []int
+}
+
+func (h *heapIndexes) Len() int {
+	return len(h.indexes)
+}
+
+func (h *heapIndexes) Less(i, j int) bool {
+	return h.q.Less(h.indexes[i], h.indexes[j])
+}
+
+func (h *heapIndexes) Swap(i, j int) {
+	h.indexes[i], h.indexes[j] = h.indexes[j], h.indexes[i]
+}
+
+func (h *heapIndexes) Push(x interface{}) {
+	h.indexes = append(h.indexes, x.(int))
+}
+
+func (h *heapIndexes) Pop() interface{} {
+	lasti := len(h.indexes) - 1
+	i := h.indexes[lasti]
+	h.indexes = h.indexes[:lasti]
+	return i
+}